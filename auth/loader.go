@@ -2,34 +2,73 @@ package auth
 
 import (
 	"bufio"
+	"errors"
 	"log"
 	"os"
 	"strings"
-
-	"github.com/gin-gonic/gin"
 )
 
-// LoadAccounts reads lines in "username:password" format
-func LoadAccounts(path string) (gin.Accounts, error) {
+var errNoAccounts = errors.New("no accounts found")
+
+// Permission is what an account may do in one repository.
+type Permission struct {
+	Read  bool `json:"read"`
+	Write bool `json:"write"`
+}
+
+// Account is one user of the accounts file. A nil Repos grants full access,
+// as plain "username:password" lines always have.
+type Account struct {
+	Password string
+	Repos    map[string]Permission
+}
+
+// Accounts maps usernames to their account.
+type Accounts map[string]Account
+
+// allows reports whether the account may read (or write) repo. An empty repo
+// stands for routes outside any repository, which need full access. The repo
+// "*" in a permission list matches every repository.
+func (a Account) allows(repo string, write bool) bool {
+	if a.Repos == nil {
+		return true
+	}
+	if repo == "" {
+		return false
+	}
+	perm, ok := a.Repos[repo]
+	if !ok {
+		perm = a.Repos["*"]
+	}
+	if write {
+		return perm.Write
+	}
+	return perm.Read
+}
+
+// LoadAccounts reads lines in "username:password" format, optionally followed
+// by ":repo=perm,..." where perm is r, w or rw, e.g.
+// "ci:secret:releases=rw,snapshots=r".
+func LoadAccounts(path string) (Accounts, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
 
-	accounts := make(gin.Accounts)
+	accounts := make(Accounts)
 	scanner := bufio.NewScanner(file)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
 		}
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
+		user, account, ok := parseAccount(line)
+		if !ok {
 			log.Printf("Skipping invalid line: %s\n", line)
 			continue
 		}
-		accounts[parts[0]] = parts[1]
+		accounts[user] = account
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -38,3 +77,43 @@ func LoadAccounts(path string) (gin.Accounts, error) {
 
 	return accounts, nil
 }
+
+// parseAccount parses one "username:password[:permissions]" entry.
+func parseAccount(line string) (string, Account, bool) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return "", Account{}, false
+	}
+	account := Account{Password: parts[1]}
+	// Passwords may contain colons, so only a trailing field that parses
+	// as a permission list is treated as one
+	if i := strings.LastIndex(parts[1], ":"); i >= 0 {
+		if repos, ok := parsePermissions(parts[1][i+1:]); ok {
+			account = Account{Password: parts[1][:i], Repos: repos}
+		}
+	}
+	return parts[0], account, true
+}
+
+// parsePermissions parses "repo1=rw,repo2=r". It reports false unless every
+// element is a valid repo=perm pair.
+func parsePermissions(field string) (map[string]Permission, bool) {
+	repos := make(map[string]Permission)
+	for _, item := range strings.Split(field, ",") {
+		repo, perm, found := strings.Cut(strings.TrimSpace(item), "=")
+		if !found || repo == "" {
+			return nil, false
+		}
+		switch perm {
+		case "r":
+			repos[repo] = Permission{Read: true}
+		case "w":
+			repos[repo] = Permission{Write: true}
+		case "rw":
+			repos[repo] = Permission{Read: true, Write: true}
+		default:
+			return nil, false
+		}
+	}
+	return repos, true
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAccounts_Permissions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "accounts")
+	content := `# comment
+admin:secret
+colon:pa:ss:word
+ci:deploy:releases=rw,snapshots=r
+reader:read:*=r
+broken
+`
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := LoadAccounts(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 4 {
+		t.Fatalf("expected 4 accounts, got %+v", accounts)
+	}
+
+	if a := accounts["admin"]; a.Password != "secret" || a.Repos != nil {
+		t.Errorf("plain line should grant full access: %+v", a)
+	}
+	if a := accounts["colon"]; a.Password != "pa:ss:word" || a.Repos != nil {
+		t.Errorf("colons in passwords must be kept: %+v", a)
+	}
+	ci := accounts["ci"]
+	if ci.Password != "deploy" {
+		t.Errorf("unexpected ci password %q", ci.Password)
+	}
+
+	for _, tc := range []struct {
+		account Account
+		repo    string
+		write   bool
+		want    bool
+	}{
+		{accounts["admin"], "", true, true},
+		{ci, "releases", true, true},
+		{ci, "snapshots", false, true},
+		{ci, "snapshots", true, false},
+		{ci, "private", false, false},
+		{ci, "", false, false},
+		{accounts["reader"], "anything", false, true},
+		{accounts["reader"], "anything", true, false},
+	} {
+		if got := tc.account.allows(tc.repo, tc.write); got != tc.want {
+			t.Errorf("%+v allows(%q, write=%v) = %v, want %v", tc.account.Repos, tc.repo, tc.write, got, tc.want)
+		}
+	}
+}
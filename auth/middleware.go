@@ -1,47 +1,218 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
+	"log"
 	"maven_repo/config"
+	"net"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// WriteAccessKey is the gin context key BasicAuth sets to whether the
+// authenticated user may write to the repository the request addresses. It is
+// unset for anonymous requests and trusted networks.
+const WriteAccessKey = "writeAccess"
+
+// ReadAccessKey is the gin context key BasicAuth sets to a func(repo string)
+// bool reporting whether the user may read repo. Routes that serve several
+// repositories at once, such as maven-public, use it to leave out the ones
+// the user has no permission for. It is unset when every repository may be
+// read.
+const ReadAccessKey = "readAccess"
+
+// parseTrustedNetworks turns CIDRs (or bare IPs) into networks. Invalid
+// entries are logged and skipped.
+func parseTrustedNetworks(entries []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Printf("Ignoring invalid trusted network %q: %v\n", entry, err)
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+func trusted(networks []*net.IPNet, clientIP string) bool {
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// BasicAuth authenticates requests against MAVEN_ACCOUNTS_FILE, or the single
+// configured user without one. Requests carrying a Bearer token are checked
+// against the configured API tokens instead. The accounts and tokens files are
+// loaded once and reloaded when they change; it panics when either cannot be
+// loaded at startup.
 func BasicAuth(cfg *config.Config) gin.HandlerFunc {
+	trustedNetworks := parseTrustedNetworks(cfg.TrustedCIDRs)
+	anonymousRepos := make(map[string]bool, len(cfg.AnonymousRepos))
+	for _, repo := range cfg.AnonymousRepos {
+		anonymousRepos[repo] = true
+	}
+	a := newAuthenticator(cfg)
 	return func(c *gin.Context) {
-		// Anonymous Access Check
-		if cfg.AnonymousAccess {
-			if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
-				// If no auth header provided, allow through
-				if c.GetHeader("Authorization") == "" {
-					c.Next()
-					return
-				}
+		// Internal networks skip authentication for every method. ClientIP only
+		// honours X-Forwarded-For from the engine's trusted proxies.
+		if trusted(trustedNetworks, c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		// Anonymous reads, of every repository or only of those in
+		// MAVEN_ANONYMOUS_REPOS, need no credentials. Valid ones still
+		// identify the user; missing, malformed or wrong ones are ignored
+		// instead of refused, since the read would be allowed without them.
+		if !isWrite(c.Request.Method) && (cfg.AnonymousAccess || anonymousRepos[requestRepo(c.Request.URL.Path)]) {
+			user, account, ok := a.identify(c)
+			if ok {
+				c.Set(gin.AuthUserKey, user)
+				c.Set(WriteAccessKey, account.allows(requestRepo(c.Request.URL.Path), true))
 			}
+			if !cfg.AnonymousAccess {
+				c.Set(ReadAccessKey, func(repo string) bool {
+					return anonymousRepos[repo] || (ok && account.allows(repo, false))
+				})
+			}
+			c.Next()
+			return
+		}
+
+		user, account, ok := a.authenticate(c)
+		if !ok {
+			return
 		}
+		authorize(c, user, account)
+		c.Set(WriteAccessKey, account.allows(requestRepo(c.Request.URL.Path), true))
+		if account.Repos != nil {
+			c.Set(ReadAccessKey, func(repo string) bool { return account.allows(repo, false) })
+		}
+	}
+}
 
-		var accounts gin.Accounts
+// authenticator checks Basic Auth credentials against the accounts and
+// Bearer tokens against the API tokens.
+type authenticator struct {
+	cfg    *config.Config
+	store  *accountStore
+	tokens *tokens
+}
+
+// newAuthenticator loads the accounts and tokens files; it panics when either
+// cannot be loaded.
+func newAuthenticator(cfg *config.Config) *authenticator {
+	a := &authenticator{cfg: cfg}
+	if cfg.AccountsFile != "" {
 		var err error
-		if cfg.AccountsFile != "" {
-			accounts, err = LoadAccounts(cfg.AccountsFile)
-			if err != nil {
-				// Log error but maybe fail safe?
-				// For now panic as it is configuration error
-				panic(fmt.Sprintf("Failed to load accounts file: %v", err))
-			}
+		a.store, err = accountsFor(cfg.AccountsFile)
+		if err != nil {
+			panic(fmt.Sprintf("Failed to load accounts file: %v", err))
+		}
+	}
+	apiTokens, err := newTokens(cfg)
+	if err != nil {
+		panic(err.Error())
+	}
+	a.tokens = apiTokens
+	return a
+}
+
+// accounts returns the current accounts, or the single configured user.
+func (a *authenticator) accounts() Accounts {
+	if a.store != nil {
+		return a.store.get()
+	}
+	return Accounts{
+		a.cfg.Username: {Password: a.cfg.Password},
+	}
+}
+
+// identify returns the identity and account of the request's credentials. It
+// reports false when they are missing, malformed or wrong.
+func (a *authenticator) identify(c *gin.Context) (string, Account, bool) {
+	if token, ok := bearerToken(c); ok {
+		return a.tokens.lookup(token)
+	}
+	user, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return "", Account{}, false
+	}
+	account, found := a.accounts()[user]
+	if !found || subtle.ConstantTimeCompare([]byte(account.Password), []byte(password)) != 1 {
+		return "", Account{}, false
+	}
+	return user, account, true
+}
+
+// authenticate returns the identity and account of the request's credentials
+// and sets gin.AuthUserKey. It aborts with 401 and reports false when they
+// are missing or wrong.
+func (a *authenticator) authenticate(c *gin.Context) (string, Account, bool) {
+	user, account, ok := a.identify(c)
+	if !ok {
+		if _, bearer := bearerToken(c); bearer {
+			c.Header("WWW-Authenticate", `Bearer realm="Authorization Required"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		} else {
-			accounts = gin.Accounts{
-				cfg.Username: cfg.Password,
-			}
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			c.AbortWithStatus(http.StatusUnauthorized)
 		}
+		return "", Account{}, false
+	}
+	c.Set(gin.AuthUserKey, user)
+	return user, account, true
+}
+
+// authorize aborts with 403 unless the authenticated account may access the
+// repository the request addresses.
+func authorize(c *gin.Context, user string, account Account) {
+	repo := requestRepo(c.Request.URL.Path)
+	if !account.allows(repo, isWrite(c.Request.Method)) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("%s may not %s this resource", user, accessVerb(c.Request.Method))})
+	}
+}
+
+// requestRepo returns the repository a request addresses, or "" for routes
+// outside /repository/. The path is cleaned first so "releases/../private"
+// is checked against "private", the directory storage will actually use.
+func requestRepo(p string) string {
+	p = path.Clean("/" + p)
+	rest, ok := strings.CutPrefix(p, "/repository/")
+	if !ok {
+		return ""
+	}
+	repo, _, _ := strings.Cut(rest, "/")
+	return repo
+}
 
-		// Use manual basic auth verification to avoid gin.BasicAuth which validates header
-		// actually gin.BasicAuth is fine, we just didn't call it if anonymous.
-		// If header IS provided, we want to verify it?
-		// Yes, if user provides creds, we verify them.
+func isWrite(method string) bool {
+	return method != http.MethodGet && method != http.MethodHead
+}
 
-		authHandler := gin.BasicAuth(accounts)
-		authHandler(c)
+func accessVerb(method string) string {
+	if isWrite(method) {
+		return "write to"
 	}
+	return "read"
 }
@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newAuthRouter(t *testing.T, cfg *config.Config, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		t.Fatal(err)
+	}
+	r.PUT("/repository/*path", BasicAuth(cfg), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	return r
+}
+
+func put(r http.Handler, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodPut, "/repository/releases/app.jar", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestBasicAuth_TrustedCIDRs(t *testing.T) {
+	cfg := &config.Config{
+		Username:     "admin",
+		Password:     "password",
+		TrustedCIDRs: []string{"10.0.0.0/8", "192.168.1.10", "not-a-cidr"},
+	}
+	r := newAuthRouter(t, cfg, nil)
+
+	if code := put(r, "10.1.2.3:5000", ""); code != http.StatusCreated {
+		t.Errorf("in-CIDR deploy: expected auth bypass, got %d", code)
+	}
+	if code := put(r, "192.168.1.10:5000", ""); code != http.StatusCreated {
+		t.Errorf("trusted single IP: expected auth bypass, got %d", code)
+	}
+	if code := put(r, "203.0.113.7:5000", ""); code != http.StatusUnauthorized {
+		t.Errorf("out-of-CIDR deploy: expected 401, got %d", code)
+	}
+	// Without trusted proxies a forged header changes nothing
+	if code := put(r, "203.0.113.7:5000", "10.1.2.3"); code != http.StatusUnauthorized {
+		t.Errorf("spoofed X-Forwarded-For: expected 401, got %d", code)
+	}
+}
+
+func TestBasicAuth_TrustedCIDRsBehindProxy(t *testing.T) {
+	cfg := &config.Config{
+		Username:     "admin",
+		Password:     "password",
+		TrustedCIDRs: []string{"10.0.0.0/8"},
+	}
+	r := newAuthRouter(t, cfg, []string{"172.16.0.1"})
+
+	if code := put(r, "172.16.0.1:5000", "10.1.2.3"); code != http.StatusCreated {
+		t.Errorf("internal client behind trusted proxy: expected auth bypass, got %d", code)
+	}
+	if code := put(r, "172.16.0.1:5000", "203.0.113.7"); code != http.StatusUnauthorized {
+		t.Errorf("external client behind trusted proxy: expected 401, got %d", code)
+	}
+}
+
+func TestBasicAuth_RepositoryPermissions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "accounts")
+	if err := os.WriteFile(file, []byte("admin:secret\nci:deploy:releases=rw,snapshots=r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.Any("/repository/*path", BasicAuth(&config.Config{AccountsFile: file}), ok)
+	r.GET("/admin/status", BasicAuth(&config.Config{AccountsFile: file}), ok)
+
+	request := func(method, target, user, pass string) int {
+		req := httptest.NewRequest(method, target, nil)
+		req.SetBasicAuth(user, pass)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for _, tc := range []struct {
+		method, target, user string
+		want                 int
+	}{
+		{http.MethodPut, "/repository/releases/app.jar", "ci", http.StatusOK},
+		{http.MethodGet, "/repository/snapshots/app.jar", "ci", http.StatusOK},
+		{http.MethodHead, "/repository/snapshots/app.jar", "ci", http.StatusOK},
+		{http.MethodPut, "/repository/snapshots/app.jar", "ci", http.StatusForbidden},
+		{http.MethodDelete, "/repository/snapshots/app.jar", "ci", http.StatusForbidden},
+		{http.MethodGet, "/repository/private/app.jar", "ci", http.StatusForbidden},
+		{http.MethodPut, "/repository/releases/../private/app.jar", "ci", http.StatusForbidden},
+		{http.MethodGet, "/admin/status", "ci", http.StatusForbidden},
+		{http.MethodPut, "/repository/private/app.jar", "admin", http.StatusOK},
+		{http.MethodGet, "/admin/status", "admin", http.StatusOK},
+	} {
+		pass := map[string]string{"ci": "deploy", "admin": "secret"}[tc.user]
+		if got := request(tc.method, tc.target, tc.user, pass); got != tc.want {
+			t.Errorf("%s %s as %s: expected %d, got %d", tc.method, tc.target, tc.user, tc.want, got)
+		}
+	}
+	if got := request(http.MethodGet, "/repository/releases/app.jar", "ci", "wrong"); got != http.StatusUnauthorized {
+		t.Errorf("bad password: expected 401, got %d", got)
+	}
+}
+
+func TestBasicAuth_AnonymousIgnoresBadCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handler := func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(gin.AuthUserKey))
+	}
+	auth := BasicAuth(&config.Config{Username: "admin", Password: "password", AnonymousAccess: true})
+	r.GET("/repository/*path", auth, handler)
+	r.PUT("/repository/*path", auth, handler)
+
+	for _, tc := range []struct {
+		name          string
+		method        string
+		authorization string
+		wantCode      int
+		wantUser      string
+	}{
+		{"read without credentials", http.MethodGet, "", http.StatusOK, ""},
+		{"read with valid credentials", http.MethodGet, "Basic YWRtaW46cGFzc3dvcmQ=", http.StatusOK, "admin"},
+		{"read with wrong password", http.MethodGet, "Basic YWRtaW46d3Jvbmc=", http.StatusOK, ""},
+		{"read with malformed header", http.MethodGet, "Basic !!!", http.StatusOK, ""},
+		{"read with unknown token", http.MethodGet, "Bearer nope", http.StatusOK, ""},
+		{"write without credentials", http.MethodPut, "", http.StatusUnauthorized, ""},
+		{"write with wrong password", http.MethodPut, "Basic YWRtaW46d3Jvbmc=", http.StatusUnauthorized, ""},
+		{"write with valid credentials", http.MethodPut, "Basic YWRtaW46cGFzc3dvcmQ=", http.StatusOK, "admin"},
+	} {
+		req := httptest.NewRequest(tc.method, "/repository/releases/app.jar", nil)
+		if tc.authorization != "" {
+			req.Header.Set("Authorization", tc.authorization)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.wantCode {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.wantCode, w.Code)
+			continue
+		}
+		if w.Code == http.StatusOK && w.Body.String() != tc.wantUser {
+			t.Errorf("%s: expected user %q, got %q", tc.name, tc.wantUser, w.Body.String())
+		}
+	}
+}
+
+func TestBasicAuth_AnonymousRepos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/repository/*path", BasicAuth(&config.Config{
+		Username:       "admin",
+		Password:       "password",
+		AnonymousRepos: []string{"public"},
+	}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, target string, withAuth bool) int {
+		req := httptest.NewRequest(method, target, nil)
+		if withAuth {
+			req.SetBasicAuth("admin", "password")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := request(http.MethodGet, "/repository/public/app.jar", false); code != http.StatusOK {
+		t.Errorf("anonymous read of public: expected 200, got %d", code)
+	}
+	if code := request(http.MethodHead, "/repository/public/app.jar", false); code != http.StatusOK {
+		t.Errorf("anonymous HEAD of public: expected 200, got %d", code)
+	}
+	if code := request(http.MethodGet, "/repository/internal/app.jar", false); code != http.StatusUnauthorized {
+		t.Errorf("anonymous read of internal: expected 401, got %d", code)
+	}
+	if code := request(http.MethodGet, "/repository/public/../internal/app.jar", false); code != http.StatusUnauthorized {
+		t.Errorf("anonymous read escaping public: expected 401, got %d", code)
+	}
+	if code := request(http.MethodPut, "/repository/public/app.jar", false); code != http.StatusUnauthorized {
+		t.Errorf("anonymous write to public: expected 401, got %d", code)
+	}
+	if code := request(http.MethodGet, "/repository/internal/app.jar", true); code != http.StatusOK {
+		t.Errorf("authenticated read of internal: expected 200, got %d", code)
+	}
+}
+
+func TestBasicAuth_ReadAccessForGroups(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "accounts")
+	if err := os.WriteFile(file, []byte("admin:secret\nci:deploy:maven-public=r,releases=r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/repository/*path", BasicAuth(&config.Config{AccountsFile: file}), func(c *gin.Context) {
+		canRead, ok := c.Value(ReadAccessKey).(func(string) bool)
+		if !ok {
+			c.String(http.StatusOK, "all")
+			return
+		}
+		c.String(http.StatusOK, "releases=%v private=%v", canRead("releases"), canRead("private"))
+	})
+
+	for user, want := range map[string]string{
+		"ci":    "releases=true private=false",
+		"admin": "all",
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/repository/maven-public/app.jar", nil)
+		req.SetBasicAuth(user, map[string]string{"ci": "deploy", "admin": "secret"}[user])
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != want {
+			t.Errorf("%s: got %q, want %q", user, w.Body.String(), want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// accountsPollInterval is how often, at most, the accounts file is checked
+// for changes.
+var accountsPollInterval = 2 * time.Second
+
+// accountStore caches the parsed accounts file and reloads it when its
+// modification time or size changes. A reload that fails keeps the previous
+// accounts.
+type accountStore struct {
+	path string
+
+	mu        sync.Mutex
+	accounts  Accounts
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+var (
+	storesMu sync.Mutex
+	stores   = map[string]*accountStore{}
+)
+
+// accountsFor returns the shared store for path, loading it on first use so
+// every route group sees the same accounts.
+func accountsFor(path string) (*accountStore, error) {
+	storesMu.Lock()
+	defer storesMu.Unlock()
+	if s, ok := stores[path]; ok {
+		return s, nil
+	}
+	s := &accountStore{path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := LoadAccounts(path)
+	if err != nil {
+		return nil, err
+	}
+	s.accounts, s.modTime, s.size, s.lastCheck = accounts, info.ModTime(), info.Size(), time.Now()
+	log.Printf("Loaded %d accounts from %s\n", len(accounts), path)
+	stores[path] = s
+	return s, nil
+}
+
+// get returns the current accounts, reloading the file first when it changed.
+func (s *accountStore) get() Accounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if now.Sub(s.lastCheck) < accountsPollInterval {
+		return s.accounts
+	}
+	s.lastCheck = now
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		log.Printf("Failed to check accounts file %s, keeping %d accounts: %v\n", s.path, len(s.accounts), err)
+		return s.accounts
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.accounts
+	}
+
+	accounts, err := LoadAccounts(s.path)
+	if err == nil && len(accounts) == 0 {
+		// Most likely caught mid-write; an empty set would lock everyone out
+		err = errNoAccounts
+	}
+	if err != nil {
+		log.Printf("Failed to reload accounts file %s, keeping %d accounts: %v\n", s.path, len(s.accounts), err)
+		return s.accounts
+	}
+	s.accounts, s.modTime, s.size = accounts, info.ModTime(), info.Size()
+	log.Printf("Reloaded accounts file %s: %d accounts\n", s.path, len(accounts))
+	return s.accounts
+}
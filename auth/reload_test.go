@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBasicAuth_ReloadsAccountsFile(t *testing.T) {
+	defer func(old time.Duration) { accountsPollInterval = old }(accountsPollInterval)
+	accountsPollInterval = 0
+
+	file := filepath.Join(t.TempDir(), "accounts")
+	// Each write gets a later mod time so the change is seen even on coarse
+	// filesystem clocks
+	modTime := time.Now().Add(-time.Hour)
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		modTime = modTime.Add(time.Minute)
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("alice:one\n")
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.PUT("/repository/*path", BasicAuth(&config.Config{AccountsFile: file}), func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+	put := func(user, pass string) int {
+		req := httptest.NewRequest(http.MethodPut, "/repository/releases/app.jar", nil)
+		req.SetBasicAuth(user, pass)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := put("alice", "one"); code != http.StatusCreated {
+		t.Fatalf("initial account: expected 201, got %d", code)
+	}
+
+	write("alice:two\nbob:three\n")
+	if code := put("alice", "two"); code != http.StatusCreated {
+		t.Errorf("changed password: expected 201, got %d", code)
+	}
+	if code := put("bob", "three"); code != http.StatusCreated {
+		t.Errorf("added account: expected 201, got %d", code)
+	}
+	if code := put("alice", "one"); code != http.StatusUnauthorized {
+		t.Errorf("old password: expected 401, got %d", code)
+	}
+
+	// A file that no longer parses, or holds no accounts, keeps the last good set
+	write("garbage\n")
+	if code := put("bob", "three"); code != http.StatusCreated {
+		t.Errorf("after malformed reload: expected previous accounts to work, got %d", code)
+	}
+	write("")
+	if code := put("bob", "three"); code != http.StatusCreated {
+		t.Errorf("after empty reload: expected previous accounts to work, got %d", code)
+	}
+}
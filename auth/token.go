@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"strings"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tokens holds the API tokens accepted as "Authorization: Bearer <token>".
+// Entries use the accounts file format with the token in place of the
+// password, so "ci:3f9a...:releases=rw" is a token for identity "ci" that may
+// only deploy to releases.
+type tokens struct {
+	static Accounts
+	store  *accountStore
+}
+
+// newTokens collects MAVEN_TOKENS and MAVEN_TOKENS_FILE. It returns nil when
+// neither is configured.
+func newTokens(cfg *config.Config) (*tokens, error) {
+	if len(cfg.Tokens) == 0 && cfg.TokensFile == "" {
+		return nil, nil
+	}
+	t := &tokens{static: make(Accounts)}
+	for _, entry := range cfg.Tokens {
+		identity, account, ok := parseAccount(entry)
+		if !ok || account.Password == "" {
+			log.Printf("Ignoring invalid MAVEN_TOKENS entry for %q\n", identity)
+			continue
+		}
+		t.static[identity] = account
+	}
+	if cfg.TokensFile != "" {
+		store, err := accountsFor(cfg.TokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tokens file: %w", err)
+		}
+		t.store = store
+	}
+	return t, nil
+}
+
+// lookup returns the identity and account the token belongs to.
+func (t *tokens) lookup(token string) (string, Account, bool) {
+	if t == nil || token == "" {
+		return "", Account{}, false
+	}
+	sets := []Accounts{t.static}
+	if t.store != nil {
+		sets = append(sets, t.store.get())
+	}
+	for _, set := range sets {
+		for identity, account := range set {
+			if account.Password != "" && subtle.ConstantTimeCompare([]byte(account.Password), []byte(token)) == 1 {
+				return identity, account, true
+			}
+		}
+	}
+	return "", Account{}, false
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
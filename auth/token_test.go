@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBasicAuth_BearerTokens(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tokens")
+	if err := os.WriteFile(file, []byte("deployer:file-token:releases=rw\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		Username:   "admin",
+		Password:   "password",
+		Tokens:     []string{"ci:env-token", "reader:read-token:*=r", "broken"},
+		TokensFile: file,
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/repository/*path", BasicAuth(cfg), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(gin.AuthUserKey))
+	})
+	request := func(method, target, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, tc := range []struct {
+		method, target, authorization string
+		want                          int
+		user                          string
+	}{
+		{http.MethodPut, "/repository/snapshots/app.jar", "Bearer env-token", http.StatusOK, "ci"},
+		{http.MethodGet, "/repository/snapshots/app.jar", "bearer read-token", http.StatusOK, "reader"},
+		{http.MethodPut, "/repository/snapshots/app.jar", "Bearer read-token", http.StatusForbidden, ""},
+		{http.MethodPut, "/repository/releases/app.jar", "Bearer file-token", http.StatusOK, "deployer"},
+		{http.MethodGet, "/repository/snapshots/app.jar", "Bearer file-token", http.StatusForbidden, ""},
+		{http.MethodGet, "/repository/snapshots/app.jar", "Bearer wrong", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/repository/snapshots/app.jar", "Bearer ", http.StatusUnauthorized, ""},
+		// Basic auth keeps working next to tokens
+		{http.MethodPut, "/repository/snapshots/app.jar", "Basic YWRtaW46cGFzc3dvcmQ=", http.StatusOK, "admin"},
+	} {
+		w := request(tc.method, tc.target, tc.authorization)
+		if w.Code != tc.want {
+			t.Errorf("%s %s with %q: expected %d, got %d", tc.method, tc.target, tc.authorization, tc.want, w.Code)
+		} else if tc.user != "" && w.Body.String() != tc.user {
+			t.Errorf("%s %s with %q: authenticated as %q, want %q", tc.method, tc.target, tc.authorization, w.Body.String(), tc.user)
+		}
+	}
+}
+
+func TestBasicAuth_BearerWithoutTokens(t *testing.T) {
+	r := newAuthRouter(t, &config.Config{Username: "admin", Password: "password"}, nil)
+	req := httptest.NewRequest(http.MethodPut, "/repository/releases/app.jar", nil)
+	req.Header.Set("Authorization", "Bearer password")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 when no tokens are configured, got %d", w.Code)
+	}
+}
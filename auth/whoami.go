@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"net/http"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Identity is the document served by /auth/whoami.
+type Identity struct {
+	Username string `json:"username"`
+	// FullAccess is set for accounts without a permission list, which may
+	// read and write every repository and use the admin API.
+	FullAccess  bool                  `json:"fullAccess"`
+	Permissions map[string]Permission `json:"permissions,omitempty"`
+}
+
+// Whoami reports who the request's credentials authenticate as and what that
+// identity may access, so users can check their credentials before debugging
+// a build. Unlike BasicAuth it never lets requests through anonymously or
+// from trusted networks: without valid credentials it answers 401.
+func Whoami(cfg *config.Config) gin.HandlerFunc {
+	a := newAuthenticator(cfg)
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Header("WWW-Authenticate", `Basic realm="Authorization Required"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "no credentials provided"})
+			return
+		}
+		user, account, ok := a.authenticate(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, Identity{
+			Username:    user,
+			FullAccess:  account.Repos == nil,
+			Permissions: account.Repos,
+		})
+	}
+}
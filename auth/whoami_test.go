@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestWhoami(t *testing.T) {
+	accountsFile := filepath.Join(t.TempDir(), "accounts")
+	if err := os.WriteFile(accountsFile, []byte("admin:secret\nci:deploy:releases=rw,snapshots=r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/auth/whoami", Whoami(&config.Config{
+		AccountsFile:    accountsFile,
+		AnonymousAccess: true,
+		TrustedCIDRs:    []string{"192.0.2.0/24"},
+		Tokens:          []string{"bot:tok3n:releases=r"},
+	}))
+
+	whoami := func(setAuth func(*http.Request)) (int, Identity) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/whoami", nil)
+		if setAuth != nil {
+			setAuth(req)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var identity Identity
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &identity); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, identity
+	}
+
+	code, identity := whoami(func(req *http.Request) { req.SetBasicAuth("admin", "secret") })
+	if code != http.StatusOK || identity.Username != "admin" || !identity.FullAccess || identity.Permissions != nil {
+		t.Errorf("admin: got %d %+v", code, identity)
+	}
+
+	code, identity = whoami(func(req *http.Request) { req.SetBasicAuth("ci", "deploy") })
+	if code != http.StatusOK || identity.Username != "ci" || identity.FullAccess {
+		t.Fatalf("ci: got %d %+v", code, identity)
+	}
+	if p := identity.Permissions["releases"]; !p.Read || !p.Write {
+		t.Errorf("ci should read and write releases: %+v", identity.Permissions)
+	}
+	if p := identity.Permissions["snapshots"]; !p.Read || p.Write {
+		t.Errorf("ci should only read snapshots: %+v", identity.Permissions)
+	}
+
+	code, identity = whoami(func(req *http.Request) { req.Header.Set("Authorization", "Bearer tok3n") })
+	if code != http.StatusOK || identity.Username != "bot" || !identity.Permissions["releases"].Read {
+		t.Errorf("token: got %d %+v", code, identity)
+	}
+
+	if code, _ := whoami(func(req *http.Request) { req.SetBasicAuth("ci", "wrong") }); code != http.StatusUnauthorized {
+		t.Errorf("wrong password: expected 401, got %d", code)
+	}
+	if code, _ := whoami(func(req *http.Request) { req.Header.Set("Authorization", "Bearer nope") }); code != http.StatusUnauthorized {
+		t.Errorf("unknown token: expected 401, got %d", code)
+	}
+	// Neither anonymous access nor a trusted network stand in for credentials
+	if code, _ := whoami(func(req *http.Request) { req.RemoteAddr = "192.0.2.1:1234" }); code != http.StatusUnauthorized {
+		t.Errorf("no credentials: expected 401, got %d", code)
+	}
+}
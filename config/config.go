@@ -7,51 +7,243 @@ import (
 )
 
 type Config struct {
-	Username                string
-	Password                string
-	StoragePath             string
-	Port                    string
-	AccountsFile            string
-	ProxyURLs               []string
-	AnonymousAccess         bool
-	SnapshotCleanupEnabled  bool
-	SnapshotCleanupInterval string // Using string for duration parsing later or just "1h"
-	SnapshotKeepDays        int
-	SnapshotKeepLatestOnly  bool
-	LogPath                 string
-	LogKeepDays             int
-	LogMaxSize              int
-	LogMaxBackups           int
+	Username                   string            `yaml:"username"`
+	Password                   string            `yaml:"password"`
+	StoragePath                string            `yaml:"storage_path"`
+	Port                       string            `yaml:"port"`
+	AccountsFile               string            `yaml:"accounts_file"`
+	ProxyURLs                  []string          `yaml:"proxy_urls"`
+	ProxyMode                  string            `yaml:"proxy_mode"`
+	AnonymousAccess            bool              `yaml:"anonymous_access"`
+	AnonymousRepos             []string          `yaml:"anonymous_repos"`
+	SnapshotCleanupEnabled     bool              `yaml:"snapshot_cleanup_enabled"`
+	SnapshotCleanupInterval    string            `yaml:"snapshot_cleanup_interval"` // Using string for duration parsing later or just "1h"
+	SnapshotKeepDays           int               `yaml:"snapshot_keep_days"`
+	SnapshotKeepLatestOnly     bool              `yaml:"snapshot_keep_latest_only"`
+	SnapshotKeepCount          int               `yaml:"snapshot_keep_count"`
+	SnapshotPolicies           map[string]string `yaml:"snapshot_policy"`
+	LogPath                    string            `yaml:"log_path"`
+	LogKeepDays                int               `yaml:"log_keep_days"`
+	LogMaxSize                 int               `yaml:"log_max_size_mb"`
+	LogMaxBackups              int               `yaml:"log_max_backups"`
+	LogCompress                bool              `yaml:"log_compress"`
+	LogLevel                   string            `yaml:"log_level"`
+	DeleteProtectionMinutes    int               `yaml:"delete_protection_minutes"`
+	WalkFollowSymlinks         bool              `yaml:"walk_follow_symlinks"`
+	BloomFilterEnabled         bool              `yaml:"bloom_filter_enabled"`
+	BloomFilterExpected        int               `yaml:"bloom_filter_expected_items"`
+	ProxyAllowedContentTypes   []string          `yaml:"proxy_allowed_content_types"`
+	ProxyBlockedContentTypes   []string          `yaml:"proxy_blocked_content_types"`
+	MetadataLockTTL            string            `yaml:"metadata_lock_ttl"`
+	ListingMaxEntries          int               `yaml:"listing_max_entries"`
+	GenerateChecksums          bool              `yaml:"generate_checksums"`
+	GenerateChecksumsSkipRepos []string          `yaml:"generate_checksums_skip_repos"`
+	RootRedirect               string            `yaml:"root_redirect"`
+	RootIndex                  bool              `yaml:"root_index"`
+	ProxyMaxSize               int64             `yaml:"proxy_max_size"`
+	ListingCacheTTL            string            `yaml:"listing_cache_ttl"`
+	StatsCacheTTL              string            `yaml:"stats_cache_ttl"`
+	StorageBreakerThreshold    int               `yaml:"storage_breaker_threshold"`
+	StorageBreakerCooldown     string            `yaml:"storage_breaker_cooldown"`
+	StorageListCacheTTL        string            `yaml:"storage_list_cache_ttl"`
+	UploadMemoryThreshold      int64             `yaml:"upload_memory_threshold"`
+	MaxUploadSize              int64             `yaml:"max_upload_size"`
+	MinFreeSpace               int64             `yaml:"min_free_space"`
+	ProxyMaxConcurrency        int               `yaml:"proxy_max_concurrency"`
+	ProxyDirectoryListings     bool              `yaml:"proxy_directory_listings"`
+	ProxyListingCacheTTL       string            `yaml:"proxy_listing_cache_ttl"`
+	VerifyDownloadChecksums    bool              `yaml:"verify_download_checksums"`
+	VerifyUploadChecksums      bool              `yaml:"verify_upload_checksums"`
+	LogFormat                  string            `yaml:"log_format"`
+	UploadGracePeriod          string            `yaml:"upload_grace_period"`
+	TrustedCIDRs               []string          `yaml:"trusted_cidrs"`
+	TrustedProxies             []string          `yaml:"trusted_proxies"`
+	UploadConflictPolicy       string            `yaml:"upload_conflict_policy"`
+	ProxyCacheMaxIdle          string            `yaml:"proxy_cache_max_idle"`
+	ProxyCachePrefix           string            `yaml:"proxy_cache_prefix"`
+	ProxyCacheCleanupInterval  string            `yaml:"proxy_cache_cleanup_interval"`
+	ProxyCacheAsync            bool              `yaml:"proxy_cache_async"`
+	ProxyVerifyChecksums       bool              `yaml:"proxy_verify_checksums"`
+	ProxyRevalidateTTL         string            `yaml:"proxy_revalidate_ttl"`
+	Compression                bool              `yaml:"compression"`
+	UniqueSnapshotRepos        []string          `yaml:"unique_snapshot_repos"`
+	StorageBackend             string            `yaml:"storage_backend"`
+	StorageRoots               map[string]string `yaml:"storage_root"`
+	S3Bucket                   string            `yaml:"s3_bucket"`
+	S3Region                   string            `yaml:"s3_region"`
+	S3Endpoint                 string            `yaml:"s3_endpoint"`
+	S3AccessKey                string            `yaml:"s3_access_key"`
+	S3SecretKey                string            `yaml:"s3_secret_key"`
+	GCSBucket                  string            `yaml:"gcs_bucket"`
+	GCSCredentialsFile         string            `yaml:"gcs_credentials_file"`
+	GCSEndpoint                string            `yaml:"gcs_endpoint"`
+	ChecksumTrailingNewline    bool              `yaml:"checksum_trailing_newline"`
+	ReleaseRepos               []string          `yaml:"release_repos"`
+	GenerateMetadata           bool              `yaml:"generate_metadata"`
+	NegativeCacheTTL           string            `yaml:"negative_cache_ttl"`
+	ProxyHeadCacheTTL          string            `yaml:"proxy_head_cache_ttl"`
+	ProxyInclude               []string          `yaml:"proxy_include"`
+	ProxyExclude               []string          `yaml:"proxy_exclude"`
+	ProxyTimeout               string            `yaml:"proxy_timeout"`
+	ProxyMaxIdleConnsPerHost   int               `yaml:"proxy_max_idle_conns_per_host"`
+	ProxyRetryMaxAttempts      int               `yaml:"proxy_retry_max_attempts"`
+	ProxyRetryBaseDelay        string            `yaml:"proxy_retry_base_delay"`
+	TLSCertFile                string            `yaml:"tls_cert_file"`
+	TLSKeyFile                 string            `yaml:"tls_key_file"`
+	TLSMinVersion              string            `yaml:"tls_min_version"`
+	TLSRedirectPort            string            `yaml:"tls_redirect_port"`
+	Tokens                     []string          `yaml:"tokens"`
+	TokensFile                 string            `yaml:"tokens_file"`
+	RateLimitReadRPS           int               `yaml:"rate_limit_read_rps"`
+	RateLimitReadBurst         int               `yaml:"rate_limit_read_burst"`
+	RateLimitWriteRPS          int               `yaml:"rate_limit_write_rps"`
+	RateLimitWriteBurst        int               `yaml:"rate_limit_write_burst"`
+	RateLimitBy                string            `yaml:"rate_limit_by"`
+	DownloadMaxConcurrent      int               `yaml:"download_max_concurrent"`
+	DownloadQueueTimeout       string            `yaml:"download_queue_timeout"`
+	DownloadBandwidth          int64             `yaml:"download_bandwidth"`
+	DownloadConnBandwidth      int64             `yaml:"download_conn_bandwidth"`
 }
 
-func New() *Config {
-	proxyEnv := getEnv("MAVEN_PROXY_URLS", "")
+// New reads the configuration from MAVEN_* environment variables. When
+// MAVEN_CONFIG_FILE names a YAML file, its settings replace the defaults and
+// the environment variables still take precedence over them; see loadFile.
+func New() (*Config, error) {
+	s := settings{}
+	if path := os.Getenv("MAVEN_CONFIG_FILE"); path != "" {
+		file, err := loadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		s.file = file
+	}
+	return s.config(), nil
+}
+
+func (s settings) config() *Config {
+	proxyEnv := s.get("MAVEN_PROXY_URLS", "")
 	var proxies []string
 	if proxyEnv != "" {
 		proxies = split(proxyEnv)
 	}
 
 	return &Config{
-		Username:                getEnv("MAVEN_USERNAME", "admin"),
-		Password:                getEnv("MAVEN_PASSWORD", "password"),
-		StoragePath:             getEnv("MAVEN_STORAGE_PATH", "./artifacts"),
-		Port:                    getEnv("MAVEN_PORT", "8080"),
-		AccountsFile:            getEnv("MAVEN_ACCOUNTS_FILE", ""),
-		ProxyURLs:               proxies,
-		AnonymousAccess:         getEnv("MAVEN_ANONYMOUS_ACCESS", "false") == "true",
-		SnapshotCleanupEnabled:  getEnv("MAVEN_SNAPSHOT_CLEANUP_ENABLED", "false") == "true",
-		SnapshotCleanupInterval: getEnv("MAVEN_SNAPSHOT_CLEANUP_INTERVAL", "1h"),
-		SnapshotKeepDays:        getEnvInt("MAVEN_SNAPSHOT_KEEP_DAYS", 30),
-		SnapshotKeepLatestOnly:  getEnv("MAVEN_SNAPSHOT_KEEP_LATEST_ONLY", "false") == "true",
-		LogPath:                 getEnv("MAVEN_LOG_PATH", "./server.log"),
-		LogKeepDays:             getEnvInt("MAVEN_LOG_KEEP_DAYS", 7),
-		LogMaxSize:              getEnvInt("MAVEN_LOG_MAX_SIZE", 100), // MB
-		LogMaxBackups:           getEnvInt("MAVEN_LOG_MAX_BACKUPS", 3),
+		Username:                   s.get("MAVEN_USERNAME", "admin"),
+		Password:                   s.get("MAVEN_PASSWORD", "password"),
+		StoragePath:                s.get("MAVEN_STORAGE_PATH", "./artifacts"),
+		Port:                       s.get("MAVEN_PORT", "8080"),
+		AccountsFile:               s.get("MAVEN_ACCOUNTS_FILE", ""),
+		ProxyURLs:                  proxies,
+		ProxyMode:                  s.get("MAVEN_PROXY_MODE", "sequential"),
+		AnonymousAccess:            s.get("MAVEN_ANONYMOUS_ACCESS", "false") == "true",
+		AnonymousRepos:             split(s.get("MAVEN_ANONYMOUS_REPOS", "")),
+		SnapshotCleanupEnabled:     s.get("MAVEN_SNAPSHOT_CLEANUP_ENABLED", "false") == "true",
+		SnapshotCleanupInterval:    s.get("MAVEN_SNAPSHOT_CLEANUP_INTERVAL", "1h"),
+		SnapshotKeepDays:           s.getInt("MAVEN_SNAPSHOT_KEEP_DAYS", 30),
+		SnapshotKeepLatestOnly:     s.get("MAVEN_SNAPSHOT_KEEP_LATEST_ONLY", "false") == "true",
+		SnapshotKeepCount:          s.getInt("MAVEN_SNAPSHOT_KEEP_COUNT", 0),
+		SnapshotPolicies:           s.getPrefixed("MAVEN_SNAPSHOT_POLICY_"),
+		LogPath:                    s.get("MAVEN_LOG_PATH", "./server.log"),
+		LogKeepDays:                s.getInt("MAVEN_LOG_KEEP_DAYS", 7),
+		LogMaxSize:                 s.getInt("MAVEN_LOG_MAX_SIZE_MB", s.getInt("MAVEN_LOG_MAX_SIZE", 100)), // MB
+		LogMaxBackups:              s.getInt("MAVEN_LOG_MAX_BACKUPS", 3),
+		LogCompress:                s.get("MAVEN_LOG_COMPRESS", "true") == "true",
+		LogLevel:                   s.get("MAVEN_LOG_LEVEL", "info"),
+		DeleteProtectionMinutes:    s.getInt("MAVEN_DELETE_PROTECTION_MINUTES", 0),
+		WalkFollowSymlinks:         s.get("MAVEN_WALK_FOLLOW_SYMLINKS", "false") == "true",
+		BloomFilterEnabled:         s.get("MAVEN_BLOOM_FILTER_ENABLED", "false") == "true",
+		BloomFilterExpected:        s.getInt("MAVEN_BLOOM_FILTER_EXPECTED_ITEMS", 1000000),
+		ProxyAllowedContentTypes:   split(s.get("MAVEN_PROXY_ALLOWED_CONTENT_TYPES", "")),
+		ProxyBlockedContentTypes:   split(s.get("MAVEN_PROXY_BLOCKED_CONTENT_TYPES", "text/html")),
+		MetadataLockTTL:            s.get("MAVEN_METADATA_LOCK_TTL", "30s"),
+		ListingMaxEntries:          s.getInt("MAVEN_LISTING_MAX_ENTRIES", 0),
+		GenerateChecksums:          s.get("MAVEN_GENERATE_CHECKSUMS", "true") == "true",
+		GenerateChecksumsSkipRepos: split(s.get("MAVEN_GENERATE_CHECKSUMS_SKIP_REPOS", "")),
+		RootRedirect:               s.get("MAVEN_ROOT_REDIRECT", ""),
+		RootIndex:                  s.get("MAVEN_ROOT_INDEX", "true") == "true",
+		ProxyMaxSize:               s.getInt64("MAVEN_PROXY_MAX_SIZE", 0),
+		ListingCacheTTL:            s.get("MAVEN_LISTING_CACHE_TTL", ""),
+		StatsCacheTTL:              s.get("MAVEN_STATS_CACHE_TTL", "5m"),
+		StorageBreakerThreshold:    s.getInt("MAVEN_STORAGE_BREAKER_THRESHOLD", 5),
+		StorageBreakerCooldown:     s.get("MAVEN_STORAGE_BREAKER_COOLDOWN", "30s"),
+		StorageListCacheTTL:        s.get("MAVEN_STORAGE_LIST_CACHE_TTL", ""),
+		UploadMemoryThreshold:      s.getInt64("MAVEN_UPLOAD_MEMORY_THRESHOLD", 64*1024),
+		MaxUploadSize:              s.getInt64("MAVEN_MAX_UPLOAD_SIZE", 0),
+		MinFreeSpace:               s.getInt64("MAVEN_MIN_FREE_SPACE", 0),
+		ProxyMaxConcurrency:        s.getInt("MAVEN_PROXY_MAX_CONCURRENCY", 0),
+		ProxyDirectoryListings:     s.get("MAVEN_PROXY_DIRECTORY_LISTINGS", "false") == "true",
+		ProxyListingCacheTTL:       s.get("MAVEN_PROXY_LISTING_CACHE_TTL", "1m"),
+		VerifyDownloadChecksums:    s.get("MAVEN_VERIFY_DOWNLOAD_CHECKSUMS", "false") == "true",
+		VerifyUploadChecksums:      s.get("MAVEN_VERIFY_UPLOAD_CHECKSUMS", "false") == "true",
+		LogFormat:                  s.get("MAVEN_LOG_FORMAT", "text"),
+		UploadGracePeriod:          s.get("MAVEN_UPLOAD_GRACE_PERIOD", ""),
+		TrustedCIDRs:               split(s.get("MAVEN_TRUSTED_CIDRS", "")),
+		TrustedProxies:             split(s.get("MAVEN_TRUSTED_PROXIES", "")),
+		UploadConflictPolicy:       s.get("MAVEN_UPLOAD_CONFLICT_POLICY", "reject"),
+		ProxyCacheMaxIdle:          s.get("MAVEN_PROXY_CACHE_MAX_IDLE", ""),
+		ProxyCachePrefix:           s.get("MAVEN_PROXY_CACHE_PREFIX", "repository/maven-public"),
+		ProxyCacheCleanupInterval:  s.get("MAVEN_PROXY_CACHE_CLEANUP_INTERVAL", "1h"),
+		ProxyCacheAsync:            s.get("MAVEN_PROXY_CACHE_ASYNC", "false") == "true",
+		ProxyVerifyChecksums:       s.get("MAVEN_PROXY_VERIFY_CHECKSUMS", "false") == "true",
+		ProxyRevalidateTTL:         s.get("MAVEN_PROXY_REVALIDATE_TTL", ""),
+		Compression:                s.get("MAVEN_COMPRESSION", "false") == "true",
+		UniqueSnapshotRepos:        split(s.get("MAVEN_UNIQUE_SNAPSHOT_REPOS", "")),
+		StorageBackend:             s.get("MAVEN_STORAGE_BACKEND", "local"),
+		StorageRoots:               s.getPrefixed("MAVEN_STORAGE_ROOT_"),
+		S3Bucket:                   s.get("MAVEN_S3_BUCKET", ""),
+		S3Region:                   s.get("MAVEN_S3_REGION", "us-east-1"),
+		S3Endpoint:                 s.get("MAVEN_S3_ENDPOINT", ""),
+		S3AccessKey:                s.get("MAVEN_S3_ACCESS_KEY", ""),
+		S3SecretKey:                s.get("MAVEN_S3_SECRET_KEY", ""),
+		GCSBucket:                  s.get("MAVEN_GCS_BUCKET", ""),
+		GCSCredentialsFile:         s.get("MAVEN_GCS_CREDENTIALS_FILE", ""),
+		GCSEndpoint:                s.get("MAVEN_GCS_ENDPOINT", ""),
+		ChecksumTrailingNewline:    s.get("MAVEN_CHECKSUM_TRAILING_NEWLINE", "false") == "true",
+		ReleaseRepos:               split(s.get("MAVEN_RELEASE_REPOS", "")),
+		GenerateMetadata:           s.get("MAVEN_GENERATE_METADATA", "true") == "true",
+		NegativeCacheTTL:           s.get("MAVEN_NEGATIVE_CACHE_TTL", "5m"),
+		ProxyHeadCacheTTL:          s.get("MAVEN_PROXY_HEAD_CACHE_TTL", "30s"),
+		ProxyInclude:               split(s.get("MAVEN_PROXY_INCLUDE", "")),
+		ProxyExclude:               split(s.get("MAVEN_PROXY_EXCLUDE", "")),
+		ProxyTimeout:               s.get("MAVEN_PROXY_TIMEOUT", "30s"),
+		ProxyMaxIdleConnsPerHost:   s.getInt("MAVEN_PROXY_MAX_IDLE_CONNS_PER_HOST", 16),
+		ProxyRetryMaxAttempts:      s.getInt("MAVEN_PROXY_RETRY_MAX_ATTEMPTS", 1),
+		ProxyRetryBaseDelay:        s.get("MAVEN_PROXY_RETRY_BASE_DELAY", "200ms"),
+		TLSCertFile:                s.get("MAVEN_TLS_CERT_FILE", ""),
+		TLSKeyFile:                 s.get("MAVEN_TLS_KEY_FILE", ""),
+		TLSMinVersion:              s.get("MAVEN_TLS_MIN_VERSION", "1.2"),
+		TLSRedirectPort:            s.get("MAVEN_TLS_REDIRECT_PORT", ""),
+		Tokens:                     strings.Fields(s.get("MAVEN_TOKENS", "")),
+		TokensFile:                 s.get("MAVEN_TOKENS_FILE", ""),
+		RateLimitReadRPS:           s.getInt("MAVEN_RATE_LIMIT_READ_RPS", 0),
+		RateLimitReadBurst:         s.getInt("MAVEN_RATE_LIMIT_READ_BURST", 0),
+		RateLimitWriteRPS:          s.getInt("MAVEN_RATE_LIMIT_WRITE_RPS", 0),
+		RateLimitWriteBurst:        s.getInt("MAVEN_RATE_LIMIT_WRITE_BURST", 0),
+		RateLimitBy:                s.get("MAVEN_RATE_LIMIT_BY", "ip"),
+		DownloadMaxConcurrent:      s.getInt("MAVEN_DOWNLOAD_MAX_CONCURRENT", 0),
+		DownloadQueueTimeout:       s.get("MAVEN_DOWNLOAD_QUEUE_TIMEOUT", ""),
+		DownloadBandwidth:          s.getInt64("MAVEN_DOWNLOAD_BANDWIDTH", 0),
+		DownloadConnBandwidth:      s.getInt64("MAVEN_DOWNLOAD_CONN_BANDWIDTH", 0),
+	}
+}
+
+// settings looks configuration values up in the environment first and in the
+// config file second. file is keyed by the environment variable each setting
+// stands in for.
+type settings struct {
+	file map[string]string
+}
+
+func (s settings) lookup(key string) (string, bool) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value, true
 	}
+	value, ok := s.file[key]
+	return value, ok
 }
 
-func getEnvInt(key string, fallback int) int {
-	if val, ok := os.LookupEnv(key); ok {
+func (s settings) getInt(key string, fallback int) int {
+	if val, ok := s.lookup(key); ok {
 		var i int
 		if _, err := fmt.Sscanf(val, "%d", &i); err == nil {
 			return i
@@ -60,6 +252,16 @@ func getEnvInt(key string, fallback int) int {
 	return fallback
 }
 
+func (s settings) getInt64(key string, fallback int64) int64 {
+	if val, ok := s.lookup(key); ok {
+		var i int64
+		if _, err := fmt.Sscanf(val, "%d", &i); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
 func split(s string) []string {
 	var res []string
 	for _, p := range strings.Split(s, ",") {
@@ -70,8 +272,26 @@ func split(s string) []string {
 	return res
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
+// getPrefixed collects the settings named prefix+<key> into a map keyed by
+// <key>.
+func (s settings) getPrefixed(prefix string) map[string]string {
+	values := make(map[string]string)
+	for key, value := range s.file {
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+			values[name] = value
+		}
+	}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if name, ok := strings.CutPrefix(key, prefix); ok && name != "" {
+			values[name] = value
+		}
+	}
+	return values
+}
+
+func (s settings) get(key, fallback string) string {
+	if value, ok := s.lookup(key); ok {
 		return value
 	}
 	return fallback
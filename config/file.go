@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadFile reads a YAML config file. Its keys are the yaml tags of Config,
+// each the name of a MAVEN_* environment variable without the prefix and in
+// lower case, so
+//
+//	proxy_urls:
+//	  - https://repo.maven.apache.org/maven2
+//	snapshot_policy:
+//	  develop: days:7
+//
+// stands for MAVEN_PROXY_URLS and MAVEN_SNAPSHOT_POLICY_develop. Lists are
+// joined the way the environment variable expects them and scalars are used
+// as written. The result is keyed by environment variable name.
+func loadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeFor[Config]()
+	for i := 0; i < t.NumField(); i++ {
+		if tag := t.Field(i).Tag.Get("yaml"); tag != "" {
+			kinds[tag] = t.Field(i).Type.Kind()
+		}
+	}
+
+	values := make(map[string]string)
+	for key, value := range doc {
+		kind, ok := kinds[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown setting %q in config file %s", key, path)
+		}
+		env := "MAVEN_" + strings.ToUpper(key)
+		switch v := value.(type) {
+		case nil:
+			continue
+		case map[string]any:
+			if kind != reflect.Map {
+				return nil, fmt.Errorf("setting %q in config file %s must not be a map", key, path)
+			}
+			for name, policy := range v {
+				values[env+"_"+name] = fmt.Sprint(policy)
+			}
+		case []any:
+			if kind != reflect.Slice {
+				return nil, fmt.Errorf("setting %q in config file %s must not be a list", key, path)
+			}
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			// Tokens are separated by whitespace, every other list by commas
+			sep := ","
+			if key == "tokens" {
+				sep = " "
+			}
+			values[env] = strings.Join(items, sep)
+		default:
+			if kind == reflect.Map {
+				return nil, fmt.Errorf("setting %q in config file %s must be a map", key, path)
+			}
+			values[env] = fmt.Sprint(v)
+		}
+	}
+	return values, nil
+}
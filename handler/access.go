@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"maven_repo/storage"
+)
+
+// accessTouchInterval throttles access marker writes so a hot artifact does
+// not cost a storage write on every download.
+const accessTouchInterval = time.Minute
+
+// accessTracker remembers when each cached artifact's access marker was last
+// written.
+type accessTracker struct {
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+// touchAccess records a read of path when it lies below the proxy cache
+// prefix and last-access cleanup is enabled. The marker's modification time is
+// what the proxy cache cleanup compares against.
+func (h *MavenHandler) touchAccess(path string) {
+	prefix := strings.Trim(h.Config.ProxyCachePrefix, "/")
+	if h.Config.ProxyCacheMaxIdle == "" || prefix == "" || !strings.HasPrefix(path, prefix+"/") {
+		return
+	}
+
+	now := time.Now()
+	t := &h.access
+	t.mu.Lock()
+	if now.Sub(t.touched[path]) < accessTouchInterval {
+		t.mu.Unlock()
+		return
+	}
+	if t.touched == nil || len(t.touched) >= 10000 {
+		t.touched = make(map[string]time.Time)
+	}
+	t.touched[path] = now
+	t.mu.Unlock()
+
+	if err := h.Store.Save(path+storage.AccessSuffix, bytes.NewReader(nil)); err != nil {
+		log.Printf("Failed to record access to %s: %v\n", path, err)
+	}
+}
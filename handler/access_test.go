@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func TestHandleDownload_TouchesAccessMarker(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{ProxyCacheMaxIdle: "720h", ProxyCachePrefix: "repository/cache"})
+
+	cached := "repository/cache/com/example/lib/1.0/lib-1.0.jar"
+	other := "repository/releases/com/example/lib/1.0/lib-1.0.jar"
+	for _, p := range []string{cached, other} {
+		doRequest(r, http.MethodPut, "/"+p, "jar")
+		if w := doRequest(r, http.MethodGet, "/"+p, ""); w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d", p, w.Code)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(base, cached+storage.AccessSuffix)); err != nil {
+		t.Errorf("expected access marker for cached artifact: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, other+storage.AccessSuffix)); !os.IsNotExist(err) {
+		t.Errorf("expected no access marker outside the cache prefix, stat err: %v", err)
+	}
+
+	// The marker is internal and never listed
+	w := doRequest(r, http.MethodGet, "/repository/cache/com/example/lib/1.0/", "")
+	if body := w.Body.String(); strings.Contains(body, storage.AccessSuffix) {
+		t.Errorf("listing exposes access marker: %s", body)
+	}
+}
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 
 	"maven_repo/service"
@@ -9,12 +11,16 @@ import (
 )
 
 type AdminHandler struct {
-	CleanupService *service.SnapshotCleanupService
+	CleanupService  *service.SnapshotCleanupService
+	MetadataService *service.MetadataService
+	Maven           *MavenHandler
 }
 
-func NewAdminHandler(cleanupService *service.SnapshotCleanupService) *AdminHandler {
+func NewAdminHandler(cleanupService *service.SnapshotCleanupService, metadataService *service.MetadataService, maven *MavenHandler) *AdminHandler {
 	return &AdminHandler{
-		CleanupService: cleanupService,
+		CleanupService:  cleanupService,
+		MetadataService: metadataService,
+		Maven:           maven,
 	}
 }
 
@@ -32,9 +38,62 @@ func (h *AdminHandler) CleanupStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": h.CleanupService.Status()})
 }
 
+// CleanupProgress streams the progress of the running (or next) cleanup pass
+// as server-sent events. A "status" event with the current cleanup state is
+// sent first; the stream ends after the pass's final event (done=true).
+func (h *AdminHandler) CleanupProgress(c *gin.Context) {
+	events, unsubscribe := h.CleanupService.Subscribe()
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.SSEvent("status", gin.H{"status": h.CleanupService.Status()})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case p, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent("progress", p)
+			return !p.Done
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// TriggerCleanup starts a cleanup pass in the background. With ?wait=true it
+// runs the pass before answering and returns its report.
 func (h *AdminHandler) TriggerCleanup(c *gin.Context) {
-	go func() {
-		h.CleanupService.RunCleanup()
-	}()
-	c.JSON(http.StatusOK, gin.H{"message": "Cleanup triggered manually"})
+	if c.Query("wait") != "true" {
+		go func() {
+			h.CleanupService.RunCleanup()
+		}()
+		c.JSON(http.StatusOK, gin.H{"message": "Cleanup triggered manually"})
+		return
+	}
+
+	run, err := h.CleanupService.RunCleanup()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, run)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// RegenerateMetadata rebuilds the maven-metadata.xml of the snapshot version
+// directory given by ?path= from the timestamped files it contains.
+func (h *AdminHandler) RegenerateMetadata(c *gin.Context) {
+	md, err := h.MetadataService.ReconcileSnapshot(c.Query("path"))
+	switch {
+	case errors.Is(err, service.ErrNotSnapshotDir):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrNoSnapshotBuilds):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	default:
+		c.XML(http.StatusOK, md)
+	}
 }
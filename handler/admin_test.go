@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/service"
+)
+
+func TestAdminHandler_CleanupProgressStream(t *testing.T) {
+	cfg := &config.Config{SnapshotKeepLatestOnly: true}
+	r, h, _ := newTestRouter(t, cfg)
+	cleanup := service.NewSnapshotCleanupService(h.Store, cfg)
+	admin := NewAdminHandler(cleanup, h.Metadata, h)
+	r.GET("/admin/snapshots/cleanup/progress", admin.CleanupProgress)
+
+	for _, dir := range []string{"com/example/a/1.0-SNAPSHOT", "com/example/b/1.0-SNAPSHOT", "com/example/c/1.0-SNAPSHOT"} {
+		h.Store.Save(filepath.Join(dir, "x-1.0-20240101.120000-1.jar"), strings.NewReader("jar"))
+	}
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/admin/snapshots/cleanup/progress")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Errorf("expected an event stream, got %q", ct)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var progress []service.CleanupProgress
+	event := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:") && event == "status":
+			// Subscribed; start the run the stream should report on
+			go cleanup.RunCleanup()
+		case strings.HasPrefix(line, "data:") && event == "progress":
+			var p service.CleanupProgress
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &p); err != nil {
+				t.Fatal(err)
+			}
+			progress = append(progress, p)
+		}
+	}
+
+	if len(progress) != 4 {
+		t.Fatalf("expected 3 directory events and a final one, got %+v", progress)
+	}
+	if last := progress[len(progress)-1]; !last.Done || last.Processed != 3 || last.Total != 3 {
+		t.Errorf("unexpected final event %+v", last)
+	}
+}
+
+func TestAdminHandler_TriggerCleanupWait(t *testing.T) {
+	cfg := &config.Config{SnapshotKeepLatestOnly: true}
+	r, h, _ := newTestRouter(t, cfg)
+	cleanup := service.NewSnapshotCleanupService(h.Store, cfg)
+	admin := NewAdminHandler(cleanup, h.Metadata, h)
+	r.POST("/admin/snapshots/cleanup/trigger", admin.TriggerCleanup)
+	r.GET("/admin/status", admin.SystemStatus)
+
+	dir := "com/example/app/1.0-SNAPSHOT"
+	h.Store.Save(dir+"/app-1.0-20240101.120000-1.jar", strings.NewReader("old"))
+	h.Store.Save(dir+"/app-1.0-20240102.120000-2.jar", strings.NewReader("new!"))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(h.Config.StoragePath, dir, "app-1.0-20240101.120000-1.jar"), old, old)
+
+	w := doRequest(r, http.MethodPost, "/admin/snapshots/cleanup/trigger?wait=true", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var run service.CleanupRun
+	if err := json.Unmarshal(w.Body.Bytes(), &run); err != nil {
+		t.Fatal(err)
+	}
+	if run.Directories != 1 || run.Versions != 2 || run.DeletedVersions != 1 || run.DeletedFiles != 1 || run.FreedBytes != 3 || len(run.Errors) != 0 {
+		t.Errorf("unexpected report %+v", run)
+	}
+
+	var status struct {
+		Cleanup struct{ LastRun service.CleanupRun } `json:"cleanup"`
+	}
+	json.Unmarshal(doRequest(r, http.MethodGet, "/admin/status", "").Body.Bytes(), &status)
+	if status.Cleanup.LastRun.DeletedFiles != 1 {
+		t.Errorf("status should report the last run, got %+v", status.Cleanup.LastRun)
+	}
+}
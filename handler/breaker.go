@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retryAfterer is implemented by storage decorators that can refuse calls,
+// such as storage.BreakerStorage.
+type retryAfterer interface {
+	RetryAfter() (time.Duration, bool)
+}
+
+// StorageGuard fails requests fast with 503 and Retry-After while the storage
+// circuit breaker is open. Stores without a breaker pass everything through.
+func StorageGuard(store storage.StorageProvider) gin.HandlerFunc {
+	breaker, ok := store.(retryAfterer)
+	if !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		if wait, open := breaker.RetryAfter(); open {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": storage.ErrCircuitOpen.Error()})
+			return
+		}
+		c.Next()
+	}
+}
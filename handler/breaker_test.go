@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type downStorage struct {
+	storage.StorageProvider
+}
+
+func (downStorage) Head(path string) (bool, error) {
+	return false, errors.New("backend down")
+}
+
+func TestStorageGuard_FailsFastWhileOpen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := storage.NewBreakerStorage(downStorage{}, 2, time.Minute)
+	h := NewMavenHandler(store, &config.Config{})
+
+	r := gin.New()
+	r.HEAD("/repository/:repoName/*path", StorageGuard(store), h.HandleHead)
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	for i := 0; i < 2; i++ {
+		if w := doRequest(r, http.MethodHead, target, ""); w.Code != http.StatusInternalServerError {
+			t.Fatalf("request %d: expected 500 from the failing backend, got %d", i, w.Code)
+		}
+	}
+
+	w := doRequest(r, http.MethodHead, target, "")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 once the breaker is open, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+}
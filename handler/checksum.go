@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checksumAlgorithms are the sidecars generated for uploaded artifacts.
+var checksumAlgorithms = []struct {
+	Ext string
+	New func() hash.Hash
+}{
+	{".sha1", sha1.New},
+	{".md5", md5.New},
+}
+
+// checksumWriter hashes everything written to it with every algorithm.
+type checksumWriter struct {
+	hashes []hash.Hash
+}
+
+func newChecksumWriter() *checksumWriter {
+	w := &checksumWriter{}
+	for _, algo := range checksumAlgorithms {
+		w.hashes = append(w.hashes, algo.New())
+	}
+	return w
+}
+
+func (w *checksumWriter) Write(p []byte) (int, error) {
+	for _, h := range w.hashes {
+		h.Write(p)
+	}
+	return len(p), nil
+}
+
+// Sums returns lowercase hex digests keyed by sidecar extension.
+func (w *checksumWriter) Sums() map[string]string {
+	sums := make(map[string]string, len(w.hashes))
+	for i, algo := range checksumAlgorithms {
+		sums[algo.Ext] = hex.EncodeToString(w.hashes[i].Sum(nil))
+	}
+	return sums
+}
+
+// shouldGenerateChecksums decides whether the server writes checksum sidecars
+// for an upload. The X-Generate-Checksums header overrides the repo default.
+func (h *MavenHandler) shouldGenerateChecksums(c *gin.Context, path string) bool {
+	if isSidecar(path) {
+		return false
+	}
+	switch strings.ToLower(c.GetHeader("X-Generate-Checksums")) {
+	case "false":
+		return false
+	case "true":
+		return true
+	}
+	if !h.Config.GenerateChecksums {
+		return false
+	}
+	repo := targetRepo(c)
+	for _, skip := range h.Config.GenerateChecksumsSkipRepos {
+		if skip == repo {
+			return false
+		}
+	}
+	return true
+}
+
+// formatChecksum renders a digest the way Maven writes sidecars: lowercase hex
+// without a trailing newline unless configured.
+func (h *MavenHandler) formatChecksum(sum string) string {
+	if h.Config.ChecksumTrailingNewline {
+		return sum + "\n"
+	}
+	return sum
+}
+
+func (h *MavenHandler) writeChecksums(path string, sums map[string]string) error {
+	for ext, sum := range sums {
+		if err := h.Store.Save(path+ext, strings.NewReader(h.formatChecksum(sum))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serveGeneratedChecksum answers a request for a missing .sha1/.md5 sidecar
+// whose artifact is stored by hashing the artifact. The result is saved so
+// later requests are served from storage.
+func (h *MavenHandler) serveGeneratedChecksum(c *gin.Context, path string) bool {
+	for _, algo := range checksumAlgorithms {
+		base, ok := strings.CutSuffix(path, algo.Ext)
+		if !ok || storage.IsInternal(base) {
+			continue
+		}
+		reader, found, err := h.Store.Get(base)
+		if err != nil || !found {
+			return false
+		}
+		digest := algo.New()
+		_, err = io.Copy(digest, reader)
+		reader.Close()
+		if err != nil {
+			// Directories and unreadable files have no checksum
+			return false
+		}
+
+		sum := h.formatChecksum(hex.EncodeToString(digest.Sum(nil)))
+		if err := h.Store.Save(path, strings.NewReader(sum)); err != nil {
+			log.Printf("Failed to save generated checksum %s: %v\n", path, err)
+		} else {
+			h.listings.invalidate(path)
+		}
+		c.Data(http.StatusOK, "text/plain", []byte(sum))
+		return true
+	}
+	return false
+}
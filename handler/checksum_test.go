@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestUpload_GeneratesChecksums(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{GenerateChecksums: true})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodPut, target, "hello"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+
+	want := map[string]string{
+		".sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		".md5":  "5d41402abc4b2a76b9719d911017c592",
+	}
+	for ext, sum := range want {
+		data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/")+ext))
+		if err != nil {
+			t.Fatalf("missing %s sidecar: %v", ext, err)
+		}
+		if string(data) != sum {
+			t.Errorf("%s: expected %s, got %s", ext, sum, data)
+		}
+	}
+}
+
+func TestUpload_ChecksumGenerationSuppressed(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{
+		GenerateChecksums:          true,
+		GenerateChecksumsSkipRepos: []string{"thirdparty"},
+	})
+
+	// Suppressed by header
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	req := httptest.NewRequest(http.MethodPut, target, strings.NewReader("hello"))
+	req.Header.Set("X-Generate-Checksums", "false")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if _, err := os.Stat(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1")); !os.IsNotExist(err) {
+		t.Errorf("expected no generated sha1 when suppressed by header")
+	}
+
+	// Suppressed by repo default, re-enabled by header
+	target = "/repository/thirdparty/com/example/lib/1.0/lib-1.0.jar"
+	doRequest(r, http.MethodPut, target, "hello")
+	if _, err := os.Stat(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1")); !os.IsNotExist(err) {
+		t.Errorf("expected no generated sha1 for skipped repo")
+	}
+	// Dot segments do not pick up another repository's default
+	doRequest(r, http.MethodPut, "/repository/releases/../thirdparty/com/example/lib/1.0/lib-1.0.jar", "hello")
+	if _, err := os.Stat(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1")); !os.IsNotExist(err) {
+		t.Errorf("expected no generated sha1 for skipped repo reached through dot segments")
+	}
+	req = httptest.NewRequest(http.MethodPut, target, strings.NewReader("hello"))
+	req.Header.Set("X-Generate-Checksums", "true")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if _, err := os.Stat(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1")); err != nil {
+		t.Errorf("expected sha1 when forced by header: %v", err)
+	}
+}
+
+func TestDownload_GeneratesMissingChecksums(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "hello")
+	if _, err := os.Stat(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1")); !os.IsNotExist(err) {
+		t.Fatal("expected no sidecar after upload with generation disabled")
+	}
+
+	for ext, sum := range map[string]string{
+		".sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d",
+		".md5":  "5d41402abc4b2a76b9719d911017c592",
+	} {
+		w := doRequest(r, http.MethodGet, target+ext, "")
+		if w.Code != http.StatusOK || w.Body.String() != sum {
+			t.Errorf("%s: expected 200 %s, got %d %q", ext, sum, w.Code, w.Body.String())
+		}
+		// Saved for later requests
+		if data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/")+ext)); err != nil || string(data) != sum {
+			t.Errorf("%s: generated sidecar not stored: %q, %v", ext, data, err)
+		}
+	}
+
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/missing.jar.sha1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("checksum of a missing artifact: expected 404, got %d", w.Code)
+	}
+}
+
+func TestUpload_ChecksumTrailingNewline(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{GenerateChecksums: true, ChecksumTrailingNewline: true})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "hello")
+	data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/")+".sha1"))
+	if err != nil || string(data) != "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d\n" {
+		t.Errorf("expected sha1 with trailing newline, got %q, %v", data, err)
+	}
+}
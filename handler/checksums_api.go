@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// reportedDigests are the digests served by /api/checksums, keyed by name.
+var reportedDigests = []struct {
+	Name string
+	New  func() hash.Hash
+}{
+	{"sha1", sha1.New},
+	{"md5", md5.New},
+	{"sha256", sha256.New},
+	{"sha512", sha512.New},
+}
+
+// maxDigestEntries bounds the digest cache; it starts over once reached.
+const maxDigestEntries = 10000
+
+type computedDigests struct {
+	size    int64
+	modTime time.Time
+	sums    map[string]string
+}
+
+// digestCache remembers digests computed for artifacts without a sidecar.
+// Entries are keyed by path and only reused while size and modification time
+// are unchanged, so a re-deploy is never answered with stale digests.
+type digestCache struct {
+	mu      sync.Mutex
+	entries map[string]computedDigests
+}
+
+func (dc *digestCache) get(path string, e storage.Entry) (map[string]string, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	cached, ok := dc.entries[path]
+	if !ok {
+		return nil, false
+	}
+	if cached.size != e.Size || !cached.modTime.Equal(e.ModTime) {
+		// Re-deployed since, so these digests will never match again
+		delete(dc.entries, path)
+		return nil, false
+	}
+	return cached.sums, true
+}
+
+func (dc *digestCache) set(path string, e storage.Entry, sums map[string]string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	if dc.entries == nil || len(dc.entries) >= maxDigestEntries {
+		dc.entries = make(map[string]computedDigests)
+	}
+	dc.entries[path] = computedDigests{size: e.Size, modTime: e.ModTime, sums: sums}
+}
+
+func (dc *digestCache) size() int {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return len(dc.entries)
+}
+
+// readSidecarDigest returns the digest stored in path+"."+name, if any.
+func (h *MavenHandler) readSidecarDigest(path, name string) string {
+	reader, found, err := h.Store.Get(path + "." + name)
+	if err != nil || !found {
+		return ""
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, 1024))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// computeDigests hashes the artifact once for every digest it lacks.
+func (h *MavenHandler) computeDigests(path string) (map[string]string, error) {
+	reader, found, err := h.Store.Get(path)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	defer reader.Close()
+
+	hashes := make([]hash.Hash, len(reportedDigests))
+	writers := make([]io.Writer, len(reportedDigests))
+	for i, d := range reportedDigests {
+		hashes[i] = d.New()
+		writers[i] = hashes[i]
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), reader); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(reportedDigests))
+	for i, d := range reportedDigests {
+		sums[d.Name] = hex.EncodeToString(hashes[i].Sum(nil))
+	}
+	return sums, nil
+}
+
+// HandleChecksums returns every digest of the artifact at ?path= in one call.
+// Digests come from sidecars when present and are computed otherwise.
+func (h *MavenHandler) HandleChecksums(c *gin.Context) {
+	p := strings.TrimPrefix(c.Query("path"), "/")
+	if p == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	entries, err := h.Store.List(path.Dir(p))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var entry *storage.Entry
+	for i := range entries {
+		if entries[i].Name == path.Base(p) && !entries[i].IsDir {
+			entry = &entries[i]
+			break
+		}
+	}
+	if entry == nil || storage.IsInternal(p) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "artifact not found: " + p})
+		return
+	}
+
+	result := gin.H{"path": p}
+	sources := gin.H{}
+	var missing []string
+	for _, d := range reportedDigests {
+		if sum := h.readSidecarDigest(p, d.Name); sum != "" {
+			result[d.Name] = sum
+			sources[d.Name] = "sidecar"
+			continue
+		}
+		missing = append(missing, d.Name)
+	}
+
+	if len(missing) > 0 {
+		computed, ok := h.digests.get(p, *entry)
+		if !ok {
+			computed, err = h.computeDigests(p)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if computed == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "artifact not found: " + p})
+				return
+			}
+			h.digests.set(p, *entry, computed)
+		}
+		for _, name := range missing {
+			result[name] = computed[name]
+			sources[name] = "computed"
+		}
+	}
+	result["sources"] = sources
+	c.JSON(http.StatusOK, result)
+}
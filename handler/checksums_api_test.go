@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleChecksums(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	artifact := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	content := []byte("jar bytes")
+	doRequest(r, http.MethodPut, "/"+artifact, string(content))
+	// The published sha1 sidecar is served as-is, even in "<digest>  <file>" form
+	doRequest(r, http.MethodPut, "/"+artifact+".sha1", "0123456789abcdef0123456789abcdef01234567  app-1.0.jar")
+
+	md5Sum := md5.Sum(content)
+	sha256Sum := sha256.Sum256(content)
+	sha512Sum := sha512.Sum512(content)
+	want := map[string]string{
+		"sha1":   "0123456789abcdef0123456789abcdef01234567",
+		"md5":    hex.EncodeToString(md5Sum[:]),
+		"sha256": hex.EncodeToString(sha256Sum[:]),
+		"sha512": hex.EncodeToString(sha512Sum[:]),
+	}
+
+	for i := 0; i < 2; i++ {
+		w := doRequest(r, http.MethodGet, "/api/checksums?path="+artifact, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var got map[string]any
+		json.Unmarshal(w.Body.Bytes(), &got)
+		for name, sum := range want {
+			if got[name] != sum {
+				t.Errorf("%s: expected %s, got %v", name, sum, got[name])
+			}
+		}
+		sources := got["sources"].(map[string]any)
+		if sources["sha1"] != "sidecar" || sources["sha256"] != "computed" {
+			t.Errorf("unexpected sources %v", sources)
+		}
+	}
+
+	// A re-deploy must not be answered from the digest cache
+	doRequest(r, http.MethodPut, "/"+artifact, "new jar bytes!")
+	w := doRequest(r, http.MethodGet, "/api/checksums?path="+artifact, "")
+	var got map[string]any
+	json.Unmarshal(w.Body.Bytes(), &got)
+	newSum := md5.Sum([]byte("new jar bytes!"))
+	if got["md5"] != hex.EncodeToString(newSum[:]) {
+		t.Errorf("expected digests to be recomputed after a re-deploy, got md5 %v", got["md5"])
+	}
+
+	if w := doRequest(r, http.MethodGet, "/api/checksums?path=repository/releases/missing.jar", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing artifact, got %d", w.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compressMinSize is the smallest response worth compressing when its length
+// is known up front.
+const compressMinSize = 1024
+
+// compressibleExtensions are the text artifacts served as
+// application/octet-stream that are still worth compressing. Jars, zips and
+// other archives are already compressed.
+var compressibleExtensions = []string{".pom", ".xml", ".json", ".module", ".txt", ".properties", ".html"}
+
+// Compress gzips (or deflates, if that is all the client accepts) HTML
+// listings, JSON and text artifacts such as POMs when MAVEN_COMPRESSION is
+// enabled. Range requests and partial responses are always sent as they are,
+// since their byte offsets refer to the uncompressed file.
+func Compress(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.Compression || c.Request.Method != http.MethodGet || c.GetHeader("Range") != "" {
+			c.Next()
+			return
+		}
+		encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"))
+		w := &compressWriter{ResponseWriter: c.Writer, encoding: encoding, urlPath: c.Request.URL.Path}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// acceptedEncoding picks gzip or deflate from an Accept-Encoding header, or ""
+// when the client accepts neither.
+func acceptedEncoding(header string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if v, err := strconv.ParseFloat(q, 64); err == nil && v == 0 {
+				continue
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	switch {
+	case accepted["gzip"]:
+		return "gzip"
+	case accepted["deflate"]:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressible reports whether a response with this Content-Type, for this
+// URL, is text worth compressing.
+func compressible(contentType, urlPath string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	ext := path.Ext(urlPath)
+	for _, e := range compressibleExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// compressWriter decides on the first write, when status and headers are
+// final, whether to compress the body.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding string
+	urlPath  string
+	decided  bool
+	encoder  io.WriteCloser
+}
+
+func (w *compressWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	header := w.Header()
+	if w.Status() != http.StatusOK || header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return
+	}
+	if !compressible(header.Get("Content-Type"), w.urlPath) {
+		return
+	}
+	header.Add("Vary", "Accept-Encoding")
+	if w.encoding == "" {
+		return
+	}
+	if length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64); err == nil && length < compressMinSize {
+		return
+	}
+
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", w.encoding)
+	// The compressed bytes differ from the stored file, so only a weak
+	// validator still applies
+	if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		header.Set("ETag", "W/"+etag)
+	}
+	if w.encoding == "gzip" {
+		w.encoder = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.encoder = zlib.NewWriter(w.ResponseWriter)
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	w.decide()
+	if w.encoder == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	return w.encoder.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush pushes out what has been compressed so far, for streamed listings.
+func (w *compressWriter) Flush() {
+	if flusher, ok := w.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) close() {
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+}
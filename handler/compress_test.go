@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCompress(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{Compression: true}
+	store := storage.NewMemoryStorage()
+	h := NewMavenHandler(store, cfg)
+	r := gin.New()
+	r.Use(Compress(cfg))
+	r.GET("/repository/:repoName/*path", h.HandleDownload)
+
+	pom := strings.Repeat("<dependency></dependency>\n", 100)
+	dir := "repository/releases/com/example/app/1.0/"
+	store.Save(dir+"app-1.0.pom", strings.NewReader(pom))
+	store.Save(dir+"app-1.0.pom.sha1", strings.NewReader(sha1String(pom)))
+	store.Save(dir+"app-1.0.jar", strings.NewReader(pom))
+	store.Save(dir+"small.pom", strings.NewReader("<project/>"))
+
+	get := func(target string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	gzipped := map[string]string{"Accept-Encoding": "gzip, deflate"}
+
+	w := get("/"+dir+"app-1.0.pom", gzipped)
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Content-Length") != "" {
+		t.Fatalf("expected a gzipped POM without Content-Length, got %v", w.Header())
+	}
+	if etag := w.Header().Get("ETag"); !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("compressed response should carry a weak ETag, got %q", etag)
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != pom {
+		t.Errorf("decompressed body differs")
+	}
+
+	w = get("/"+dir, gzipped)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Errorf("expected a gzipped HTML listing, got %v", w.Header())
+	}
+
+	for name, tc := range map[string]struct {
+		target  string
+		headers map[string]string
+	}{
+		"jar":             {dir + "app-1.0.jar", gzipped},
+		"small":           {dir + "small.pom", gzipped},
+		"range":           {dir + "app-1.0.pom", map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-9"}},
+		"not accepted":    {dir + "app-1.0.pom", map[string]string{"Accept-Encoding": "gzip;q=0, br"}},
+		"no encoding":     {dir + "app-1.0.pom", nil},
+		"missing (404)":   {dir + "missing.pom", gzipped},
+		"checksum (text)": {dir + "app-1.0.pom.sha1", gzipped},
+	} {
+		w := get("/"+tc.target, tc.headers)
+		if enc := w.Header().Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s: expected no compression, got %q", name, enc)
+		}
+	}
+
+	if w := get("/"+dir+"app-1.0.pom", map[string]string{"Accept-Encoding": "deflate"}); w.Header().Get("Content-Encoding") != "deflate" {
+		t.Errorf("expected deflate when gzip is not accepted, got %v", w.Header())
+	}
+}
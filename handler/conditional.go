@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fileStat is implemented by readers that know the size and modification time
+// of what they read, such as *os.File.
+type fileStat interface {
+	Stat() (os.FileInfo, error)
+}
+
+func statReader(reader io.Reader) os.FileInfo {
+	st, ok := reader.(fileStat)
+	if !ok {
+		return nil
+	}
+	info, err := st.Stat()
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	return info
+}
+
+// fileETag identifies the content of a stored file: strongly by its .sha1
+// sidecar when there is one, otherwise weakly by size and modification time.
+func (h *MavenHandler) fileETag(path string, info os.FileInfo) string {
+	if sum := h.readSHA1Sidecar(path); sum != "" {
+		return `"` + sum + `"`
+	}
+	if info == nil {
+		return ""
+	}
+	return fmt.Sprintf(`W/"%x-%x"`, info.Size(), info.ModTime().UnixNano())
+}
+
+// writeFileHeaders sets ETag and Last-Modified for the stored file read by
+// reader and returns its size (-1 if the backend does not report it). When the
+// request's If-None-Match or If-Modified-Since shows the client already has
+// this version, 304 is written and notModified is true.
+func (h *MavenHandler) writeFileHeaders(c *gin.Context, path string, reader io.Reader) (size int64, notModified bool) {
+	info := statReader(reader)
+	size = -1
+	var modTime time.Time
+	if info != nil {
+		size = info.Size()
+		modTime = info.ModTime()
+	}
+
+	etag := h.fileETag(path, info)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		c.Header("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+
+	if isNotModified(c.Request, etag, modTime) {
+		c.Status(http.StatusNotModified)
+		return size, true
+	}
+	return size, false
+}
+
+// isNotModified evaluates the conditional GET headers. If-None-Match takes
+// precedence over If-Modified-Since, as RFC 9110 requires.
+func isNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if etag == "" {
+			return false
+		}
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modTime.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !modTime.Truncate(time.Second).After(since)
+}
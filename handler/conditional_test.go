@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func conditionalGet(r http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleDownload_ConditionalGet(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "hello")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	os.Chtimes(filepath.Join(base, strings.TrimPrefix(target, "/")), modTime, modTime)
+
+	w := conditionalGet(r, target, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected 200 with a weak ETag, got %d %q", w.Code, etag)
+	}
+
+	cases := []struct {
+		name    string
+		headers map[string]string
+		want    int
+	}{
+		{"matching etag", map[string]string{"If-None-Match": etag}, http.StatusNotModified},
+		{"etag in list", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"stale etag", map[string]string{"If-None-Match": `W/"stale"`}, http.StatusOK},
+		{"not modified since", map[string]string{"If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"}, http.StatusNotModified},
+		{"modified since", map[string]string{"If-Modified-Since": "Thu, 29 Feb 2024 12:00:00 GMT"}, http.StatusOK},
+		// If-None-Match wins over If-Modified-Since
+		{"stale etag, old date", map[string]string{"If-None-Match": `W/"stale"`, "If-Modified-Since": "Fri, 01 Mar 2024 12:00:00 GMT"}, http.StatusOK},
+	}
+	for _, tc := range cases {
+		w := conditionalGet(r, target, tc.headers)
+		if w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+		if tc.want == http.StatusNotModified && w.Body.Len() != 0 {
+			t.Errorf("%s: 304 with a body", tc.name)
+		}
+		if w.Header().Get("ETag") != etag {
+			t.Errorf("%s: ETag missing from response", tc.name)
+		}
+	}
+}
+
+func TestHandleDownload_ETagFromSHA1Sidecar(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{GenerateChecksums: true})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "hello")
+
+	w := conditionalGet(r, target, nil)
+	want := `"aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"`
+	if got := w.Header().Get("ETag"); got != want {
+		t.Fatalf("expected ETag %s, got %q", want, got)
+	}
+	if w := conditionalGet(r, target, map[string]string{"If-None-Match": want}); w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+}
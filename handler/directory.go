@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createDirRequest struct {
+	Paths []string `json:"paths" binding:"required"`
+}
+
+// HandleCreateDir creates empty directories, e.g. a new repository or group
+// skeleton, so they show up in listings before anything is deployed.
+func (h *MavenHandler) HandleCreateDir(c *gin.Context) {
+	var req createDirRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created := []string{}
+	failed := map[string]string{}
+	for _, p := range req.Paths {
+		path := strings.Trim(p, "/")
+		if path == "" {
+			failed[p] = "path is required"
+			continue
+		}
+		if err := h.Store.CreateDir(path); err != nil {
+			failed[p] = err.Error()
+			continue
+		}
+		h.listings.invalidate(path)
+		created = append(created, p)
+	}
+	c.JSON(http.StatusOK, gin.H{"created": created, "failed": failed})
+}
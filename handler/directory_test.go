@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleCreateDir_AppearsInListing(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	w := doRequest(r, http.MethodPost, "/admin/directories/create", `{"paths":["repository/staging/com/example"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = doRequest(r, http.MethodGet, "/repository/staging/com/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "example/") {
+		t.Fatalf("expected the new directory in the parent listing, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, http.MethodGet, "/repository/staging/com/example/", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected the empty directory to list, got %d", w.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// DownloadLimit caps concurrent downloads at MAVEN_DOWNLOAD_MAX_CONCURRENT
+// and throttles download responses to MAVEN_DOWNLOAD_BANDWIDTH bytes per
+// second in total and MAVEN_DOWNLOAD_CONN_BANDWIDTH per request. A download
+// beyond the concurrency limit waits up to MAVEN_DOWNLOAD_QUEUE_TIMEOUT for a
+// slot and is then answered 503. Bandwidth is measured before compression.
+// Every limit is off when 0.
+func DownloadLimit(cfg *config.Config) gin.HandlerFunc {
+	var slots chan struct{}
+	if cfg.DownloadMaxConcurrent > 0 {
+		slots = make(chan struct{}, cfg.DownloadMaxConcurrent)
+	}
+	queueTimeout, err := time.ParseDuration(cfg.DownloadQueueTimeout)
+	if cfg.DownloadQueueTimeout != "" && (err != nil || queueTimeout < 0) {
+		log.Printf("Invalid MAVEN_DOWNLOAD_QUEUE_TIMEOUT %q, downloads beyond the limit are rejected\n", cfg.DownloadQueueTimeout)
+		queueTimeout = 0
+	}
+	global := newBandwidthLimiter(cfg.DownloadBandwidth)
+	if slots == nil && global == nil && cfg.DownloadConnBandwidth <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		if slots != nil {
+			if !acquireSlot(c.Request.Context(), slots, queueTimeout) {
+				c.Header("Retry-After", "1")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "too many concurrent downloads"})
+				return
+			}
+			defer func() { <-slots }()
+		}
+
+		var limiters []*rate.Limiter
+		if global != nil {
+			limiters = append(limiters, global)
+		}
+		if conn := newBandwidthLimiter(cfg.DownloadConnBandwidth); conn != nil {
+			limiters = append(limiters, conn)
+		}
+		if len(limiters) > 0 {
+			c.Writer = &throttledWriter{ResponseWriter: c.Writer, ctx: c.Request.Context(), limiters: limiters}
+		}
+		c.Next()
+	}
+}
+
+// acquireSlot takes a slot, waiting up to timeout for one to free up. It
+// gives up early when the client goes away.
+func acquireSlot(ctx context.Context, slots chan struct{}, timeout time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return false
+}
+
+// newBandwidthLimiter returns a limiter for bytesPerSecond that allows one
+// second's worth of data in a burst, or nil when unlimited.
+func newBandwidthLimiter(bytesPerSecond int64) *rate.Limiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond))
+}
+
+// throttledWriter delays writes until every limiter has granted the bytes.
+// A client that disconnects ends the wait with an error.
+type throttledWriter struct {
+	gin.ResponseWriter
+	ctx      context.Context
+	limiters []*rate.Limiter
+}
+
+func (w *throttledWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		for _, l := range w.limiters {
+			n = min(n, l.Burst())
+		}
+		for _, l := range w.limiters {
+			if err := l.WaitN(w.ctx, n); err != nil {
+				return written, err
+			}
+		}
+		m, err := w.ResponseWriter.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (w *throttledWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
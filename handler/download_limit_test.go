@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDownloadLimit_Concurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	r := gin.New()
+	r.GET("/*path", DownloadLimit(&config.Config{
+		DownloadMaxConcurrent: 1,
+		DownloadQueueTimeout:  "50ms",
+	}), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	request := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.jar", nil))
+		return w
+	}
+
+	first := make(chan int)
+	go func() { first <- request().Code }()
+	<-started
+
+	w := request()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("download over the limit: expected 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// A queued download gets the slot once the first one finishes
+	queued := make(chan int)
+	go func() { queued <- request().Code }()
+	time.Sleep(10 * time.Millisecond)
+	release <- struct{}{}
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("first download: expected 200, got %d", code)
+	}
+	<-started
+	release <- struct{}{}
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("queued download: expected 200, got %d", code)
+	}
+}
+
+func TestDownloadLimit_Bandwidth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := strings.Repeat("x", 30000)
+	r := gin.New()
+	r.GET("/*path", DownloadLimit(&config.Config{DownloadConnBandwidth: 20000}), func(c *gin.Context) {
+		c.DataFromReader(http.StatusOK, int64(len(body)), "application/java-archive", strings.NewReader(body), nil)
+	})
+
+	// The first second's worth goes out at once, the rest at 20000 bytes/s
+	start := time.Now()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/app.jar", nil))
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("expected the download to be throttled, took %v", elapsed)
+	}
+	if w.Body.String() != body {
+		t.Errorf("expected the full body, got %d bytes", w.Body.Len())
+	}
+}
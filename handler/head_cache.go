@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHeadEntries bounds the upstream HEAD cache; expired entries are dropped
+// once it is reached.
+const maxHeadEntries = 10000
+
+// upstreamHead is what a proxy answered to a HEAD request for an artifact.
+type upstreamHead struct {
+	url string
+	// size is the artifact's length, or -1 when the proxy did not say
+	size        int64
+	contentType string
+	expires     time.Time
+}
+
+// write answers a HEAD request with the upstream's size and content type.
+func (u upstreamHead) write(c *gin.Context) {
+	if u.size >= 0 {
+		c.Header("Content-Length", strconv.FormatInt(u.size, 10))
+	}
+	if u.contentType != "" {
+		c.Header("Content-Type", u.contentType)
+	}
+	c.Status(http.StatusOK)
+}
+
+// headCache remembers upstream HEAD hits by artifact path for a short while.
+// Maven clients usually HEAD an artifact right before they GET it, so the GET
+// goes straight to the proxy that has it and knows its length even when the
+// download itself is chunked. A zero TTL disables it.
+type headCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]upstreamHead
+}
+
+func newHeadCache(ttl time.Duration) *headCache {
+	return &headCache{ttl: ttl, entries: make(map[string]upstreamHead)}
+}
+
+func (hc *headCache) get(artifactPath string) (upstreamHead, bool) {
+	if hc.ttl <= 0 {
+		return upstreamHead{}, false
+	}
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	head, ok := hc.entries[artifactPath]
+	if !ok || !time.Now().Before(head.expires) {
+		return upstreamHead{}, false
+	}
+	return head, true
+}
+
+func (hc *headCache) set(artifactPath string, head upstreamHead) {
+	if hc.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	if len(hc.entries) >= maxHeadEntries {
+		for p, e := range hc.entries {
+			if now.After(e.expires) {
+				delete(hc.entries, p)
+			}
+		}
+		if len(hc.entries) >= maxHeadEntries {
+			hc.entries = make(map[string]upstreamHead)
+		}
+	}
+	head.expires = now.Add(hc.ttl)
+	hc.entries[artifactPath] = head
+}
+
+// forget drops artifactPath once a GET has fetched it, since from then on it
+// is served from the cache or has to be looked up again.
+func (hc *headCache) forget(artifactPath string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	delete(hc.entries, artifactPath)
+}
+
+// sizeAt returns the length a recent HEAD reported for artifactPath at url,
+// or -1.
+func (hc *headCache) sizeAt(artifactPath, url string) int64 {
+	if head, ok := hc.get(artifactPath); ok && head.url == url {
+		return head.size
+	}
+	return -1
+}
+
+func (hc *headCache) size() int {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	return len(hc.entries)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleHead_CachesUpstreamHead(t *testing.T) {
+	body := "jar-content"
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	count := func(name string, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls[name+" "+r.Method]++
+	}
+	missing := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		count("missing", r)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	hit := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		count("hit", r)
+		w.Header().Set("Content-Type", "application/java-archive")
+		if r.Method == http.MethodHead {
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			return
+		}
+		// Flushing early makes the download chunked, without a length
+		w.Write([]byte(body[:3]))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(body[3:]))
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{missing.URL, hit.URL}, ProxyHeadCacheTTL: "1m"})
+	r.GET("/aggregate/*path", h.HandleAggregateDownload("repository"))
+	r.HEAD("/aggregate/*path", h.HandleAggregateHead("repository"))
+
+	target := "/aggregate/com/example/app/1.0/app-1.0.jar"
+	for i := 0; i < 2; i++ {
+		w := doRequest(r, http.MethodHead, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("HEAD %d: expected 200, got %d", i, w.Code)
+		}
+		if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+			t.Errorf("HEAD %d: expected the upstream Content-Length, got %q", i, got)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/java-archive" {
+			t.Errorf("HEAD %d: expected the upstream Content-Type, got %q", i, got)
+		}
+	}
+	if calls["missing HEAD"] != 1 || calls["hit HEAD"] != 1 {
+		t.Errorf("expected the second HEAD to be answered from memory, got %v", calls)
+	}
+
+	w := doRequest(r, http.MethodGet, target, "")
+	if w.Code != http.StatusOK || w.Body.String() != body {
+		t.Fatalf("GET: expected the artifact, got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Length"); got != strconv.Itoa(len(body)) {
+		t.Errorf("GET: expected the length the HEAD reported, got %q", got)
+	}
+	if calls["missing GET"] != 0 || calls["hit GET"] != 1 {
+		t.Errorf("expected the GET to go straight to the upstream that had it, got %v", calls)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Upload conflict policies for a PUT to a path that is already being written.
+const (
+	uploadConflictReject = "reject"
+	uploadConflictWait   = "wait"
+)
+
+type inflightUpload struct {
+	done   chan struct{}
+	status int
+}
+
+// inflightUploads tracks paths with an upload in progress on this instance.
+type inflightUploads struct {
+	mu    sync.Mutex
+	paths map[string]*inflightUpload
+}
+
+// claimUpload registers the request as the writer of path. If another upload
+// of path is still streaming (typically a client retrying after a timeout) it
+// is rejected with 409, or with the "wait" policy it waits for the first
+// upload: a successful first upload answers the retry too, a failed one lets
+// the retry store its own body. The returned release must be called once the
+// response status is final; ok is false when the response was written.
+func (h *MavenHandler) claimUpload(c *gin.Context, path string) (release func(), ok bool) {
+	for {
+		h.uploads.mu.Lock()
+		if h.uploads.paths == nil {
+			h.uploads.paths = make(map[string]*inflightUpload)
+		}
+		first, busy := h.uploads.paths[path]
+		if !busy {
+			mine := &inflightUpload{done: make(chan struct{})}
+			h.uploads.paths[path] = mine
+			h.uploads.mu.Unlock()
+			return func() {
+				mine.status = c.Writer.Status()
+				h.uploads.mu.Lock()
+				delete(h.uploads.paths, path)
+				h.uploads.mu.Unlock()
+				close(mine.done)
+			}, true
+		}
+		h.uploads.mu.Unlock()
+
+		if h.Config.UploadConflictPolicy != uploadConflictWait {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("an upload of %s is already in progress", path)})
+			return nil, false
+		}
+
+		select {
+		case <-first.done:
+		case <-c.Request.Context().Done():
+			return nil, false
+		}
+		if first.status >= 200 && first.status < 300 {
+			// The retry carries the same bytes; drop them and adopt the result
+			io.Copy(io.Discard, c.Request.Body)
+			c.Status(first.status)
+			return nil, false
+		}
+	}
+}
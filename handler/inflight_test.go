@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+// startSlowUpload begins a PUT whose body is fed through the returned writer
+// and waits until the handler has claimed the path.
+func startSlowUpload(t *testing.T, r http.Handler, h *MavenHandler, target string) (*io.PipeWriter, <-chan int) {
+	t.Helper()
+	pr, pw := io.Pipe()
+	done := make(chan int, 1)
+	go func() {
+		req := httptest.NewRequest(http.MethodPut, target, pr)
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		done <- w.Code
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		h.uploads.mu.Lock()
+		_, claimed := h.uploads.paths[strings.TrimPrefix(target, "/")]
+		h.uploads.mu.Unlock()
+		if claimed {
+			return pw, done
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("first upload never started")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestHandleUpload_RetryWhileInFlight(t *testing.T) {
+	for _, policy := range []string{uploadConflictReject, uploadConflictWait} {
+		t.Run(policy, func(t *testing.T) {
+			r, h, base := newTestRouter(t, &config.Config{UploadConflictPolicy: policy})
+			target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+			first := strings.Repeat("a", 64*1024)
+
+			pw, firstDone := startSlowUpload(t, r, h, target)
+			pw.Write([]byte(first[:1024]))
+
+			retryDone := make(chan int, 1)
+			go func() {
+				w := doRequest(r, http.MethodPut, target, strings.Repeat("b", len(first)))
+				retryDone <- w.Code
+			}()
+
+			if policy == uploadConflictReject {
+				if code := <-retryDone; code != http.StatusConflict {
+					t.Fatalf("expected the retry to be rejected with 409, got %d", code)
+				}
+			} else {
+				select {
+				case code := <-retryDone:
+					t.Fatalf("expected the retry to wait for the first upload, got %d", code)
+				case <-time.After(50 * time.Millisecond):
+				}
+			}
+
+			pw.Write([]byte(first[1024:]))
+			pw.Close()
+			if code := <-firstDone; code != http.StatusCreated {
+				t.Fatalf("first upload: expected 201, got %d", code)
+			}
+			if policy == uploadConflictWait {
+				if code := <-retryDone; code != http.StatusCreated {
+					t.Fatalf("expected the retry to adopt 201, got %d", code)
+				}
+			}
+
+			data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != first {
+				t.Fatalf("stored artifact is corrupted (%d bytes, first byte %q)", len(data), data[:1])
+			}
+
+			// Once the first upload is done the path is free again
+			if w := doRequest(r, http.MethodPut, target, "c"); w.Code != http.StatusCreated {
+				t.Fatalf("later upload: expected 201, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleUpload_WaitRetriesFailedUpload(t *testing.T) {
+	r, h, base := newTestRouter(t, &config.Config{UploadConflictPolicy: uploadConflictWait})
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+
+	pw, firstDone := startSlowUpload(t, r, h, target)
+	pw.Write([]byte("partial"))
+
+	retryDone := make(chan int, 1)
+	go func() {
+		w := doRequest(r, http.MethodPut, target, "retried")
+		retryDone <- w.Code
+	}()
+	select {
+	case code := <-retryDone:
+		t.Fatalf("expected the retry to wait for the first upload, got %d", code)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	pw.CloseWithError(errors.New("client went away"))
+	if code := <-firstDone; code < 400 {
+		t.Fatalf("first upload: expected a failure, got %d", code)
+	}
+	if code := <-retryDone; code != http.StatusCreated {
+		t.Fatalf("expected the retry to store its own body with 201, got %d", code)
+	}
+	data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/")))
+	if err != nil || string(data) != "retried" {
+		t.Fatalf("expected the retried body to be stored, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,347 @@
+package handler
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hasListingOptions reports whether the request shapes the listing through
+// query parameters or asks for JSON, in which case it bypasses the listing
+// cache.
+func hasListingOptions(c *gin.Context) bool {
+	return isPaginated(c) || onlyArtifacts(c) || wantsJSONListing(c)
+}
+
+// wantsJSONListing reports whether the client asked for a machine-readable
+// listing with ?format=json or Accept: application/json.
+func wantsJSONListing(c *gin.Context) bool {
+	return c.Query("format") == "json" || strings.Contains(c.GetHeader("Accept"), "application/json")
+}
+
+// listingEntry is one element of a JSON directory listing.
+type listingEntry struct {
+	Name    string    `json:"name"`
+	IsDir   bool      `json:"isDir"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// jsonListing is a JSON directory listing. Total counts every entry of the
+// directory; Truncated is set when MAVEN_LISTING_MAX_ENTRIES cut Entries short.
+type jsonListing struct {
+	Entries   []listingEntry `json:"entries"`
+	Total     int            `json:"total"`
+	Truncated bool           `json:"truncated"`
+}
+
+func onlyArtifacts(c *gin.Context) bool {
+	return c.Query("onlyArtifacts") == "true"
+}
+
+// artifactEntries keeps directories and primary artifacts, dropping checksum,
+// signature and metadata files.
+func artifactEntries(entries []storage.Entry) []storage.Entry {
+	filtered := make([]storage.Entry, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir && (isSidecar(e.Name) || strings.HasPrefix(e.Name, "maven-metadata")) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// visibleEntries drops server bookkeeping files and leaked Maven resolution
+// markers from a directory listing.
+func visibleEntries(entries []storage.Entry) []storage.Entry {
+	visible := make([]storage.Entry, 0, len(entries))
+	for _, e := range entries {
+		if storage.IsInternal(e.Name) || storage.IsResolutionMarker(e.Name) {
+			continue
+		}
+		visible = append(visible, e)
+	}
+	return visible
+}
+
+// sortEntries orders a listing with directories first, then files, each
+// alphabetically.
+func sortEntries(entries []storage.Entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].IsDir != entries[j].IsDir {
+			return entries[i].IsDir
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// humanSize renders a byte count with binary units, e.g. 1.5 KB.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 3; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %s", float64(n)/float64(div), []string{"KB", "MB", "GB", "TB"}[exp])
+}
+
+// redirectToDirectory answers a directory request whose URL lacks the trailing
+// slash with a 301 to the slash-terminated URL, so the relative links in the
+// listing resolve below the directory instead of next to it. The Location is
+// relative too, so it stays correct behind a proxy that rewrites the prefix.
+// JSON listings carry no links and are served as they are.
+func redirectToDirectory(c *gin.Context) bool {
+	urlPath := c.Request.URL.EscapedPath()
+	if strings.HasSuffix(urlPath, "/") || wantsJSONListing(c) {
+		return false
+	}
+	target := urlPath[strings.LastIndex(urlPath, "/")+1:] + "/"
+	if c.Request.URL.RawQuery != "" {
+		target += "?" + c.Request.URL.RawQuery
+	}
+	// Set directly: c.Redirect would resolve it against the request path
+	c.Header("Location", target)
+	c.Status(http.StatusMovedPermanently)
+	return true
+}
+
+// serveCachedListing writes a previously rendered listing for path, if any.
+func (h *MavenHandler) serveCachedListing(c *gin.Context, path string) bool {
+	if hasListingOptions(c) {
+		return false
+	}
+	body, ok := h.listings.get(path)
+	if ok {
+		c.Data(http.StatusOK, "text/html", body)
+	}
+	return ok
+}
+
+// renderListing writes an HTML directory index for path and caches it.
+// Listings longer than the configured maximum are truncated with a notice.
+// Listings shaped by query options (?onlyArtifacts=true, ?offset=&limit=) are
+// neither cached nor served from cache, and neither is a listing rendered
+// with an empty path. JSON listings carry the same entries along with the
+// untruncated count, which is also sent in X-Total-Count.
+func (h *MavenHandler) renderListing(c *gin.Context, path, title string, entries []storage.Entry) {
+	sortEntries(entries)
+	if onlyArtifacts(c) {
+		entries = artifactEntries(entries)
+	}
+
+	// Pages are bounded by the maximum instead of being cut by it, so every
+	// entry stays reachable through the Link headers
+	total := len(entries)
+	truncated := false
+	if isPaginated(c) {
+		entries = paginate(c, entries, h.Config.ListingMaxEntries)
+	} else if h.Config.ListingMaxEntries > 0 && total > h.Config.ListingMaxEntries {
+		entries = entries[:h.Config.ListingMaxEntries]
+		truncated = true
+	}
+
+	if wantsJSONListing(c) {
+		listing := jsonListing{Entries: make([]listingEntry, 0, len(entries)), Total: total, Truncated: truncated}
+		for _, e := range entries {
+			listing.Entries = append(listing.Entries, listingEntry{Name: e.Name, IsDir: e.IsDir, Size: e.Size, ModTime: e.ModTime})
+		}
+		c.Header("X-Total-Count", strconv.Itoa(total))
+		c.JSON(http.StatusOK, listing)
+		return
+	}
+
+	var buf bytes.Buffer
+	writeListingHeader(&buf, c.Request.URL.Path, title)
+	for _, e := range entries {
+		writeListingEntry(&buf, e)
+	}
+	shown := len(entries)
+	if truncated {
+		writeListingFooter(&buf, shown, total)
+	} else {
+		writeListingFooter(&buf, shown, shown)
+	}
+
+	if path != "" && !hasListingOptions(c) {
+		h.listings.set(path, buf.Bytes())
+	}
+	c.Data(http.StatusOK, "text/html", buf.Bytes())
+}
+
+//go:embed templates/listing.html templates/listing.css
+var listingFS embed.FS
+
+// listingTemplates renders HTML directory listings in three parts, "header",
+// "entry" and "footer", so large directories can be streamed.
+var listingTemplates = template.Must(template.ParseFS(listingFS, "templates/listing.html"))
+
+// listingStyle is the stylesheet inlined into every listing, which keeps
+// listings self-contained behind any authentication or path prefix.
+var listingStyle = func() template.CSS {
+	css, err := listingFS.ReadFile("templates/listing.css")
+	if err != nil {
+		panic(err)
+	}
+	return template.CSS(css)
+}()
+
+// breadcrumb is one link of a listing's navigation path.
+type breadcrumb struct {
+	Name string
+	Href string
+}
+
+// breadcrumbs links every directory above and including urlPath. The links are
+// relative, like those of the entries, so they survive a proxy that rewrites
+// the path prefix.
+func breadcrumbs(urlPath string) []breadcrumb {
+	var segments []string
+	for _, segment := range strings.Split(urlPath, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	crumbs := make([]breadcrumb, 0, len(segments)+1)
+	crumbs = append(crumbs, breadcrumb{Name: "/", Href: upLinks(len(segments))})
+	for i, segment := range segments {
+		crumbs = append(crumbs, breadcrumb{Name: segment, Href: upLinks(len(segments) - 1 - i)})
+	}
+	return crumbs
+}
+
+// upLinks returns a relative link n directories up from a listing.
+func upLinks(n int) string {
+	if n == 0 {
+		return "./"
+	}
+	return strings.Repeat("../", n)
+}
+
+// writeListingHeader starts the listing of the directory at urlPath.
+func writeListingHeader(w io.Writer, urlPath, title string) {
+	listingTemplates.ExecuteTemplate(w, "header", struct {
+		Title       string
+		Style       template.CSS
+		Breadcrumbs []breadcrumb
+	}{title, listingStyle, breadcrumbs(urlPath)})
+}
+
+// writeListingEntry writes one row of a listing. The template HTML-escapes the
+// name; the link is path-escaped first, so names containing "?", "#" or "%"
+// still lead to the entry itself.
+func writeListingEntry(w io.Writer, e storage.Entry) {
+	row := struct {
+		Name, Href, Size, Modified string
+	}{Name: e.Name, Href: url.PathEscape(e.Name), Size: "-", Modified: "-"}
+	if e.IsDir {
+		row.Name += "/"
+		row.Href += "/"
+	} else {
+		row.Size = humanSize(e.Size)
+	}
+	if !e.ModTime.IsZero() {
+		row.Modified = e.ModTime.UTC().Format("2006-01-02 15:04")
+	}
+	listingTemplates.ExecuteTemplate(w, "entry", row)
+}
+
+// writeListingFooter closes a listing of shown out of total entries.
+func writeListingFooter(w io.Writer, shown, total int) {
+	listingTemplates.ExecuteTemplate(w, "footer", struct{ Shown, Total int }{shown, total})
+}
+
+// listingBatchSize is how many entries of a directory are read at a time.
+// Directories with more are streamed rather than sorted in memory.
+var listingBatchSize = 1000
+
+// listDirectory reads the directory at path for HandleDownload. A plain HTML
+// listing of a directory larger than one batch is written to the client as it
+// is read, in storage order, keeping memory bounded however large the
+// directory; streamed is then set. Anything else is returned whole for
+// renderListing: small directories, and listings that need every entry first
+// (JSON, pagination, filters, the listing cache, or a redirect to the
+// slash-terminated URL). entries is nil when path is not a directory.
+func (h *MavenHandler) listDirectory(c *gin.Context, path string) (entries []storage.Entry, streamed bool, err error) {
+	if hasListingOptions(c) || h.listings.ttl > 0 || !strings.HasSuffix(c.Request.URL.Path, "/") {
+		entries, err = h.Store.List(path)
+		return entries, false, err
+	}
+
+	shown, total := 0, 0
+	write := func(batch []storage.Entry) {
+		for _, e := range batch {
+			total++
+			if h.Config.ListingMaxEntries <= 0 || shown < h.Config.ListingMaxEntries {
+				writeListingEntry(c.Writer, e)
+				shown++
+			}
+		}
+		c.Writer.Flush()
+	}
+
+	var first []storage.Entry
+	found, err := storage.ListStream(h.Store, path, listingBatchSize, func(batch []storage.Entry) error {
+		batch = visibleEntries(batch)
+		if !streamed && first == nil {
+			first = batch
+			return nil
+		}
+		if !streamed {
+			streamed = true
+			c.Header("Content-Type", "text/html")
+			c.Status(http.StatusOK)
+			writeListingHeader(c.Writer, c.Request.URL.Path, "/"+path)
+			write(first)
+			first = nil
+		}
+		write(batch)
+		return nil
+	})
+	if streamed {
+		if err != nil {
+			// Too late for an error status; the listing just ends early
+			log.Printf("Directory listing of %s failed: %v\n", path, err)
+		}
+		writeListingFooter(c.Writer, shown, total)
+		return nil, true, nil
+	}
+	if err != nil || !found {
+		return nil, false, err
+	}
+	if first == nil {
+		// Non-nil even when empty so an empty directory is still a directory
+		first = []storage.Entry{}
+	}
+	return first, false, nil
+}
+
+// InvalidateListing drops the cached listings a change to path can affect,
+// for writers outside the handler such as the cleanup services.
+func (h *MavenHandler) InvalidateListing(path string) {
+	h.listings.invalidate(path)
+}
+
+// HandleInvalidateListing drops cached listings for ?path= (all when empty).
+func (h *MavenHandler) HandleInvalidateListing(c *gin.Context) {
+	path := c.Query("path")
+	h.listings.invalidate(path)
+	c.JSON(http.StatusOK, gin.H{"invalidated": listingKey(path)})
+}
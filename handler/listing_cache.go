@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const aggregatePrefix = "repository/maven-public"
+
+type cachedListing struct {
+	body    []byte
+	expires time.Time
+}
+
+// listingCache keeps rendered directory listings keyed by storage path. A zero
+// TTL disables it.
+type listingCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedListing
+}
+
+func newListingCache(ttl time.Duration) *listingCache {
+	return &listingCache{
+		ttl:     ttl,
+		entries: make(map[string]cachedListing),
+	}
+}
+
+func listingKey(path string) string {
+	return strings.Trim(path, "/")
+}
+
+func (lc *listingCache) get(path string) ([]byte, bool) {
+	if lc == nil || lc.ttl <= 0 {
+		return nil, false
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	entry, ok := lc.entries[listingKey(path)]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (lc *listingCache) set(path string, body []byte) {
+	if lc == nil || lc.ttl <= 0 {
+		return
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	lc.entries[listingKey(path)] = cachedListing{body: body, expires: time.Now().Add(lc.ttl)}
+}
+
+func (lc *listingCache) size() int {
+	if lc == nil {
+		return 0
+	}
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	return len(lc.entries)
+}
+
+// invalidate drops the listings a write to path can affect: every ancestor
+// directory (new entries may have appeared) and everything below it (a
+// directory may have been removed). Writes to a repository also invalidate the
+// matching maven-public aggregate listings. An empty path clears the cache.
+func (lc *listingCache) invalidate(path string) {
+	if lc == nil {
+		return
+	}
+	key := listingKey(path)
+	keys := []string{key}
+	if parts := strings.SplitN(key, "/", 3); len(parts) == 3 && parts[0] == "repository" {
+		keys = append(keys, aggregatePrefix+"/"+parts[2])
+	}
+
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+	if key == "" {
+		lc.entries = make(map[string]cachedListing)
+		return
+	}
+	for cached := range lc.entries {
+		for _, k := range keys {
+			if cached == "" || k == cached || strings.HasPrefix(k, cached+"/") || strings.HasPrefix(cached, k+"/") {
+				delete(lc.entries, cached)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func TestListing_Truncation(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ListingMaxEntries: 3})
+	for i := 0; i < 5; i++ {
+		doRequest(r, http.MethodPut, fmt.Sprintf("/repository/releases/com/example/app/1.%d/app-1.%d.jar", i, i), "jar")
+	}
+
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if got := strings.Count(body, `<tr class="entry">`) - 1; got != 3 { // minus the ../ entry
+		t.Errorf("expected 3 entries, got %d: %s", got, body)
+	}
+	if !strings.Contains(body, "showing 3 of 5 entries") {
+		t.Errorf("expected truncation notice: %s", body)
+	}
+
+	w = doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/", "")
+	if strings.Contains(w.Body.String(), "truncated") {
+		t.Errorf("unexpected truncation notice for short listing: %s", w.Body.String())
+	}
+
+	var listing jsonListing
+	w = doRequest(r, http.MethodGet, "/repository/releases/com/example/app/?format=json", "")
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Entries) != 3 || listing.Total != 5 || !listing.Truncated {
+		t.Errorf("expected a truncated JSON listing of 3 of 5 entries, got %+v", listing)
+	}
+}
+
+func TestListing_Cache(t *testing.T) {
+	r, h, base := newTestRouter(t, &config.Config{ListingCacheTTL: "1m"})
+	r.POST("/admin/cache/invalidate-listing", h.HandleInvalidateListing)
+	dir := "/repository/releases/com/example/app/"
+	doRequest(r, http.MethodPut, dir+"1.0/app-1.0.jar", "jar")
+
+	if body := doRequest(r, http.MethodGet, dir, "").Body.String(); !strings.Contains(body, "1.0/") {
+		t.Fatalf("expected 1.0 in listing: %s", body)
+	}
+
+	// Changes made behind the server's back are not seen while cached
+	if err := os.MkdirAll(filepath.Join(base, "repository/releases/com/example/app/0.9"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if body := doRequest(r, http.MethodGet, dir, "").Body.String(); strings.Contains(body, "0.9/") {
+		t.Fatalf("expected cached listing, got fresh one: %s", body)
+	}
+
+	// An upload into the directory invalidates it
+	doRequest(r, http.MethodPut, dir+"2.0/app-2.0.jar", "jar")
+	body := doRequest(r, http.MethodGet, dir, "").Body.String()
+	if !strings.Contains(body, "0.9/") || !strings.Contains(body, "2.0/") {
+		t.Fatalf("expected listing to be refreshed after upload: %s", body)
+	}
+
+	// Manual invalidation
+	if err := os.MkdirAll(filepath.Join(base, "repository/releases/com/example/app/0.8"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	doRequest(r, http.MethodPost, "/admin/cache/invalidate-listing?path=repository/releases/com/example/app", "")
+	if body := doRequest(r, http.MethodGet, dir, "").Body.String(); !strings.Contains(body, "0.8/") {
+		t.Fatalf("expected listing to be refreshed after manual invalidation: %s", body)
+	}
+}
+
+func TestListing_PaginationLinks(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+	for i := 0; i < 5; i++ {
+		doRequest(r, http.MethodPut, fmt.Sprintf("/repository/releases/com/example/app/1.%d/app-1.%d.jar", i, i), "jar")
+	}
+	dir := "/repository/releases/com/example/app/"
+
+	w := doRequest(r, http.MethodGet, dir+"?offset=2&limit=2", "")
+	body := w.Body.String()
+	if !strings.Contains(body, "1.2/") || !strings.Contains(body, "1.3/") || strings.Contains(body, "1.1/") || strings.Contains(body, "1.4/") {
+		t.Fatalf("unexpected page contents: %s", body)
+	}
+	link := w.Header().Get("Link")
+	for _, want := range []string{
+		`<` + dir + `?limit=2&offset=0>; rel="first"`,
+		`<` + dir + `?limit=2&offset=0>; rel="prev"`,
+		`<` + dir + `?limit=2&offset=4>; rel="next"`,
+		`<` + dir + `?limit=2&offset=4>; rel="last"`,
+	} {
+		if !strings.Contains(link, want) {
+			t.Errorf("Link header %q missing %q", link, want)
+		}
+	}
+
+	w = doRequest(r, http.MethodGet, dir+"?offset=4&limit=2", "")
+	link = w.Header().Get("Link")
+	if strings.Contains(link, `rel="next"`) {
+		t.Errorf("last page should not link to next: %q", link)
+	}
+	if !strings.Contains(link, `rel="prev"`) || !strings.Contains(link, `rel="last"`) {
+		t.Errorf("last page missing prev/last links: %q", link)
+	}
+}
+
+func TestListing_CacheInvalidatedByGeneratedChecksum(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ListingCacheTTL: "1m"})
+	dir := "/repository/releases/com/example/app/1.0/"
+	doRequest(r, http.MethodPut, dir+"app-1.0.jar", "jar")
+
+	if body := doRequest(r, http.MethodGet, dir, "").Body.String(); strings.Contains(body, "app-1.0.jar.sha1") {
+		t.Fatalf("expected no checksum before it is requested: %s", body)
+	}
+	if w := doRequest(r, http.MethodGet, dir+"app-1.0.jar.sha1", ""); w.Code != http.StatusOK {
+		t.Fatalf("expected a generated checksum, got %d", w.Code)
+	}
+	if body := doRequest(r, http.MethodGet, dir, "").Body.String(); !strings.Contains(body, "app-1.0.jar.sha1") {
+		t.Errorf("expected the generated checksum in the listing: %s", body)
+	}
+}
+
+func TestListing_PaginationWithinMaxEntries(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ListingMaxEntries: 2})
+	for i := 0; i < 5; i++ {
+		doRequest(r, http.MethodPut, fmt.Sprintf("/repository/releases/com/example/app/1.%d/app-1.%d.jar", i, i), "jar")
+	}
+	dir := "/repository/releases/com/example/app/"
+
+	// The page size is clamped to the maximum and the total stays exact
+	w := doRequest(r, http.MethodGet, dir+"?format=json&offset=0&limit=10", "")
+	var listing jsonListing
+	if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+		t.Fatal(err)
+	}
+	if len(listing.Entries) != 2 || listing.Total != 5 || listing.Truncated || w.Header().Get("X-Total-Count") != "5" {
+		t.Fatalf("expected 2 of 5 entries, got %+v", listing)
+	}
+	if link := w.Header().Get("Link"); !strings.Contains(link, `offset=2>; rel="next"`) {
+		t.Errorf("next page should start after the first two entries: %q", link)
+	}
+
+	// Every entry is reachable, and pages carry no truncation notice
+	body := doRequest(r, http.MethodGet, dir+"?offset=4", "").Body.String()
+	if !strings.Contains(body, "1.4/") || strings.Contains(body, "truncated") {
+		t.Errorf("unexpected last page: %s", body)
+	}
+}
+
+func TestListing_OnlyArtifacts(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{GenerateChecksums: true})
+	dir := "/repository/releases/com/example/app/1.0/"
+	doRequest(r, http.MethodPut, dir+"app-1.0.jar", "jar")
+	doRequest(r, http.MethodPut, dir+"app-1.0.jar.asc", "sig")
+	doRequest(r, http.MethodPut, dir+"app-1.0.pom", "pom")
+
+	body := doRequest(r, http.MethodGet, dir, "").Body.String()
+	for _, name := range []string{"app-1.0.jar.sha1", "app-1.0.jar.md5", "app-1.0.jar.asc"} {
+		if !strings.Contains(body, name) {
+			t.Errorf("expected %s in default listing: %s", name, body)
+		}
+	}
+
+	body = doRequest(r, http.MethodGet, dir+"?onlyArtifacts=true", "").Body.String()
+	for _, name := range []string{".sha1", ".md5", ".asc"} {
+		if strings.Contains(body, name) {
+			t.Errorf("expected %s files to be filtered: %s", name, body)
+		}
+	}
+	if !strings.Contains(body, "app-1.0.jar") || !strings.Contains(body, "app-1.0.pom") {
+		t.Errorf("expected primary artifacts to remain: %s", body)
+	}
+}
+
+func TestListing_JSON(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ListingCacheTTL: "1m"})
+	dir := "/repository/releases/com/example/app/"
+	doRequest(r, http.MethodPut, dir+"1.0/app-1.0.jar", "jar")
+	doRequest(r, http.MethodPut, dir+"notes.txt", "hello")
+
+	// Prime the HTML cache; JSON requests must not be answered from it
+	if w := doRequest(r, http.MethodGet, dir, ""); !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("HTML should stay the default, got %q", w.Header().Get("Content-Type"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, dir, nil)
+	req.Header.Set("Accept", "application/json")
+	byHeader := httptest.NewRecorder()
+	r.ServeHTTP(byHeader, req)
+	byQuery := doRequest(r, http.MethodGet, dir+"?format=json", "")
+
+	for _, w := range []*httptest.ResponseRecorder{byHeader, byQuery} {
+		var listing jsonListing
+		if err := json.Unmarshal(w.Body.Bytes(), &listing); err != nil {
+			t.Fatalf("invalid JSON listing %q: %v", w.Body.String(), err)
+		}
+		if len(listing.Entries) != 2 || listing.Total != 2 || listing.Truncated || w.Header().Get("X-Total-Count") != "2" {
+			t.Fatalf("unexpected listing %+v", listing)
+		}
+		for _, e := range listing.Entries {
+			switch e.Name {
+			case "1.0":
+				if !e.IsDir {
+					t.Errorf("1.0 should be a directory: %+v", e)
+				}
+			case "notes.txt":
+				if e.IsDir || e.Size != 5 || e.ModTime.IsZero() {
+					t.Errorf("unexpected file entry %+v", e)
+				}
+			default:
+				t.Errorf("unexpected entry %+v", e)
+			}
+		}
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	entries := []storage.Entry{
+		{Name: "b.jar"},
+		{Name: "2.0", IsDir: true},
+		{Name: "a.pom"},
+		{Name: "maven-metadata.xml"},
+		{Name: "1.0", IsDir: true},
+		{Name: "10.0", IsDir: true},
+	}
+	sortEntries(entries)
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	want := "1.0,10.0,2.0,a.pom,b.jar,maven-metadata.xml"
+	if got := strings.Join(names, ","); got != want {
+		t.Errorf("sorted listing = %s, want %s", got, want)
+	}
+}
+
+func TestHumanSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:                "0 B",
+		1023:             "1023 B",
+		1536:             "1.5 KB",
+		5 * 1024 * 1024:  "5.0 MB",
+		3 << 30:          "3.0 GB",
+		2048 * (1 << 40): "2048.0 TB",
+	} {
+		if got := humanSize(n); got != want {
+			t.Errorf("humanSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestListing_AggregateSorted(t *testing.T) {
+	r, h, _ := newTestRouter(t, &config.Config{})
+	r.GET("/aggregate/*path", h.HandleAggregateDownload("repository"))
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/zeta.txt", "z")
+	doRequest(r, http.MethodPut, "/repository/develop/com/example/alpha.txt", "a")
+	doRequest(r, http.MethodPut, "/repository/develop/com/example/lib/1.0/lib-1.0.jar", "x")
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "x")
+
+	body := doRequest(r, http.MethodGet, "/aggregate/com/example/", "").Body.String()
+	order := []string{`"app/"`, `"lib/"`, `"alpha.txt"`, `"zeta.txt"`}
+	last := -1
+	for _, name := range order {
+		i := strings.Index(body, name)
+		if i <= last {
+			t.Fatalf("expected order %v in %s", order, body)
+		}
+		last = i
+	}
+	if !strings.Contains(body, `<td class="size">1 B</td>`) {
+		t.Errorf("expected human-readable file sizes: %s", body)
+	}
+}
+
+func TestListing_AggregateRedirectsToSlash(t *testing.T) {
+	r, h, _ := newTestRouter(t, &config.Config{ListingCacheTTL: "1m"})
+	r.GET("/aggregate/*path", h.HandleAggregateDownload("repository"))
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "x")
+
+	w := doRequest(r, http.MethodGet, "/aggregate/com/example/", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Index of /aggregate/com/example/ (Aggregated)") {
+		t.Fatalf("unexpected listing %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `<a href="app/">`) {
+		t.Errorf("expected relative child links: %s", w.Body.String())
+	}
+
+	// Also once the listing is cached
+	for target, location := range map[string]string{
+		"/aggregate/com/example":          "example/",
+		"/aggregate/com":                  "com/",
+		"/aggregate/com/example?limit=10": "example/?limit=10",
+	} {
+		w := doRequest(r, http.MethodGet, target, "")
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != location {
+			t.Errorf("%s: expected 301 to %s, got %d %q", target, location, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	if w := doRequest(r, http.MethodGet, "/aggregate/com/example?format=json", ""); w.Code != http.StatusOK {
+		t.Errorf("JSON listings should not redirect, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/aggregate/com/example/app/1.0/app-1.0.jar", ""); w.Code != http.StatusOK {
+		t.Errorf("file download: expected 200, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/aggregate/com/example/app/1.0/app-1.0.jar/", ""); w.Code != http.StatusNotFound {
+		t.Errorf("file with trailing slash: expected 404, got %d", w.Code)
+	}
+}
+
+func TestHandleDownload_TrailingSlash(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ListingCacheTTL: "1m"})
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "x")
+
+	for target, want := range map[string]int{
+		"/repository/releases/com/example/":                     http.StatusOK,
+		"/repository/releases/com/example":                      http.StatusMovedPermanently,
+		"/repository/releases/com/example?format=json":          http.StatusOK,
+		"/repository/releases/com/example/app/1.0/app-1.0.jar":  http.StatusOK,
+		"/repository/releases/com/example/app/1.0/app-1.0.jar/": http.StatusNotFound,
+	} {
+		w := doRequest(r, http.MethodGet, target, "")
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", target, want, w.Code)
+		}
+		if want == http.StatusMovedPermanently && w.Header().Get("Location") != "example/" {
+			t.Errorf("%s: unexpected Location %q", target, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestListing_StreamsLargeDirectories(t *testing.T) {
+	defer func(n int) { listingBatchSize = n }(listingBatchSize)
+	listingBatchSize = 2
+
+	r, h, _ := newTestRouter(t, &config.Config{})
+	for i := 0; i < 5; i++ {
+		doRequest(r, http.MethodPut, fmt.Sprintf("/repository/releases/com/example/app/1.%d/app-1.%d.jar", i, i), "jar")
+	}
+
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/html" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for i := 0; i < 5; i++ {
+		if !strings.Contains(body, fmt.Sprintf(`<a href="1.%d/">`, i)) {
+			t.Errorf("missing 1.%d in %s", i, body)
+		}
+	}
+	if !strings.HasPrefix(body, "<!DOCTYPE html>") || !strings.Contains(body, "<h1>Index of /repository/releases/com/example/app/</h1>") || !strings.HasSuffix(body, "</body></html>") {
+		t.Errorf("incomplete listing %s", body)
+	}
+
+	h.Config.ListingMaxEntries = 3
+	body = doRequest(r, http.MethodGet, "/repository/releases/com/example/app/", "").Body.String()
+	if got := strings.Count(body, `<tr class="entry">`) - 1; got != 3 {
+		t.Errorf("expected 3 entries, got %d: %s", got, body)
+	}
+	if !strings.Contains(body, "showing 3 of 5 entries") {
+		t.Errorf("expected truncation notice: %s", body)
+	}
+}
+
+func TestBreadcrumbs(t *testing.T) {
+	got := breadcrumbs("/repository/releases/com/")
+	want := []breadcrumb{
+		{"/", "../../../"},
+		{"repository", "../../"},
+		{"releases", "../"},
+		{"com", "./"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("breadcrumbs = %+v, want %+v", got, want)
+	}
+}
+
+func TestListing_EscapesNames(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+	// <img src=x onerror=alert(1)>.jar and a?b#c.jar
+	for _, name := range []string{"%3Cimg%20src=x%20onerror=alert(1)%3E.jar", "a%3Fb%23c.jar"} {
+		if w := doRequest(r, http.MethodPut, "/repository/releases/com/example/"+name, "x"); w.Code != http.StatusCreated {
+			t.Fatalf("upload of %s: expected 201, got %d", name, w.Code)
+		}
+	}
+
+	for _, target := range []string{"/repository/releases/com/example/", "/repository/releases/com/example/?onlyArtifacts=true"} {
+		body := doRequest(r, http.MethodGet, target, "").Body.String()
+		if strings.Contains(body, "<img") {
+			t.Fatalf("%s: unescaped entry name in listing: %s", target, body)
+		}
+		for _, want := range []string{
+			`&lt;img src=x onerror=alert(1)&gt;.jar</a>`,
+			`href="%3Cimg%20src=x%20onerror=alert%281%29%3E.jar"`,
+			`href="a%3Fb%23c.jar"`,
+			`>a?b#c.jar</a>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("%s: expected %s in %s", target, want, body)
+			}
+		}
+	}
+
+	// The escaped link leads back to the file
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/a%3Fb%23c.jar", ""); w.Code != http.StatusOK || w.Body.String() != "x" {
+		t.Errorf("following the escaped link: got %d %q", w.Code, w.Body.String())
+	}
+}
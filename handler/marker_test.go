@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestResolutionMarkers_NotStoredOrServed(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	dir := "repository/releases/com/example/app/1.0/"
+	for _, name := range []string{"app-1.0.jar.lastUpdated", "_remote.repositories"} {
+		if w := doRequest(r, http.MethodPut, "/"+dir+name, "#NOTE: This is a Maven Resolver internal file"); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: expected 400, got %d", name, w.Code)
+		}
+		if _, err := os.Stat(filepath.Join(base, dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was stored, stat err: %v", name, err)
+		}
+	}
+
+	doRequest(r, http.MethodPut, "/"+dir+"app-1.0.jar", "jar")
+	// Markers that reached the disk some other way stay invisible
+	for _, name := range []string{"app-1.0.pom.lastUpdated", "_remote.repositories"} {
+		if err := os.WriteFile(filepath.Join(base, dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if w := doRequest(r, http.MethodGet, "/"+dir+name, ""); w.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected 404, got %d", name, w.Code)
+		}
+		if w := doRequest(r, http.MethodHead, "/"+dir+name, ""); w.Code != http.StatusNotFound {
+			t.Errorf("HEAD %s: expected 404, got %d", name, w.Code)
+		}
+	}
+
+	body := doRequest(r, http.MethodGet, "/"+dir, "").Body.String()
+	if !strings.Contains(body, "app-1.0.jar") {
+		t.Errorf("listing misses the artifact: %s", body)
+	}
+	if strings.Contains(body, ".lastUpdated") || strings.Contains(body, "_remote.repositories") {
+		t.Errorf("listing exposes resolution markers: %s", body)
+	}
+}
+
+func TestHandleUpload_RejectsInternalFiles(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	dir := "repository/releases/com/example/app/1.0/"
+	for _, name := range []string{"app-1.0.jar.pin", "app-1.0.jar.provenance.json", "app-1.0.jar.complete", "app-1.0.jar.access", ".keep"} {
+		if w := doRequest(r, http.MethodPut, "/"+dir+name, "forged"); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: expected 400, got %d", name, w.Code)
+		}
+		if _, err := os.Stat(filepath.Join(base, dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was stored, stat err: %v", name, err)
+		}
+	}
+}
+
+func TestHandleDownload_HidesInternalFiles(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	dir := "repository/releases/com/example/app/1.0/"
+	doRequest(r, http.MethodPut, "/"+dir+"app-1.0.jar", "jar")
+	for _, name := range []string{"app-1.0.jar.pin", "app-1.0.jar.provenance.json", "app-1.0.jar.tags.json", "app-1.0.jar.lock", "app-1.0.jar.access"} {
+		if err := os.WriteFile(filepath.Join(base, dir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, method := range []string{http.MethodGet, http.MethodHead} {
+			if w := doRequest(r, method, "/"+dir+name, ""); w.Code != http.StatusNotFound {
+				t.Errorf("%s %s: expected 404, got %d", method, name, w.Code)
+			}
+		}
+	}
+	if w := doRequest(r, http.MethodHead, "/"+dir+"app-1.0.jar", ""); w.Code != http.StatusOK {
+		t.Errorf("HEAD artifact: expected 200, got %d", w.Code)
+	}
+}
+
+func TestAggregate_HidesInternalFiles(t *testing.T) {
+	r, h, base := newTestRouter(t, &config.Config{})
+	r.GET("/aggregate/*path", h.HandleAggregateDownload("repository"))
+	r.HEAD("/aggregate/*path", h.HandleAggregateHead("repository"))
+
+	dir := "com/example/app/1.0/"
+	doRequest(r, http.MethodPut, "/repository/releases/"+dir+"app-1.0.jar", "jar")
+	name := "app-1.0.jar.provenance.json"
+	if err := os.WriteFile(filepath.Join(base, "repository/releases", dir, name), []byte(`{"user":"alice"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if w := doRequest(r, method, "/aggregate/"+dir+name, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s %s: expected 404, got %d", method, name, w.Code)
+		}
+	}
+	if w := doRequest(r, http.MethodGet, "/aggregate/"+dir+"app-1.0.jar", ""); w.Code != http.StatusOK {
+		t.Errorf("artifact: expected 200, got %d", w.Code)
+	}
+}
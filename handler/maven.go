@@ -1,32 +1,76 @@
 package handler
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"maven_repo/auth"
 	"maven_repo/config"
+	"maven_repo/logger"
+	"maven_repo/service"
 	"maven_repo/storage"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/sync/singleflight"
 )
 
 type MavenHandler struct {
-	Store  storage.StorageProvider
-	Config *config.Config
-	Client *http.Client
+	Store    storage.StorageProvider
+	Config   *config.Config
+	Client   *http.Client
+	Metadata *service.MetadataService
+	listings *listingCache
+	tagsMu   sync.Mutex
+	// proxySlots limits concurrent upstream fetches; nil means unlimited
+	proxySlots       chan struct{}
+	prewarm          prewarmState
+	upstreamListings *upstreamListingCache
+	negative         *negativeCache
+	heads            *headCache
+	// checksumMismatches counts served artifacts that failed verification
+	checksumMismatches atomic.Int64
+	digests            digestCache
+	uploads            inflightUploads
+	pendingSums        pendingChecksums
+	activeDownloads    atomic.Int64
+	access             accessTracker
+	// fetches collapses concurrent fetches of the same upstream artifact
+	fetches singleflight.Group
+	stats   statsCache
 }
 
 func NewMavenHandler(store storage.StorageProvider, cfg *config.Config) *MavenHandler {
-	return &MavenHandler{
-		Store:  store,
-		Config: cfg,
-		Client: &http.Client{},
+	listingTTL, _ := time.ParseDuration(cfg.ListingCacheTTL)
+	upstreamListingTTL, _ := time.ParseDuration(cfg.ProxyListingCacheTTL)
+	negativeTTL, _ := time.ParseDuration(cfg.NegativeCacheTTL)
+	headTTL, _ := time.ParseDuration(cfg.ProxyHeadCacheTTL)
+	h := &MavenHandler{
+		Store:            store,
+		Config:           cfg,
+		Client:           newProxyClient(cfg),
+		Metadata:         service.NewMetadataService(store, cfg),
+		listings:         newListingCache(listingTTL),
+		upstreamListings: newUpstreamListingCache(upstreamListingTTL),
+		negative:         newNegativeCache(negativeTTL),
+		heads:            newHeadCache(headTTL),
 	}
+	if cfg.ProxyMaxConcurrency > 0 {
+		h.proxySlots = make(chan struct{}, cfg.ProxyMaxConcurrency)
+	}
+	return h
 }
 
 func (h *MavenHandler) HandleDownload(c *gin.Context) {
+	h.activeDownloads.Add(1)
+	defer h.activeDownloads.Add(-1)
 	path := strings.TrimPrefix(c.Request.URL.Path, "/")
 
 	// Check if this is a directory listing request
@@ -36,111 +80,82 @@ func (h *MavenHandler) HandleDownload(c *gin.Context) {
 	// Let's assume file first, then directory if file fails?
 	// Or check List.
 
+	// A trailing slash names a directory: listings are only rendered there,
+	// and files are never served there
+	dirRequest := strings.HasSuffix(c.Request.URL.Path, "/")
+	if dirRequest && h.serveCachedListing(c, path) {
+		return
+	}
+
 	// Try to list first. If it returns entries, it's a directory.
-	entries, err := h.Store.List(path)
+	entries, streamed, err := h.listDirectory(c, path)
+	if streamed || rejectInvalidPath(c, err) {
+		return
+	}
 	if err == nil && entries != nil {
-		// It is a directory, verify it's not empty or just treat as dir
-		// Render HTML
-		c.Header("Content-Type", "text/html")
-		c.Writer.WriteHeader(http.StatusOK)
-		fmt.Fprintf(c.Writer, "<html><body><h1>Index of /%s</h1><hr><ul>", path)
-		fmt.Fprintf(c.Writer, "<li><a href=\"../\">../</a></li>")
-		for _, e := range entries {
-			slash := ""
-			if e.IsDir {
-				slash = "/"
-			}
-			fmt.Fprintf(c.Writer, "<li><a href=\"%s%s\">%s%s</a> (Size: %d)</li>", e.Name, slash, e.Name, slash, e.Size)
+		if redirectToDirectory(c) {
+			return
 		}
-		fmt.Fprintf(c.Writer, "</ul><hr></body></html>")
+		h.renderListing(c, path, "/"+path, visibleEntries(entries))
 		return
 	}
 
-	// If not directory, try file
-	reader, found, err := h.Store.Get(path)
-	if err == nil && found {
-		defer reader.Close()
-		c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, nil)
+	if storage.IsInternal(path) || storage.IsResolutionMarker(path) {
+		c.Status(http.StatusNotFound)
 		return
 	}
 
-	// Not found locally, try proxy
-	if len(h.Config.ProxyURLs) > 0 {
-		// We need to strip the local repository path prefix (e.g. repository/develop/)
-		// to get the actual artifact path (com/...)
-		// The path variable currently is "repository/develop/com/..." or "public/com/..."
-		// We assume the upstream is a root maven repo.
-		// We can try to split by "/" and ignore first 2 parts if it starts with "repository/"?
-		// Or ignore first 1 part if "public/"?
-		// A cleaner way relies on us knowing the structure.
-		// Let's assume standard layout:
-		// If path starts with "repository/", skip 2 segments.
-		// If path starts with "public/", skip 1 segment.
-
-		parts := strings.Split(path, "/")
-		var artifactPath string
-		if strings.HasPrefix(path, "repository/") && len(parts) > 2 {
-			artifactPath = strings.Join(parts[2:], "/")
-		} else if strings.HasPrefix(path, "public/") && len(parts) > 1 {
-			artifactPath = strings.Join(parts[1:], "/")
-		} else {
-			// fallback, maybe it is direct?
-			artifactPath = path
-		}
-
-		for _, proxy := range h.Config.ProxyURLs {
-			url := strings.TrimRight(proxy, "/") + "/" + artifactPath
-			resp, err := h.Client.Get(url)
-			if err == nil && resp.StatusCode == http.StatusOK {
-				contentType := resp.Header.Get("Content-Type")
-				if strings.HasPrefix(contentType, "text/html") {
-					resp.Body.Close()
-					// If upstream returns HTML (directory listing), we don't want to cache it as a file.
-					// We treat this as not found (or effectively not a valid artifact download).
-					continue
-				}
-
-				defer resp.Body.Close()
-
-				// Cache it
-				// We need to stream to both Response and Storage
-				// io.TeeReader writes to a writer mainly...
-				// But TeeReader(r, w) returns a Reader. When we read from it, it writes to w.
-				// But Storage.Save takes a Reader.
-				// We can use Pipe.
-				pr, pw := io.Pipe()
-
-				go func() {
-					if err := h.Store.Save(path, pr); err != nil {
-						// log error?
-					}
-				}()
-
-				// Tee: Write to Pipe (which goes to storage) AND return reader for response
-				// wait, Save consumes reader fully.
-				// We accept request body -> Save.
-				// Here we have Response Body -> Save AND Response Writer.
-				// Gin DataFromReader consumes reader.
-				// So we want: Resp.Body -> Tee(PipeWriter) -> Gin Response
-				// and PipeReader -> Save.
-
-				tee := io.TeeReader(resp.Body, pw)
+	refresh, ok := h.refreshRequested(c, path)
+	if !ok {
+		return
+	}
 
-				// We need to ensure Pipe is closed when reading finishes
-				// But DataFromReader will read until EOF.
-				// After EOF, we close PW.
+	// If not directory, try file
+	if !dirRequest && !refresh {
+		reader, found, getErr := h.Store.Get(path)
+		if getErr == nil && found {
+			h.serveStored(c, path, reader)
+			return
+		}
+		if getErr == nil && h.serveGeneratedChecksum(c, path) {
+			return
+		}
+		err = getErr
+	}
 
-				// Actually, Save runs in goroutine reading from PipeReader.
-				// We read from TeeReader (which is Resp body + write to PipeWriter).
-				// When TeeReader is done, we close PipeWriter.
+	// Not found locally, try proxy
+	if len(h.Config.ProxyURLs) > 0 {
+		artifactPath := artifactPathForProxy(path)
+		if h.serveUpstreamListing(c, artifactPath, path, "/"+path) {
+			return
+		}
+		if dirRequest {
+			c.Status(http.StatusNotFound)
+			return
+		}
 
-				// Wrap tee to close pw on EOF
-				wrappedReader := &NotifyReader{Reader: tee, OnEOF: func() { pw.Close() }}
+		if h.negative.has(path) && !refresh {
+			c.Set(logger.CacheSourceKey, "negative")
+			c.Status(http.StatusNotFound)
+			return
+		}
 
-				c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, wrappedReader, nil)
-				return
+		if h.fetchFromProxies(c, artifactPath, path) {
+			if refresh && c.Writer.Status() == http.StatusOK {
+				h.dropCachedSidecars(path)
 			}
+			return
+		}
+	}
+
+	// No upstream has it any more, so keep serving the cached copy
+	if refresh {
+		reader, found, getErr := h.Store.Get(path)
+		if getErr == nil && found {
+			h.serveStored(c, path, reader)
+			return
 		}
+		err = getErr
 	}
 
 	if err != nil {
@@ -150,38 +165,48 @@ func (h *MavenHandler) HandleDownload(c *gin.Context) {
 	c.Status(http.StatusNotFound)
 }
 
-// NotifyReader closes pipe on completion
+// NotifyReader reports when the wrapped reader reaches EOF or fails
 type NotifyReader struct {
 	io.Reader
-	OnEOF func()
+	OnEOF   func()
+	OnError func(error)
 }
 
 func (n *NotifyReader) Read(p []byte) (int, error) {
 	read, err := n.Reader.Read(p)
 	if err == io.EOF {
 		n.OnEOF()
+	} else if err != nil && n.OnError != nil {
+		n.OnError(err)
 	}
 	return read, err
 }
 
 func (h *MavenHandler) HandleHead(c *gin.Context) {
 	path := strings.TrimPrefix(c.Request.URL.Path, "/")
+	if storage.IsInternal(path) || storage.IsResolutionMarker(path) {
+		c.Status(http.StatusNotFound)
+		return
+	}
 	found, err := h.Store.Head(path)
 	if err == nil && found {
 		c.Status(http.StatusOK)
 		return
 	}
+	if errors.Is(err, storage.ErrInvalidPath) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
 
-	// Try proxy
-	if len(h.Config.ProxyURLs) > 0 {
-		for _, proxy := range h.Config.ProxyURLs {
-			url := strings.TrimRight(proxy, "/") + "/" + path
-			resp, err := h.Client.Head(url)
-			if err == nil && resp.StatusCode == http.StatusOK {
-				c.Status(http.StatusOK)
-				return
-			}
-		}
+	// Try proxy, asking for the same upstream path a GET would fetch
+	head, ok, proxyErr := h.proxyHead(c, artifactPathForProxy(path))
+	if ok {
+		head.write(c)
+		return
+	}
+	if proxyErr != nil {
+		c.Status(http.StatusBadGateway)
+		return
 	}
 
 	if err != nil {
@@ -196,21 +221,244 @@ func (h *MavenHandler) HandleUpload(c *gin.Context) {
 
 	// Ensure body is closed
 	defer c.Request.Body.Close()
+	if !h.limitUpload(c) || !h.checkFreeSpace(c, path) {
+		return
+	}
+
+	// Metadata checksums are regenerated whenever the metadata is merged,
+	// so the client's copies would be stale.
+	if isMetadataChecksum(path) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.Status(http.StatusCreated)
+		return
+	}
+
+	// Bookkeeping files such as pins and provenance records are written by
+	// the server only
+	if storage.IsInternal(path) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is reserved for the server's own bookkeeping", path)})
+		return
+	}
+
+	// Maven's local resolution markers must never end up on the server
+	if storage.IsResolutionMarker(path) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s is a Maven resolution marker, not an artifact", path)})
+		return
+	}
+
+	if h.requiresUniqueSnapshots(c) && isNonUniqueSnapshot(path) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("repository %s only accepts timestamped snapshots, got %s", targetRepo(c), path)})
+		return
+	}
+
+	if !isMetadata(path) {
+		release, ok := h.claimUpload(c, path)
+		if !ok {
+			return
+		}
+		defer release()
+		if h.rejectRedeploy(c, path) {
+			return
+		}
+	}
+
+	upload, _, err := bufferUpload(c.Request.Body, c.Request.ContentLength, h.Config.UploadMemoryThreshold)
+	if rejectTooLarge(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+
+	if isMetadata(path) {
+		if err := h.Metadata.Update(path, upload); err != nil {
+			if rejectInvalidPath(c, err) || rejectTooLarge(c, err) {
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save metadata: %v", err)})
+			return
+		}
+		h.listings.invalidate(path)
+		c.Status(http.StatusCreated)
+		return
+	}
+
+	body, cleanup, ok := h.verifyPinnedUpload(c, path, upload)
+	defer cleanup()
+	if !ok {
+		return
+	}
+	if body, ok = h.verifyUploadedChecksum(c, path, body); !ok {
+		return
+	}
+	pending := h.pendingSums.take(path)
+	generate := h.shouldGenerateChecksums(c, path)
+	var sums *checksumWriter
+	if generate || len(pending) > 0 {
+		sums = newChecksumWriter()
+		body = io.TeeReader(body, sums)
+	}
 
-	if err := h.Store.Save(path, c.Request.Body); err != nil {
+	if err := h.Store.Save(path, body); err != nil {
+		if rejectInvalidPath(c, err) || rejectTooLarge(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save artifact: %v", err)})
 		return
 	}
+	if len(pending) > 0 && !h.verifyUploadedArtifact(c, path, pending, sums.Sums()) {
+		return
+	}
+	if generate {
+		if err := h.writeChecksums(path, sums.Sums()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to save checksums: %v", err)})
+			return
+		}
+	}
+	h.recordUploadProvenance(c, path)
+	h.recordUploadMetadata(path)
+	h.listings.invalidate(path)
+	h.negative.invalidate(path)
 
 	c.Status(http.StatusCreated)
 }
 
+func (h *MavenHandler) HandleDelete(c *gin.Context) {
+	path := strings.TrimPrefix(c.Request.URL.Path, "/")
+
+	locked, err := h.isDeleteProtected(path)
+	if rejectInvalidPath(c, err) {
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if locked {
+		c.JSON(http.StatusLocked, gin.H{"error": fmt.Sprintf("%s was modified less than %d minutes ago", path, h.Config.DeleteProtectionMinutes)})
+		return
+	}
+
+	if err := h.deleteArtifact(path); err != nil {
+		if rejectInvalidPath(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete artifact: %v", err)})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+type batchDeleteRequest struct {
+	Paths []string `json:"paths"`
+}
+
+// HandleBatchDelete removes several storage paths at once. If any of them is
+// still inside the deletion protection window the whole batch is rejected.
+func (h *MavenHandler) HandleBatchDelete(c *gin.Context) {
+	var req batchDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var locked []string
+	for _, p := range req.Paths {
+		isLocked, err := h.isDeleteProtected(strings.TrimPrefix(p, "/"))
+		if rejectInvalidPath(c, err) {
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if isLocked {
+			locked = append(locked, p)
+		}
+	}
+	if len(locked) > 0 {
+		c.JSON(http.StatusLocked, gin.H{"error": "some paths are inside the deletion protection window", "locked": locked})
+		return
+	}
+
+	deleted := []string{}
+	failed := map[string]string{}
+	for _, p := range req.Paths {
+		if err := h.deleteArtifact(strings.TrimPrefix(p, "/")); err != nil {
+			failed[p] = err.Error()
+			continue
+		}
+		deleted = append(deleted, p)
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted, "failed": failed})
+}
+
+// rejectInvalidPath answers 400 when err reports a path that resolves outside
+// the storage root, and tells the caller whether it did.
+func rejectInvalidPath(c *gin.Context, err error) bool {
+	if !errors.Is(err, storage.ErrInvalidPath) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	return true
+}
+
+// deleteArtifact removes path together with its checksum and bookkeeping sidecars.
+func (h *MavenHandler) deleteArtifact(path string) error {
+	defer h.listings.invalidate(path)
+	if err := h.Store.Delete(path); err != nil {
+		return err
+	}
+	for _, suffix := range append(sidecarExtensions, provenanceSuffix, tagsSuffix, storage.AccessSuffix) {
+		if err := h.Store.Delete(path + suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDeleteProtected reports whether path (or any file below it) was modified
+// within the configured deletion protection window.
+func (h *MavenHandler) isDeleteProtected(path string) (bool, error) {
+	if h.Config.DeleteProtectionMinutes <= 0 {
+		return false, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(h.Config.DeleteProtectionMinutes) * time.Minute)
+
+	locked := false
+	err := h.Store.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Missing paths are not protected
+		}
+		if !info.IsDir() && info.ModTime().After(cutoff) {
+			locked = true
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	return locked, err
+}
+
 func (h *MavenHandler) HandleAggregateDownload(basePath string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		h.activeDownloads.Add(1)
+		defer h.activeDownloads.Add(-1)
 		artifactPath := strings.TrimPrefix(c.Param("path"), "/")
 
-		// Discover repos in the base path (e.g., repository/)
-		repos := h.getAggregateRepos(basePath)
+		// Discover repos in the base path (e.g., repository/) that the user
+		// may read
+		repos, restricted := readableRepos(c, h.getAggregateRepos(basePath))
+
+		// Cached listings are only served at the slash-terminated URL; the
+		// other form is redirected below. They hold every repository, so
+		// restricted users neither read nor fill the cache.
+		if !restricted && strings.HasSuffix(c.Request.URL.Path, "/") && h.serveCachedListing(c, aggregatePrefix+"/"+artifactPath) {
+			return
+		}
 
 		// 1. Try to list (directory) first across all repos
 		var allEntries []storage.Entry
@@ -225,24 +473,37 @@ func (h *MavenHandler) HandleAggregateDownload(basePath string) gin.HandlerFunc
 		}
 
 		if foundDir {
+			if redirectToDirectory(c) {
+				return
+			}
 			// Deduplicate and render
-			c.Header("Content-Type", "text/html")
-			c.Writer.WriteHeader(http.StatusOK)
-			fmt.Fprintf(c.Writer, "<html><body><h1>Index of /repository/maven-public/%s (Aggregated)</h1><hr><ul>", artifactPath)
-			fmt.Fprintf(c.Writer, "<li><a href=\"../\">../</a></li>")
 			seen := make(map[string]bool)
+			var unique []storage.Entry
 			for _, e := range allEntries {
 				if seen[e.Name] {
 					continue
 				}
 				seen[e.Name] = true
-				slash := ""
-				if e.IsDir {
-					slash = "/"
-				}
-				fmt.Fprintf(c.Writer, "<li><a href=\"%s%s\">%s%s</a> (Size: %d)</li>", e.Name, slash, e.Name, slash, e.Size)
+				unique = append(unique, e)
+			}
+			listingPath := aggregatePrefix + "/" + artifactPath
+			if restricted {
+				listingPath = "" // Not cached
+			}
+			h.renderListing(c, listingPath, c.Request.URL.Path+" (Aggregated)", visibleEntries(unique))
+			return
+		}
+
+		if storage.IsInternal(artifactPath) || storage.IsResolutionMarker(artifactPath) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+
+		// A trailing slash names a directory; files are never served there
+		if strings.HasSuffix(c.Request.URL.Path, "/") {
+			if len(h.Config.ProxyURLs) == 0 || !h.serveUpstreamListing(c, artifactPath, aggregatePrefix+"/"+artifactPath, "/repository/maven-public/"+artifactPath) {
+				c.Status(http.StatusNotFound)
 			}
-			fmt.Fprintf(c.Writer, "</ul><hr></body></html>")
 			return
 		}
 
@@ -251,37 +512,28 @@ func (h *MavenHandler) HandleAggregateDownload(basePath string) gin.HandlerFunc
 			fullPath := strings.TrimRight(repo, "/") + "/" + artifactPath
 			reader, found, err := h.Store.Get(fullPath)
 			if err == nil && found {
-				defer reader.Close()
-				c.DataFromReader(http.StatusOK, -1, "application/octet-stream", reader, nil)
+				h.serveStored(c, fullPath, reader)
+				return
+			}
+		}
+
+		for _, repo := range repos {
+			if h.serveGeneratedChecksum(c, strings.TrimRight(repo, "/")+"/"+artifactPath) {
 				return
 			}
 		}
 
 		// 3. Not found locally, try proxying the artifactPath directly
 		if len(h.Config.ProxyURLs) > 0 {
-			for _, proxy := range h.Config.ProxyURLs {
-				url := strings.TrimRight(proxy, "/") + "/" + artifactPath
-				resp, err := h.Client.Get(url)
-				if err == nil && resp.StatusCode == http.StatusOK {
-					contentType := resp.Header.Get("Content-Type")
-					if strings.HasPrefix(contentType, "text/html") {
-						resp.Body.Close()
-						continue
-					}
-					defer resp.Body.Close()
-
-					cachePath := "repository/maven-public/" + artifactPath
-
-					pr, pw := io.Pipe()
-					go func() {
-						if err := h.Store.Save(cachePath, pr); err != nil {
-						}
-					}()
-					tee := io.TeeReader(resp.Body, pw)
-					wrappedReader := &NotifyReader{Reader: tee, OnEOF: func() { pw.Close() }}
-					c.DataFromReader(http.StatusOK, resp.ContentLength, contentType, wrappedReader, nil)
-					return
-				}
+			cachePath := "repository/maven-public/" + artifactPath
+			if h.negative.has(cachePath) {
+				c.Set(logger.CacheSourceKey, "negative")
+				c.Status(http.StatusNotFound)
+				return
+			}
+
+			if h.fetchFromProxies(c, artifactPath, cachePath) {
+				return
 			}
 		}
 
@@ -292,7 +544,11 @@ func (h *MavenHandler) HandleAggregateDownload(basePath string) gin.HandlerFunc
 func (h *MavenHandler) HandleAggregateHead(basePath string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		artifactPath := strings.TrimPrefix(c.Param("path"), "/")
-		repos := h.getAggregateRepos(basePath)
+		if storage.IsInternal(artifactPath) || storage.IsResolutionMarker(artifactPath) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		repos, _ := readableRepos(c, h.getAggregateRepos(basePath))
 
 		// Check local repos
 		for _, repo := range repos {
@@ -305,21 +561,37 @@ func (h *MavenHandler) HandleAggregateHead(basePath string) gin.HandlerFunc {
 		}
 
 		// Try proxy
-		if len(h.Config.ProxyURLs) > 0 {
-			for _, proxy := range h.Config.ProxyURLs {
-				url := strings.TrimRight(proxy, "/") + "/" + artifactPath
-				resp, err := h.Client.Head(url)
-				if err == nil && resp.StatusCode == http.StatusOK {
-					c.Status(http.StatusOK)
-					return
-				}
-			}
+		head, ok, proxyErr := h.proxyHead(c, artifactPath)
+		if ok {
+			head.write(c)
+			return
+		}
+		if proxyErr != nil {
+			c.Status(http.StatusBadGateway)
+			return
 		}
 
 		c.Status(http.StatusNotFound)
 	}
 }
 
+// readableRepos drops the repositories of a group request the user may not
+// read, per auth.ReadAccessKey. It reports false when nothing was filtered
+// because every repository may be read.
+func readableRepos(c *gin.Context, repos []string) ([]string, bool) {
+	canRead, ok := c.Value(auth.ReadAccessKey).(func(string) bool)
+	if !ok {
+		return repos, false
+	}
+	var readable []string
+	for _, repo := range repos {
+		if canRead(repo[strings.LastIndex(repo, "/")+1:]) {
+			readable = append(readable, repo)
+		}
+	}
+	return readable, true
+}
+
 func (h *MavenHandler) getAggregateRepos(basePath string) []string {
 	entries, err := h.Store.List(basePath)
 	if err != nil {
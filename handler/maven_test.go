@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/auth"
+	"maven_repo/config"
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter wires a MavenHandler backed by a temporary LocalStorage into a
+// gin engine using the same routes as the server package.
+func newTestRouter(t *testing.T, cfg *config.Config) (*gin.Engine, *MavenHandler, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	base := t.TempDir()
+	h := NewMavenHandler(storage.NewLocalStorage(base), cfg)
+
+	r := gin.New()
+	// Stand-in for BasicAuth: trust the user named in X-Test-User
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-Test-User"); user != "" {
+			c.Set(gin.AuthUserKey, user)
+		}
+	})
+	repos := r.Group("/repository/:repoName")
+	{
+		repos.PUT("/*path", h.HandleUpload)
+		repos.GET("/*path", h.HandleDownload)
+		repos.HEAD("/*path", h.HandleHead)
+		repos.DELETE("/*path", h.HandleDelete)
+	}
+	r.POST("/admin/artifacts/delete", h.HandleBatchDelete)
+	r.POST("/admin/directories/create", h.HandleCreateDir)
+	r.GET("/admin/pins", h.HandleGetPin)
+	r.POST("/admin/prewarm", h.HandlePrewarm)
+	r.GET("/admin/prewarm/status", h.HandlePrewarmStatus)
+	r.PUT("/admin/pins", h.HandleSetPin)
+	r.DELETE("/admin/pins", h.HandleDeletePin)
+	r.GET("/api/provenance", h.HandleProvenance)
+	r.GET("/api/checksums", h.HandleChecksums)
+	r.GET("/api/tags", h.HandleGetTags)
+	r.PUT("/api/tags", h.HandlePutTags)
+	r.DELETE("/api/tags", h.HandleDeleteTags)
+	r.GET("/api/search", h.HandleSearch)
+	return r, h, base
+}
+
+func doRequest(r http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleDelete_ProtectionWindow(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{DeleteProtectionMinutes: 10})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodPut, target, "jar"); w.Code != http.StatusCreated {
+		t.Fatalf("upload: expected 201, got %d", w.Code)
+	}
+
+	if w := doRequest(r, http.MethodDelete, target, ""); w.Code != http.StatusLocked {
+		t.Fatalf("fresh delete: expected 423, got %d", w.Code)
+	}
+	w := doRequest(r, http.MethodPost, "/admin/artifacts/delete", `{"paths":["repository/releases/com/example/app/1.0/app-1.0.jar"]}`)
+	if w.Code != http.StatusLocked {
+		t.Fatalf("fresh batch delete: expected 423, got %d", w.Code)
+	}
+
+	// Age the file past the window
+	old := time.Now().Add(-time.Hour)
+	fullPath := filepath.Join(base, "repository/releases/com/example/app/1.0/app-1.0.jar")
+	if err := os.Chtimes(fullPath, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := doRequest(r, http.MethodDelete, target, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("aged delete: expected 204, got %d", w.Code)
+	}
+	if _, err := os.Stat(fullPath); !os.IsNotExist(err) {
+		t.Errorf("expected file to be deleted, stat err: %v", err)
+	}
+}
+
+func TestHandleDelete_NoProtection(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.pom"
+	doRequest(r, http.MethodPut, target, "pom")
+	if w := doRequest(r, http.MethodDelete, target, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+}
+
+func TestPathTraversal_Rejected(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+	outside := filepath.Dir(base)
+
+	for _, target := range []string{
+		"/repository/develop/../../../evil.jar",
+		"/repository/develop/%2e%2e/%2e%2e/%2e%2e/evil.jar",
+		"/repository/develop/..%2F..%2F..%2Fevil.jar",
+	} {
+		for _, method := range []string{http.MethodPut, http.MethodGet, http.MethodHead, http.MethodDelete} {
+			if w := doRequest(r, method, target, "evil"); w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: expected 400, got %d", method, target, w.Code)
+			}
+		}
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.jar")); !os.IsNotExist(err) {
+		t.Fatal("upload escaped the storage root")
+	}
+
+	w := doRequest(r, http.MethodPost, "/admin/artifacts/delete", `{"paths":["repository/../../evil.jar"]}`)
+	if w.Code == http.StatusOK && !strings.Contains(w.Body.String(), "invalid storage path") {
+		t.Errorf("batch delete outside the root should fail, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAggregate_OnlyServesReadableRepos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewMavenHandler(storage.NewLocalStorage(t.TempDir()), &config.Config{ListingCacheTTL: "1m"})
+	r := gin.New()
+	// Stand-in for BasicAuth: X-Test-Readable names the only readable repo
+	r.Use(func(c *gin.Context) {
+		if repo := c.GetHeader("X-Test-Readable"); repo != "" {
+			c.Set(auth.ReadAccessKey, func(r string) bool { return r == repo })
+		}
+	})
+	r.PUT("/repository/:repoName/*path", h.HandleUpload)
+	r.GET("/aggregate/*path", h.HandleAggregateDownload("repository"))
+	r.HEAD("/aggregate/*path", h.HandleAggregateHead("repository"))
+
+	doRequest(r, http.MethodPut, "/repository/private/com/example/secret/1.0/secret-1.0.jar", "secret")
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "jar")
+
+	request := func(method, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("X-Test-Readable", "releases")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		if w := request(method, "/aggregate/com/example/secret/1.0/secret-1.0.jar"); w.Code != http.StatusNotFound {
+			t.Errorf("%s unreadable repo: expected 404, got %d", method, w.Code)
+		}
+		if w := request(method, "/aggregate/com/example/app/1.0/app-1.0.jar"); w.Code != http.StatusOK {
+			t.Errorf("%s readable repo: expected 200, got %d", method, w.Code)
+		}
+	}
+
+	// The unrestricted listing is cached, but not served to restricted users
+	if body := doRequest(r, http.MethodGet, "/aggregate/com/example/", "").Body.String(); !strings.Contains(body, "secret") {
+		t.Fatalf("unrestricted listing misses the private artifact: %s", body)
+	}
+	if body := request(http.MethodGet, "/aggregate/com/example/").Body.String(); strings.Contains(body, "secret") || !strings.Contains(body, "app") {
+		t.Errorf("restricted listing: %s", body)
+	}
+}
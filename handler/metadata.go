@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"log"
+	"path"
+
+	"maven_repo/storage"
+)
+
+const metadataFile = "maven-metadata.xml"
+
+func isMetadata(p string) bool {
+	return path.Base(p) == metadataFile
+}
+
+// isMetadataChecksum matches the sidecars the metadata service maintains itself.
+func isMetadataChecksum(p string) bool {
+	base := path.Base(p)
+	return base == metadataFile+".sha1" || base == metadataFile+".md5"
+}
+
+// recordUploadMetadata keeps maven-metadata.xml in step with an uploaded
+// artifact file. The artifact is already stored, so failures are only logged.
+func (h *MavenHandler) recordUploadMetadata(p string) {
+	if !h.Config.GenerateMetadata || isSidecar(p) || storage.IsInternal(p) {
+		return
+	}
+	if err := h.Metadata.RecordUpload(p); err != nil {
+		log.Printf("Failed to update metadata for %s: %v\n", p, err)
+	}
+}
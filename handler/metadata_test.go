@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleUpload_GeneratesMetadata(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{GenerateMetadata: true})
+	dir := "/repository/develop/com/example/app/"
+
+	for _, p := range []string{"1.0/app-1.0.jar", "1.0/app-1.0.jar.sha1", "1.1-SNAPSHOT/app-1.1-20240301.110000-1.jar"} {
+		if w := doRequest(r, http.MethodPut, dir+p, "x"); w.Code != http.StatusCreated {
+			t.Fatalf("PUT %s: expected 201, got %d", p, w.Code)
+		}
+	}
+
+	body := doRequest(r, http.MethodGet, dir+"maven-metadata.xml", "").Body.String()
+	for _, want := range []string{"<groupId>com.example</groupId>", "<version>1.0</version>", "<version>1.1-SNAPSHOT</version>", "<release>1.0</release>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("artifact metadata missing %s: %s", want, body)
+		}
+	}
+	if w := doRequest(r, http.MethodGet, dir+"maven-metadata.xml.sha1", ""); w.Code != http.StatusOK {
+		t.Errorf("expected metadata checksum, got %d", w.Code)
+	}
+
+	body = doRequest(r, http.MethodGet, dir+"1.1-SNAPSHOT/maven-metadata.xml", "").Body.String()
+	if !strings.Contains(body, "<value>1.1-20240301.110000-1</value>") {
+		t.Errorf("snapshot metadata missing build: %s", body)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNegativeEntries bounds the negative cache; expired entries are dropped
+// once it is reached.
+const maxNegativeEntries = 10000
+
+// negativeCache remembers paths that every upstream answered with 404, so
+// builds referencing missing dependencies don't query all proxies on every
+// request. A zero TTL disables it.
+type negativeCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	expires map[string]time.Time
+}
+
+func newNegativeCache(ttl time.Duration) *negativeCache {
+	return &negativeCache{ttl: ttl, expires: make(map[string]time.Time)}
+}
+
+func (nc *negativeCache) has(path string) bool {
+	if nc.ttl <= 0 {
+		return false
+	}
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	expires, ok := nc.expires[listingKey(path)]
+	return ok && time.Now().Before(expires)
+}
+
+func (nc *negativeCache) set(path string) {
+	if nc.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	if len(nc.expires) >= maxNegativeEntries {
+		for p, expires := range nc.expires {
+			if now.After(expires) {
+				delete(nc.expires, p)
+			}
+		}
+		if len(nc.expires) >= maxNegativeEntries {
+			nc.expires = make(map[string]time.Time)
+		}
+	}
+	nc.expires[listingKey(path)] = now.Add(nc.ttl)
+}
+
+// invalidate forgets path, along with its maven-public aggregate path when it
+// lies in a repository, since an upload there makes it resolvable through
+// the aggregate as well.
+func (nc *negativeCache) invalidate(path string) {
+	key := listingKey(path)
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	delete(nc.expires, key)
+	if parts := strings.SplitN(key, "/", 3); len(parts) == 3 && parts[0] == "repository" {
+		delete(nc.expires, aggregatePrefix+"/"+parts[2])
+	}
+}
+
+func (nc *negativeCache) size() int {
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	return len(nc.expires)
+}
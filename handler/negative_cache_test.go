@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func TestHandleDownload_NegativeCache(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, NegativeCacheTTL: "50ms"})
+
+	target := "/repository/maven-public/com/example/missing/1.0/missing-1.0.jar"
+	for i := 0; i < 3; i++ {
+		if w := doRequest(r, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+			t.Fatalf("expected 404, got %d", w.Code)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected the miss to be cached after one upstream request, got %d", n)
+	}
+
+	// Entries expire after the TTL
+	time.Sleep(60 * time.Millisecond)
+	doRequest(r, http.MethodGet, target, "")
+	if n := hits.Load(); n != 2 {
+		t.Errorf("expected upstream to be asked again after expiry, got %d requests", n)
+	}
+
+	// An upload to a repository clears the aggregate path too
+	if !h.negative.has(target) {
+		t.Fatal("expected the miss to be cached again")
+	}
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/missing/1.0/missing-1.0.jar", "jar")
+	if h.negative.has(target) {
+		t.Error("upload did not invalidate the negative cache entry")
+	}
+}
+
+func TestHandleDownload_NegativeCacheIgnoresErrors(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, NegativeCacheTTL: "1m"})
+
+	target := "/repository/maven-public/com/example/flaky/1.0/flaky-1.0.jar"
+	for i := 0; i < 2; i++ {
+		doRequest(r, http.MethodGet, target, "")
+	}
+	if n := hits.Load(); n != 2 {
+		t.Errorf("upstream errors must not be cached as misses, got %d requests", n)
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isPaginated reports whether the request asks for a page of a listing.
+func isPaginated(c *gin.Context) bool {
+	return c.Query("offset") != "" || c.Query("limit") != ""
+}
+
+// paginate applies ?offset= and ?limit= to a listing and emits RFC 5988 Link
+// headers (first, prev, next, last) so clients can walk the pages. A
+// positive maxLimit caps the page size, and is the page size when no limit
+// is given.
+func paginate(c *gin.Context, entries []storage.Entry, maxLimit int) []storage.Entry {
+	total := len(entries)
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if maxLimit > 0 && (limit <= 0 || limit > maxLimit) {
+		limit = maxLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > total {
+		offset = total
+	}
+	if limit <= 0 {
+		return entries[offset:]
+	}
+
+	end := offset + limit
+	if end > total {
+		end = total
+	}
+
+	lastOffset := 0
+	if total > 0 {
+		lastOffset = (total - 1) / limit * limit
+	}
+	links := []string{pageLink(c, 0, limit, "first")}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, pageLink(c, prev, limit, "prev"))
+	}
+	if end < total {
+		links = append(links, pageLink(c, end, limit, "next"))
+	}
+	links = append(links, pageLink(c, lastOffset, limit, "last"))
+	c.Header("Link", strings.Join(links, ", "))
+
+	return entries[offset:end]
+}
+
+func pageLink(c *gin.Context, offset, limit int, rel string) string {
+	u := url.URL{Path: c.Request.URL.Path}
+	q := c.Request.URL.Query()
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = q.Encode()
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pinSuffix marks the sidecar holding the sha1 a coordinate must always
+// resolve to. Pins outlive the artifact so a re-deploy must match them.
+const pinSuffix = ".pin"
+
+var sha1Hex = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+func (h *MavenHandler) readPin(path string) (string, error) {
+	reader, found, err := h.Store.Get(path + pinSuffix)
+	if err != nil || !found {
+		return "", err
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func sha1Of(r io.Reader) (string, error) {
+	sum := sha1.New()
+	if _, err := io.Copy(sum, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// verifyPinnedDownload checks a stored artifact against its pin before it is
+// served. It consumes reader and returns a fresh one to serve, or false after
+// responding with 409 when storage has drifted from the pin.
+func (h *MavenHandler) verifyPinnedDownload(c *gin.Context, path string, reader io.ReadCloser) (io.ReadCloser, bool) {
+	pin, err := h.readPin(path)
+	if err != nil {
+		reader.Close()
+		if rejectInvalidPath(c, err) {
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if pin == "" {
+		return reader, true
+	}
+
+	actual, err := sha1Of(reader)
+	reader.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if actual != pin {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s does not match its pinned checksum", path), "pinned": pin, "actual": actual})
+		return nil, false
+	}
+
+	reader, found, err := h.Store.Get(path)
+	if err != nil || !found {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%s disappeared while verifying its pin", path)})
+		return nil, false
+	}
+	return reader, true
+}
+
+// verifyPinnedUpload checks an upload of a pinned coordinate before anything
+// is written. Bodies that cannot be rewound are spooled to a temp file first.
+// The returned cleanup must always be called.
+func (h *MavenHandler) verifyPinnedUpload(c *gin.Context, path string, body io.Reader) (io.Reader, func(), bool) {
+	noop := func() {}
+	pin, err := h.readPin(path)
+	if err != nil {
+		if rejectInvalidPath(c, err) {
+			return nil, noop, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, noop, false
+	}
+	if pin == "" {
+		return body, noop, true
+	}
+
+	var rewound io.ReadSeeker
+	cleanup := noop
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		rewound = seeker
+	} else {
+		spool, err := os.CreateTemp("", "maven-pinned-*")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, noop, false
+		}
+		cleanup = func() {
+			spool.Close()
+			os.Remove(spool.Name())
+		}
+		if _, err := io.Copy(spool, body); err != nil {
+			if rejectTooLarge(c, err) {
+				return nil, cleanup, false
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+			return nil, cleanup, false
+		}
+		rewound = spool
+	}
+
+	if _, err := rewound.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, cleanup, false
+	}
+	actual, err := sha1Of(rewound)
+	if err == nil {
+		_, err = rewound.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, cleanup, false
+	}
+	if actual != pin {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is pinned to different content", path), "pinned": pin, "actual": actual})
+		return nil, cleanup, false
+	}
+	return rewound, cleanup, true
+}
+
+type pinRequest struct {
+	SHA1 string `json:"sha1"`
+}
+
+func pinPath(c *gin.Context) (string, bool) {
+	path := strings.TrimPrefix(c.Query("path"), "/")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return "", false
+	}
+	return path, true
+}
+
+// HandleGetPin returns the pinned sha1 for ?path=.
+func (h *MavenHandler) HandleGetPin(c *gin.Context) {
+	path, ok := pinPath(c)
+	if !ok {
+		return
+	}
+	pin, err := h.readPin(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if pin == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no pin for " + path})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "sha1": pin})
+}
+
+// HandleSetPin pins ?path= to the sha1 in the body, or to the currently
+// stored bytes when the body names none.
+func (h *MavenHandler) HandleSetPin(c *gin.Context) {
+	path, ok := pinPath(c)
+	if !ok {
+		return
+	}
+	var req pinRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	pin := strings.ToLower(strings.TrimSpace(req.SHA1))
+	if pin == "" {
+		reader, found, err := h.Store.Get(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "artifact not found: " + path})
+			return
+		}
+		pin, err = sha1Of(reader)
+		reader.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	} else if !sha1Hex.MatchString(pin) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sha1 must be 40 hex characters"})
+		return
+	}
+
+	if err := h.Store.Save(path+pinSuffix, bytes.NewReader([]byte(pin))); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "sha1": pin})
+}
+
+// HandleDeletePin removes the pin for ?path=.
+func (h *MavenHandler) HandleDeletePin(c *gin.Context) {
+	path, ok := pinPath(c)
+	if !ok {
+		return
+	}
+	if err := h.Store.Delete(path + pinSuffix); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
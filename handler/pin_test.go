@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestPins_ServeMatchingRefuseDrifted(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	artifact := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, "/"+artifact, "original")
+
+	// Pin the stored bytes
+	if w := doRequest(r, http.MethodPut, "/admin/pins?path="+artifact, ""); w.Code != http.StatusOK {
+		t.Fatalf("set pin: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w := doRequest(r, http.MethodGet, "/"+artifact, "")
+	if w.Code != http.StatusOK || w.Body.String() != "original" {
+		t.Fatalf("matching pin: expected artifact to be served, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Re-deploying different bytes is rejected and leaves storage untouched
+	if w := doRequest(r, http.MethodPut, "/"+artifact, "tampered"); w.Code != http.StatusConflict {
+		t.Fatalf("mismatching upload: expected 409, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, "/"+artifact, "original"); w.Code != http.StatusCreated {
+		t.Fatalf("matching upload: expected 201, got %d", w.Code)
+	}
+
+	// Storage drifting behind the server's back is refused on download
+	if err := os.WriteFile(filepath.Join(base, artifact), []byte("drifted"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if w := doRequest(r, http.MethodGet, "/"+artifact, ""); w.Code != http.StatusConflict {
+		t.Fatalf("drifted artifact: expected 409, got %d", w.Code)
+	}
+}
+
+func TestPins_ExplicitChecksum(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	artifact := "repository/releases/com/example/lib/1.0/lib-1.0.jar"
+	sum := sha1.Sum([]byte("expected"))
+	pin := hex.EncodeToString(sum[:])
+
+	if w := doRequest(r, http.MethodPut, "/admin/pins?path="+artifact, `{"sha1":"not-hex"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid pin: expected 400, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, "/admin/pins?path="+artifact, `{"sha1":"`+pin+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("set pin: expected 200, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, "/"+artifact, "unexpected"); w.Code != http.StatusConflict {
+		t.Fatalf("mismatching upload: expected 409, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, "/"+artifact, "expected"); w.Code != http.StatusCreated {
+		t.Fatalf("matching upload: expected 201, got %d", w.Code)
+	}
+
+	if w := doRequest(r, http.MethodDelete, "/admin/pins?path="+artifact, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete pin: expected 204, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/admin/pins?path="+artifact, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted pin: expected 404, got %d", w.Code)
+	}
+}
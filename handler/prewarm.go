@@ -0,0 +1,237 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPrewarmWorkers bounds prewarm fetches when no proxy concurrency
+// limit is configured.
+const defaultPrewarmWorkers = 4
+
+// prewarmStatus is the progress of the most recent prewarm run.
+type prewarmStatus struct {
+	Running    bool              `json:"running"`
+	Total      int               `json:"total"`
+	Done       int               `json:"done"`
+	Cached     int               `json:"cached"`
+	Skipped    int               `json:"skipped"`
+	Failed     map[string]string `json:"failed"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+}
+
+type prewarmState struct {
+	mu     sync.Mutex
+	status prewarmStatus
+}
+
+// acquireProxySlot blocks until an upstream fetch may start. The returned
+// function releases the slot.
+func (h *MavenHandler) acquireProxySlot() func() {
+	if h.proxySlots == nil {
+		return func() {}
+	}
+	h.proxySlots <- struct{}{}
+	return func() { <-h.proxySlots }
+}
+
+type prewarmRequest struct {
+	Paths []string `json:"paths" binding:"required"`
+}
+
+// HandlePrewarm starts fetching the listed storage paths (e.g.
+// repository/releases/com/example/app/1.0/app-1.0.jar) from upstream in the
+// background. Paths already in storage are skipped.
+func (h *MavenHandler) HandlePrewarm(c *gin.Context) {
+	var req prewarmRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(h.Config.ProxyURLs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no upstream proxies configured"})
+		return
+	}
+
+	h.prewarm.mu.Lock()
+	if h.prewarm.status.Running {
+		h.prewarm.mu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "a prewarm run is already in progress"})
+		return
+	}
+	h.prewarm.status = prewarmStatus{
+		Running:   true,
+		Total:     len(req.Paths),
+		Failed:    map[string]string{},
+		StartedAt: time.Now().UTC(),
+	}
+	h.prewarm.mu.Unlock()
+
+	go h.runPrewarm(req.Paths)
+	c.JSON(http.StatusAccepted, gin.H{"status": "started", "total": len(req.Paths)})
+}
+
+// HandlePrewarmStatus reports the progress of the current or last run.
+func (h *MavenHandler) HandlePrewarmStatus(c *gin.Context) {
+	h.prewarm.mu.Lock()
+	status := h.prewarm.status
+	failed := make(map[string]string, len(status.Failed))
+	for k, v := range status.Failed {
+		failed[k] = v
+	}
+	status.Failed = failed
+	h.prewarm.mu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+func (h *MavenHandler) runPrewarm(paths []string) {
+	workers := defaultPrewarmWorkers
+	if h.Config.ProxyMaxConcurrency > 0 && h.Config.ProxyMaxConcurrency < workers {
+		workers = h.Config.ProxyMaxConcurrency
+	}
+
+	queue := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range queue {
+				cached, err := h.prewarmOne(path)
+				h.prewarm.mu.Lock()
+				h.prewarm.status.Done++
+				switch {
+				case err != nil:
+					h.prewarm.status.Failed[path] = err.Error()
+				case cached:
+					h.prewarm.status.Cached++
+				default:
+					h.prewarm.status.Skipped++
+				}
+				h.prewarm.mu.Unlock()
+			}
+		}()
+	}
+	for _, p := range paths {
+		queue <- strings.TrimPrefix(p, "/")
+	}
+	close(queue)
+	wg.Wait()
+
+	h.prewarm.mu.Lock()
+	finished := time.Now().UTC()
+	h.prewarm.status.Running = false
+	h.prewarm.status.FinishedAt = &finished
+	status := h.prewarm.status
+	h.prewarm.mu.Unlock()
+	log.Printf("Prewarm finished: %d cached, %d skipped, %d failed\n", status.Cached, status.Skipped, len(status.Failed))
+}
+
+// prewarmOne caches a single path from the first upstream that has it. It
+// reports false when the path was already in storage.
+func (h *MavenHandler) prewarmOne(path string) (bool, error) {
+	if storage.IsInternal(path) || storage.IsResolutionMarker(path) {
+		return false, fmt.Errorf("not an artifact")
+	}
+	found, err := h.Store.Head(path)
+	if err != nil {
+		return false, err
+	}
+	if found {
+		return false, nil
+	}
+
+	artifactPath := artifactPathForProxy(path)
+	if !h.proxyAllowed(artifactPath) {
+		return false, fmt.Errorf("excluded from proxying")
+	}
+
+	release := h.acquireProxySlot()
+	defer release()
+
+	missing := fmt.Errorf("not found upstream")
+	for _, proxy := range h.Config.ProxyURLs {
+		url := strings.TrimRight(proxy, "/") + "/" + artifactPath
+		resp, err := h.upstreamRequestWithRetry(context.Background(), http.MethodGet, url)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK || !h.acceptUpstreamContentType(artifactPath, resp.Header.Get("Content-Type")) {
+			resp.Body.Close()
+			continue
+		}
+		err = h.cacheUpstream(resp, url, path)
+		resp.Body.Close()
+		var rejected *rejectedDownload
+		if errors.As(err, &rejected) {
+			// A corrupt copy gives the next proxy a chance, as on GET
+			missing = err
+			continue
+		}
+		return err == nil, err
+	}
+	return false, missing
+}
+
+// cacheUpstream stores an upstream response without serving it to anyone.
+func (h *MavenHandler) cacheUpstream(resp *http.Response, upstreamURL, cachePath string) error {
+	if h.Config.ProxyMaxSize > 0 && resp.ContentLength > h.Config.ProxyMaxSize {
+		return errProxyTooLarge
+	}
+	if h.Config.ProxyVerifyChecksums && !isSidecar(cachePath) {
+		return h.cacheVerified(resp, upstreamURL, cachePath)
+	}
+	body, _, err := decodeUpstreamBody(resp)
+	if err != nil {
+		return err
+	}
+	if h.Config.ProxyMaxSize > 0 {
+		body = &maxSizeReader{Reader: body, Limit: h.Config.ProxyMaxSize}
+	}
+	if err := h.Store.Save(cachePath, body); err != nil {
+		return err
+	}
+	h.recordProxyProvenance(cachePath, upstreamURL, resp)
+	h.listings.invalidate(cachePath)
+	return nil
+}
+
+// rejectedDownload is an upstream copy that failed verification.
+type rejectedDownload struct{ err error }
+
+func (e *rejectedDownload) Error() string { return e.err.Error() }
+
+// cacheVerified is cacheUpstream's counterpart for
+// MAVEN_PROXY_VERIFY_CHECKSUMS, saving the response only once it matches the
+// upstream .sha1.
+func (h *MavenHandler) cacheVerified(resp *http.Response, upstreamURL, cachePath string) error {
+	spool, err := os.CreateTemp("", "maven-proxy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	if _, err := h.spoolVerified(context.Background(), resp, upstreamURL, spool); err != nil {
+		if errors.Is(err, errProxyTooLarge) {
+			return err
+		}
+		return &rejectedDownload{err}
+	}
+	if !h.saveSpool(spool, upstreamURL, cachePath, resp) {
+		return fmt.Errorf("failed to cache %s", cachePath)
+	}
+	return nil
+}
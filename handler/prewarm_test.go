@@ -0,0 +1,191 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+// waitPrewarm polls the prewarm status until the run has finished.
+func waitPrewarm(t *testing.T, r http.Handler) prewarmStatus {
+	t.Helper()
+	var status prewarmStatus
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		w := doRequest(r, http.MethodGet, "/admin/prewarm/status", "")
+		json.Unmarshal(w.Body.Bytes(), &status)
+		if !status.Running {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("prewarm did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPrewarm_CachesListedArtifacts(t *testing.T) {
+	var inFlight, maxInFlight int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			old := atomic.LoadInt32(&maxInFlight)
+			if n <= old || atomic.CompareAndSwapInt32(&maxInFlight, old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if filepath.Base(r.URL.Path) == "missing-1.0.jar" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar:" + r.URL.Path))
+	})
+	r, _, base := newTestRouter(t, &config.Config{
+		ProxyURLs:           []string{upstream.URL},
+		ProxyMaxConcurrency: 2,
+	})
+
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/local/1.0/local-1.0.jar", "local")
+	paths := []string{
+		"repository/releases/com/example/a/1.0/a-1.0.jar",
+		"repository/releases/com/example/b/1.0/b-1.0.jar",
+		"repository/releases/com/example/c/1.0/c-1.0.jar",
+		"repository/releases/com/example/d/1.0/d-1.0.jar",
+		"repository/releases/com/example/local/1.0/local-1.0.jar",
+		"repository/releases/com/example/missing/1.0/missing-1.0.jar",
+	}
+	body, _ := json.Marshal(map[string][]string{"paths": paths})
+	if w := doRequest(r, http.MethodPost, "/admin/prewarm", string(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	status := waitPrewarm(t, r)
+	if status.Done != 6 || status.Cached != 4 || status.Skipped != 1 || len(status.Failed) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	for _, p := range paths[:4] {
+		data, err := os.ReadFile(filepath.Join(base, p))
+		if err != nil {
+			t.Fatalf("expected %s to be cached: %v", p, err)
+		}
+		if len(data) == 0 {
+			t.Errorf("%s cached empty", p)
+		}
+	}
+	if got := atomic.LoadInt32(&maxInFlight); got > 2 {
+		t.Errorf("expected at most 2 concurrent upstream fetches, saw %d", got)
+	}
+}
+
+func TestPrewarm_VerifiesChecksums(t *testing.T) {
+	good := "jar contents"
+	r, _, base := newTestRouter(t, &config.Config{
+		ProxyURLs:            []string{newChecksumUpstream(t, "jar cont", sha1String(good))},
+		ProxyVerifyChecksums: true,
+	})
+
+	path := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	body, _ := json.Marshal(map[string][]string{"paths": {path}})
+	if w := doRequest(r, http.MethodPost, "/admin/prewarm", string(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	status := waitPrewarm(t, r)
+	if status.Cached != 0 || len(status.Failed) != 1 {
+		t.Fatalf("expected the corrupt download to fail, got %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(base, path)); !os.IsNotExist(err) {
+		t.Errorf("corrupt download was cached: %v", err)
+	}
+}
+
+func TestPrewarm_MismatchTriesNextProxy(t *testing.T) {
+	good := "jar contents"
+	r, h, base := newTestRouter(t, &config.Config{
+		ProxyURLs: []string{
+			newChecksumUpstream(t, "jar cont", sha1String(good)),
+			newChecksumUpstream(t, good, sha1String(good)),
+		},
+		ProxyVerifyChecksums: true,
+	})
+
+	path := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	body, _ := json.Marshal(map[string][]string{"paths": {path}})
+	if w := doRequest(r, http.MethodPost, "/admin/prewarm", string(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if status := waitPrewarm(t, r); status.Cached != 1 {
+		t.Fatalf("expected the second proxy's copy to be cached, got %+v", status)
+	}
+	if data, err := os.ReadFile(filepath.Join(base, path)); string(data) != good {
+		t.Errorf("cached %q, %v", data, err)
+	}
+	if got := h.ChecksumMismatches(); got != 1 {
+		t.Errorf("expected 1 checksum mismatch, got %d", got)
+	}
+}
+
+func TestPrewarm_RetriesTransientFailures(t *testing.T) {
+	var calls int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+	r, _, base := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyRetryMaxAttempts: 3, ProxyRetryBaseDelay: "1ms"})
+
+	path := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	body, _ := json.Marshal(map[string][]string{"paths": {path}})
+	if w := doRequest(r, http.MethodPost, "/admin/prewarm", string(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if status := waitPrewarm(t, r); status.Cached != 1 {
+		t.Fatalf("expected the retried fetch to be cached, got %+v", status)
+	}
+	if _, err := os.Stat(filepath.Join(base, path)); err != nil {
+		t.Errorf("expected %s to be cached: %v", path, err)
+	}
+}
+
+func TestPrewarm_RejectsInternalFiles(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("forged"))
+	})
+	r, _, base := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+
+	dir := "repository/releases/com/example/app/1.0/"
+	paths := []string{dir + "app-1.0.jar.pin", dir + "app-1.0.jar.provenance.json", dir + "app-1.0.jar.lastUpdated", dir + "_remote.repositories"}
+	body, _ := json.Marshal(map[string][]string{"paths": paths})
+	if w := doRequest(r, http.MethodPost, "/admin/prewarm", string(body)); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if status := waitPrewarm(t, r); len(status.Failed) != len(paths) || status.Cached != 0 {
+		t.Fatalf("expected every path to be refused, got %+v", status)
+	}
+	if n := hits.Load(); n != 0 {
+		t.Errorf("expected no upstream requests, got %d", n)
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(filepath.Join(base, p)); !os.IsNotExist(err) {
+			t.Errorf("%s was stored, stat err: %v", p, err)
+		}
+	}
+}
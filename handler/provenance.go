@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const provenanceSuffix = ".provenance.json"
+
+// Provenance records where a stored artifact came from.
+type Provenance struct {
+	Source               string `json:"source"` // "proxy" or "upload"
+	UpstreamURL          string `json:"upstreamUrl,omitempty"`
+	UpstreamETag         string `json:"upstreamEtag,omitempty"`
+	UpstreamLastModified string `json:"upstreamLastModified,omitempty"`
+	User                 string `json:"user,omitempty"`
+	// Timestamp is when the artifact was stored or, for proxied files, last
+	// revalidated against the upstream
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (h *MavenHandler) saveProvenance(path string, p Provenance) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+	if err := h.Store.Save(path+provenanceSuffix, bytes.NewReader(data)); err != nil {
+		log.Printf("Failed to record provenance for %s: %v\n", path, err)
+	}
+}
+
+func (h *MavenHandler) recordProxyProvenance(path, upstreamURL string, resp *http.Response) {
+	h.saveProvenance(path, Provenance{
+		Source:               "proxy",
+		UpstreamURL:          redactURL(upstreamURL),
+		UpstreamETag:         resp.Header.Get("ETag"),
+		UpstreamLastModified: resp.Header.Get("Last-Modified"),
+		Timestamp:            time.Now().UTC(),
+	})
+}
+
+func (h *MavenHandler) recordUploadProvenance(c *gin.Context, path string) {
+	h.saveProvenance(path, Provenance{
+		Source:    "upload",
+		User:      c.GetString(gin.AuthUserKey),
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+// loadProvenance reads the provenance record of the artifact at path.
+func (h *MavenHandler) loadProvenance(path string) (Provenance, bool, error) {
+	reader, found, err := h.Store.Get(path + provenanceSuffix)
+	if err != nil || !found {
+		return Provenance{}, false, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return Provenance{}, false, err
+	}
+	var p Provenance
+	if err := json.Unmarshal(data, &p); err != nil {
+		return Provenance{}, false, err
+	}
+	return p, true, nil
+}
+
+// HandleProvenance returns the provenance record for ?path=<storage path>.
+func (h *MavenHandler) HandleProvenance(c *gin.Context) {
+	path := strings.TrimPrefix(c.Query("path"), "/")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+
+	p, found, err := h.loadProvenance(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no provenance recorded for " + path})
+		return
+	}
+	c.JSON(http.StatusOK, p)
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func getProvenance(t *testing.T, r http.Handler, path string) (Provenance, int) {
+	t.Helper()
+	w := doRequest(r, http.MethodGet, "/api/provenance?path="+path, "")
+	var p Provenance
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p, w.Code
+}
+
+func TestProvenance_Upload(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", nil)
+	req.Header.Set("X-Test-User", "deployer")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	p, code := getProvenance(t, r, "repository/releases/com/example/app/1.0/app-1.0.jar")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if p.Source != "upload" || p.User != "deployer" || p.Timestamp.IsZero() {
+		t.Errorf("unexpected provenance %+v", p)
+	}
+
+	// The sidecar must not show up in listings
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/", "")
+	if body := w.Body.String(); strings.Contains(body, provenanceSuffix) {
+		t.Errorf("listing exposes provenance sidecar: %s", body)
+	}
+}
+
+func TestProvenance_Proxy(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Header().Set("ETag", `"abc123"`)
+		w.Write([]byte("jar"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+
+	path := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodGet, "/"+path, ""); w.Code != http.StatusOK {
+		t.Fatalf("expected proxied download, got %d", w.Code)
+	}
+
+	// Caching happens in the background
+	var p Provenance
+	code := http.StatusNotFound
+	for i := 0; i < 50 && code != http.StatusOK; i++ {
+		time.Sleep(10 * time.Millisecond)
+		p, code = getProvenance(t, r, path)
+	}
+	if code != http.StatusOK {
+		t.Fatalf("provenance was not recorded, last status %d", code)
+	}
+	if p.Source != "proxy" || p.UpstreamURL != upstream.URL+"/com/example/app/1.0/app-1.0.jar" || p.UpstreamETag != `"abc123"` {
+		t.Errorf("unexpected provenance %+v", p)
+	}
+}
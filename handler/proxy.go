@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sidecarExtensions are small text files published next to artifacts. Upstreams
+// usually serve them as text/plain, so they bypass the content-type allowlist.
+var sidecarExtensions = []string{".sha1", ".md5", ".sha256", ".sha512", ".asc"}
+
+func isSidecar(path string) bool {
+	for _, ext := range sidecarExtensions {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptUpstreamContentType decides whether an upstream response with the given
+// Content-Type may be cached and served as an artifact. Anything rejected is
+// treated as a miss for that proxy.
+func (h *MavenHandler) acceptUpstreamContentType(artifactPath, contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+
+	for _, blocked := range h.Config.ProxyBlockedContentTypes {
+		if strings.HasPrefix(mediaType, strings.ToLower(blocked)) {
+			return false
+		}
+	}
+
+	if len(h.Config.ProxyAllowedContentTypes) == 0 || isSidecar(artifactPath) {
+		return true
+	}
+	for _, allowed := range h.Config.ProxyAllowedContentTypes {
+		if strings.HasPrefix(mediaType, strings.ToLower(allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// upstreamStatusError is an upstream answer that is neither a hit nor a clean
+// miss, such as 403 from a misconfigured mirror or 500 from an outage.
+type upstreamStatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *upstreamStatusError) Error() string {
+	return fmt.Sprintf("upstream %s returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// retryable reports whether the next proxy should still be tried. Server
+// errors may be specific to one upstream; other client errors mean the request
+// itself is being refused and are surfaced straight away.
+func (e *upstreamStatusError) retryable() bool {
+	return e.StatusCode >= 500
+}
+
+// checkUpstreamStatus classifies a non-200 upstream response. Only 404 (and
+// statuses below 400) count as a clean miss and return nil.
+func checkUpstreamStatus(url string, resp *http.Response) *upstreamStatusError {
+	if resp.StatusCode < 400 || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return &upstreamStatusError{URL: redactURL(url), StatusCode: resp.StatusCode}
+}
+
+func respondUpstreamError(c *gin.Context, err *upstreamStatusError) {
+	log.Printf("Proxy failed: %v\n", err)
+	c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "upstreamStatus": err.StatusCode})
+}
+
+var errUpstreamUnreachable = errors.New("no upstream could be reached")
+
+// respondUnreachable answers 502 for artifactPath when an upstream that might
+// have it could not be reached.
+func respondUnreachable(c *gin.Context, artifactPath string) {
+	log.Printf("Proxy failed for %s: %v\n", artifactPath, errUpstreamUnreachable)
+	c.JSON(http.StatusBadGateway, gin.H{"error": errUpstreamUnreachable.Error()})
+}
+
+var errProxyTooLarge = errors.New("upstream response exceeds the maximum proxied artifact size")
+
+// maxSizeReader passes through at most Limit bytes and fails if the
+// underlying reader has more.
+type maxSizeReader struct {
+	Reader io.Reader
+	Limit  int64
+	read   int64
+}
+
+func (r *maxSizeReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if r.read+int64(n) > r.Limit {
+		n = int(r.Limit - r.read)
+		r.read = r.Limit
+		return n, errProxyTooLarge
+	}
+	r.read += int64(n)
+	return n, err
+}
+
+// proxyTooLarge rejects upstream responses that announce a size above the
+// configured limit before anything is streamed or cached.
+func (h *MavenHandler) proxyTooLarge(c *gin.Context, resp *http.Response) bool {
+	if h.Config.ProxyMaxSize > 0 && resp.ContentLength > h.Config.ProxyMaxSize {
+		c.JSON(http.StatusBadGateway, gin.H{"error": errProxyTooLarge.Error()})
+		return true
+	}
+	return false
+}
+
+// cacheWrite reports whether a proxied artifact made it into storage. The
+// result may only be known after the response has been sent.
+type cacheWrite struct {
+	done chan struct{}
+	ok   bool
+}
+
+func newCacheWrite() *cacheWrite {
+	return &cacheWrite{done: make(chan struct{})}
+}
+
+func (w *cacheWrite) finish(ok bool) {
+	w.ok = ok
+	close(w.done)
+}
+
+// wait blocks until the write has finished and reports whether it succeeded.
+func (w *cacheWrite) wait() bool {
+	<-w.done
+	return w.ok
+}
+
+// streamAndCache streams an upstream response to the client while saving a
+// copy to cachePath. The body is spooled to a local temp file on the way and
+// only saved once it was read to the end, so a client that disconnects, an
+// upstream that fails mid-transfer or a body that outgrows the size limit
+// never leaves a truncated artifact in the cache. knownSize, when not -1, is
+// the length to announce if the response itself does not tell.
+func (h *MavenHandler) streamAndCache(c *gin.Context, resp *http.Response, upstreamURL, cachePath string, knownSize int64) *cacheWrite {
+	write := newCacheWrite()
+	body, length, err := decodeUpstreamBody(resp)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("invalid upstream encoding: %v", err)})
+		write.finish(false)
+		return write
+	}
+	if length < 0 {
+		length = knownSize
+	}
+	if h.Config.ProxyMaxSize > 0 {
+		body = &maxSizeReader{Reader: body, Limit: h.Config.ProxyMaxSize}
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		c.Header("Last-Modified", lastModified)
+	}
+
+	h.spoolAndCache(c, resp, upstreamURL, cachePath, body, length, write)
+	return write
+}
+
+// spoolAndCache streams body to the client while copying it to a local temp
+// file, and saves that file to storage once the transfer has completed.
+// Incomplete transfers are discarded. With MAVEN_PROXY_CACHE_ASYNC the save
+// runs in the background, so a slow storage backend delays the cache write
+// instead of the end of the client's request. write is finished once the save
+// is done.
+func (h *MavenHandler) spoolAndCache(c *gin.Context, resp *http.Response, upstreamURL, cachePath string, body io.Reader, length int64, write *cacheWrite) {
+	contentType := resp.Header.Get("Content-Type")
+	spool, err := os.CreateTemp("", "maven-proxy-*")
+	if err != nil {
+		log.Printf("Failed to spool %s, serving without caching: %v\n", cachePath, err)
+		c.DataFromReader(http.StatusOK, length, contentType, body, nil)
+		write.finish(false)
+		return
+	}
+
+	complete := false
+	reader := &NotifyReader{
+		Reader: io.TeeReader(body, spool),
+		OnEOF:  func() { complete = true },
+	}
+	c.DataFromReader(http.StatusOK, length, contentType, reader, nil)
+	if !complete {
+		spool.Close()
+		os.Remove(spool.Name())
+		write.finish(false)
+		return
+	}
+
+	save := func() {
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		write.finish(h.saveSpool(spool, upstreamURL, cachePath, resp))
+	}
+	if h.Config.ProxyCacheAsync {
+		go save()
+		return
+	}
+	save()
+}
+
+// saveSpool caches a completely spooled upstream artifact and reports whether
+// it was saved.
+func (h *MavenHandler) saveSpool(spool *os.File, upstreamURL, cachePath string, resp *http.Response) bool {
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		log.Printf("Failed to cache %s: %v\n", cachePath, err)
+		return false
+	}
+	if err := h.Store.Save(cachePath, spool); err != nil {
+		log.Printf("Failed to cache %s: %v\n", cachePath, err)
+		return false
+	}
+	h.recordProxyProvenance(cachePath, upstreamURL, resp)
+	h.listings.invalidate(cachePath)
+	return true
+}
+
+// decodeUpstreamBody returns the identity bytes of an upstream response so the
+// cache never stores content-encoded data. The length is -1 whenever the body
+// had to be decoded, since Content-Length described the encoded form.
+func decodeUpstreamBody(resp *http.Response) (io.Reader, int64, error) {
+	if resp.Uncompressed {
+		// Already decoded transparently by the transport
+		return resp.Body, -1, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "", "identity":
+		return resp.Body, resp.ContentLength, nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, 0, err
+		}
+		return zr, -1, nil
+	case "deflate":
+		zr, err := zlib.NewReader(resp.Body)
+		if err != nil {
+			return nil, 0, err
+		}
+		return zr, -1, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported content encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+}
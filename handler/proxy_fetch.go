@@ -0,0 +1,364 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"maven_repo/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// artifactPathForProxy derives the path to request from the upstreams, which
+// are plain Maven repositories, from a storage path: the "repository/<repo>/"
+// or "public/" prefix is dropped and empty segments are collapsed. Paths
+// without either prefix are requested as they are.
+func artifactPathForProxy(p string) string {
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) > 0 {
+		switch parts[0] {
+		case "repository":
+			parts = parts[min(2, len(parts)):]
+		case "public":
+			parts = parts[1:]
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// proxyFetch is the outcome of requesting one upstream URL.
+type proxyFetch struct {
+	// served is set when the fetching request has answered its own client
+	served bool
+	// notFound is set when the upstream answered 404
+	notFound bool
+	// unreachable is set when the upstream could not be reached at all
+	unreachable bool
+	upstreamErr *upstreamStatusError
+	// rejected explains why a download failed checksum verification
+	rejected string
+	// cache is set when the body was streamed and is being cached
+	cache *cacheWrite
+}
+
+// fetchFromProxy runs fetch, which tries the upstream(s) identified by key for
+// cachePath. Concurrent requests for the same key and cache path share a single
+// upstream fetch: the first one streams the artifact to its client and caches
+// it, the others wait and are served the cached copy. When that fetch produced
+// nothing to serve, they carry on with the next proxy just like the first
+// request does. When it served the first client without caching the artifact
+// (a disconnect, a failed save, a body over the size limit), each of the
+// others runs fetch itself. Only a request running fetch takes a proxy slot,
+// and only for as long as fetch runs.
+func (h *MavenHandler) fetchFromProxy(c *gin.Context, key, cachePath string, fetch func() proxyFetch) proxyFetch {
+	leader := false
+	v, _, _ := h.fetches.Do(cachePath+"\n"+key, func() (interface{}, error) {
+		leader = true
+		release := h.acquireProxySlot()
+		defer release()
+		return fetch(), nil
+	})
+	result := v.(proxyFetch)
+	if leader || !result.served {
+		return result
+	}
+
+	if result.cache != nil && result.cache.wait() {
+		reader, found, err := h.Store.Get(cachePath)
+		if err == nil && found {
+			h.serveStored(c, cachePath, reader)
+			c.Set(logger.CacheSourceKey, "proxy")
+			return proxyFetch{served: true}
+		}
+	}
+	// Only the first request's client has been answered
+	release := h.acquireProxySlot()
+	defer release()
+	return fetch()
+}
+
+// add folds the outcome of one more proxy into f and reports whether the next
+// proxy should still be tried.
+func (f *proxyFetch) add(next proxyFetch) bool {
+	if next.served {
+		*f = next
+		return false
+	}
+	f.notFound = f.notFound && next.notFound
+	f.unreachable = f.unreachable || next.unreachable
+	if next.rejected != "" {
+		f.rejected = next.rejected
+	}
+	if next.upstreamErr != nil {
+		f.upstreamErr = next.upstreamErr
+		return next.upstreamErr.retryable()
+	}
+	return true
+}
+
+// fetchFromProxies tries the proxies until one serves artifactPath: the one a
+// recent HEAD found it at first, then the others in the configured order, or
+// all at once when MAVEN_PROXY_MODE is parallel. When every upstream answered
+// 404 the miss is remembered in the negative cache under cachePath. Upstreams
+// that fail or cannot be reached make it answer 502 instead of leaving the 404
+// to the caller. Paths excluded from proxying are left to the caller without
+// asking any upstream. It reports whether the client has been answered.
+func (h *MavenHandler) fetchFromProxies(c *gin.Context, artifactPath, cachePath string) bool {
+	if !h.proxyAllowed(artifactPath) {
+		return false
+	}
+	defer h.heads.forget(artifactPath)
+	result := proxyFetch{notFound: true}
+	more := true
+	tried := ""
+	if head, ok := h.heads.get(artifactPath); ok {
+		// A recent HEAD found it there, so skip probing the others
+		tried = head.url
+		more = result.add(h.fetchFromProxy(c, head.url, cachePath, func() proxyFetch {
+			return h.fetchAndServe(c, head.url, artifactPath, cachePath)
+		}))
+	}
+	switch {
+	case !more:
+		// Served, or failed in a way other proxies would not fix
+	case h.Config.ProxyMode == "parallel":
+		result.add(h.fetchFromProxy(c, "*", cachePath, func() proxyFetch {
+			return h.fetchFromFastestProxy(c, artifactPath, cachePath)
+		}))
+	default:
+		for _, proxy := range h.Config.ProxyURLs {
+			url := strings.TrimRight(proxy, "/") + "/" + artifactPath
+			if url == tried {
+				continue
+			}
+			fetch := h.fetchFromProxy(c, url, cachePath, func() proxyFetch {
+				return h.fetchAndServe(c, url, artifactPath, cachePath)
+			})
+			if !result.add(fetch) {
+				break
+			}
+		}
+	}
+
+	if result.served {
+		return true
+	}
+	if result.upstreamErr != nil {
+		respondUpstreamError(c, result.upstreamErr)
+		return true
+	}
+	if result.rejected != "" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": result.rejected})
+		return true
+	}
+	if result.unreachable {
+		// The artifact may well be on the upstream that could not be
+		// reached, so this is no reason for a build to give up on it
+		respondUnreachable(c, artifactPath)
+		return true
+	}
+	if result.notFound {
+		h.negative.set(cachePath)
+	}
+	return false
+}
+
+// fetchAndServe requests url and, on a hit, streams it to the client while
+// caching it at cachePath.
+func (h *MavenHandler) fetchAndServe(c *gin.Context, url, artifactPath, cachePath string) proxyFetch {
+	resp, err := h.upstreamRequestWithRetry(c.Request.Context(), http.MethodGet, url)
+	if err != nil {
+		return proxyFetch{unreachable: true}
+	}
+	return h.serveUpstream(c, resp, url, artifactPath, cachePath)
+}
+
+// fetchFromFastestProxy requests artifactPath from every proxy at once and
+// serves the first hit, like fetchAndServe.
+func (h *MavenHandler) fetchFromFastestProxy(c *gin.Context, artifactPath, cachePath string) proxyFetch {
+	resp, url, miss := h.firstProxyResponse(c.Request.Context(), http.MethodGet, artifactPath, func(resp *http.Response) bool {
+		return resp.StatusCode == http.StatusOK && h.acceptUpstreamContentType(artifactPath, resp.Header.Get("Content-Type"))
+	})
+	if resp == nil {
+		return miss
+	}
+	return h.serveUpstream(c, resp, url, artifactPath, cachePath)
+}
+
+// serveUpstream answers with the upstream response for url and closes it. On a
+// hit the artifact is streamed to the client while being cached at cachePath.
+func (h *MavenHandler) serveUpstream(c *gin.Context, resp *http.Response, url, artifactPath, cachePath string) proxyFetch {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return proxyFetch{notFound: resp.StatusCode == http.StatusNotFound, upstreamErr: checkUpstreamStatus(url, resp)}
+	}
+
+	// If upstream returns HTML (directory listing) or another non-artifact body,
+	// we don't want to cache it as a file. We treat this as not found.
+	if !h.acceptUpstreamContentType(artifactPath, resp.Header.Get("Content-Type")) {
+		return proxyFetch{}
+	}
+	if h.proxyTooLarge(c, resp) {
+		return proxyFetch{served: true}
+	}
+	if h.Config.ProxyVerifyChecksums && !isSidecar(artifactPath) {
+		return h.fetchVerified(c, resp, url, cachePath)
+	}
+	c.Set(logger.CacheSourceKey, "proxy")
+	return proxyFetch{served: true, cache: h.streamAndCache(c, resp, url, cachePath, h.heads.sizeAt(artifactPath, url))}
+}
+
+// firstProxyResponse sends the same request for artifactPath to every proxy at
+// once and returns the first response accepted by accept, along with its URL.
+// The other requests are cancelled as soon as there is a winner. When no
+// proxy's response is accepted, the combined miss is returned instead.
+func (h *MavenHandler) firstProxyResponse(ctx context.Context, method, artifactPath string, accept func(*http.Response) bool) (*http.Response, string, proxyFetch) {
+	type answer struct {
+		index int
+		url   string
+		resp  *http.Response
+		miss  proxyFetch
+	}
+	proxies := h.Config.ProxyURLs
+	answers := make(chan answer, len(proxies))
+	cancels := make([]context.CancelFunc, len(proxies))
+	for i, proxy := range proxies {
+		reqCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		url := strings.TrimRight(proxy, "/") + "/" + artifactPath
+		go func() {
+			resp, err := h.upstreamRequestWithRetry(reqCtx, method, url)
+			if err != nil {
+				answers <- answer{index: i, url: url, miss: proxyFetch{unreachable: true}}
+				return
+			}
+			if accept(resp) {
+				answers <- answer{index: i, url: url, resp: resp}
+				return
+			}
+			resp.Body.Close()
+			answers <- answer{index: i, url: url, miss: proxyFetch{notFound: resp.StatusCode == http.StatusNotFound, upstreamErr: checkUpstreamStatus(url, resp)}}
+		}()
+	}
+
+	miss := proxyFetch{notFound: true}
+	for received := 1; received <= len(proxies); received++ {
+		a := <-answers
+		if a.resp == nil {
+			cancels[a.index]()
+			miss.add(a.miss)
+			continue
+		}
+
+		for i, cancel := range cancels {
+			if i != a.index {
+				cancel()
+			}
+		}
+		// Late hits from the cancelled requests are discarded
+		go func(pending int) {
+			for ; pending > 0; pending-- {
+				if late := <-answers; late.resp != nil {
+					late.resp.Body.Close()
+				}
+			}
+		}(len(proxies) - received)
+		a.resp.Body = &cancelOnClose{ReadCloser: a.resp.Body, cancel: cancels[a.index]}
+		return a.resp, a.url, proxyFetch{}
+	}
+	return nil, "", miss
+}
+
+// cancelOnClose releases the context of a request once its body is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// proxyHead asks the proxies whether they have artifactPath, in order or all
+// at once like fetchFromProxies, and returns the first hit. Hits are kept in
+// the HEAD cache, which answers repeated requests without asking again. When
+// no proxy has it and any of them failed or could not be reached, the error
+// says so; a nil error means every proxy cleanly reported it missing.
+func (h *MavenHandler) proxyHead(c *gin.Context, artifactPath string) (upstreamHead, bool, error) {
+	if !h.proxyAllowed(artifactPath) {
+		return upstreamHead{}, false, nil
+	}
+	if head, ok := h.heads.get(artifactPath); ok {
+		return head, true, nil
+	}
+	var resp *http.Response
+	var url string
+	miss := proxyFetch{notFound: true}
+	if h.Config.ProxyMode == "parallel" {
+		resp, url, miss = h.firstProxyResponse(c.Request.Context(), http.MethodHead, artifactPath, func(resp *http.Response) bool {
+			return resp.StatusCode == http.StatusOK
+		})
+	} else {
+		for _, proxy := range h.Config.ProxyURLs {
+			url = strings.TrimRight(proxy, "/") + "/" + artifactPath
+			r, err := h.upstreamRequestWithRetry(c.Request.Context(), http.MethodHead, url)
+			if err != nil {
+				miss.add(proxyFetch{unreachable: true})
+				continue
+			}
+			r.Body.Close()
+			if r.StatusCode == http.StatusOK {
+				resp = r
+				break
+			}
+			if !miss.add(proxyFetch{notFound: r.StatusCode == http.StatusNotFound, upstreamErr: checkUpstreamStatus(url, r)}) {
+				// Refused in a way other proxies would not fix, as on GET
+				break
+			}
+		}
+	}
+	if resp == nil {
+		switch {
+		case miss.upstreamErr != nil:
+			return upstreamHead{}, false, miss.upstreamErr
+		case miss.unreachable:
+			return upstreamHead{}, false, errUpstreamUnreachable
+		}
+		return upstreamHead{}, false, nil
+	}
+	resp.Body.Close()
+
+	head := upstreamHead{url: url, size: resp.ContentLength, contentType: resp.Header.Get("Content-Type")}
+	h.heads.set(artifactPath, head)
+	return head, true, nil
+}
+
+// serveStored answers with a file read from storage, honouring pins,
+// conditional requests and ranges. Stale proxied copies of mutable files are
+// revalidated against their upstream first.
+func (h *MavenHandler) serveStored(c *gin.Context, path string, reader io.ReadCloser) {
+	if h.revalidateCached(c, path, reader) {
+		return
+	}
+	reader, ok := h.verifyPinnedDownload(c, path, reader)
+	if !ok {
+		return
+	}
+	defer reader.Close()
+	c.Set(logger.CacheSourceKey, "local")
+	h.touchAccess(path)
+	size, notModified := h.writeFileHeaders(c, path, reader)
+	if notModified {
+		return
+	}
+	serveFile(c, h.verifyWhileServing(path, reader), size, "application/octet-stream")
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+// concurrentGets issues n simultaneous GETs and returns the responses once
+// release has been called and all requests have finished.
+func concurrentGets(r http.Handler, target string, n int, release func()) []*httptest.ResponseRecorder {
+	responses := make([]*httptest.ResponseRecorder, n)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = doRequest(r, http.MethodGet, target, "")
+		}(i)
+	}
+	// Give every request time to join the in-flight fetch
+	time.Sleep(100 * time.Millisecond)
+	release()
+	wg.Wait()
+	return responses
+}
+
+func TestHandleDownload_DedupesConcurrentProxyFetches(t *testing.T) {
+	var hits atomic.Int32
+	unblock := make(chan struct{})
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-unblock
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar-bytes"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+
+	target := "/repository/maven-public/com/example/app/1.0/app-1.0.jar"
+	for i, w := range concurrentGets(r, target, 10, func() { close(unblock) }) {
+		if w.Code != http.StatusOK || w.Body.String() != "jar-bytes" {
+			t.Errorf("request %d: got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected a single upstream fetch, got %d", n)
+	}
+}
+
+func TestHandleDownload_SharedFetchFailureFallsThrough(t *testing.T) {
+	var failing atomic.Int32
+	unblock := make(chan struct{})
+	broken := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		<-unblock
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	mirror := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar-bytes"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{broken.URL, mirror.URL}})
+
+	target := "/repository/maven-public/com/example/app/1.0/app-1.0.jar"
+	for i, w := range concurrentGets(r, target, 5, func() { close(unblock) }) {
+		if w.Code != http.StatusOK || w.Body.String() != "jar-bytes" {
+			t.Errorf("request %d: expected the next proxy to serve, got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := failing.Load(); n != 1 {
+		t.Errorf("expected a single fetch from the failing upstream, got %d", n)
+	}
+}
+
+func TestHandleDownload_SharedFetchLeaderDisconnects(t *testing.T) {
+	var hits atomic.Int32
+	unblock := make(chan struct{})
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar-"))
+		if hits.Add(1) == 1 {
+			w.(http.Flusher).Flush()
+			<-unblock
+		}
+		w.Write([]byte("bytes"))
+	})
+	r, _, base := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+	target := "/repository/maven-public/com/example/app/1.0/app-1.0.jar"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	for hits.Load() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	followerDone := make(chan *httptest.ResponseRecorder, 1)
+	go func() { followerDone <- doRequest(r, http.MethodGet, target, "") }()
+	// Give the follower time to join the leader's fetch, then drop the leader
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	<-leaderDone
+	close(unblock)
+
+	w := <-followerDone
+	if w.Code != http.StatusOK || w.Body.String() != "jar-bytes" {
+		t.Fatalf("expected the follower to get the artifact, got %d %q", w.Code, w.Body.String())
+	}
+	if data, err := os.ReadFile(filepath.Join(base, strings.TrimPrefix(target, "/"))); string(data) != "jar-bytes" {
+		t.Errorf("expected the follower's fetch to be cached, got %q, %v", data, err)
+	}
+}
+
+func TestHandleDownload_ParallelProxies(t *testing.T) {
+	slowCancelled := make(chan struct{}, 2)
+	var slowStarted atomic.Int32
+	slow := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		slowStarted.Add(1)
+		select {
+		case <-r.Context().Done():
+			slowCancelled <- struct{}{}
+		case <-time.After(5 * time.Second):
+		}
+	})
+	missing := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	fast := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{slow.URL, missing.URL, fast.URL}, ProxyMode: "parallel"})
+
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		start := time.Now()
+		w := doRequest(r, method, "/repository/releases/com/example/app/1.0/app-1.0.jar", "")
+		if w.Code != http.StatusOK || time.Since(start) > 2*time.Second {
+			t.Errorf("%s: got %d after %v", method, w.Code, time.Since(start))
+		}
+		// A request cancelled before it reached the upstream never shows up
+		select {
+		case <-slowCancelled:
+		case <-time.After(2 * time.Second):
+			if slowStarted.Swap(0) > 0 {
+				t.Errorf("%s: the slow upstream request was not cancelled", method)
+			}
+		}
+		slowStarted.Store(0)
+	}
+	if found, _ := h.Store.Head("repository/releases/com/example/app/1.0/app-1.0.jar"); !found {
+		t.Error("the winning response was not cached")
+	}
+
+	// Every upstream answering 404 is still a miss
+	r, _, _ = newTestRouter(t, &config.Config{ProxyURLs: []string{missing.URL, fast.URL}, ProxyMode: "parallel"})
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/missing/1.0/missing-1.0.jar", ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing artifact: got %d", w.Code)
+	}
+}
+
+func TestArtifactPathForProxy(t *testing.T) {
+	for path, want := range map[string]string{
+		"repository/releases/com/example/app/1.0/app-1.0.jar": "com/example/app/1.0/app-1.0.jar",
+		"repository/maven-public/org/example/lib/lib.pom":     "org/example/lib/lib.pom",
+		"public/com/example/app/1.0/app-1.0.jar":              "com/example/app/1.0/app-1.0.jar",
+		"com/example/app/1.0/app-1.0.jar":                     "com/example/app/1.0/app-1.0.jar",
+		"/repository/releases/com/example/":                   "com/example",
+		"repository//releases//com//example/app.jar":          "com/example/app.jar",
+		"repository/releases/repository/x/app.jar":            "repository/x/app.jar",
+		"repository/releases":                                 "",
+		"repository":                                          "",
+		"public":                                              "",
+		"repositories/releases/app.jar":                       "repositories/releases/app.jar",
+		"":                                                    "",
+	} {
+		if got := artifactPathForProxy(path); got != want {
+			t.Errorf("artifactPathForProxy(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestHandleHead_ProxiesSamePathAsDownload(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]string)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.Method] = r.URL.Path
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL + "/maven2"}})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodHead, target, ""); w.Code != http.StatusOK {
+		t.Fatalf("HEAD: expected 200, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, target, ""); w.Code != http.StatusOK {
+		t.Fatalf("GET: expected 200, got %d", w.Code)
+	}
+	want := "/maven2/com/example/app/1.0/app-1.0.jar"
+	if requested[http.MethodHead] != want || requested[http.MethodGet] != want {
+		t.Errorf("expected HEAD and GET to request %s, got %v", want, requested)
+	}
+}
+
+func TestHandleDownload_FollowersDoNotTakeProxySlots(t *testing.T) {
+	var hits atomic.Int32
+	unblock := make(chan struct{})
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		<-unblock
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar-bytes"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyMaxConcurrency: 1})
+
+	// With a single slot, followers queueing for it would each fetch again
+	target := "/repository/maven-public/com/example/app/1.0/app-1.0.jar"
+	for i, w := range concurrentGets(r, target, 5, func() { close(unblock) }) {
+		if w.Code != http.StatusOK || w.Body.String() != "jar-bytes" {
+			t.Errorf("request %d: got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("expected a single upstream fetch, got %d", n)
+	}
+}
+
+func TestHandleHead_StopsOnRefusingUpstream(t *testing.T) {
+	var mirrorHits atomic.Int32
+	refusing := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	mirror := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mirrorHits.Add(1)
+		w.Header().Set("Content-Type", "application/java-archive")
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{refusing.URL, mirror.URL}})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodHead, target, ""); w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 like GET, got %d", w.Code)
+	}
+	if n := mirrorHits.Load(); n != 0 {
+		t.Errorf("expected the next proxy not to be asked, got %d requests", n)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"path"
+	"strings"
+)
+
+// proxyAllowed reports whether artifactPath may be requested from upstream:
+// it has to match one of MAVEN_PROXY_INCLUDE, when any are set, and none of
+// MAVEN_PROXY_EXCLUDE. Excluding internal group IDs keeps their coordinates
+// from ever reaching a public mirror.
+func (h *MavenHandler) proxyAllowed(artifactPath string) bool {
+	for _, pattern := range h.Config.ProxyExclude {
+		if matchGlob(pattern, artifactPath) {
+			return false
+		}
+	}
+	if len(h.Config.ProxyInclude) == 0 {
+		return true
+	}
+	for _, pattern := range h.Config.ProxyInclude {
+		if matchGlob(pattern, artifactPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against pattern. Each segment of
+// pattern uses path.Match syntax, and a "**" segment matches any number of
+// segments, so "com/example/**" matches com/example and everything below it.
+func matchGlob(pattern, p string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(strings.Trim(p, "/"), "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchSegments(pattern[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
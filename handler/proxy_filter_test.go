@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"com/**", "com/example/app/1.0/app-1.0.jar", true},
+		{"com/**", "org/example/app/1.0/app-1.0.jar", false},
+		{"com/mycorp/**", "com/mycorp", true},
+		{"com/mycorp/**", "com/mycorporation/app/1.0/app-1.0.jar", false},
+		{"com/*/internal/**", "com/mycorp/internal/lib/1.0/lib-1.0.pom", true},
+		{"com/*/internal/**", "com/mycorp/public/lib/1.0/lib-1.0.pom", false},
+		{"**/*-sources.jar", "org/example/app/1.0/app-1.0-sources.jar", true},
+		{"**/*-sources.jar", "org/example/app/1.0/app-1.0.jar", false},
+		{"org/example/app", "org/example/app/1.0", false},
+	} {
+		if got := matchGlob(tc.pattern, tc.path); got != tc.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tc.pattern, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestHandleDownload_ProxyIncludeExclude(t *testing.T) {
+	var hits atomic.Int32
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+	r, h, _ := newTestRouter(t, &config.Config{
+		ProxyURLs:    []string{upstream.URL},
+		ProxyInclude: []string{"com/**", "org/**"},
+		ProxyExclude: []string{"com/mycorp/**"},
+	})
+	r.HEAD("/aggregate/*path", h.HandleAggregateHead("repository"))
+
+	for _, tc := range []struct {
+		target string
+		want   int
+	}{
+		{"/repository/releases/com/example/app/1.0/app-1.0.jar", http.StatusOK},
+		{"/repository/releases/com/mycorp/secret/1.0/secret-1.0.jar", http.StatusNotFound},
+		{"/repository/releases/net/example/app/1.0/app-1.0.jar", http.StatusNotFound},
+	} {
+		before := hits.Load()
+		if w := doRequest(r, http.MethodGet, tc.target, ""); w.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.target, tc.want, w.Code)
+		}
+		if tc.want == http.StatusNotFound && hits.Load() != before {
+			t.Errorf("%s: blocked path reached the upstream", tc.target)
+		}
+	}
+
+	before := hits.Load()
+	if w := doRequest(r, http.MethodHead, "/aggregate/com/mycorp/secret/1.0/secret-1.0.pom", ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD of an excluded path: expected 404, got %d", w.Code)
+	}
+	if hits.Load() != before {
+		t.Error("HEAD of an excluded path reached the upstream")
+	}
+}
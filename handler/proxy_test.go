@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+// newUpstream starts a fake upstream repository serving fixed bodies per path.
+func newUpstream(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHandleDownload_ContentTypeAllowlist(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("not an artifact"))
+	})
+
+	r, _, base := newTestRouter(t, &config.Config{
+		ProxyURLs:                []string{upstream.URL},
+		ProxyAllowedContentTypes: []string{"application/"},
+		ProxyBlockedContentTypes: []string{"text/html"},
+	})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodGet, target, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected text/plain artifact to be treated as a miss, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(base, "repository/releases/com/example/app/1.0/app-1.0.jar")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be cached, stat err: %v", err)
+	}
+
+	// Checksum sidecars are legitimately text/plain
+	w := doRequest(r, http.MethodGet, target+".sha1", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected sidecar to be proxied, got %d", w.Code)
+	}
+}
+
+func TestAcceptUpstreamContentType(t *testing.T) {
+	h := &MavenHandler{Config: &config.Config{
+		ProxyAllowedContentTypes: []string{"application/", "text/xml"},
+		ProxyBlockedContentTypes: []string{"text/html"},
+	}}
+	cases := []struct {
+		path        string
+		contentType string
+		want        bool
+	}{
+		{"a.jar", "application/java-archive", true},
+		{"a.pom", "text/xml; charset=utf-8", true},
+		{"a.jar", "", true},
+		{"a.jar", "application/json", true},
+		{"a.jar", "text/plain", false},
+		{"a.jar", "text/html; charset=utf-8", false},
+		{"a.jar.sha1", "text/plain", true},
+		{"a.jar.sha1", "text/html", false},
+	}
+	for _, tc := range cases {
+		if got := h.acceptUpstreamContentType(tc.path, tc.contentType); got != tc.want {
+			t.Errorf("%s (%q): expected %v, got %v", tc.path, tc.contentType, tc.want, got)
+		}
+	}
+}
+
+func TestHandleDownload_ProxyMaxSize(t *testing.T) {
+	body := strings.Repeat("x", 1024)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		if strings.Contains(r.URL.Path, "chunked") {
+			// Flushing before writing hides the length from the client
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(body))
+	})
+	r, _, base := newTestRouter(t, &config.Config{
+		ProxyURLs:    []string{upstream.URL},
+		ProxyMaxSize: 100,
+	})
+
+	// Announced length above the limit is rejected up front
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/big/1.0/big-1.0.jar", "")
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected 502 for oversized upstream, got %d", w.Code)
+	}
+
+	// Unknown length is aborted mid-stream
+	target := "repository/releases/com/example/chunked/1.0/chunked-1.0.jar"
+	w = doRequest(r, http.MethodGet, "/"+target, "")
+	if w.Body.Len() >= len(body) {
+		t.Fatalf("expected transfer to be aborted, got %d bytes", w.Body.Len())
+	}
+
+	for _, p := range []string{"repository/releases/com/example/big/1.0/big-1.0.jar", target} {
+		cached := filepath.Join(base, p)
+		deadline := time.Now().Add(time.Second)
+		for {
+			_, err := os.Stat(cached)
+			if os.IsNotExist(err) {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s not to be cached", p)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestHandleDownload_GzipEncodedUpstream(t *testing.T) {
+	artifact := strings.Repeat("jar-bytes", 100)
+	var encoded bytes.Buffer
+	zw := gzip.NewWriter(&encoded)
+	zw.Write([]byte(artifact))
+	zw.Close()
+
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		// Encode regardless of what the client asked for
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(encoded.Bytes())
+	})
+
+	for name, client := range map[string]*http.Client{
+		"transparent": {},
+		"raw":         {Transport: &http.Transport{DisableCompression: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			r, h, base := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+			h.Client = client
+
+			target := "repository/releases/com/example/app/1.0/app-1.0.jar"
+			w := doRequest(r, http.MethodGet, "/"+target, "")
+			if w.Code != http.StatusOK || w.Body.String() != artifact {
+				t.Fatalf("expected decoded artifact, got %d (%d bytes)", w.Code, w.Body.Len())
+			}
+			if got := w.Header().Get("Content-Length"); got != "" && got != fmt.Sprint(len(artifact)) {
+				t.Errorf("Content-Length %s does not match decoded size", got)
+			}
+
+			cached := filepath.Join(base, target)
+			deadline := time.Now().Add(time.Second)
+			for {
+				data, err := os.ReadFile(cached)
+				if err == nil && string(data) == artifact {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("cached file is not the decoded artifact (err %v, %d bytes)", err, len(data))
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func TestHandleDownload_UpstreamStatuses(t *testing.T) {
+	statusUpstream := func(status int, hits *int) *httptest.Server {
+		return newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+			*hits++
+			w.WriteHeader(status)
+		})
+	}
+	var forbiddenHits, brokenHits, missingHits, goodHits int
+	forbidden := statusUpstream(http.StatusForbidden, &forbiddenHits)
+	broken := statusUpstream(http.StatusInternalServerError, &brokenHits)
+	missing := statusUpstream(http.StatusNotFound, &missingHits)
+	good := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		goodHits++
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	cases := []struct {
+		name    string
+		proxies []string
+		want    int
+	}{
+		{"403 surfaces as 502", []string{forbidden.URL, good.URL}, http.StatusBadGateway},
+		{"500 falls through to the next proxy", []string{broken.URL, good.URL}, http.StatusOK},
+		{"500 everywhere is a 502", []string{broken.URL, missing.URL}, http.StatusBadGateway},
+		{"404 is a clean miss", []string{missing.URL}, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: tc.proxies})
+			w := doRequest(r, http.MethodGet, target, "")
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusBadGateway && !strings.Contains(w.Body.String(), `"upstreamStatus"`) {
+				t.Errorf("expected the upstream status in the body: %s", w.Body.String())
+			}
+		})
+	}
+	if goodHits != 1 {
+		t.Errorf("expected the healthy proxy to be reached only after a 5xx, got %d hits", goodHits)
+	}
+}
+
+func TestHandleDownload_UnreachableUpstream(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	missing := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	good := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	cases := []struct {
+		name    string
+		proxies []string
+		want    int
+	}{
+		{"unreachable is a 502", []string{down.URL}, http.StatusBadGateway},
+		{"unreachable next to a miss is a 502", []string{missing.URL, down.URL}, http.StatusBadGateway},
+		{"unreachable falls through to the next proxy", []string{down.URL, good.URL}, http.StatusOK},
+		{"404 is a clean miss", []string{missing.URL}, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, method := range []string{http.MethodHead, http.MethodGet} {
+				r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: tc.proxies})
+				if w := doRequest(r, method, target, ""); w.Code != tc.want {
+					t.Errorf("%s: expected %d, got %d: %s", method, tc.want, w.Code, w.Body.String())
+				}
+			}
+		})
+	}
+}
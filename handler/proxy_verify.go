@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+
+	"maven_repo/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fetchVerified is fetchAndServe's counterpart for MAVEN_PROXY_VERIFY_CHECKSUMS.
+// The artifact is spooled completely and compared with the .sha1 published
+// next to it upstream before anything is cached or sent, so a truncated or
+// corrupt response is rejected and the next proxy gets a chance instead.
+func (h *MavenHandler) fetchVerified(c *gin.Context, resp *http.Response, url, cachePath string) proxyFetch {
+	spool, err := os.CreateTemp("", "maven-proxy-*")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return proxyFetch{served: true}
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	size, err := h.spoolVerified(c.Request.Context(), resp, url, spool)
+	if errors.Is(err, errProxyTooLarge) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return proxyFetch{served: true}
+	}
+	if err != nil {
+		return proxyFetch{rejected: err.Error()}
+	}
+
+	write := newCacheWrite()
+	write.finish(h.saveSpool(spool, url, cachePath, resp))
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return proxyFetch{served: true, cache: write}
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		c.Header("Last-Modified", lastModified)
+	}
+	c.Set(logger.CacheSourceKey, "proxy")
+	c.DataFromReader(http.StatusOK, size, resp.Header.Get("Content-Type"), spool, nil)
+	return proxyFetch{served: true, cache: write}
+}
+
+// spoolVerified copies the upstream response for url into spool and checks
+// it against the .sha1 published next to it. It returns errProxyTooLarge
+// when the body exceeds MAVEN_PROXY_MAX_SIZE; any other error means the
+// download was rejected.
+func (h *MavenHandler) spoolVerified(ctx context.Context, resp *http.Response, url string, spool *os.File) (int64, error) {
+	body, _, err := decodeUpstreamBody(resp)
+	if err != nil {
+		return 0, fmt.Errorf("invalid upstream encoding from %s: %v", redactURL(url), err)
+	}
+	if h.Config.ProxyMaxSize > 0 {
+		body = &maxSizeReader{Reader: body, Limit: h.Config.ProxyMaxSize}
+	}
+
+	sum := sha1.New()
+	size, err := io.Copy(io.MultiWriter(spool, sum), body)
+	if errors.Is(err, errProxyTooLarge) {
+		return 0, err
+	}
+	if err != nil {
+		log.Printf("Discarding incomplete download of %s: %v\n", redactURL(url), err)
+		return 0, fmt.Errorf("incomplete download from %s", redactURL(url))
+	}
+
+	actual := hex.EncodeToString(sum.Sum(nil))
+	expected, err := h.upstreamSHA1(ctx, url)
+	if err != nil {
+		log.Printf("Discarding %s: %v\n", redactURL(url), err)
+		return 0, err
+	}
+	if expected == "" {
+		logger.Debugf("No upstream checksum for %s, caching it unverified", redactURL(url))
+	} else if expected != actual {
+		h.checksumMismatches.Add(1)
+		log.Printf("WARNING: discarding %s: upstream sha1 %s, downloaded %s\n", redactURL(url), expected, actual)
+		return 0, fmt.Errorf("checksum mismatch for %s", redactURL(url))
+	}
+	return size, nil
+}
+
+// upstreamSHA1 fetches the .sha1 published next to url. It returns "" when
+// the upstream has none.
+func (h *MavenHandler) upstreamSHA1(ctx context.Context, url string) (string, error) {
+	resp, err := h.upstreamRequest(ctx, http.MethodGet, url+".sha1")
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum for %s: %v", redactURL(url), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch checksum for %s: upstream returned %d", redactURL(url), resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch checksum for %s: %v", redactURL(url), err)
+	}
+	// Sidecars written as "<digest>  <filename>" are accepted too
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 || !sha1Hex.MatchString(strings.ToLower(fields[0])) {
+		return "", fmt.Errorf("invalid checksum published for %s", redactURL(url))
+	}
+	return strings.ToLower(fields[0]), nil
+}
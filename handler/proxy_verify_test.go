@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+// newChecksumUpstream serves body for every artifact and sha1 as its .sha1;
+// an empty sha1 means the upstream publishes none.
+func newChecksumUpstream(t *testing.T, body, sha1 string) string {
+	return newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha1") {
+			if sha1 == "" {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(sha1 + "  app-1.0.jar\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte(body))
+	}).URL
+}
+
+func sha1String(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestHandleDownload_ProxyVerifyChecksums(t *testing.T) {
+	const target = "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	cached := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	good := "jar contents"
+
+	tests := []struct {
+		name     string
+		proxies  []string
+		wantCode int
+		wantBody string
+	}{
+		{"match", []string{newChecksumUpstream(t, good, sha1String(good))}, http.StatusOK, good},
+		{"no checksum published", []string{newChecksumUpstream(t, good, "")}, http.StatusOK, good},
+		{"mismatch falls through", []string{newChecksumUpstream(t, "jar cont", sha1String(good)), newChecksumUpstream(t, good, sha1String(good))}, http.StatusOK, good},
+		{"mismatch everywhere", []string{newChecksumUpstream(t, "jar cont", sha1String(good))}, http.StatusBadGateway, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, base := newTestRouter(t, &config.Config{ProxyURLs: tt.proxies, ProxyVerifyChecksums: true})
+
+			w := doRequest(r, http.MethodGet, target, "")
+			if w.Code != tt.wantCode {
+				t.Fatalf("expected %d, got %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+			data, err := os.ReadFile(filepath.Join(base, cached))
+			if tt.wantBody == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("rejected download was cached: %q, %v", data, err)
+				}
+				return
+			}
+			if w.Body.String() != tt.wantBody {
+				t.Errorf("served %q", w.Body.String())
+			}
+			if string(data) != tt.wantBody {
+				t.Errorf("cached %q, %v", data, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	errMalformedRange = errors.New("malformed range")
+	errMultipleRanges = errors.New("multiple ranges are not supported")
+	errUnsatisfiable  = errors.New("range not satisfiable")
+)
+
+// byteRange is an inclusive byte range within a file.
+type byteRange struct {
+	Start int64
+	End   int64
+}
+
+func (r byteRange) Length() int64 {
+	return r.End - r.Start + 1
+}
+
+// parseRange parses a single "bytes=" Range header value against a file of the
+// given size. Multi-range requests are rejected rather than served as
+// multipart/byteranges.
+func parseRange(header string, size int64) (byteRange, error) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(header, prefix) {
+		return byteRange{}, errMalformedRange
+	}
+	spec := strings.TrimSpace(strings.TrimPrefix(header, prefix))
+	if strings.Contains(spec, ",") {
+		return byteRange{}, errMultipleRanges
+	}
+
+	startStr, endStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return byteRange{}, errMalformedRange
+	}
+	startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+
+	if startStr == "" {
+		// Suffix range: last N bytes
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return byteRange{}, errMalformedRange
+		}
+		if size == 0 {
+			return byteRange{}, errUnsatisfiable
+		}
+		if n > size {
+			n = size
+		}
+		return byteRange{Start: size - n, End: size - 1}, nil
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, errMalformedRange
+	}
+	end := size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, errMalformedRange
+		}
+	}
+	if start >= size {
+		return byteRange{}, errUnsatisfiable
+	}
+	if end >= size {
+		end = size - 1
+	}
+	return byteRange{Start: start, End: end}, nil
+}
+
+// serveFile streams a stored file of the given size (-1 if unknown) to the
+// client, honouring Range requests when the underlying reader is seekable.
+// Known sizes are sent as Content-Length; unknown ones fall back to chunked
+// transfer encoding.
+func serveFile(c *gin.Context, reader io.Reader, size int64, contentType string) {
+	seeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
+		return
+	}
+	c.Header("Accept-Ranges", "bytes")
+
+	rangeHeader := c.GetHeader("Range")
+	if rangeHeader == "" {
+		c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
+		return
+	}
+
+	size, err := seeker.Seek(0, io.SeekEnd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rng, err := parseRange(rangeHeader, size)
+	if err != nil {
+		c.Header("Content-Range", fmt.Sprintf("bytes */%d", size))
+		c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := seeker.Seek(rng.Start, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	headers := map[string]string{
+		"Content-Range": fmt.Sprintf("bytes %d-%d/%d", rng.Start, rng.End, size),
+	}
+	c.DataFromReader(http.StatusPartialContent, rng.Length(), contentType, io.LimitReader(seeker, rng.Length()), headers)
+}
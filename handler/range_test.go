@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func TestParseRange(t *testing.T) {
+	cases := []struct {
+		header string
+		want   byteRange
+		err    error
+	}{
+		{"bytes=0-4", byteRange{0, 4}, nil},
+		{"bytes=5-", byteRange{5, 9}, nil},
+		{"bytes=-3", byteRange{7, 9}, nil},
+		{"bytes=8-100", byteRange{8, 9}, nil},
+		{"bytes=10-", byteRange{}, errUnsatisfiable},
+		{"bytes=abc", byteRange{}, errMalformedRange},
+		{"bytes=4-2", byteRange{}, errMalformedRange},
+		{"items=0-1", byteRange{}, errMalformedRange},
+		{"bytes=0-1,3-4", byteRange{}, errMultipleRanges},
+	}
+	for _, tc := range cases {
+		got, err := parseRange(tc.header, 10)
+		if err != tc.err {
+			t.Errorf("%q: expected err %v, got %v", tc.header, tc.err, err)
+			continue
+		}
+		if err == nil && got != tc.want {
+			t.Errorf("%q: expected %+v, got %+v", tc.header, tc.want, got)
+		}
+	}
+}
+
+func TestHandleDownload_Range(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "0123456789")
+
+	get := func(rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Range", rangeHeader)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("bytes=2-5")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "2345" {
+		t.Fatalf("expected 206 with 2345, got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 2-5/10" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+
+	w = get("bytes=20-")
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("out-of-range start: expected 416, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */10" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+
+	w = get("bytes=garbage")
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("malformed range: expected 416, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes */10" {
+		t.Errorf("unexpected Content-Range %q", got)
+	}
+
+	w = get("bytes=0-1,4-5")
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("multi range: expected 416, got %d", w.Code)
+	}
+}
+
+func TestHandleDownload_ContentLengthAndLastModified(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, target, "0123456789")
+	modTime := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(base, strings.TrimPrefix(target, "/")), modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	w := doRequest(r, http.MethodGet, target, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Length"); got != "10" {
+		t.Errorf("expected Content-Length 10, got %q", got)
+	}
+	if got := w.Header().Get("Last-Modified"); got != "Fri, 01 Mar 2024 12:00:00 GMT" {
+		t.Errorf("unexpected Last-Modified %q", got)
+	}
+}
+
+func TestHandleDownload_ProxyForwardsContentLength(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Header().Set("Content-Length", "3")
+		w.Header().Set("Last-Modified", "Fri, 01 Mar 2024 12:00:00 GMT")
+		w.Write([]byte("jar"))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/lib/1.0/lib-1.0.jar", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("Content-Length"); got != "3" {
+		t.Errorf("expected Content-Length 3, got %q", got)
+	}
+	if got := w.Header().Get("Last-Modified"); got != "Fri, 01 Mar 2024 12:00:00 GMT" {
+		t.Errorf("unexpected Last-Modified %q", got)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// rateLimiter keeps a token bucket per client and request class.
+type rateLimiter struct {
+	limit  rate.Limit
+	burst  int
+	byUser bool
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*rate.Limiter
+	lastSweep time.Time
+}
+
+func newRateLimiter(rps, burst int, byUser bool) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rps
+	}
+	return &rateLimiter{
+		limit:   rate.Limit(rps),
+		burst:   burst,
+		byUser:  byUser,
+		now:     time.Now,
+		buckets: make(map[string]*rate.Limiter),
+	}
+}
+
+// reserve takes a token for key and returns how long the client has to wait
+// when none is left.
+func (l *rateLimiter) reserve(key string) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = rate.NewLimiter(l.limit, l.burst)
+		l.buckets[key] = bucket
+	}
+	if bucket.AllowN(now, 1) {
+		return 0, true
+	}
+	r := bucket.ReserveN(now, 1)
+	wait := r.DelayFrom(now)
+	r.CancelAt(now)
+	return wait, false
+}
+
+// sweep drops buckets that have refilled completely, which behave exactly
+// like new ones, so idle clients do not accumulate.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.TokensAt(now) >= float64(l.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *rateLimiter) key(c *gin.Context) string {
+	if l.byUser {
+		if user := c.GetString(gin.AuthUserKey); user != "" {
+			return "user:" + user
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// RateLimit answers 429 with Retry-After once a client exceeds its request
+// rate. Reads (GET/HEAD) and writes have separate limits; a limit of 0
+// disables it. Clients are told apart by IP, or by authenticated user when
+// MAVEN_RATE_LIMIT_BY is "user", so it must run after BasicAuth.
+func RateLimit(cfg *config.Config) gin.HandlerFunc {
+	byUser := cfg.RateLimitBy == "user"
+	read := newRateLimiter(cfg.RateLimitReadRPS, cfg.RateLimitReadBurst, byUser)
+	write := newRateLimiter(cfg.RateLimitWriteRPS, cfg.RateLimitWriteBurst, byUser)
+	if read == nil && write == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		limiter := write
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			limiter = read
+		}
+		if limiter == nil {
+			c.Next()
+			return
+		}
+		if wait, ok := limiter.reserve(limiter.key(c)); !ok {
+			c.Header("Retry-After", strconv.Itoa(max(1, int(math.Ceil(wait.Seconds())))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "rate limit exceeded"})
+			return
+		}
+		c.Next()
+	}
+}
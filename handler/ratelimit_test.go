@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/repository/*path", RateLimit(&config.Config{
+		RateLimitReadRPS:   1,
+		RateLimitReadBurst: 2,
+		RateLimitWriteRPS:  1,
+		RateLimitBy:        "ip",
+	}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/repository/releases/app.jar", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := request(http.MethodHead, "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("read %d within burst: expected 200, got %d", i, w.Code)
+		}
+	}
+	w := request(http.MethodGet, "10.0.0.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("read over burst: expected 429, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After 1, got %q", w.Header().Get("Retry-After"))
+	}
+
+	// Writes and other clients have their own buckets
+	if w := request(http.MethodPut, "10.0.0.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("write after reads were limited: expected 200, got %d", w.Code)
+	}
+	if w := request(http.MethodPut, "10.0.0.1:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("second write: expected 429, got %d", w.Code)
+	}
+	if w := request(http.MethodGet, "10.0.0.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("other client: expected 200, got %d", w.Code)
+	}
+}
+
+func TestRateLimit_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/*path", RateLimit(&config.Config{}), func(c *gin.Context) { c.Status(http.StatusOK) })
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/a", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200 without limits, got %d", i, w.Code)
+		}
+	}
+}
+
+func TestRateLimiter_ByUserAndSweep(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newRateLimiter(1, 1, true)
+	l.now = func() time.Time { return now }
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	if key := l.key(c); key != "ip:192.0.2.1" {
+		t.Errorf("anonymous key = %q", key)
+	}
+	c.Set(gin.AuthUserKey, "ci")
+	if key := l.key(c); key != "user:ci" {
+		t.Errorf("authenticated key = %q", key)
+	}
+
+	if _, ok := l.reserve("user:ci"); !ok {
+		t.Fatal("first request should pass")
+	}
+	if wait, ok := l.reserve("user:ci"); ok || wait != time.Second {
+		t.Fatalf("second request: ok=%v wait=%v, want refused for 1s", ok, wait)
+	}
+
+	now = now.Add(2 * time.Minute)
+	l.reserve("user:other")
+	if _, ok := l.buckets["user:ci"]; ok {
+		t.Error("refilled bucket should have been swept")
+	}
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"maven_repo/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// refreshRequested reports whether a download asked with ?refresh=true to skip
+// the cached copy and fetch it from upstream again, e.g. after a snapshot was
+// republished there. Only users who may write to the repository can do so;
+// others are answered 403, and pinned artifacts 409; ok is then false. The
+// parameter is ignored without proxies and in release repositories, whose
+// artifacts never change.
+func (h *MavenHandler) refreshRequested(c *gin.Context, path string) (refresh, ok bool) {
+	if c.Query("refresh") != "true" || len(h.Config.ProxyURLs) == 0 || h.isReleaseRepo(c) {
+		return false, true
+	}
+	if !c.GetBool(auth.WriteAccessKey) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "refresh requires write access to the repository"})
+		return false, false
+	}
+	if pin, _ := h.readPin(path); pin != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is pinned to sha1 %s", path, pin)})
+		return false, false
+	}
+	return true, true
+}
+
+// dropCachedSidecars removes the checksums and signature cached next to path,
+// which described the bytes a refresh has just replaced. They are fetched or
+// generated again on their next request.
+func (h *MavenHandler) dropCachedSidecars(path string) {
+	for _, ext := range sidecarExtensions {
+		if found, err := h.Store.Head(path + ext); err == nil && found {
+			h.Store.Delete(path + ext)
+		}
+	}
+	h.listings.invalidate(path)
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"maven_repo/auth"
+	"maven_repo/config"
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleDownload_Refresh(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".sha1") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		fmt.Fprintf(w, "build-%d", version.Load())
+	})
+	gin.SetMode(gin.TestMode)
+	h := NewMavenHandler(storage.NewLocalStorage(t.TempDir()), &config.Config{
+		ProxyURLs:       []string{upstream.URL},
+		ReleaseRepos:    []string{"releases"},
+		ListingCacheTTL: "1m",
+	})
+	r := gin.New()
+	// Stand-in for BasicAuth: X-Test-Write grants write access
+	r.Use(func(c *gin.Context) {
+		c.Set(auth.WriteAccessKey, c.GetHeader("X-Test-Write") == "true")
+	})
+	r.GET("/repository/:repoName/*path", h.HandleDownload)
+	get := func(target string, write bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if write {
+			req.Header.Set("X-Test-Write", "true")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	snapshot := "/repository/snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar"
+	release := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	for _, target := range []string{snapshot, release} {
+		if w := get(target, false); w.Body.String() != "build-1" {
+			t.Fatalf("%s: expected the first build, got %d %q", target, w.Code, w.Body.String())
+		}
+	}
+	h.Store.Save(strings.TrimPrefix(snapshot, "/")+".sha1", strings.NewReader("stale"))
+	version.Store(2)
+
+	if w := get(snapshot+"?refresh=true", false); w.Code != http.StatusForbidden {
+		t.Errorf("refresh without write access: expected 403, got %d", w.Code)
+	}
+	if w := get(snapshot+"?refresh=true", true); w.Body.String() != "build-2" {
+		t.Errorf("refresh: expected the republished build, got %d %q", w.Code, w.Body.String())
+	}
+	if w := get(snapshot, false); w.Body.String() != "build-2" {
+		t.Errorf("expected the refreshed copy to be cached, got %q", w.Body.String())
+	}
+	if found, _ := h.Store.Head(strings.TrimPrefix(snapshot, "/") + ".sha1"); found {
+		t.Error("expected the stale checksum to be dropped")
+	}
+
+	if w := get(release+"?refresh=true", true); w.Body.String() != "build-1" {
+		t.Errorf("release repositories are immutable: expected the cached copy, got %q", w.Body.String())
+	}
+
+	// Dropped sidecars leave the cached listing of their directory stale
+	dir := strings.TrimPrefix(snapshot[:strings.LastIndex(snapshot, "/")], "/")
+	h.listings.set(dir, []byte("cached listing"))
+	h.dropCachedSidecars(strings.TrimPrefix(snapshot, "/"))
+	if _, ok := h.listings.get(dir); ok {
+		t.Error("expected dropping sidecars to invalidate the listing")
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSidecarSize bounds how much of a re-uploaded sidecar is compared with
+// the stored copy.
+const maxSidecarSize = 64 << 10
+
+// targetRepo returns the repository a request will actually write to. The
+// repoName parameter is not enough: storage resolves dot segments, so
+// "snapshots/../releases/..." lands in releases.
+func targetRepo(c *gin.Context) string {
+	rest, ok := strings.CutPrefix(path.Clean("/"+c.Request.URL.Path), "/repository/")
+	if !ok {
+		return ""
+	}
+	repo, _, _ := strings.Cut(rest, "/")
+	return repo
+}
+
+// isReleaseRepo reports whether the target repository refuses redeploys.
+func (h *MavenHandler) isReleaseRepo(c *gin.Context) bool {
+	repo := targetRepo(c)
+	for _, r := range h.Config.ReleaseRepos {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// rejectRedeploy answers the upload itself when p already exists in a release
+// repository: 409 Conflict, except for a sidecar identical to the stored one,
+// which is accepted without rewriting it so deploys still succeed when the
+// server generated the checksums. Snapshot versions stay writable. It reports
+// whether a response was written.
+func (h *MavenHandler) rejectRedeploy(c *gin.Context, p string) bool {
+	if !h.isReleaseRepo(c) || strings.HasSuffix(path.Base(path.Dir(p)), "-SNAPSHOT") {
+		return false
+	}
+
+	exists, err := h.Store.Head(p)
+	if err != nil {
+		if !rejectInvalidPath(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return true
+	}
+	if !exists {
+		return false
+	}
+
+	if isSidecar(p) && h.sameAsStored(c.Request.Body, p) {
+		io.Copy(io.Discard, c.Request.Body)
+		c.Status(http.StatusCreated)
+		return true
+	}
+	io.Copy(io.Discard, c.Request.Body)
+	c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s already exists in release repository %s", p, targetRepo(c))})
+	return true
+}
+
+// sameAsStored compares a small upload with the stored file at p, ignoring
+// surrounding whitespace.
+func (h *MavenHandler) sameAsStored(body io.Reader, p string) bool {
+	uploaded, err := io.ReadAll(io.LimitReader(body, maxSidecarSize))
+	if err != nil {
+		return false
+	}
+	reader, found, err := h.Store.Get(p)
+	if err != nil || !found {
+		return false
+	}
+	defer reader.Close()
+	stored, err := io.ReadAll(io.LimitReader(reader, maxSidecarSize))
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(bytes.TrimSpace(uploaded), bytes.TrimSpace(stored))
+}
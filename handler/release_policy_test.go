@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleUpload_ReleaseRedeployProtection(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{ReleaseRepos: []string{"releases"}, GenerateChecksums: true})
+
+	jar := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodPut, jar, "jar"); w.Code != http.StatusCreated {
+		t.Fatalf("first deploy: expected 201, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, jar, "changed"); w.Code != http.StatusConflict {
+		t.Errorf("redeploy: expected 409, got %d", w.Code)
+	}
+	// Dot segments cannot route a redeploy around the policy
+	if w := doRequest(r, http.MethodPut, "/repository/snapshots/../releases/com/example/app/1.0/app-1.0.jar", "changed"); w.Code != http.StatusConflict {
+		t.Errorf("redeploy through dot segments: expected 409, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, jar, ""); w.Body.String() != "jar" {
+		t.Errorf("stored artifact was overwritten: %q", w.Body.String())
+	}
+
+	// The client's own checksum matches the one the server generated
+	other := "5e29b1e6d3a8a4ae5ae4e5d0ad0e1ef9b8d87c5d"
+	stored := doRequest(r, http.MethodGet, jar+".sha1", "").Body.String()
+	if w := doRequest(r, http.MethodPut, jar+".sha1", stored+"\n"); w.Code != http.StatusCreated {
+		t.Errorf("identical sidecar: expected 201, got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodPut, jar+".sha1", other); w.Code != http.StatusConflict {
+		t.Errorf("different sidecar: expected 409, got %d", w.Code)
+	}
+	if got := doRequest(r, http.MethodGet, jar+".sha1", "").Body.String(); got != stored {
+		t.Errorf("sidecar was overwritten: %q", got)
+	}
+
+	// Metadata is merged, snapshots and other repositories stay writable
+	meta := `<metadata><groupId>com.example</groupId><artifactId>app</artifactId><versioning><versions><version>1.0</version></versions></versioning></metadata>`
+	for i := 0; i < 2; i++ {
+		if w := doRequest(r, http.MethodPut, "/repository/releases/com/example/app/maven-metadata.xml", meta); w.Code != http.StatusCreated {
+			t.Errorf("metadata deploy %d: expected 201, got %d", i, w.Code)
+		}
+		if w := doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.1-SNAPSHOT/app-1.1-SNAPSHOT.jar", "x"); w.Code != http.StatusCreated {
+			t.Errorf("snapshot deploy %d: expected 201, got %d", i, w.Code)
+		}
+		if w := doRequest(r, http.MethodPut, "/repository/develop/com/example/app/1.0/app-1.0.jar", "x"); w.Code != http.StatusCreated {
+			t.Errorf("unrestricted repo deploy %d: expected 201, got %d", i, w.Code)
+		}
+	}
+}
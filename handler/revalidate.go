@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isMutable reports whether a file may change upstream after it was cached:
+// repository metadata (and its checksums) and anything in a -SNAPSHOT version
+// directory. Release artifacts are immutable and never revalidated.
+func isMutable(p string) bool {
+	return strings.HasPrefix(path.Base(p), "maven-metadata") || strings.HasSuffix(path.Dir(p), "-SNAPSHOT")
+}
+
+// revalidateTTL returns how long a proxied mutable file is served from the
+// cache before it is revalidated, or false when revalidation is disabled.
+func (h *MavenHandler) revalidateTTL() (time.Duration, bool) {
+	ttl, err := time.ParseDuration(h.Config.ProxyRevalidateTTL)
+	if err != nil || ttl <= 0 {
+		return 0, false
+	}
+	return ttl, true
+}
+
+// proxyURLFor turns the redacted upstream URL recorded in a provenance record
+// back into a request URL, restoring the credentials of the configured proxy it
+// belongs to. It returns false when that proxy is no longer configured or the
+// path is no longer proxied.
+func (h *MavenHandler) proxyURLFor(recorded string) (string, bool) {
+	for _, proxy := range h.Config.ProxyURLs {
+		base := strings.TrimRight(proxy, "/") + "/"
+		if rest, ok := strings.CutPrefix(recorded, redactURL(base)); ok {
+			return base + rest, h.proxyAllowed(rest)
+		}
+	}
+	return "", false
+}
+
+// revalidateCached checks a proxied copy of a mutable file that was fetched or
+// last revalidated more than MAVEN_PROXY_REVALIDATE_TTL ago with a conditional
+// request using the upstream's ETag and Last-Modified. A 304 keeps the copy
+// for another TTL; a 200 replaces it and is served to the client, in which
+// case reader is closed and true is returned. On any other outcome the cached
+// copy is served as before.
+func (h *MavenHandler) revalidateCached(c *gin.Context, path string, reader io.ReadCloser) bool {
+	ttl, ok := h.revalidateTTL()
+	if !ok || !isMutable(path) {
+		return false
+	}
+	prov, found, err := h.loadProvenance(path)
+	if err != nil || !found || prov.Source != "proxy" || time.Since(prov.Timestamp) < ttl {
+		return false
+	}
+	url, ok := h.proxyURLFor(prov.UpstreamURL)
+	if !ok {
+		return false
+	}
+
+	header := http.Header{}
+	if prov.UpstreamETag != "" {
+		header.Set("If-None-Match", prov.UpstreamETag)
+	}
+	if prov.UpstreamLastModified != "" {
+		header.Set("If-Modified-Since", prov.UpstreamLastModified)
+	}
+	resp, err := h.upstreamRequestWithHeader(c.Request.Context(), http.MethodGet, url, header)
+	if err != nil {
+		log.Printf("Failed to revalidate %s, serving the cached copy: %v\n", path, err)
+		return false
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		resp.Body.Close()
+		prov.Timestamp = time.Now().UTC()
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			prov.UpstreamETag = etag
+		}
+		h.saveProvenance(path, prov)
+		return false
+	case http.StatusOK:
+		if !h.serveUpstream(c, resp, url, path, path).served {
+			return false
+		}
+		reader.Close()
+		return true
+	default:
+		resp.Body.Close()
+		log.Printf("Failed to revalidate %s, serving the cached copy: upstream returned %d\n", path, resp.StatusCode)
+		return false
+	}
+}
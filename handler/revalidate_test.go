@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func TestIsMutable(t *testing.T) {
+	for p, want := range map[string]bool{
+		"repository/maven-public/com/example/app/maven-metadata.xml":                true,
+		"repository/maven-public/com/example/app/maven-metadata.xml.sha1":           true,
+		"repository/maven-public/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar": true,
+		"repository/maven-public/com/example/app/1.0-SNAPSHOT/maven-metadata.xml":   true,
+		"repository/maven-public/com/example/app/1.0/app-1.0.jar":                   false,
+		"repository/maven-public/com/example/app/1.0/app-1.0.pom.sha1":              false,
+		"repository/maven-public/com/example/app-SNAPSHOT-tools/1.0/app-1.0.jar":    false,
+	} {
+		if got := isMutable(p); got != want {
+			t.Errorf("isMutable(%s) = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestHandleDownload_RevalidatesMutableFiles(t *testing.T) {
+	var mu sync.Mutex
+	body, etag, failing := "v1", `"1"`, false
+	var requests, conditional int
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("If-None-Match") != "" {
+			conditional++
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(body))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyRevalidateTTL: "20ms"})
+	metadata := "/repository/releases/com/example/app/maven-metadata.xml"
+	release := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+
+	get := func(target, want string) {
+		t.Helper()
+		if w := doRequest(r, http.MethodGet, target, ""); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Fatalf("GET %s: got %d %q, want %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+	counts := func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests, conditional
+	}
+
+	get(metadata, "v1")
+	get(release, "v1")
+	get(metadata, "v1")
+	if n, _ := counts(); n != 2 {
+		t.Errorf("a fresh cached copy was revalidated: %d upstream requests", n)
+	}
+
+	// Unchanged upstream: 304, the cached copy is kept
+	time.Sleep(30 * time.Millisecond)
+	get(metadata, "v1")
+	if n, cond := counts(); n != 3 || cond != 1 {
+		t.Errorf("after the TTL: %d requests, %d conditional; want 3 and 1", n, cond)
+	}
+
+	// Changed upstream: the new copy is served and cached
+	mu.Lock()
+	body, etag = "v2", `"2"`
+	mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	get(metadata, "v2")
+	get(metadata, "v2")
+	if n, cond := counts(); n != 4 || cond != 2 {
+		t.Errorf("after the upstream changed: %d requests, %d conditional; want 4 and 2", n, cond)
+	}
+
+	// A failing upstream leaves the cached copy in service
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	time.Sleep(30 * time.Millisecond)
+	get(metadata, "v2")
+
+	// Release artifacts are never revalidated
+	before, _ := counts()
+	get(release, "v1")
+	if n, _ := counts(); n != before {
+		t.Error("a release artifact was revalidated")
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rootRedirectTarget resolves the configured landing target. A bare name is
+// taken as a repository under /repository/.
+func rootRedirectTarget(target string) string {
+	if strings.HasPrefix(target, "/") || strings.Contains(target, "://") {
+		return target
+	}
+	return "/repository/" + strings.Trim(target, "/") + "/"
+}
+
+// HandleRootRedirect sends requests for / to the configured default repository.
+func (h *MavenHandler) HandleRootRedirect(c *gin.Context) {
+	c.Redirect(http.StatusFound, rootRedirectTarget(h.Config.RootRedirect))
+}
+
+//go:embed static/favicon.ico
+var favicon []byte
+
+// HandleFavicon serves the embedded favicon, so browsers looking for one do
+// not fill the access log with 404s.
+func HandleFavicon(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "image/x-icon", favicon)
+}
+
+// HandleRootIndex serves a landing page for / that links to every repository
+// under repository/, plus the maven-public aggregate.
+func (h *MavenHandler) HandleRootIndex(c *gin.Context) {
+	entries, err := h.Store.List("repository")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	repos := []string{"maven-public"}
+	for _, e := range visibleEntries(entries) {
+		if e.IsDir && e.Name != "maven-public" {
+			repos = append(repos, e.Name)
+		}
+	}
+	sort.Strings(repos)
+
+	if wantsJSONListing(c) {
+		c.JSON(http.StatusOK, gin.H{"repositories": repos})
+		return
+	}
+	var buf bytes.Buffer
+	buf.WriteString("<html><body><h1>Repositories</h1><hr><ul>")
+	for _, repo := range repos {
+		fmt.Fprintf(&buf, "<li><a href=\"/repository/%s/\">%s/</a></li>", html.EscapeString(url.PathEscape(repo)), html.EscapeString(repo))
+	}
+	buf.WriteString("</ul><hr></body></html>")
+	c.Data(http.StatusOK, "text/html", buf.Bytes())
+}
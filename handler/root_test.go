@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleRootRedirect(t *testing.T) {
+	cases := map[string]string{
+		"maven-public":          "/repository/maven-public/",
+		"develop/":              "/repository/develop/",
+		"/repository/releases/": "/repository/releases/",
+	}
+	for setting, want := range cases {
+		r, h, _ := newTestRouter(t, &config.Config{RootRedirect: setting})
+		r.GET("/", h.HandleRootRedirect)
+
+		w := doRequest(r, http.MethodGet, "/", "")
+		if w.Code != http.StatusFound {
+			t.Fatalf("%s: expected 302, got %d", setting, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != want {
+			t.Errorf("%s: expected redirect to %s, got %s", setting, want, got)
+		}
+	}
+}
+
+func TestHandleRootIndex(t *testing.T) {
+	r, h, _ := newTestRouter(t, &config.Config{})
+	r.GET("/", h.HandleRootIndex)
+	r.GET("/favicon.ico", HandleFavicon)
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app.jar", "x")
+	doRequest(r, http.MethodPut, "/repository/develop/com/example/app.jar", "x")
+
+	body := doRequest(r, http.MethodGet, "/", "").Body.String()
+	for _, link := range []string{`href="/repository/develop/"`, `href="/repository/maven-public/"`, `href="/repository/releases/"`} {
+		if !strings.Contains(body, link) {
+			t.Errorf("expected %s in the landing page: %s", link, body)
+		}
+	}
+	if w := doRequest(r, http.MethodGet, "/?format=json", ""); w.Body.String() != `{"repositories":["develop","maven-public","releases"]}` {
+		t.Errorf("unexpected JSON index: %s", w.Body.String())
+	}
+
+	w := doRequest(r, http.MethodGet, "/favicon.ico", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/x-icon" {
+		t.Fatalf("favicon: got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte{0, 0, 1, 0}) {
+		t.Error("favicon is not an ICO file")
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isNonUniqueSnapshot reports whether p is a file in a snapshot version
+// directory that carries the literal -SNAPSHOT version instead of a
+// timestamp, e.g. app-1.0-SNAPSHOT.jar (or its checksum) in app/1.0-SNAPSHOT/.
+func isNonUniqueSnapshot(p string) bool {
+	version := path.Base(path.Dir(p))
+	base := path.Base(p)
+	if !strings.HasSuffix(version, "-SNAPSHOT") || strings.HasPrefix(base, "maven-metadata") {
+		return false
+	}
+	return strings.Contains(base, "-"+version)
+}
+
+// requiresUniqueSnapshots reports whether the target repository only accepts
+// timestamped snapshot deployments.
+func (h *MavenHandler) requiresUniqueSnapshots(c *gin.Context) bool {
+	repo := targetRepo(c)
+	for _, r := range h.Config.UniqueSnapshotRepos {
+		if r == repo {
+			return true
+		}
+	}
+	return false
+}
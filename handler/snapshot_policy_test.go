@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleUpload_UniqueSnapshotPolicy(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{UniqueSnapshotRepos: []string{"snapshots"}})
+
+	dir := "/repository/snapshots/com/example/app/1.0-SNAPSHOT/"
+	for _, name := range []string{"app-1.0-SNAPSHOT.jar", "app-1.0-SNAPSHOT-sources.jar", "app-1.0-SNAPSHOT.pom.sha1"} {
+		if w := doRequest(r, http.MethodPut, dir+name, "x"); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: expected 400, got %d", name, w.Code)
+		}
+		if w := doRequest(r, http.MethodGet, dir+name, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s was stored", name)
+		}
+	}
+
+	if w := doRequest(r, http.MethodPut, "/repository/releases/../snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar", "x"); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT through dot segments: expected 400, got %d", w.Code)
+	}
+
+	for _, name := range []string{"app-1.0-20240101.120000-1.jar", "app-1.0-20240101.120000-1-sources.jar", "maven-metadata.xml"} {
+		body := "x"
+		if name == "maven-metadata.xml" {
+			body = `<metadata><groupId>com.example</groupId><artifactId>app</artifactId><version>1.0-SNAPSHOT</version></metadata>`
+		}
+		if w := doRequest(r, http.MethodPut, dir+name, body); w.Code != http.StatusCreated {
+			t.Errorf("PUT %s: expected 201, got %d: %s", name, w.Code, w.Body.String())
+		}
+	}
+
+	// Other repositories keep accepting non-unique snapshots
+	if w := doRequest(r, http.MethodPut, "/repository/develop/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar", "x"); w.Code != http.StatusCreated {
+		t.Errorf("unrestricted repo: expected 201, got %d", w.Code)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+// slowSaveStorage holds every Save until release is closed, standing in for a
+// storage backend that cannot keep up.
+type slowSaveStorage struct {
+	storage.StorageProvider
+	release chan struct{}
+}
+
+func (s *slowSaveStorage) Save(path string, data io.Reader) error {
+	<-s.release
+	return s.StorageProvider.Save(path, data)
+}
+
+func TestHandleDownload_AsyncCacheDoesNotThrottleClient(t *testing.T) {
+	payload := strings.Repeat("x", 256*1024)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte(payload))
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyCacheAsync: true})
+	slow := &slowSaveStorage{StorageProvider: h.Store, release: make(chan struct{})}
+	h.Store = slow
+
+	target := "/repository/releases/com/example/lib/1.0/lib-1.0.jar"
+	done := make(chan string)
+	go func() {
+		w := doRequest(r, http.MethodGet, target, "")
+		done <- w.Body.String()
+	}()
+
+	select {
+	case body := <-done:
+		if body != payload {
+			t.Fatalf("client received %d bytes, want %d", len(body), len(payload))
+		}
+	case <-time.After(5 * time.Second):
+		close(slow.release)
+		t.Fatal("client download waited for the cache write")
+	}
+
+	// Once storage catches up the artifact is cached; provenance is written last
+	close(slow.release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if found, _ := slow.StorageProvider.Head(strings.TrimPrefix(target, "/") + provenanceSuffix); found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("artifact was never cached")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// disconnectingWriter fails every write after the first, like a client that
+// went away mid-download.
+type disconnectingWriter struct {
+	*httptest.ResponseRecorder
+	writes int
+}
+
+func (w *disconnectingWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes > 1 {
+		return 0, errors.New("client disconnected")
+	}
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestHandleDownload_ClientDisconnectIsNotCached(t *testing.T) {
+	payload := strings.Repeat("x", 1024*1024)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte(payload))
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}})
+
+	target := "/repository/releases/com/example/lib/1.0/lib-1.0.jar"
+	w := &disconnectingWriter{ResponseRecorder: httptest.NewRecorder()}
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+	if found, _ := h.Store.Head(strings.TrimPrefix(target, "/")); found {
+		t.Fatal("a truncated download was cached")
+	}
+
+	// The next complete download caches the full artifact
+	if w := doRequest(r, http.MethodGet, target, ""); w.Body.String() != payload {
+		t.Fatalf("expected the full payload, got %d bytes", w.Body.Len())
+	}
+	reader, found, err := h.Store.Get(strings.TrimPrefix(target, "/"))
+	if err != nil || !found {
+		t.Fatalf("artifact not cached: %v", err)
+	}
+	defer reader.Close()
+	if data, _ := io.ReadAll(reader); len(data) != len(payload) {
+		t.Errorf("cached %d bytes, want %d", len(data), len(payload))
+	}
+}
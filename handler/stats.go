@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// artifactStats counts the artifacts and bytes stored below some path.
+// Checksums, signatures and metadata count towards files and bytes but not
+// towards artifacts; bookkeeping files are left out entirely.
+type artifactStats struct {
+	Artifacts int64 `json:"artifacts"`
+	Files     int64 `json:"files"`
+	Bytes     int64 `json:"bytes"`
+}
+
+func (s *artifactStats) add(name string, size int64) {
+	s.Files++
+	s.Bytes += size
+	if !isSidecar(name) && !strings.HasPrefix(name, "maven-metadata") {
+		s.Artifacts++
+	}
+}
+
+// repoStats are the statistics of one repository, split into snapshot and
+// release versions.
+type repoStats struct {
+	artifactStats
+	Snapshots artifactStats `json:"snapshots"`
+	Releases  artifactStats `json:"releases"`
+}
+
+// storageCapacity is the space left on the storage volume.
+type storageCapacity struct {
+	FreeBytes  uint64 `json:"freeBytes"`
+	TotalBytes uint64 `json:"totalBytes"`
+}
+
+// StorageStats is the document served by /admin/stats. Capacity is left out
+// for backends that cannot report it.
+type StorageStats struct {
+	Repositories map[string]*repoStats `json:"repositories"`
+	Total        artifactStats         `json:"total"`
+	Capacity     *storageCapacity      `json:"capacity,omitempty"`
+	GeneratedAt  time.Time             `json:"generatedAt"`
+}
+
+// statsCache keeps computed statistics per repository filter ("" for all),
+// since every computation walks the whole storage below it.
+type statsCache struct {
+	mu      sync.Mutex
+	entries map[string]*StorageStats
+}
+
+func (sc *statsCache) get(repo string, ttl time.Duration) *StorageStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	stats, ok := sc.entries[repo]
+	if !ok || time.Since(stats.GeneratedAt) >= ttl {
+		return nil
+	}
+	return stats
+}
+
+func (sc *statsCache) set(repo string, stats *StorageStats) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.entries == nil {
+		sc.entries = make(map[string]*StorageStats)
+	}
+	sc.entries[repo] = stats
+}
+
+// computeStats walks repository/ (or only repository/<repo>) and aggregates
+// the files of each repository. Files in a -SNAPSHOT version directory count
+// as snapshots, all others as releases.
+func (h *MavenHandler) computeStats(repo string) (*StorageStats, error) {
+	root := "repository"
+	if repo != "" {
+		root += "/" + repo
+	}
+	stats := &StorageStats{Repositories: make(map[string]*repoStats)}
+	err := h.Store.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil // Continue walk
+		}
+		rel, ok := strings.CutPrefix(p, "repository/")
+		name := path.Base(p)
+		if !ok || storage.IsInternal(name) || storage.IsResolutionMarker(name) {
+			return nil
+		}
+		repoName, _, _ := strings.Cut(rel, "/")
+		rs, ok := stats.Repositories[repoName]
+		if !ok {
+			rs = &repoStats{}
+			stats.Repositories[repoName] = rs
+		}
+
+		rs.add(name, info.Size())
+		if strings.HasSuffix(path.Dir(p), "-SNAPSHOT") {
+			rs.Snapshots.add(name, info.Size())
+		} else {
+			rs.Releases.add(name, info.Size())
+		}
+		stats.Total.add(name, info.Size())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats.GeneratedAt = time.Now().UTC()
+	return stats, nil
+}
+
+// withCapacity returns a copy of stats with the current free space at root,
+// which is read fresh even when the counts come from the cache.
+func (h *MavenHandler) withCapacity(stats *StorageStats, root string) *StorageStats {
+	free, total, err := storage.Capacity(h.Store, root)
+	if err != nil {
+		return stats
+	}
+	withCapacity := *stats
+	withCapacity.Capacity = &storageCapacity{FreeBytes: free, TotalBytes: total}
+	return &withCapacity
+}
+
+// HandleStats reports artifact counts and sizes per repository, optionally
+// only for ?repo=<name>, along with the free space of the storage. Counts
+// are reused for MAVEN_STATS_CACHE_TTL; ?refresh=true recomputes them
+// straight away.
+func (h *MavenHandler) HandleStats(c *gin.Context) {
+	repo := c.Query("repo")
+	if strings.ContainsAny(repo, `/\`) || repo == "." || repo == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "repo must be a repository name"})
+		return
+	}
+	if repo != "" {
+		if found, err := h.Store.Head("repository/" + repo); err != nil || !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "repository " + repo + " not found"})
+			return
+		}
+	}
+
+	root := "repository"
+	if repo != "" {
+		root += "/" + repo
+	}
+	ttl, _ := time.ParseDuration(h.Config.StatsCacheTTL)
+	if c.Query("refresh") != "true" {
+		if stats := h.stats.get(repo, ttl); stats != nil {
+			c.JSON(http.StatusOK, h.withCapacity(stats, root))
+			return
+		}
+	}
+
+	stats, err := h.computeStats(repo)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.stats.set(repo, stats)
+	c.JSON(http.StatusOK, h.withCapacity(stats, root))
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func TestHandleStats(t *testing.T) {
+	r, h, base := newTestRouter(t, &config.Config{StatsCacheTTL: "1h"})
+	r.GET("/admin/stats", h.HandleStats)
+
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "jar!")
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar.sha1", "0123456789012345678901234567890123456789")
+	doRequest(r, http.MethodPut, "/repository/snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-20240101.120000-1.jar", "snapshot")
+	doRequest(r, http.MethodPut, "/repository/snapshots/com/example/app/2.0/app-2.0.pom", "pom")
+
+	stats := func(target string) StorageStats {
+		t.Helper()
+		w := doRequest(r, http.MethodGet, target, "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: got %d %s", target, w.Code, w.Body.String())
+		}
+		var s StorageStats
+		if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	s := stats("/admin/stats")
+	if s.Capacity == nil || s.Capacity.TotalBytes == 0 {
+		if _, _, err := storage.DiskUsage(base); err == nil {
+			t.Errorf("capacity = %+v", s.Capacity)
+		}
+	}
+	releases, snapshots := s.Repositories["releases"], s.Repositories["snapshots"]
+	if releases == nil || releases.Artifacts != 1 || releases.Files != 2 || releases.Bytes != 44 || releases.Snapshots.Files != 0 {
+		t.Errorf("releases = %+v", releases)
+	}
+	if snapshots == nil || snapshots.Artifacts != 2 || snapshots.Snapshots.Artifacts != 1 || snapshots.Snapshots.Bytes != 8 || snapshots.Releases.Bytes != 3 {
+		t.Errorf("snapshots = %+v", snapshots)
+	}
+	if s.Total.Artifacts != 3 {
+		t.Errorf("total = %+v", s.Total)
+	}
+
+	if s := stats("/admin/stats?repo=snapshots"); len(s.Repositories) != 1 || s.Repositories["snapshots"] == nil {
+		t.Errorf("filtered stats = %+v", s.Repositories)
+	}
+
+	// Cached until refreshed
+	os.WriteFile(filepath.Join(base, "repository/releases/com/example/app/1.0/app-1.0.pom"), []byte("pom"), 0644)
+	if s := stats("/admin/stats"); s.Total.Artifacts != 3 {
+		t.Errorf("expected cached stats, got %+v", s.Total)
+	}
+	if s := stats("/admin/stats?refresh=true"); s.Total.Artifacts != 4 {
+		t.Errorf("expected refreshed stats, got %+v", s.Total)
+	}
+
+	for target, want := range map[string]int{
+		"/admin/stats?repo=missing": http.StatusNotFound,
+		"/admin/stats?repo=..":      http.StatusBadRequest,
+		"/admin/stats?repo=a/b":     http.StatusBadRequest,
+	} {
+		if w := doRequest(r, http.MethodGet, target, ""); w.Code != want {
+			t.Errorf("%s: got %d, want %d", target, w.Code, want)
+		}
+	}
+}
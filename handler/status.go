@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"math"
+	"net/http"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storageHealth probes the storage backend and reports the circuit breaker
+// state when there is one.
+func (h *MavenHandler) storageHealth() gin.H {
+	health := gin.H{"breakerOpen": false}
+	if breaker, ok := h.Store.(retryAfterer); ok {
+		if wait, open := breaker.RetryAfter(); open {
+			health["breakerOpen"] = true
+			health["retryAfterSeconds"] = int(math.Ceil(wait.Seconds()))
+		}
+	}
+
+	start := time.Now()
+	_, err := h.Store.Head(".")
+	health["latencyMs"] = time.Since(start).Milliseconds()
+	health["healthy"] = err == nil
+	if err != nil {
+		health["error"] = err.Error()
+	}
+	return health
+}
+
+func (h *MavenHandler) proxyStatus() gin.H {
+	upstreams := make([]string, len(h.Config.ProxyURLs))
+	for i, proxy := range h.Config.ProxyURLs {
+		upstreams[i] = redactURL(proxy)
+	}
+	status := gin.H{
+		"enabled":        len(h.Config.ProxyURLs) > 0,
+		"upstreams":      upstreams,
+		"maxConcurrency": h.Config.ProxyMaxConcurrency,
+	}
+	if h.proxySlots != nil {
+		status["activeFetches"] = len(h.proxySlots)
+	}
+	return status
+}
+
+// Status returns a snapshot of the handler's own subsystems.
+func (h *MavenHandler) Status() gin.H {
+	h.prewarm.mu.Lock()
+	prewarmRunning := h.prewarm.status.Running
+	h.prewarm.mu.Unlock()
+	h.uploads.mu.Lock()
+	activeUploads := len(h.uploads.paths)
+	h.uploads.mu.Unlock()
+
+	return gin.H{
+		"proxy":   h.proxyStatus(),
+		"storage": h.storageHealth(),
+		"caches": gin.H{
+			"listings":         h.listings.size(),
+			"upstreamListings": h.upstreamListings.size(),
+			"digests":          h.digests.size(),
+			"negative":         h.negative.size(),
+			"upstreamHeads":    h.heads.size(),
+		},
+		"downloads":          gin.H{"active": h.activeDownloads.Load()},
+		"uploads":            gin.H{"active": activeUploads},
+		"prewarm":            gin.H{"running": prewarmRunning},
+		"checksumMismatches": h.ChecksumMismatches(),
+	}
+}
+
+// SystemStatus aggregates the health of every subsystem into one document.
+func (h *AdminHandler) SystemStatus(c *gin.Context) {
+	status := h.Maven.Status()
+
+	status["cleanup"] = gin.H{
+		"enabled":  h.CleanupService.Config.SnapshotCleanupEnabled,
+		"status":   h.CleanupService.Status(),
+		"interval": h.CleanupService.Config.SnapshotCleanupInterval,
+		"lastRun":  h.CleanupService.LastRun(),
+	}
+
+	disk := gin.H{"path": h.Maven.Config.StoragePath}
+	if free, total, err := storage.Capacity(h.Maven.Store, "."); err != nil {
+		disk["error"] = err.Error()
+	} else {
+		disk["freeBytes"] = free
+		disk["totalBytes"] = total
+	}
+	status["disk"] = disk
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/service"
+)
+
+func TestAdminHandler_SystemStatus(t *testing.T) {
+	cfg := &config.Config{ProxyURLs: []string{"http://upstream.invalid"}, ProxyMaxConcurrency: 2, ListingCacheTTL: "1m"}
+	r, h, _ := newTestRouter(t, cfg)
+	admin := NewAdminHandler(service.NewSnapshotCleanupService(h.Store, cfg), h.Metadata, h)
+	r.GET("/admin/status", admin.SystemStatus)
+
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.0/app-1.0.jar", "jar")
+	doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/", "")
+
+	w := doRequest(r, http.MethodGet, "/admin/status", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status map[string]json.RawMessage
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"cleanup", "proxy", "storage", "caches", "disk", "downloads", "uploads", "prewarm", "checksumMismatches"} {
+		if _, ok := status[key]; !ok {
+			t.Errorf("missing %q in %s", key, w.Body.String())
+		}
+	}
+
+	var storageHealth struct {
+		Healthy     bool `json:"healthy"`
+		BreakerOpen bool `json:"breakerOpen"`
+	}
+	json.Unmarshal(status["storage"], &storageHealth)
+	if !storageHealth.Healthy || storageHealth.BreakerOpen {
+		t.Errorf("expected healthy storage, got %s", status["storage"])
+	}
+	// Capacity comes from the store, not from MAVEN_STORAGE_PATH
+	var disk struct {
+		TotalBytes uint64 `json:"totalBytes"`
+	}
+	json.Unmarshal(status["disk"], &disk)
+	if disk.TotalBytes == 0 {
+		t.Errorf("expected the storage capacity, got %s", status["disk"])
+	}
+	var caches struct {
+		Listings int `json:"listings"`
+	}
+	json.Unmarshal(status["caches"], &caches)
+	if caches.Listings != 1 {
+		t.Errorf("expected a cached listing, got %s", status["caches"])
+	}
+	var downloads struct {
+		Active int64 `json:"active"`
+	}
+	json.Unmarshal(status["downloads"], &downloads)
+	if downloads.Active != 0 {
+		t.Errorf("expected no active downloads, got %d", downloads.Active)
+	}
+	var proxy struct {
+		Enabled bool `json:"enabled"`
+	}
+	json.Unmarshal(status["proxy"], &proxy)
+	if !proxy.Enabled {
+		t.Errorf("expected proxy enabled, got %s", status["proxy"])
+	}
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tagsSuffix = ".tags.json"
+
+type artifactTags struct {
+	Tags []string `json:"tags"`
+}
+
+func (h *MavenHandler) readTags(path string) ([]string, error) {
+	reader, found, err := h.Store.Get(path + tagsSuffix)
+	if err != nil || !found {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var t artifactTags
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, err
+	}
+	return t.Tags, nil
+}
+
+func (h *MavenHandler) writeTags(path string, tags []string) error {
+	if len(tags) == 0 {
+		return h.Store.Delete(path + tagsSuffix)
+	}
+	sort.Strings(tags)
+	data, err := json.Marshal(artifactTags{Tags: tags})
+	if err != nil {
+		return err
+	}
+	return h.Store.Save(path+tagsSuffix, bytes.NewReader(data))
+}
+
+func tagsPath(c *gin.Context) (string, bool) {
+	path := strings.TrimPrefix(c.Query("path"), "/")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return "", false
+	}
+	return path, true
+}
+
+// HandleGetTags returns the tags of the artifact at ?path=.
+func (h *MavenHandler) HandleGetTags(c *gin.Context) {
+	path, ok := tagsPath(c)
+	if !ok {
+		return
+	}
+	tags, err := h.readTags(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "tags": tags})
+}
+
+// HandlePutTags adds the tags in the JSON body ({"tags": [...]}) to the
+// artifact at ?path=.
+func (h *MavenHandler) HandlePutTags(c *gin.Context) {
+	path, ok := tagsPath(c)
+	if !ok {
+		return
+	}
+	var req artifactTags
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.Tags) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "body must be {\"tags\": [...]}"})
+		return
+	}
+
+	found, err := h.Store.Head(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "artifact not found: " + path})
+		return
+	}
+
+	h.tagsMu.Lock()
+	defer h.tagsMu.Unlock()
+	tags, err := h.readTags(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for _, tag := range req.Tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !containsTag(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if err := h.writeTags(path, tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "tags": tags})
+}
+
+// HandleDeleteTags removes ?tag= from the artifact at ?path=, or all of its
+// tags when no tag is given.
+func (h *MavenHandler) HandleDeleteTags(c *gin.Context) {
+	path, ok := tagsPath(c)
+	if !ok {
+		return
+	}
+
+	h.tagsMu.Lock()
+	defer h.tagsMu.Unlock()
+	var remaining []string
+	if remove := c.Query("tag"); remove != "" {
+		tags, err := h.readTags(path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		for _, tag := range tags {
+			if tag != remove {
+				remaining = append(remaining, tag)
+			}
+		}
+	}
+	if err := h.writeTags(path, remaining); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if remaining == nil {
+		remaining = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"path": path, "tags": remaining})
+}
+
+// HandleSearch finds artifacts carrying ?tag=.
+func (h *MavenHandler) HandleSearch(c *gin.Context) {
+	tag := c.Query("tag")
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag is required"})
+		return
+	}
+
+	results := []string{}
+	err := h.Store.Walk(".", func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(p, tagsSuffix) {
+			return nil
+		}
+		artifact := strings.TrimSuffix(filepath.ToSlash(p), tagsSuffix)
+		tags, err := h.readTags(artifact)
+		if err == nil && containsTag(tags, tag) {
+			results = append(results, artifact)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	sort.Strings(results)
+	c.JSON(http.StatusOK, gin.H{"tag": tag, "results": results})
+}
+
+func containsTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
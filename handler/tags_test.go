@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestTags_AddSearchRemove(t *testing.T) {
+	r, _, _ := newTestRouter(t, &config.Config{})
+
+	artifact := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, "/"+artifact, "jar")
+	doRequest(r, http.MethodPut, "/repository/releases/com/example/app/1.1/app-1.1.jar", "jar")
+
+	w := doRequest(r, http.MethodPut, "/api/tags?path="+artifact, `{"tags":["qa-approved","release-candidate"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("put tags: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, http.MethodPut, "/api/tags?path=repository/releases/missing.jar", `{"tags":["x"]}`); w.Code != http.StatusNotFound {
+		t.Fatalf("tagging a missing artifact: expected 404, got %d", w.Code)
+	}
+
+	var got struct {
+		Tags    []string `json:"tags"`
+		Results []string `json:"results"`
+	}
+	w = doRequest(r, http.MethodGet, "/api/tags?path="+artifact, "")
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if strings.Join(got.Tags, ",") != "qa-approved,release-candidate" {
+		t.Fatalf("unexpected tags: %v", got.Tags)
+	}
+
+	w = doRequest(r, http.MethodGet, "/api/search?tag=qa-approved", "")
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if len(got.Results) != 1 || got.Results[0] != artifact {
+		t.Fatalf("unexpected search results: %v", got.Results)
+	}
+
+	// The sidecar stays out of directory listings
+	w = doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/", "")
+	if strings.Contains(w.Body.String(), tagsSuffix) {
+		t.Errorf("listing exposes the tags sidecar: %s", w.Body.String())
+	}
+
+	doRequest(r, http.MethodDelete, "/api/tags?path="+artifact+"&tag=qa-approved", "")
+	w = doRequest(r, http.MethodGet, "/api/search?tag=qa-approved", "")
+	got.Results = nil
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if len(got.Results) != 0 {
+		t.Fatalf("expected no results after removing the tag, got %v", got.Results)
+	}
+
+	doRequest(r, http.MethodDelete, "/api/tags?path="+artifact, "")
+	w = doRequest(r, http.MethodGet, "/api/tags?path="+artifact, "")
+	got.Tags = nil
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if len(got.Tags) != 0 {
+		t.Fatalf("expected all tags to be removed, got %v", got.Tags)
+	}
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limitUpload caps the request body at MAVEN_MAX_UPLOAD_SIZE, so a single PUT
+// cannot fill the disk. An upload whose Content-Length is already too large
+// is answered 413 without reading it, and false is returned.
+func (h *MavenHandler) limitUpload(c *gin.Context) bool {
+	limit := h.Config.MaxUploadSize
+	if limit <= 0 {
+		return true
+	}
+	if c.Request.ContentLength > limit {
+		c.Header("Connection", "close")
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": uploadTooLarge(limit)})
+		return false
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+	return true
+}
+
+// checkFreeSpace refuses an upload with 507 when it would leave less than
+// MAVEN_MIN_FREE_SPACE bytes free where path is stored, so a full volume
+// cannot wedge the server. Backends that cannot report their capacity are
+// not checked. It reports false when the response was written.
+func (h *MavenHandler) checkFreeSpace(c *gin.Context, path string) bool {
+	minFree := h.Config.MinFreeSpace
+	if minFree <= 0 {
+		return true
+	}
+	free, _, err := storage.Capacity(h.Store, path)
+	if err != nil {
+		if !errors.Is(err, errors.ErrUnsupported) {
+			log.Printf("Failed to check free space for %s: %v\n", path, err)
+		}
+		return true
+	}
+	if int64(free)-max(c.Request.ContentLength, 0) >= minFree {
+		return true
+	}
+	c.Header("Connection", "close")
+	c.JSON(http.StatusInsufficientStorage, gin.H{"error": fmt.Sprintf("insufficient storage: %d bytes free, at least %d must remain", free, minFree)})
+	return false
+}
+
+// rejectTooLarge answers 413 and reports true when err comes from reading an
+// upload past MAVEN_MAX_UPLOAD_SIZE. Storage backends discard the partial
+// write of a failed Save.
+func rejectTooLarge(c *gin.Context, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": uploadTooLarge(tooLarge.Limit)})
+	return true
+}
+
+func uploadTooLarge(limit int64) string {
+	return fmt.Sprintf("upload exceeds the maximum size of %d bytes", limit)
+}
+
+// bufferUpload reads bodies of at most threshold bytes into memory so the
+// common small pom or metadata deploy reaches storage as a single write.
+// Larger bodies, or any body when threshold is 0, are streamed. When the
+// length is unknown, up to threshold+1 bytes are read to find out; those bytes
+// are replayed ahead of the rest of the stream. A known length sizes the
+// buffer instead, with one spare byte to notice a body longer than declared.
+// The bool reports whether the body was buffered.
+func bufferUpload(body io.Reader, contentLength, threshold int64) (io.Reader, bool, error) {
+	if threshold <= 0 || contentLength > threshold {
+		return body, false, nil
+	}
+
+	size := threshold
+	if contentLength >= 0 {
+		size = contentLength
+	}
+	buf := make([]byte, size+1)
+	n, err := io.ReadFull(body, buf)
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		return bytes.NewReader(buf[:n]), true, nil
+	case nil:
+		return io.MultiReader(bytes.NewReader(buf), body), false, nil
+	default:
+		return nil, false, err
+	}
+}
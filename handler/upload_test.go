@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordingStorage notes whether each Save received an in-memory body.
+type recordingStorage struct {
+	storage.StorageProvider
+	buffered map[string]bool
+}
+
+func (s *recordingStorage) Save(path string, data io.Reader) error {
+	_, inMemory := data.(*bytes.Reader)
+	s.buffered[path] = inMemory
+	return s.StorageProvider.Save(path, data)
+}
+
+func TestHandleUpload_BuffersSmallBodies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	store := &recordingStorage{StorageProvider: storage.NewLocalStorage(t.TempDir()), buffered: map[string]bool{}}
+	h := NewMavenHandler(store, &config.Config{UploadMemoryThreshold: 1024})
+	r := gin.New()
+	r.PUT("/repository/:repoName/*path", h.HandleUpload)
+
+	cases := []struct {
+		path          string
+		body          string
+		unknownLength bool
+		want          bool
+	}{
+		{"repository/releases/com/example/app/1.0/app-1.0.pom", "<project/>", false, true},
+		{"repository/releases/com/example/app/1.0/app-1.0-sources.jar", "small", true, true},
+		{"repository/releases/com/example/app/1.0/app-1.0.jar", strings.Repeat("x", 4096), false, false},
+		{"repository/releases/com/example/app/1.0/app-1.0-all.jar", strings.Repeat("x", 4096), true, false},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodPut, "/"+tc.path, strings.NewReader(tc.body))
+		if tc.unknownLength {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: expected 201, got %d", tc.path, w.Code)
+		}
+		if store.buffered[tc.path] != tc.want {
+			t.Errorf("%s: expected buffered=%v", tc.path, tc.want)
+		}
+
+		reader, _, _ := store.Get(tc.path)
+		data, _ := io.ReadAll(reader)
+		reader.Close()
+		if string(data) != tc.body {
+			t.Errorf("%s: stored %d bytes, want %d", tc.path, len(data), len(tc.body))
+		}
+	}
+}
+
+func TestHandleUpload_MaxUploadSize(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{MaxUploadSize: 1024, UploadMemoryThreshold: 16})
+
+	cases := []struct {
+		name          string
+		body          string
+		unknownLength bool
+		want          int
+	}{
+		{"app-1.0.jar", strings.Repeat("x", 1024), false, http.StatusCreated},
+		{"app-1.0-all.jar", strings.Repeat("x", 1025), false, http.StatusRequestEntityTooLarge},
+		{"app-1.0-sources.jar", strings.Repeat("x", 4096), true, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		target := "/repository/releases/com/example/app/1.0/" + tc.name
+		req := httptest.NewRequest(http.MethodPut, target, strings.NewReader(tc.body))
+		if tc.unknownLength {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, w.Code)
+		}
+		_, err := os.Stat(filepath.Join(base, target))
+		if stored := err == nil; stored != (tc.want == http.StatusCreated) {
+			t.Errorf("%s: stored=%v after %d", tc.name, stored, w.Code)
+		}
+	}
+}
+
+func TestHandleUpload_MinFreeSpace(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{MinFreeSpace: 1 << 62})
+	if _, _, err := storage.DiskUsage(base); err != nil {
+		t.Skipf("disk usage unavailable: %v", err)
+	}
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodPut, target, "jar"); w.Code != http.StatusInsufficientStorage {
+		t.Fatalf("expected 507, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(base, target)); !os.IsNotExist(err) {
+		t.Error("expected nothing to be stored")
+	}
+
+	r, _, _ = newTestRouter(t, &config.Config{MinFreeSpace: 1})
+	if w := doRequest(r, http.MethodPut, target, "jar"); w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", w.Code)
+	}
+}
+
+func TestBufferUpload_SizedByContentLength(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		body          string
+		contentLength int64
+		buffered      bool
+	}{
+		{"known length", "jar", 3, true},
+		{"unknown length", "jar", -1, true},
+		{"longer than declared", "jar-bytes", 3, false},
+		{"above threshold", strings.Repeat("x", 2048), 2048, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reader, buffered, err := bufferUpload(strings.NewReader(tc.body), tc.contentLength, 1024)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if buffered != tc.buffered {
+				t.Errorf("expected buffered=%v, got %v", tc.buffered, buffered)
+			}
+			if data, _ := io.ReadAll(reader); string(data) != tc.body {
+				t.Errorf("body changed: %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pendingChecksumTTL is how long a checksum uploaded ahead of its artifact
+// waits for the artifact before it is forgotten.
+const pendingChecksumTTL = 10 * time.Minute
+
+// maxPendingChecksums bounds the checksums waiting for their artifact; the
+// oldest are dropped first once it is reached.
+const maxPendingChecksums = 10000
+
+// pendingChecksum is a .sha1 or .md5 that arrived before its artifact.
+type pendingChecksum struct {
+	sums     map[string]string // digest by sidecar extension
+	uploaded time.Time
+}
+
+// pendingChecksums pairs checksums uploaded before their artifact with the
+// artifact upload that follows. Only those are checked on artifact upload;
+// sidecars already in storage may belong to a previous deploy of the path.
+type pendingChecksums struct {
+	mu    sync.Mutex
+	paths map[string]*pendingChecksum
+}
+
+func (p *pendingChecksums) add(artifactPath, ext, sum string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paths == nil {
+		p.paths = make(map[string]*pendingChecksum)
+	}
+	now := time.Now()
+	pending, ok := p.paths[artifactPath]
+	if ok && now.Sub(pending.uploaded) > pendingChecksumTTL {
+		// Left over from an abandoned deploy
+		ok = false
+	} else if !ok && len(p.paths) >= maxPendingChecksums {
+		p.prune(now)
+	}
+	if !ok {
+		pending = &pendingChecksum{sums: make(map[string]string)}
+		p.paths[artifactPath] = pending
+	}
+	pending.sums[ext] = sum
+	pending.uploaded = now
+}
+
+// prune drops expired checksums, and the oldest ones while the map is still
+// full.
+func (p *pendingChecksums) prune(now time.Time) {
+	for path, pending := range p.paths {
+		if now.Sub(pending.uploaded) > pendingChecksumTTL {
+			delete(p.paths, path)
+		}
+	}
+	for len(p.paths) >= maxPendingChecksums {
+		oldest := ""
+		for path, pending := range p.paths {
+			if oldest == "" || pending.uploaded.Before(p.paths[oldest].uploaded) {
+				oldest = path
+			}
+		}
+		delete(p.paths, oldest)
+	}
+}
+
+// take returns and forgets the checksums waiting for artifactPath.
+func (p *pendingChecksums) take(artifactPath string) map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending, ok := p.paths[artifactPath]
+	if !ok {
+		return nil
+	}
+	delete(p.paths, artifactPath)
+	if time.Since(pending.uploaded) > pendingChecksumTTL {
+		return nil
+	}
+	return pending.sums
+}
+
+// uploadedChecksum splits a .sha1/.md5 upload path into its artifact path and
+// sidecar extension.
+func uploadedChecksum(path string) (artifactPath, ext string, ok bool) {
+	for _, algo := range checksumAlgorithms {
+		if base, found := strings.CutSuffix(path, algo.Ext); found {
+			return base, algo.Ext, true
+		}
+	}
+	return "", "", false
+}
+
+// parseChecksum returns the digest of a sidecar body, accepting the
+// "<digest>  <filename>" form too.
+func parseChecksum(data []byte) string {
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// verifyUploadedChecksum checks an uploaded .sha1/.md5 against its stored
+// artifact when MAVEN_VERIFY_UPLOAD_CHECKSUMS is on. On a mismatch the
+// artifact and its sidecars are deleted and 400 is answered. A checksum whose
+// artifact is not stored yet is remembered for the artifact upload. It returns
+// the body to store, or false when the response was written.
+func (h *MavenHandler) verifyUploadedChecksum(c *gin.Context, path string, body io.Reader) (io.Reader, bool) {
+	artifactPath, ext, ok := uploadedChecksum(path)
+	if !h.Config.VerifyUploadChecksums || !ok {
+		return body, true
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxSidecarSize))
+	if rejectTooLarge(c, err) {
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return nil, false
+	}
+	body = io.MultiReader(bytes.NewReader(data), body)
+	expected := parseChecksum(data)
+	if expected == "" {
+		return body, true
+	}
+
+	actual, found, err := h.storedDigest(artifactPath, ext)
+	if err != nil {
+		if !rejectInvalidPath(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return nil, false
+	}
+	if !found {
+		h.pendingSums.add(artifactPath, ext, expected)
+		return body, true
+	}
+	if actual != expected {
+		h.rejectChecksumMismatch(c, artifactPath, ext, expected, actual)
+		return nil, false
+	}
+	return body, true
+}
+
+// storedDigest hashes the stored artifact with the algorithm of ext.
+func (h *MavenHandler) storedDigest(artifactPath, ext string) (string, bool, error) {
+	for _, algo := range checksumAlgorithms {
+		if algo.Ext != ext {
+			continue
+		}
+		reader, found, err := h.Store.Get(artifactPath)
+		if err != nil || !found {
+			return "", false, err
+		}
+		defer reader.Close()
+		digest := algo.New()
+		if _, err := io.Copy(digest, reader); err != nil {
+			// Directories have no checksum
+			return "", false, nil
+		}
+		return hex.EncodeToString(digest.Sum(nil)), true, nil
+	}
+	return "", false, nil
+}
+
+// verifyUploadedArtifact compares a freshly saved artifact with the
+// checksums uploaded ahead of it. It reports false after deleting the
+// artifact and answering 400 on a mismatch.
+func (h *MavenHandler) verifyUploadedArtifact(c *gin.Context, path string, pending, sums map[string]string) bool {
+	for ext, expected := range pending {
+		if actual := sums[ext]; actual != expected {
+			h.rejectChecksumMismatch(c, path, ext, expected, actual)
+			return false
+		}
+	}
+	return true
+}
+
+func (h *MavenHandler) rejectChecksumMismatch(c *gin.Context, artifactPath, ext, expected, actual string) {
+	log.Printf("WARNING: deleting %s: uploaded %s %s, artifact has %s\n", artifactPath, strings.TrimPrefix(ext, "."), expected, actual)
+	if err := h.deleteArtifact(artifactPath); err != nil {
+		log.Printf("Failed to delete %s after a checksum mismatch: %v\n", artifactPath, err)
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":    fmt.Sprintf("%s does not match its uploaded %s checksum", artifactPath, strings.TrimPrefix(ext, ".")),
+		"expected": expected,
+		"actual":   actual,
+	})
+}
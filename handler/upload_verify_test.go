@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleUpload_VerifiesChecksumAfterArtifact(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{VerifyUploadChecksums: true, GenerateChecksums: true})
+	sum := sha1.Sum([]byte("jar"))
+
+	good := "/repository/snapshots/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, good, "jar")
+	if w := doRequest(r, http.MethodPut, good+".sha1", hex.EncodeToString(sum[:])+"  app-1.0.jar\n"); w.Code != http.StatusCreated {
+		t.Fatalf("matching sha1: expected 201, got %d", w.Code)
+	}
+
+	bad := "/repository/snapshots/com/example/app/1.1/app-1.1.jar"
+	doRequest(r, http.MethodPut, bad, "corrupt")
+	if w := doRequest(r, http.MethodPut, bad+".sha1", hex.EncodeToString(sum[:])); w.Code != http.StatusBadRequest {
+		t.Fatalf("mismatching sha1: expected 400, got %d", w.Code)
+	}
+	for _, p := range []string{bad, bad + ".sha1", bad + ".md5"} {
+		if _, err := os.Stat(filepath.Join(base, p)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be deleted", p)
+		}
+	}
+}
+
+func TestHandleUpload_VerifiesChecksumBeforeArtifact(t *testing.T) {
+	r, _, base := newTestRouter(t, &config.Config{VerifyUploadChecksums: true})
+	sum := sha1.Sum([]byte("jar"))
+
+	good := "/repository/snapshots/com/example/app/1.0/app-1.0.jar"
+	doRequest(r, http.MethodPut, good+".sha1", hex.EncodeToString(sum[:]))
+	if w := doRequest(r, http.MethodPut, good, "jar"); w.Code != http.StatusCreated {
+		t.Fatalf("matching artifact: expected 201, got %d", w.Code)
+	}
+
+	bad := "/repository/snapshots/com/example/app/1.1/app-1.1.jar"
+	doRequest(r, http.MethodPut, bad+".sha1", hex.EncodeToString(sum[:]))
+	if w := doRequest(r, http.MethodPut, bad, "corrupt"); w.Code != http.StatusBadRequest {
+		t.Fatalf("mismatching artifact: expected 400, got %d", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(base, bad)); !os.IsNotExist(err) {
+		t.Error("expected the mismatching artifact to be deleted")
+	}
+
+	// The pending checksum was consumed, so a corrected re-upload succeeds
+	if w := doRequest(r, http.MethodPut, bad, "anything"); w.Code != http.StatusCreated {
+		t.Fatalf("re-upload: expected 201, got %d", w.Code)
+	}
+}
+
+func TestPendingChecksums_Bounded(t *testing.T) {
+	var p pendingChecksums
+	for i := 0; i <= maxPendingChecksums; i++ {
+		p.add(fmt.Sprintf("com/example/app/%d/app.jar", i), ".sha1", "abc")
+	}
+	if n := len(p.paths); n > maxPendingChecksums {
+		t.Errorf("expected at most %d pending checksums, got %d", maxPendingChecksums, n)
+	}
+	if sums := p.take(fmt.Sprintf("com/example/app/%d/app.jar", maxPendingChecksums)); sums[".sha1"] != "abc" {
+		t.Errorf("expected the newest checksum to be kept, got %v", sums)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"maven_repo/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxUpstreamListingSize caps how much of an upstream index page is read.
+const maxUpstreamListingSize = 4 << 20
+
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+
+// parseUpstreamListing extracts the entries of an HTML directory index as
+// served by Maven Central, Nexus or Artifactory. Links leaving the directory
+// (parents, absolute URLs, queries, fragments) are ignored.
+func parseUpstreamListing(body []byte) []storage.Entry {
+	seen := make(map[string]bool)
+	entries := []storage.Entry{}
+	for _, m := range hrefPattern.FindAllSubmatch(body, -1) {
+		href := string(m[1])
+		if strings.Contains(href, "://") || strings.ContainsAny(href, "?#") || strings.HasPrefix(href, "/") || strings.HasPrefix(href, "..") {
+			continue
+		}
+		href = strings.TrimPrefix(href, "./")
+		isDir := strings.HasSuffix(href, "/")
+		name, err := url.PathUnescape(strings.TrimSuffix(href, "/"))
+		if err != nil || name == "" || name == "." || strings.Contains(name, "/") || seen[name] {
+			continue
+		}
+		seen[name] = true
+		entries = append(entries, storage.Entry{Name: name, IsDir: isDir})
+	}
+	return entries
+}
+
+// maxUpstreamListings bounds the upstream listing cache; expired listings are
+// dropped once it is reached.
+const maxUpstreamListings = 1000
+
+type cachedUpstreamListing struct {
+	entries []storage.Entry
+	expires time.Time
+}
+
+// upstreamListingCache keeps parsed upstream listings briefly so browsing a
+// proxied group doesn't hit the upstream on every click.
+type upstreamListingCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]cachedUpstreamListing
+}
+
+func newUpstreamListingCache(ttl time.Duration) *upstreamListingCache {
+	return &upstreamListingCache{ttl: ttl, entries: make(map[string]cachedUpstreamListing)}
+}
+
+func (uc *upstreamListingCache) get(artifactPath string) ([]storage.Entry, bool) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	key := listingKey(artifactPath)
+	cached, ok := uc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(cached.expires) {
+		delete(uc.entries, key)
+		return nil, false
+	}
+	return cached.entries, true
+}
+
+func (uc *upstreamListingCache) set(artifactPath string, entries []storage.Entry) {
+	if uc.ttl <= 0 {
+		return
+	}
+	now := time.Now()
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if len(uc.entries) >= maxUpstreamListings {
+		for key, cached := range uc.entries {
+			if now.After(cached.expires) {
+				delete(uc.entries, key)
+			}
+		}
+		if len(uc.entries) >= maxUpstreamListings {
+			uc.entries = make(map[string]cachedUpstreamListing)
+		}
+	}
+	uc.entries[listingKey(artifactPath)] = cachedUpstreamListing{entries: entries, expires: now.Add(uc.ttl)}
+}
+
+func (uc *upstreamListingCache) size() int {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return len(uc.entries)
+}
+
+// serveUpstreamListing renders the upstream index of artifactPath for
+// directory requests (paths ending in "/") that missed locally. It reports
+// whether a listing was written.
+func (h *MavenHandler) serveUpstreamListing(c *gin.Context, artifactPath, path, title string) bool {
+	if !h.Config.ProxyDirectoryListings || !strings.HasSuffix(c.Request.URL.Path, "/") || !h.proxyAllowed(artifactPath) {
+		return false
+	}
+
+	entries, ok := h.upstreamListings.get(artifactPath)
+	if !ok {
+		entries, ok = h.fetchUpstreamListing(c.Request.Context(), artifactPath)
+		if !ok {
+			return false
+		}
+		h.upstreamListings.set(artifactPath, entries)
+	}
+	h.renderListing(c, path, title+" (Upstream)", entries)
+	return true
+}
+
+func (h *MavenHandler) fetchUpstreamListing(ctx context.Context, artifactPath string) ([]storage.Entry, bool) {
+	release := h.acquireProxySlot()
+	defer release()
+
+	dir := strings.Trim(artifactPath, "/")
+	if dir != "" {
+		dir += "/"
+	}
+	for _, proxy := range h.Config.ProxyURLs {
+		resp, err := h.upstreamRequest(ctx, http.MethodGet, strings.TrimRight(proxy, "/")+"/"+dir)
+		if err != nil {
+			continue
+		}
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if resp.StatusCode != http.StatusOK || mediaType != "text/html" {
+			resp.Body.Close()
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamListingSize))
+		resp.Body.Close()
+		if err != nil {
+			continue
+		}
+		return parseUpstreamListing(body), true
+	}
+	return nil, false
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+const sampleUpstreamIndex = `<html><head><title>Central Repository: com/example/app</title></head>
+<body><header><h1>com/example/app</h1></header><hr/><main><pre id="contents">
+<a href="../">../</a>
+<a href="1.0/" title="1.0/">1.0/</a>                                              2023-01-02 10:00         -
+<a href="1.1/" title="1.1/">1.1/</a>                                              2023-03-04 11:00         -
+<a href="maven-metadata.xml" title="maven-metadata.xml">maven-metadata.xml</a>    2023-03-04 11:00       412
+<a href="maven-metadata.xml.sha1" title="maven-metadata.xml.sha1">maven-metadata.xml.sha1</a>
+<a href="https://example.org/elsewhere">elsewhere</a>
+</pre></main></body></html>`
+
+func TestHandleDownload_UpstreamDirectoryListing(t *testing.T) {
+	hits := 0
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/com/example/app/" {
+			http.NotFound(w, r)
+			return
+		}
+		hits++
+		w.Header().Set("Content-Type", "text/html;charset=utf-8")
+		w.Write([]byte(sampleUpstreamIndex))
+	})
+
+	r, _, _ := newTestRouter(t, &config.Config{
+		ProxyURLs:                []string{upstream.URL},
+		ProxyBlockedContentTypes: []string{"text/html"},
+		ProxyDirectoryListings:   true,
+		ProxyListingCacheTTL:     "1m",
+	})
+
+	for i := 0; i < 2; i++ {
+		w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected the upstream listing, got %d", w.Code)
+		}
+		body := w.Body.String()
+		for _, want := range []string{`href="1.0/"`, `href="1.1/"`, `href="maven-metadata.xml"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("listing is missing %s: %s", want, body)
+			}
+		}
+		if strings.Contains(body, "elsewhere") {
+			t.Errorf("listing kept an external link: %s", body)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("expected the upstream listing to be cached, got %d fetches", hits)
+	}
+
+	// The filters apply to upstream listings too
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/?onlyArtifacts=true", "")
+	if strings.Contains(w.Body.String(), "maven-metadata.xml") {
+		t.Errorf("expected onlyArtifacts to hide metadata: %s", w.Body.String())
+	}
+
+	// A directory the upstream doesn't have is still a 404
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/other/", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a missing upstream directory to 404, got %d", w.Code)
+	}
+}
+
+func TestHandleDownload_UpstreamDirectoryListingDisabled(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(sampleUpstreamIndex))
+	})
+	r, _, _ := newTestRouter(t, &config.Config{
+		ProxyURLs:                []string{upstream.URL},
+		ProxyBlockedContentTypes: []string{"text/html"},
+	})
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without the option, got %d", w.Code)
+	}
+}
+
+func TestUpstreamListingCache_Eviction(t *testing.T) {
+	uc := newUpstreamListingCache(time.Millisecond)
+	uc.set("com/example/app/", []storage.Entry{{Name: "1.0", IsDir: true}})
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := uc.get("com/example/app/"); ok || uc.size() != 0 {
+		t.Errorf("expected the expired listing to be dropped on read, %d left", uc.size())
+	}
+
+	uc = newUpstreamListingCache(time.Hour)
+	for i := 0; i <= maxUpstreamListings; i++ {
+		uc.set(fmt.Sprintf("com/example/app%d/", i), nil)
+	}
+	if n := uc.size(); n > maxUpstreamListings {
+		t.Errorf("expected at most %d listings, got %d", maxUpstreamListings, n)
+	}
+}
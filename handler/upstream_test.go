@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func TestProxy_UpstreamCredentials(t *testing.T) {
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "deployer" || pass != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if strings.Contains(r.URL.Path, "forbidden") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/java-archive")
+		w.Write([]byte("jar"))
+	})
+	proxy := strings.Replace(upstream.URL, "http://", "http://deployer:s3cret@", 1)
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{proxy}})
+
+	target := "/repository/releases/com/example/app/1.0/app-1.0.jar"
+	if w := doRequest(r, http.MethodGet, target, ""); w.Code != http.StatusOK || w.Body.String() != "jar" {
+		t.Fatalf("authenticated GET: got %d %q", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, http.MethodHead, "/repository/releases/com/example/other/1.0/other-1.0.jar", ""); w.Code != http.StatusOK {
+		t.Errorf("authenticated HEAD: expected 200, got %d", w.Code)
+	}
+
+	// Credentials never leak into errors, provenance or the status document
+	w := doRequest(r, http.MethodGet, "/repository/releases/com/example/forbidden/1.0/forbidden-1.0.jar", "")
+	if w.Code != http.StatusBadGateway || strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("upstream error: got %d %s", w.Code, w.Body.String())
+	}
+	if body := doRequest(r, http.MethodGet, "/api/provenance?path="+strings.TrimPrefix(target, "/"), "").Body.String(); strings.Contains(body, "s3cret") {
+		t.Errorf("provenance exposes credentials: %s", body)
+	}
+	status, _ := json.Marshal(h.Status())
+	if strings.Contains(string(status), "s3cret") {
+		t.Errorf("status exposes credentials: %s", status)
+	}
+}
+
+func TestProxy_UpstreamTimeouts(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "stalled") {
+			w.Header().Set("Content-Type", "application/java-archive")
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+		}
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	})
+	r, h, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyTimeout: "100ms"})
+
+	for _, target := range []string{
+		"/repository/releases/com/example/hung/1.0/hung-1.0.jar",
+		"/repository/releases/com/example/stalled/1.0/stalled-1.0.jar",
+	} {
+		start := time.Now()
+		doRequest(r, http.MethodGet, target, "")
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: request took %v despite the proxy timeout", target, elapsed)
+		}
+		if found, _ := h.Store.Head(strings.TrimPrefix(target, "/")); found {
+			t.Errorf("%s: incomplete upstream response was cached", target)
+		}
+	}
+}
+
+func TestProxy_ClientDisconnectCancelsUpstream(t *testing.T) {
+	cancelled := make(chan struct{})
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyTimeout: "1m"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/repository/releases/com/example/app/1.0/app-1.0.jar", nil).WithContext(ctx)
+	go r.ServeHTTP(httptest.NewRecorder(), req)
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-cancelled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("upstream fetch was not cancelled when the client went away")
+	}
+}
+
+func TestProxy_RetriesTransientFailures(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.Method+" "+path.Base(r.URL.Path)]++
+		n := calls[r.Method+" "+path.Base(r.URL.Path)]
+		mu.Unlock()
+		switch {
+		case strings.Contains(r.URL.Path, "missing"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.Contains(r.URL.Path, "down"), n < 3:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "application/java-archive")
+			w.Write([]byte("jar"))
+		}
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyRetryMaxAttempts: 3, ProxyRetryBaseDelay: "1ms"})
+
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/app/1.0/app-1.0.jar", ""); w.Code != http.StatusOK || w.Body.String() != "jar" {
+		t.Errorf("GET after two failures: got %d %q", w.Code, w.Body.String())
+	}
+	if w := doRequest(r, http.MethodHead, "/repository/releases/com/example/other/1.0/other-1.0.jar", ""); w.Code != http.StatusOK {
+		t.Errorf("HEAD after two failures: got %d", w.Code)
+	}
+	if w := doRequest(r, http.MethodGet, "/repository/releases/com/example/down/1.0/down-1.0.jar", ""); w.Code != http.StatusBadGateway {
+		t.Errorf("persistent 503: got %d", w.Code)
+	}
+	doRequest(r, http.MethodGet, "/repository/releases/com/example/missing/1.0/missing-1.0.jar", "")
+
+	mu.Lock()
+	defer mu.Unlock()
+	for call, want := range map[string]int{
+		"GET app-1.0.jar":     3,
+		"HEAD other-1.0.jar":  3,
+		"GET down-1.0.jar":    3,
+		"GET missing-1.0.jar": 1,
+	} {
+		if calls[call] != want {
+			t.Errorf("%s: %d upstream calls, want %d", call, calls[call], want)
+		}
+	}
+}
+
+func TestProxy_ClientDisconnectStopsRetries(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	upstream := newUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	r, _, _ := newTestRouter(t, &config.Config{ProxyURLs: []string{upstream.URL}, ProxyRetryMaxAttempts: 5, ProxyRetryBaseDelay: "1h"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, "/repository/releases/com/example/app/1.0/app-1.0.jar", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("retries continued after the client went away")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("%d upstream calls, want 1", calls)
+	}
+}
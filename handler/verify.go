@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"hash"
+	"io"
+	"log"
+	"strings"
+)
+
+// verifyingReader hashes an artifact as it is served and compares the result
+// with its .sha1 sidecar at EOF.
+type verifyingReader struct {
+	reader     io.Reader
+	hash       hash.Hash
+	expected   string
+	onMismatch func(actual string)
+	skip       bool
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.reader.Read(p)
+	if !v.skip {
+		v.hash.Write(p[:n])
+		if err == io.EOF {
+			v.skip = true
+			if actual := hex.EncodeToString(v.hash.Sum(nil)); actual != v.expected {
+				v.onMismatch(actual)
+			}
+		}
+	}
+	return n, err
+}
+
+// verifyingReadSeeker keeps range support for seekable artifacts. Seeking
+// disables the check since only part of the file is read.
+type verifyingReadSeeker struct {
+	*verifyingReader
+	seeker io.Seeker
+}
+
+func (v *verifyingReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	v.skip = true
+	return v.seeker.Seek(offset, whence)
+}
+
+// readSHA1Sidecar returns the digest stored next to path, if any. Sidecars
+// written as "<digest>  <filename>" are accepted too.
+func (h *MavenHandler) readSHA1Sidecar(path string) string {
+	reader, found, err := h.Store.Get(path + ".sha1")
+	if err != nil || !found {
+		return ""
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(io.LimitReader(reader, 1024))
+	if err != nil {
+		return ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(fields[0])
+}
+
+// verifyWhileServing wraps a stored artifact so that, when enabled, the bytes
+// sent to the client are checked against the .sha1 sidecar without delaying
+// the response. Mismatches are logged and counted.
+func (h *MavenHandler) verifyWhileServing(path string, reader io.ReadCloser) io.Reader {
+	if !h.Config.VerifyDownloadChecksums || isSidecar(path) {
+		return reader
+	}
+	expected := h.readSHA1Sidecar(path)
+	if expected == "" {
+		return reader
+	}
+	v := &verifyingReader{
+		reader:   reader,
+		hash:     sha1.New(),
+		expected: expected,
+		onMismatch: func(actual string) {
+			h.checksumMismatches.Add(1)
+			log.Printf("WARNING: checksum mismatch serving %s: sidecar sha1 %s, actual %s\n", path, expected, actual)
+		},
+	}
+	if seeker, ok := reader.(io.Seeker); ok {
+		return &verifyingReadSeeker{verifyingReader: v, seeker: seeker}
+	}
+	return v
+}
+
+// ChecksumMismatches returns how many served artifacts did not match their
+// .sha1 sidecar, and how many proxied downloads did not match the upstream
+// .sha1, since startup.
+func (h *MavenHandler) ChecksumMismatches() int64 {
+	return h.checksumMismatches.Load()
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"maven_repo/config"
+)
+
+func TestHandleDownload_VerifyChecksums(t *testing.T) {
+	r, h, base := newTestRouter(t, &config.Config{VerifyDownloadChecksums: true})
+
+	artifact := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	sum := sha1.Sum([]byte("original bytes"))
+	doRequest(r, http.MethodPut, "/"+artifact, "original bytes")
+	doRequest(r, http.MethodPut, "/"+artifact+".sha1", hex.EncodeToString(sum[:]))
+
+	w := doRequest(r, http.MethodGet, "/"+artifact, "")
+	if w.Code != http.StatusOK || w.Body.String() != "original bytes" {
+		t.Fatalf("expected artifact, got %d %q", w.Code, w.Body.String())
+	}
+	if got := h.ChecksumMismatches(); got != 0 {
+		t.Fatalf("expected no mismatch for intact artifact, got %d", got)
+	}
+
+	// Flip bytes on disk behind the server's back
+	if err := os.WriteFile(filepath.Join(base, artifact), []byte("corrupt bytes!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(r, http.MethodGet, "/"+artifact, "")
+	if w.Code != http.StatusOK || w.Body.String() != "corrupt bytes!" {
+		t.Fatalf("expected the response not to be blocked, got %d %q", w.Code, w.Body.String())
+	}
+	if got := h.ChecksumMismatches(); got != 1 {
+		t.Fatalf("expected the mismatch to be recorded, got %d", got)
+	}
+
+	// Partial reads can't be verified and must not count as mismatches
+	req := httptest.NewRequest(http.MethodGet, "/"+artifact, nil)
+	req.Header.Set("Range", "bytes=0-3")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected range support to be kept, got %d", w.Code)
+	}
+	if got := h.ChecksumMismatches(); got != 1 {
+		t.Errorf("range request changed the mismatch count to %d", got)
+	}
+}
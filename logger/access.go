@@ -0,0 +1,86 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"maven_repo/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheSourceKey is the gin context key handlers set to say where a download
+// was served from: "local", "proxy" or "negative" (a cached upstream miss).
+const CacheSourceKey = "cacheSource"
+
+// sensitiveParams are query parameters whose values never reach the log.
+var sensitiveParams = []string{"token", "access_token", "password", "passwd", "secret", "key", "signature", "auth"}
+
+// AccessLog writes one line per request through the standard logger, so it
+// lands in the rotated log file as well as stdout. With MAVEN_LOG_FORMAT=json
+// each line is a JSON object.
+func AccessLog(cfg *config.Config) gin.HandlerFunc {
+	jsonFormat := cfg.LogFormat == "json"
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", redactPath(c.Request.URL)),
+			slog.Int("status", c.Writer.Status()),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.Int64("durationMs", time.Since(start).Milliseconds()),
+			slog.String("remoteAddr", c.ClientIP()),
+		}
+		if user := c.GetString(gin.AuthUserKey); user != "" {
+			attrs = append(attrs, slog.String("user", user))
+		}
+		if source := c.GetString(CacheSourceKey); source != "" {
+			attrs = append(attrs, slog.String("cache", source))
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate).String(); errs != "" {
+			attrs = append(attrs, slog.String("error", strings.TrimSpace(errs)))
+		}
+
+		if jsonFormat {
+			logger := slog.New(slog.NewJSONHandler(log.Writer(), nil))
+			logger.LogAttrs(context.Background(), slog.LevelInfo, "access", attrs...)
+			return
+		}
+		var b strings.Builder
+		b.WriteString("[ACCESS]")
+		for _, a := range attrs {
+			fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		}
+		log.Println(b.String())
+	}
+}
+
+// redactPath returns the request path and query with credentials masked:
+// userinfo in an embedded URL and the values of sensitive query parameters.
+func redactPath(u *url.URL) string {
+	path := u.Path
+	if i := strings.Index(path, "@"); i >= 0 {
+		if j := strings.LastIndex(path[:i], "//"); j >= 0 {
+			path = path[:j+2] + "xxxxx" + path[i:]
+		}
+	}
+	if u.RawQuery == "" {
+		return path
+	}
+	query := u.Query()
+	for name := range query {
+		for _, s := range sensitiveParams {
+			if strings.EqualFold(name, s) {
+				query.Set(name, "xxxxx")
+			}
+		}
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Level is a log severity. It is slog's, so JSON events and text lines share
+// one threshold.
+type Level = slog.Level
+
+const (
+	LevelDebug = slog.LevelDebug
+	LevelInfo  = slog.LevelInfo
+	LevelWarn  = slog.LevelWarn
+	LevelError = slog.LevelError
+)
+
+var threshold atomic.Int64
+
+func init() {
+	threshold.Store(int64(LevelInfo))
+}
+
+// ParseLevel parses MAVEN_LOG_LEVEL: debug, info, warn or error.
+func ParseLevel(s string) (Level, error) {
+	if strings.TrimSpace(s) == "" {
+		return LevelInfo, nil
+	}
+	var l Level
+	if err := l.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return LevelInfo, fmt.Errorf("invalid log level %q", s)
+	}
+	return l, nil
+}
+
+// SetLevel sets the lowest level that is logged.
+func SetLevel(l Level) {
+	threshold.Store(int64(l))
+}
+
+// MinLevel returns the lowest level that is logged, for slog handlers.
+func MinLevel() Level {
+	return Level(threshold.Load())
+}
+
+// Enabled reports whether messages at l are logged.
+func Enabled(l Level) bool {
+	return l >= Level(threshold.Load())
+}
+
+// Logger is the leveled logging other packages use instead of log.Printf.
+type Logger interface {
+	Debugf(format string, args ...any)
+	Infof(format string, args ...any)
+	Warnf(format string, args ...any)
+	Errorf(format string, args ...any)
+}
+
+// Std logs through the standard logger, which Setup points at the log file.
+var Std Logger = stdLogger{}
+
+type stdLogger struct{}
+
+func (stdLogger) Debugf(format string, args ...any) { Logf(LevelDebug, format, args...) }
+func (stdLogger) Infof(format string, args ...any)  { Logf(LevelInfo, format, args...) }
+func (stdLogger) Warnf(format string, args ...any)  { Logf(LevelWarn, format, args...) }
+func (stdLogger) Errorf(format string, args ...any) { Logf(LevelError, format, args...) }
+
+func Debugf(format string, args ...any) { Std.Debugf(format, args...) }
+func Infof(format string, args ...any)  { Std.Infof(format, args...) }
+func Warnf(format string, args ...any)  { Std.Warnf(format, args...) }
+func Errorf(format string, args ...any) { Std.Errorf(format, args...) }
+
+// Logf logs at l when it is enabled. Info lines are written as they are;
+// other levels are prefixed with their name.
+func Logf(l Level, format string, args ...any) {
+	if !Enabled(l) {
+		return
+	}
+	if l != LevelInfo {
+		format = l.String() + " " + format
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,47 @@
+package logger
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"testing"
+)
+
+func TestLogf_Levels(t *testing.T) {
+	defer func(w io.Writer, flags int, l Level) {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+		SetLevel(l)
+	}(log.Writer(), log.Flags(), MinLevel())
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+
+	level, err := ParseLevel("WARN")
+	if err != nil || level != LevelWarn {
+		t.Fatalf("ParseLevel(WARN) = %v, %v", level, err)
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+	if level, _ := ParseLevel(""); level != LevelInfo {
+		t.Errorf("empty level should default to info, got %v", level)
+	}
+
+	SetLevel(LevelWarn)
+	Debugf("debug\n")
+	Infof("info\n")
+	Warnf("warn %d\n", 1)
+	Errorf("error\n")
+	if got, want := buf.String(), "WARN warn 1\nERROR error\n"; got != want {
+		t.Errorf("at warn got %q, want %q", got, want)
+	}
+
+	buf.Reset()
+	SetLevel(LevelDebug)
+	Debugf("debug\n")
+	Infof("info\n")
+	if got, want := buf.String(), "DEBUG debug\ninfo\n"; got != want {
+		t.Errorf("at debug got %q, want %q", got, want)
+	}
+}
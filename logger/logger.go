@@ -1,9 +1,11 @@
 package logger
 
 import (
+	"context"
 	"io"
 	"log"
 	"os"
+	"time"
 
 	"maven_repo/config"
 
@@ -13,36 +15,85 @@ import (
 
 type LogManager struct {
 	Cfg *config.Config
+
+	lj   *lumberjack.Logger
+	stop chan struct{}
 }
 
 func NewLogManager(cfg *config.Config) *LogManager {
 	return &LogManager{Cfg: cfg}
 }
 
+// Setup points the standard logger at the log file once. Rotation never
+// swaps the logger's output: lumberjack renames and reopens the file under its
+// own lock, so concurrent writers always land in exactly one file.
 func (l *LogManager) Setup() {
+	if level, err := ParseLevel(l.Cfg.LogLevel); err != nil {
+		log.Printf("%v, using info\n", err)
+	} else {
+		SetLevel(level)
+	}
+
 	if l.Cfg.LogPath == "" {
 		return
 	}
 
-	lj := &lumberjack.Logger{
+	l.lj = &lumberjack.Logger{
 		Filename:   l.Cfg.LogPath,
-		MaxSize:    l.Cfg.LogMaxSize, // megabytes
+		MaxSize:    l.Cfg.LogMaxSize, // megabytes, rotates as soon as a write would exceed it
 		MaxBackups: l.Cfg.LogMaxBackups,
 		MaxAge:     l.Cfg.LogKeepDays, // days
-		Compress:   true,              // disabled by default
+		Compress:   l.Cfg.LogCompress, // rotated files only, so writers never race it
 		LocalTime:  true,
 	}
 
 	// Set multi-writer for standard logger (file + stdout)
-	mw := io.MultiWriter(os.Stdout, lj)
+	mw := io.MultiWriter(os.Stdout, l.lj)
 	log.SetOutput(mw)
 
-	log.Printf("Logging initialized to %s (MaxSize: %dMB, Keep: %d days, MaxBackups: %d)\n",
-		l.Cfg.LogPath, l.Cfg.LogMaxSize, l.Cfg.LogKeepDays, l.Cfg.LogMaxBackups)
+	log.Printf("Logging initialized to %s (MaxSize: %dMB, Keep: %d days, MaxBackups: %d, Compress: %v)\n",
+		l.Cfg.LogPath, l.Cfg.LogMaxSize, l.Cfg.LogKeepDays, l.Cfg.LogMaxBackups, l.Cfg.LogCompress)
 }
 
+// Start rotates the log at every local midnight, in addition to the
+// size-based rotation lumberjack does on Write. Backups carry the time of
+// rotation in their name, so several rotations on one day never collide.
 func (l *LogManager) Start(lc fx.Lifecycle) {
-	// No background loop needed, lumberjack handles it on Write()
+	if l.lj == nil {
+		return
+	}
+	l.stop = make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			go l.rotateDaily()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(l.stop)
+			return nil
+		},
+	})
+}
+
+func (l *LogManager) rotateDaily() {
+	for {
+		timer := time.NewTimer(time.Until(nextMidnight(time.Now())))
+		select {
+		case <-timer.C:
+			if err := l.lj.Rotate(); err != nil {
+				log.Printf("Daily log rotation failed: %v\n", err)
+			}
+		case <-l.stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// nextMidnight returns the start of the day after now, in now's location.
+func nextMidnight(now time.Time) time.Time {
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
 }
 
 var Module = fx.Options(
@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+)
+
+func TestNextMidnight(t *testing.T) {
+	loc := time.FixedZone("UTC+2", 2*60*60)
+	for _, tc := range []struct{ now, want time.Time }{
+		{time.Date(2023, 10, 27, 15, 4, 5, 0, loc), time.Date(2023, 10, 28, 0, 0, 0, 0, loc)},
+		{time.Date(2023, 10, 27, 0, 0, 0, 0, loc), time.Date(2023, 10, 28, 0, 0, 0, 0, loc)},
+		{time.Date(2023, 12, 31, 23, 59, 59, 0, loc), time.Date(2024, 1, 1, 0, 0, 0, 0, loc)},
+	} {
+		if got := nextMidnight(tc.now); !got.Equal(tc.want) {
+			t.Errorf("nextMidnight(%v) = %v, want %v", tc.now, got, tc.want)
+		}
+	}
+}
+
+func TestLogManager_RotationKeepsConcurrentLines(t *testing.T) {
+	defer func(w io.Writer, flags int) {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	}(log.Writer(), log.Flags())
+
+	dir := t.TempDir()
+	l := NewLogManager(&config.Config{LogPath: filepath.Join(dir, "server.log"), LogMaxSize: 100})
+	l.Setup()
+	defer l.lj.Close()
+	log.SetOutput(l.lj)
+	log.SetFlags(0)
+
+	const writers, lines = 8, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < lines; i++ {
+				log.Println("line")
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		if err := l.lj.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+
+	files, _ := filepath.Glob(filepath.Join(dir, "server*.log"))
+	total := 0
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if scanner.Text() == "line" {
+				total++
+			}
+		}
+		f.Close()
+	}
+	if total != writers*lines {
+		t.Errorf("found %d lines across %d files, want %d", total, len(files), writers*lines)
+	}
+}
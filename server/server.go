@@ -2,12 +2,17 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"time"
 
 	"maven_repo/auth"
 	"maven_repo/config"
 	"maven_repo/handler"
+	"maven_repo/logger"
 	"maven_repo/service"
 	"maven_repo/storage"
 
@@ -15,22 +20,107 @@ import (
 	"go.uber.org/fx"
 )
 
-func NewGinEngine(cfg *config.Config, h *handler.MavenHandler, admin *handler.AdminHandler) *gin.Engine {
-	r := gin.Default()
+func NewGinEngine(cfg *config.Config, store storage.StorageProvider, h *handler.MavenHandler, admin *handler.AdminHandler) *gin.Engine {
+	r := gin.New()
+	r.Use(logger.AccessLog(cfg))
+	r.Use(gin.Recovery())
+	r.Use(handler.Compress(cfg))
+	// Only listed proxies may set the client IP via X-Forwarded-For; it decides
+	// whether a request comes from a trusted network
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Invalid MAVEN_TRUSTED_PROXIES: %v\n", err)
+	}
+	guard := handler.StorageGuard(store)
+	limit := handler.RateLimit(cfg)
+	downloads := handler.DownloadLimit(cfg)
+
+	if cfg.RootRedirect != "" {
+		r.GET("/", h.HandleRootRedirect)
+	} else if cfg.RootIndex {
+		r.GET("/", auth.BasicAuth(cfg), guard, h.HandleRootIndex)
+	}
+	r.GET("/favicon.ico", handler.HandleFavicon)
 
 	// Public repository (Aggregates all repos under repository/)
-	mavenPublic := r.Group("/repository/maven-public", auth.BasicAuth(cfg))
+	mavenPublic := r.Group("/repository/maven-public", auth.BasicAuth(cfg), limit, guard)
 	{
-		mavenPublic.GET("/*path", h.HandleAggregateDownload("repository"))
+		mavenPublic.GET("/*path", downloads, h.HandleAggregateDownload("repository"))
 		mavenPublic.HEAD("/*path", h.HandleAggregateHead("repository"))
 	}
 
 	// Dynamic repository (handles /repository/develop, /repository/staging, /repository/whatever)
-	repos := r.Group("/repository/:repoName", auth.BasicAuth(cfg))
+	repos := r.Group("/repository/:repoName", auth.BasicAuth(cfg), limit, guard)
 	{
 		repos.PUT("/*path", h.HandleUpload)
-		repos.GET("/*path", h.HandleDownload)
+		repos.GET("/*path", downloads, h.HandleDownload)
 		repos.HEAD("/*path", h.HandleHead)
+		repos.DELETE("/*path", h.HandleDelete)
+	}
+
+	// Artifact information API
+	api := r.Group("/api", auth.BasicAuth(cfg), guard)
+	{
+		api.GET("/provenance", h.HandleProvenance)
+		api.GET("/checksums", h.HandleChecksums)
+		api.GET("/tags", h.HandleGetTags)
+		api.PUT("/tags", h.HandlePutTags)
+		api.DELETE("/tags", h.HandleDeleteTags)
+		api.GET("/search", h.HandleSearch)
+	}
+
+	// Credential check for clients setting up access
+	r.GET("/auth/whoami", auth.Whoami(cfg))
+
+	// Admin API for system status. Not behind the storage guard so it still
+	// answers while the storage breaker is open.
+	statusRoutes := r.Group("/admin", auth.BasicAuth(cfg))
+	{
+		statusRoutes.GET("/status", admin.SystemStatus)
+	}
+
+	// Admin API for storage statistics
+	statsRoutes := r.Group("/admin/stats", auth.BasicAuth(cfg), guard)
+	{
+		statsRoutes.GET("", h.HandleStats)
+	}
+
+	// Admin API for artifacts
+	artifactRoutes := r.Group("/admin/artifacts", auth.BasicAuth(cfg), guard)
+	{
+		artifactRoutes.POST("/delete", h.HandleBatchDelete)
+	}
+
+	// Admin API for cache prewarming
+	prewarmRoutes := r.Group("/admin/prewarm", auth.BasicAuth(cfg), guard)
+	{
+		prewarmRoutes.POST("", h.HandlePrewarm)
+		prewarmRoutes.GET("/status", h.HandlePrewarmStatus)
+	}
+
+	// Admin API for checksum pins
+	pinRoutes := r.Group("/admin/pins", auth.BasicAuth(cfg), guard)
+	{
+		pinRoutes.GET("", h.HandleGetPin)
+		pinRoutes.PUT("", h.HandleSetPin)
+		pinRoutes.DELETE("", h.HandleDeletePin)
+	}
+
+	// Admin API for directories
+	directoryRoutes := r.Group("/admin/directories", auth.BasicAuth(cfg), guard)
+	{
+		directoryRoutes.POST("/create", h.HandleCreateDir)
+	}
+
+	// Admin API for caches
+	cacheRoutes := r.Group("/admin/cache", auth.BasicAuth(cfg))
+	{
+		cacheRoutes.POST("/invalidate-listing", h.HandleInvalidateListing)
+	}
+
+	// Admin API for metadata
+	metadataRoutes := r.Group("/admin/metadata", auth.BasicAuth(cfg), guard)
+	{
+		metadataRoutes.POST("/regenerate", admin.RegenerateMetadata)
 	}
 
 	// Admin API for snapshots
@@ -39,31 +129,105 @@ func NewGinEngine(cfg *config.Config, h *handler.MavenHandler, admin *handler.Ad
 		adminRoutes.POST("/pause", admin.PauseCleanup)
 		adminRoutes.POST("/resume", admin.ResumeCleanup)
 		adminRoutes.GET("/status", admin.CleanupStatus)
+		adminRoutes.GET("/progress", admin.CleanupProgress)
 		adminRoutes.POST("/trigger", admin.TriggerCleanup)
 	}
 
 	return r
 }
 
-func StartHTTPServer(lc fx.Lifecycle, cfg *config.Config, engine *gin.Engine) {
+func StartHTTPServer(lc fx.Lifecycle, cfg *config.Config, engine *gin.Engine) error {
 	srv := &http.Server{
 		Addr:    ":" + cfg.Port,
 		Handler: engine,
 	}
 
+	useTLS := cfg.TLSCertFile != "" && cfg.TLSKeyFile != ""
+	var redirect *http.Server
+	if useTLS {
+		minVersion, err := tlsVersion(cfg.TLSMinVersion)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{MinVersion: minVersion}
+		if cfg.TLSRedirectPort != "" {
+			redirect = &http.Server{
+				Addr:    ":" + cfg.TLSRedirectPort,
+				Handler: httpsRedirect(cfg.Port),
+			}
+		}
+	} else if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		return fmt.Errorf("both MAVEN_TLS_CERT_FILE and MAVEN_TLS_KEY_FILE must be set to enable TLS")
+	}
+
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
 			go func() {
-				if err := engine.Run(":" + cfg.Port); err != nil {
+				var err error
+				if useTLS {
+					err = srv.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+				} else {
+					err = srv.ListenAndServe()
+				}
+				if err != nil && err != http.ErrServerClosed {
 					log.Printf("Listen: %s\n", err)
 				}
 			}()
+			if redirect != nil {
+				go func() {
+					if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+						log.Printf("Listen (HTTPS redirect): %s\n", err)
+					}
+				}()
+			}
 			return nil
 		},
 		OnStop: func(ctx context.Context) error {
+			if redirect != nil {
+				redirect.Shutdown(ctx)
+			}
 			return srv.Shutdown(ctx)
 		},
 	})
+	return nil
+}
+
+// tlsVersion maps MAVEN_TLS_MIN_VERSION ("1.0" to "1.3") to its constant.
+func tlsVersion(v string) (uint16, error) {
+	switch v {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported MAVEN_TLS_MIN_VERSION %q", v)
+	}
+}
+
+// httpsRedirect sends plain HTTP requests to the same URL on the HTTPS port.
+// 308 keeps the method and body, so redirected deploys still work.
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
+
+// InvalidateListingsOnCleanup drops the cached listings of directories the
+// cleanup services delete from.
+func InvalidateListingsOnCleanup(h *handler.MavenHandler, snapshots *service.SnapshotCleanupService, proxyCache *service.ProxyCacheCleanupService) {
+	snapshots.Changed = h.InvalidateListing
+	proxyCache.Changed = h.InvalidateListing
 }
 
 func StartCleanupService(lc fx.Lifecycle, svc *service.SnapshotCleanupService) {
@@ -79,18 +243,116 @@ func StartCleanupService(lc fx.Lifecycle, svc *service.SnapshotCleanupService) {
 	})
 }
 
+func StartProxyCacheCleanupService(lc fx.Lifecycle, svc *service.ProxyCacheCleanupService) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			svc.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			svc.Stop()
+			return nil
+		},
+	})
+}
+
+// newBackend creates the storage backend selected by MAVEN_STORAGE_BACKEND.
+func newBackend(cfg *config.Config) (storage.StorageProvider, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		local := storage.NewLocalStorage(cfg.StoragePath)
+		local.FollowSymlinks = cfg.WalkFollowSymlinks
+		return local, nil
+	case "s3":
+		if cfg.S3Bucket == "" {
+			return nil, fmt.Errorf("MAVEN_S3_BUCKET is required for the s3 storage backend")
+		}
+		client, err := storage.NewS3Client(cfg.S3Region, cfg.S3Endpoint, cfg.S3AccessKey, cfg.S3SecretKey)
+		if err != nil {
+			return nil, err
+		}
+		return storage.NewS3Storage(client, cfg.S3Bucket), nil
+	case "gcs":
+		if cfg.GCSBucket == "" {
+			return nil, fmt.Errorf("MAVEN_GCS_BUCKET is required for the gcs storage backend")
+		}
+		// Emulators take unauthenticated requests
+		client := http.DefaultClient
+		if cfg.GCSEndpoint == "" || cfg.GCSCredentialsFile != "" {
+			var err error
+			if client, err = storage.NewGCSClient(cfg.GCSCredentialsFile); err != nil {
+				return nil, err
+			}
+		}
+		return storage.NewGCSStorage(client, cfg.GCSBucket, cfg.GCSEndpoint), nil
+	default:
+		return nil, fmt.Errorf("unknown MAVEN_STORAGE_BACKEND %q", cfg.StorageBackend)
+	}
+}
+
 var Module = fx.Options(
 	fx.Provide(
 		config.New,
-		func(cfg *config.Config) storage.StorageProvider {
-			return storage.NewLocalStorage(cfg.StoragePath)
+		func(cfg *config.Config) (storage.StorageProvider, error) {
+			store, err := newBackend(cfg)
+			if err != nil {
+				return nil, err
+			}
+			if len(cfg.StorageRoots) > 0 {
+				mounts := make(map[string]storage.StorageProvider, len(cfg.StorageRoots))
+				for repo, root := range cfg.StorageRoots {
+					local := storage.NewLocalStorage(root)
+					local.FollowSymlinks = cfg.WalkFollowSymlinks
+					mounts["repository/"+repo] = local
+					log.Printf("Storing repository %s in %s\n", repo, root)
+				}
+				store = storage.NewMountStorage(store, mounts)
+			}
+			if cfg.UploadGracePeriod != "" {
+				if grace, err := time.ParseDuration(cfg.UploadGracePeriod); err != nil || grace <= 0 {
+					log.Printf("Invalid MAVEN_UPLOAD_GRACE_PERIOD %q, grace period disabled\n", cfg.UploadGracePeriod)
+				} else {
+					store = storage.NewGraceStorage(store, grace)
+				}
+			}
+			if cfg.BloomFilterEnabled && cfg.StorageBackend != "" && cfg.StorageBackend != "local" {
+				// Other instances write to shared buckets behind the filter's back
+				log.Printf("Bloom filter is not supported with the %s storage backend, disabling it\n", cfg.StorageBackend)
+			} else if cfg.BloomFilterEnabled {
+				bloom := storage.NewBloomStorage(store, cfg.BloomFilterExpected)
+				if count, err := bloom.Rebuild(); err != nil {
+					// A partial filter would hide real artifacts, so run without it
+					log.Printf("Failed to build artifact bloom filter, disabling it: %v\n", err)
+				} else {
+					log.Printf("Artifact bloom filter loaded with %d paths\n", count)
+					store = bloom
+				}
+			}
+			if cfg.StorageListCacheTTL != "" {
+				if ttl, err := time.ParseDuration(cfg.StorageListCacheTTL); err != nil || ttl <= 0 {
+					log.Printf("Invalid MAVEN_STORAGE_LIST_CACHE_TTL %q, list cache disabled\n", cfg.StorageListCacheTTL)
+				} else {
+					store = storage.NewListCacheStorage(store, ttl)
+				}
+			}
+			if cfg.StorageBreakerThreshold > 0 {
+				cooldown, err := time.ParseDuration(cfg.StorageBreakerCooldown)
+				if err != nil || cooldown <= 0 {
+					log.Printf("Invalid MAVEN_STORAGE_BREAKER_COOLDOWN %q, using 30s\n", cfg.StorageBreakerCooldown)
+					cooldown = 30 * time.Second
+				}
+				store = storage.NewBreakerStorage(store, cfg.StorageBreakerThreshold, cooldown)
+			}
+			return store, nil
 		},
 		func(store storage.StorageProvider, cfg *config.Config) *handler.MavenHandler {
 			return handler.NewMavenHandler(store, cfg)
 		},
 		service.NewSnapshotCleanupService,
+		service.NewProxyCacheCleanupService,
+		service.NewMetadataService,
 		handler.NewAdminHandler,
 		NewGinEngine,
 	),
-	fx.Invoke(StartHTTPServer, StartCleanupService),
+	fx.Invoke(InvalidateListingsOnCleanup, StartHTTPServer, StartCleanupService, StartProxyCacheCleanupService),
 )
@@ -2,8 +2,12 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -11,8 +15,11 @@ import (
 	"time"
 
 	"maven_repo/config"
+	"maven_repo/logger"
 	"maven_repo/storage"
 	"regexp"
+
+	"github.com/robfig/cron/v3"
 )
 
 var (
@@ -21,22 +28,77 @@ var (
 )
 
 type SnapshotCleanupService struct {
-	Store  storage.StorageProvider
-	Config *config.Config
-	Mu     sync.Mutex
-	Paused bool
-	Ctx    context.Context
-	Cancel context.CancelFunc
+	Store    storage.StorageProvider
+	Config   *config.Config
+	Metadata *MetadataService
+	Mu       sync.Mutex
+	Paused   bool
+	Ctx      context.Context
+	Cancel   context.CancelFunc
+	// Changed, when set, is called with each directory a pass deleted files
+	// from, so caches describing it can be dropped
+	Changed func(dir string)
+	lastRun *CleanupRun
+
+	subMu       sync.Mutex
+	subscribers map[chan CleanupProgress]struct{}
+}
+
+// CleanupProgress is published after each snapshot directory a cleanup pass
+// has processed, and once more with Done set when the pass ends.
+type CleanupProgress struct {
+	Directory       string  `json:"directory,omitempty"`
+	Processed       int     `json:"processed"`
+	Total           int     `json:"total"`
+	DeletedVersions int     `json:"deletedVersions"`
+	DeletedFiles    int     `json:"deletedFiles"`
+	FreedBytes      int64   `json:"freedBytes"`
+	ETASeconds      float64 `json:"etaSeconds"`
+	Done            bool    `json:"done"`
+}
+
+// CleanupRun summarises one cleanup pass. RemovedDirectories counts the
+// directories the pass left empty and removed. Errors lists the directories
+// and files that could not be cleaned; Error is set when the pass itself
+// failed.
+type CleanupRun struct {
+	StartedAt          time.Time `json:"startedAt"`
+	FinishedAt         time.Time `json:"finishedAt"`
+	Directories        int       `json:"directories"`
+	Versions           int       `json:"versions"`
+	DeletedVersions    int       `json:"deletedVersions"`
+	DeletedFiles       int       `json:"deletedFiles"`
+	FreedBytes         int64     `json:"freedBytes"`
+	RemovedDirectories int       `json:"removedDirectories"`
+	Errors             []string  `json:"errors,omitempty"`
+	Error              string    `json:"error,omitempty"`
 }
 
 func NewSnapshotCleanupService(store storage.StorageProvider, cfg *config.Config) *SnapshotCleanupService {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &SnapshotCleanupService{
-		Store:  store,
-		Config: cfg,
-		Ctx:    ctx,
-		Cancel: cancel,
+		Store:    store,
+		Config:   cfg,
+		Metadata: NewMetadataService(store, cfg),
+		Ctx:      ctx,
+		Cancel:   cancel,
+	}
+}
+
+func (s *SnapshotCleanupService) jsonEvents() bool {
+	return s.Config.LogFormat == "json"
+}
+
+// event reports a cleanup event. Text mode keeps the human-readable line;
+// with MAVEN_LOG_FORMAT=json the event is written as one JSON object carrying
+// attrs instead, so it can be queried in a log aggregator.
+func (s *SnapshotCleanupService) event(level slog.Level, name, text string, attrs ...slog.Attr) {
+	if !s.jsonEvents() {
+		logger.Logf(level, "%s", text)
+		return
 	}
+	l := slog.New(slog.NewJSONHandler(log.Writer(), &slog.HandlerOptions{Level: logger.MinLevel()}))
+	l.LogAttrs(context.Background(), level, name, attrs...)
 }
 
 func (s *SnapshotCleanupService) Start() {
@@ -45,37 +107,58 @@ func (s *SnapshotCleanupService) Start() {
 		return
 	}
 
-	interval, err := time.ParseDuration(s.Config.SnapshotCleanupInterval)
+	schedule, err := cleanupSchedule(s.Config.SnapshotCleanupInterval)
 	if err != nil {
 		log.Printf("Invalid snapshot cleanup interval: %v, using default 1h\n", err)
-		interval = time.Hour
+		schedule = cron.Every(time.Hour)
 	}
 
-	ticker := time.NewTicker(interval)
+	timer := time.NewTimer(time.Until(schedule.Next(time.Now())))
 	go func() {
-		defer ticker.Stop() // Ensure ticker is stopped when goroutine exits
+		defer timer.Stop() // Ensure timer is stopped when goroutine exits
 		for {
 			select {
-			case <-ticker.C:
+			case <-timer.C:
+				// Paused runs are skipped, not queued: the next one is
+				// scheduled either way
+				timer.Reset(time.Until(schedule.Next(time.Now())))
 				s.Mu.Lock()
 				paused := s.Paused
 				s.Mu.Unlock()
 
 				if !paused {
-					log.Println("Starting snapshot cleanup...")
-					if err := s.RunCleanup(); err != nil {
-						log.Printf("Snapshot cleanup failed: %v\n", err)
+					s.event(slog.LevelInfo, "cleanup.start", "Starting snapshot cleanup...\n")
+					if _, err := s.RunCleanup(); err != nil {
+						s.event(slog.LevelError, "cleanup.error", fmt.Sprintf("Snapshot cleanup failed: %v\n", err),
+							slog.String("error", err.Error()))
 					}
-					log.Println("Snapshot cleanup finished.")
+					s.event(slog.LevelInfo, "cleanup.finish", "Snapshot cleanup finished.\n")
 				}
 			case <-s.Ctx.Done():
-				ticker.Stop()
 				return
 			}
 		}
 	}()
 }
 
+// cleanupSchedule parses MAVEN_SNAPSHOT_CLEANUP_INTERVAL: a Go duration such
+// as "1h" runs that long apart, anything else is read as a standard five-field
+// cron expression ("0 3 * * *") or descriptor ("@daily"). Durations are tried
+// first.
+func cleanupSchedule(spec string) (cron.Schedule, error) {
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("interval %q must be positive", spec)
+		}
+		return cron.Every(interval), nil
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a duration nor a cron expression: %w", spec, err)
+	}
+	return schedule, nil
+}
+
 func (s *SnapshotCleanupService) Stop() {
 	s.Cancel()
 }
@@ -101,7 +184,105 @@ func (s *SnapshotCleanupService) Status() string {
 	return "running"
 }
 
-func (s *SnapshotCleanupService) RunCleanup() error {
+// LastRun returns the summary of the most recent cleanup pass, or nil if none
+// has run yet.
+func (s *SnapshotCleanupService) LastRun() *CleanupRun {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.lastRun == nil {
+		return nil
+	}
+	run := *s.lastRun
+	return &run
+}
+
+// Subscribe returns a channel receiving the progress of the running (or next)
+// cleanup pass and a function that stops the subscription. Events are
+// dropped for subscribers that fall behind rather than slowing the cleanup
+// down, but the final event is always delivered and the channel is closed
+// after it.
+func (s *SnapshotCleanupService) Subscribe() (<-chan CleanupProgress, func()) {
+	ch := make(chan CleanupProgress, 64)
+	s.subMu.Lock()
+	if s.subscribers == nil {
+		s.subscribers = make(map[chan CleanupProgress]struct{})
+	}
+	s.subscribers[ch] = struct{}{}
+	s.subMu.Unlock()
+	return ch, func() {
+		s.subMu.Lock()
+		delete(s.subscribers, ch)
+		s.subMu.Unlock()
+	}
+}
+
+// publishDone delivers the final event of a pass to every subscriber, making
+// room by dropping the oldest pending event if needed, and ends their
+// subscriptions.
+func (s *SnapshotCleanupService) publishDone(p CleanupProgress) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- p:
+		default:
+			<-ch
+			ch <- p
+		}
+		close(ch)
+		delete(s.subscribers, ch)
+	}
+}
+
+func (s *SnapshotCleanupService) publish(p CleanupProgress) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// progress builds the event for a pass that has processed some of its
+// directories, extrapolating the remaining time from the pace so far.
+func progress(run *CleanupRun, dir string, processed, total int) CleanupProgress {
+	p := CleanupProgress{
+		Directory:       dir,
+		Processed:       processed,
+		Total:           total,
+		DeletedVersions: run.DeletedVersions,
+		DeletedFiles:    run.DeletedFiles,
+		FreedBytes:      run.FreedBytes,
+	}
+	if processed > 0 {
+		perDir := time.Since(run.StartedAt).Seconds() / float64(processed)
+		p.ETASeconds = perDir * float64(total-processed)
+	}
+	return p
+}
+
+// RunCleanup runs one cleanup pass and returns its summary, which is also kept
+// as the last run.
+func (s *SnapshotCleanupService) RunCleanup() (*CleanupRun, error) {
+	run := &CleanupRun{StartedAt: time.Now().UTC()}
+	err := s.runCleanup(run)
+	run.FinishedAt = time.Now().UTC()
+	if err != nil {
+		run.Error = err.Error()
+	}
+	final := progress(run, "", run.Directories, run.Directories)
+	final.Done = true
+	s.publishDone(final)
+	s.Mu.Lock()
+	s.lastRun = run
+	s.Mu.Unlock()
+	report := *run
+	return &report, err
+}
+
+func (s *SnapshotCleanupService) runCleanup(run *CleanupRun) error {
 	// Find all directories ending in -SNAPSHOT
 	snapshotDirs := make(map[string]bool)
 	err := s.Store.Walk(".", func(path string, info os.FileInfo, err error) error {
@@ -117,18 +298,35 @@ func (s *SnapshotCleanupService) RunCleanup() error {
 		return err
 	}
 
-	log.Printf("Found %d snapshot directories to check\n", len(snapshotDirs))
+	s.event(slog.LevelInfo, "cleanup.scan", fmt.Sprintf("Found %d snapshot directories to check\n", len(snapshotDirs)),
+		slog.Int("directories", len(snapshotDirs)))
+	run.Directories = len(snapshotDirs)
+	processed := 0
 	for dir := range snapshotDirs {
-		log.Printf("Cleaning up snapshot directory: %s\n", dir)
-		if err := s.cleanupDir(dir); err != nil {
-			log.Printf("Failed to cleanup directory %s: %v\n", dir, err)
+		if !s.jsonEvents() {
+			logger.Debugf("Cleaning up snapshot directory: %s\n", dir)
+		}
+		deletedBefore := run.DeletedFiles
+		if err := s.cleanupDir(dir, run); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", dir, err))
+			s.event(slog.LevelError, "cleanup.error", fmt.Sprintf("Failed to cleanup directory %s: %v\n", dir, err),
+				slog.String("directory", dir), slog.String("error", err.Error()))
+		} else if run.DeletedFiles > deletedBefore {
+			if err := s.pruneEmptyDirs(dir, run); err != nil {
+				run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", dir, err))
+			}
 		}
+		if run.DeletedFiles > deletedBefore && s.Changed != nil {
+			s.Changed(dir)
+		}
+		processed++
+		s.publish(progress(run, dir, processed, run.Directories))
 	}
 
 	return nil
 }
 
-func (s *SnapshotCleanupService) cleanupDir(dir string) error {
+func (s *SnapshotCleanupService) cleanupDir(dir string, run *CleanupRun) error {
 	entries, err := s.Store.List(dir)
 	if err != nil {
 		return err
@@ -139,6 +337,7 @@ func (s *SnapshotCleanupService) cleanupDir(dir string) error {
 
 	type fileInfo struct {
 		Name    string
+		Size    int64
 		ModTime time.Time
 	}
 
@@ -162,19 +361,23 @@ func (s *SnapshotCleanupService) cleanupDir(dir string) error {
 			continue
 		}
 
-		if strings.HasPrefix(e.Name, "maven-metadata") {
+		if strings.HasPrefix(e.Name, "maven-metadata") || storage.IsResolutionMarker(e.Name) {
 			continue
 		}
 
 		// Extract version identifier
 		version := s.extractVersion(e.Name)
-		groups[version] = append(groups[version], fileInfo{Name: e.Name, ModTime: e.ModTime})
+		groups[version] = append(groups[version], fileInfo{Name: e.Name, Size: e.Size, ModTime: e.ModTime})
 	}
 
 	now := time.Now()
-	keepDays := time.Duration(s.Config.SnapshotKeepDays) * 24 * time.Hour
+	policy := s.policyFor(dir)
+	keepDays := time.Duration(policy.KeepDays) * 24 * time.Hour
 
-	log.Printf("Processing directory %s: %d snapshot versions found\n", dir, len(groups))
+	textOnly := !s.jsonEvents()
+	if textOnly {
+		log.Printf("Processing directory %s: %d snapshot versions found\n", dir, len(groups))
+	}
 
 	// Create a list of versions to sort them by their latest file mod time
 	type versionInfo struct {
@@ -198,25 +401,42 @@ func (s *SnapshotCleanupService) cleanupDir(dir string) error {
 		return versions[i].MaxTime.After(versions[j].MaxTime)
 	})
 
-	if s.Config.SnapshotKeepDays > 0 {
-		log.Printf("  Retention policy: keep versions newer than %d days\n", s.Config.SnapshotKeepDays)
+	if textOnly && policy.KeepDays > 0 {
+		logger.Debugf("  Retention policy: keep versions newer than %d days\n", policy.KeepDays)
+	}
+	if textOnly && policy.KeepCount > 0 {
+		logger.Debugf("  Retention policy: keep the latest %d snapshot versions\n", policy.KeepCount)
 	}
-	if s.Config.SnapshotKeepLatestOnly {
-		log.Printf("  Retention policy: keep only the latest snapshot version\n")
+	if textOnly && policy.KeepLatestOnly {
+		logger.Debugf("  Retention policy: keep only the latest snapshot version\n")
 	}
 
+	var deletedVersions, deletedFiles int
+	var freedBytes int64
+
 	for i, v := range versions {
 		shouldDelete := false
 		reason := ""
 
-		// Check age (based on the newest file in this version)
-		if s.Config.SnapshotKeepDays > 0 && now.Sub(v.MaxTime) > keepDays {
+		// Check age (based on the newest file in this version). With a keep
+		// count the newest versions survive whatever their age, and older ones
+		// go once they are also expired (or right away without a keep window).
+		expired := policy.KeepDays > 0 && now.Sub(v.MaxTime) > keepDays
+		if keepCount := policy.KeepCount; keepCount > 0 {
+			if i >= keepCount && (expired || policy.KeepDays <= 0) {
+				shouldDelete = true
+				reason = "beyond keep count"
+				if expired {
+					reason = "expired"
+				}
+			}
+		} else if expired {
 			shouldDelete = true
 			reason = "expired"
 		}
 
 		// Check keep latest
-		if s.Config.SnapshotKeepLatestOnly && i > 0 {
+		if policy.KeepLatestOnly && i > 0 {
 			shouldDelete = true
 			if reason == "" {
 				reason = "not latest"
@@ -224,19 +444,113 @@ func (s *SnapshotCleanupService) cleanupDir(dir string) error {
 		}
 
 		if shouldDelete {
-			log.Printf("    Deleting snapshot version %s (Reason: %s, MaxAge: %v)\n", v.Name, reason, now.Sub(v.MaxTime))
+			if textOnly {
+				log.Printf("    Deleting snapshot version %s (Reason: %s, MaxAge: %v)\n", v.Name, reason, now.Sub(v.MaxTime))
+			}
+			files := 0
+			var bytes int64
 			for _, f := range v.Files {
 				relPath := filepath.Join(dir, f.Name)
-				log.Printf("      Deleting file: %s\n", f.Name)
+				if textOnly {
+					logger.Debugf("      Deleting file: %s\n", f.Name)
+				}
 				if err := s.Store.Delete(relPath); err != nil {
-					log.Printf("      Failed to delete %s: %v\n", relPath, err)
+					run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", relPath, err))
+					s.event(slog.LevelError, "cleanup.error", fmt.Sprintf("      Failed to delete %s: %v\n", relPath, err),
+						slog.String("directory", dir), slog.String("version", v.Name), slog.String("file", f.Name), slog.String("error", err.Error()))
+					continue
 				}
+				files++
+				bytes += f.Size
 			}
-		} else {
-			log.Printf("    Keeping snapshot version: %s (%d files)\n", v.Name, len(v.Files))
+			if !textOnly {
+				s.event(slog.LevelInfo, "cleanup.delete", "",
+					slog.String("directory", dir), slog.String("version", v.Name), slog.Int("files", files),
+					slog.Int64("bytes", bytes), slog.String("reason", reason))
+			}
+			deletedVersions++
+			deletedFiles += files
+			freedBytes += bytes
+		} else if textOnly {
+			logger.Debugf("    Keeping snapshot version: %s (%d files)\n", v.Name, len(v.Files))
+		}
+	}
+
+	if deletedVersions > 0 {
+		if err := s.updateMetadata(dir, entries, deletedVersions == len(versions)); err != nil {
+			run.Errors = append(run.Errors, fmt.Sprintf("%s: %v", dir, err))
+			s.event(slog.LevelError, "cleanup.error", fmt.Sprintf("Failed to update metadata in %s: %v\n", dir, err),
+				slog.String("directory", dir), slog.String("error", err.Error()))
+		}
+	}
+
+	run.Versions += len(versions)
+	run.DeletedVersions += deletedVersions
+	run.DeletedFiles += deletedFiles
+	run.FreedBytes += freedBytes
+	if !textOnly {
+		s.event(slog.LevelInfo, "cleanup.directory", "",
+			slog.String("directory", dir), slog.Int("versions", len(versions)), slog.Int("deletedVersions", deletedVersions),
+			slog.Int("files", deletedFiles), slog.Int64("bytes", freedBytes))
+	}
+	return nil
+}
+
+// pruneEmptyDirs removes dir if cleanup left it empty, then each parent that
+// became empty in turn. The storage root, "repository" and the repository
+// roots below it are never removed.
+func (s *SnapshotCleanupService) pruneEmptyDirs(dir string, run *CleanupRun) error {
+	remover, ok := s.Store.(storage.EmptyDirRemover)
+	if !ok {
+		return nil
+	}
+	for dir = path.Clean(filepath.ToSlash(dir)); !isRepoRoot(dir); dir = path.Dir(dir) {
+		removed, err := remover.RemoveEmptyDir(dir)
+		if err != nil || !removed {
+			return err
 		}
+		logger.Debugf("Removed empty directory %s\n", dir)
+		run.RemovedDirectories++
 	}
+	return nil
+}
 
+func isRepoRoot(dir string) bool {
+	if dir == "." || dir == "/" || dir == "repository" {
+		return true
+	}
+	repo, _, nested := strings.Cut(strings.TrimPrefix(dir, "repository/"), "/")
+	return strings.HasPrefix(dir, "repository/") && repo != "" && !nested
+}
+
+// updateMetadata brings a cleaned directory's maven-metadata.xml in line with
+// the builds that are left, so clients are not sent after deleted ones. When
+// every build is gone the document and its checksums are deleted too.
+// Directories without metadata are left alone.
+func (s *SnapshotCleanupService) updateMetadata(dir string, entries []storage.Entry, empty bool) error {
+	hasMetadata := false
+	for _, e := range entries {
+		if e.Name == "maven-metadata.xml" {
+			hasMetadata = true
+		}
+	}
+	if !hasMetadata {
+		return nil
+	}
+
+	if empty {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name, "maven-metadata.xml") && !storage.IsInternal(e.Name) {
+				if err := s.Store.Delete(dir + "/" + e.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if _, err := s.Metadata.ReconcileSnapshot(dir); err != nil && !errors.Is(err, ErrNoSnapshotBuilds) {
+		return err
+	}
 	return nil
 }
 
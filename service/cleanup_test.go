@@ -1,8 +1,13 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -59,7 +64,7 @@ func TestSnapshotCleanupService_RunCleanup(t *testing.T) {
 	}
 
 	// Run cleanup
-	if err := svc.RunCleanup(); err != nil {
+	if _, err := svc.RunCleanup(); err != nil {
 		t.Fatal(err)
 	}
 
@@ -92,3 +97,357 @@ func TestSnapshotCleanupService_RunCleanup(t *testing.T) {
 		t.Errorf("Expected 1 jar, got %d", remainingJar)
 	}
 }
+
+func TestSnapshotCleanupService_JSONEvents(t *testing.T) {
+	var buf bytes.Buffer
+	prevOutput, prevFlags := log.Writer(), log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(prevOutput)
+		log.SetFlags(prevFlags)
+	}()
+
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{
+		SnapshotKeepLatestOnly: true,
+		LogFormat:              "json",
+	})
+
+	dir := "com/example/app/1.0-SNAPSHOT"
+	now := time.Now()
+	for i, name := range []string{"app-1.0-20240101.120000-1.jar", "app-1.0-20240102.120000-2.jar"} {
+		store.Save(filepath.Join(dir, name), strings.NewReader("0123456789"))
+		age := now.Add(-time.Duration(2-i) * time.Hour)
+		os.Chtimes(filepath.Join(base, dir, name), age, age)
+	}
+
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]map[string]any{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected only JSON lines, got %q", line)
+		}
+		events[event["msg"].(string)] = event
+	}
+
+	del, ok := events["cleanup.delete"]
+	if !ok {
+		t.Fatalf("expected a cleanup.delete event, got %v", events)
+	}
+	want := map[string]any{
+		"directory": dir,
+		"version":   "app-1.0-20240101.120000-1",
+		"files":     float64(1),
+		"bytes":     float64(10),
+		"reason":    "not latest",
+	}
+	for field, value := range want {
+		if del[field] != value {
+			t.Errorf("cleanup.delete %s: expected %v, got %v", field, value, del[field])
+		}
+	}
+
+	summary, ok := events["cleanup.directory"]
+	if !ok || summary["directory"] != dir || summary["deletedVersions"] != float64(1) || summary["bytes"] != float64(10) {
+		t.Errorf("unexpected cleanup.directory event: %v", summary)
+	}
+	if scan, ok := events["cleanup.scan"]; !ok || scan["directories"] != float64(1) {
+		t.Errorf("unexpected cleanup.scan event: %v", scan)
+	}
+}
+
+func TestSnapshotCleanupService_IgnoresResolutionMarkers(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{SnapshotKeepLatestOnly: true})
+
+	dir := "com/example/app/1.0-SNAPSHOT"
+	now := time.Now()
+	for _, f := range []struct {
+		Name string
+		Age  time.Duration
+	}{
+		{"app-1.0-20240101.120000-1.jar", 2 * time.Hour},
+		{"app-1.0-20240101.120000-1.jar.lastUpdated", 0},
+		{"_remote.repositories", 0},
+	} {
+		path := filepath.Join(dir, f.Name)
+		if err := store.Save(path, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(filepath.Join(base, path), now.Add(-f.Age), now.Add(-f.Age))
+	}
+
+	if err := svc.cleanupDir(dir, &CleanupRun{}); err != nil {
+		t.Fatal(err)
+	}
+	// The markers must not count as newer snapshot versions
+	if found, _ := store.Head(filepath.Join(dir, "app-1.0-20240101.120000-1.jar")); !found {
+		t.Error("latest snapshot build was deleted because of a resolution marker")
+	}
+}
+
+func TestSnapshotCleanupService_PublishesProgress(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{SnapshotKeepLatestOnly: true})
+
+	for _, dir := range []string{"com/example/a/1.0-SNAPSHOT", "com/example/b/1.0-SNAPSHOT"} {
+		store.Save(filepath.Join(dir, "x-1.0-20240101.120000-1.jar"), strings.NewReader("old"))
+		store.Save(filepath.Join(dir, "x-1.0-20240102.120000-2.jar"), strings.NewReader("new"))
+		old := time.Now().Add(-time.Hour)
+		os.Chtimes(filepath.Join(base, dir, "x-1.0-20240101.120000-1.jar"), old, old)
+	}
+
+	events, unsubscribe := svc.Subscribe()
+	defer unsubscribe()
+	changed := map[string]bool{}
+	svc.Changed = func(dir string) { changed[dir] = true }
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 || !changed["com/example/a/1.0-SNAPSHOT"] {
+		t.Errorf("expected both cleaned directories to be reported changed, got %v", changed)
+	}
+
+	var got []CleanupProgress
+	for len(got) == 0 || !got[len(got)-1].Done {
+		select {
+		case p := <-events:
+			got = append(got, p)
+		default:
+			t.Fatalf("missing final event, got %+v", got)
+		}
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 2 directory events and a final one, got %+v", got)
+	}
+	for i, p := range got[:2] {
+		if p.Processed != i+1 || p.Total != 2 || p.Directory == "" {
+			t.Errorf("event %d: unexpected %+v", i, p)
+		}
+	}
+	if final := got[2]; final.DeletedVersions != 2 || final.DeletedFiles != 2 || final.ETASeconds != 0 {
+		t.Errorf("unexpected final event %+v", final)
+	}
+}
+
+func TestSnapshotCleanupService_DeliversDoneToSlowSubscribers(t *testing.T) {
+	svc := NewSnapshotCleanupService(storage.NewLocalStorage(t.TempDir()), &config.Config{})
+	events, unsubscribe := svc.Subscribe()
+	defer unsubscribe()
+
+	// Fill the subscriber's buffer and more without reading it
+	for i := 0; i < 100; i++ {
+		svc.publish(CleanupProgress{Processed: i})
+	}
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	var last CleanupProgress
+	for p := range events {
+		last = p
+	}
+	if !last.Done {
+		t.Fatalf("expected the final event before the channel closed, got %+v", last)
+	}
+}
+
+func TestSnapshotCleanupService_KeepCount(t *testing.T) {
+	now := time.Now()
+	// Five builds, one a day, newest first
+	builds := []string{"20240105.120000-5", "20240104.120000-4", "20240103.120000-3", "20240102.120000-2", "20240101.120000-1"}
+
+	for _, tc := range []struct {
+		name     string
+		keepDays int
+		want     []string
+	}{
+		{"count only", 0, builds[:3]},
+		// Builds 4 and 5 are beyond the count, but only the oldest is also
+		// outside the four-day window
+		{"count and days", 4, builds[:4]},
+		{"window keeps all", 10, builds},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			base := t.TempDir()
+			store := storage.NewLocalStorage(base)
+			svc := NewSnapshotCleanupService(store, &config.Config{SnapshotKeepCount: 3, SnapshotKeepDays: tc.keepDays})
+
+			dir := "com/example/app/1.0-SNAPSHOT"
+			for i, b := range builds {
+				for _, ext := range []string{".jar", ".pom"} {
+					name := "app-1.0-" + b + ext
+					store.Save(filepath.Join(dir, name), strings.NewReader("x"))
+					age := now.Add(-time.Duration(i)*24*time.Hour - time.Hour)
+					os.Chtimes(filepath.Join(base, dir, name), age, age)
+				}
+			}
+
+			run, err := svc.RunCleanup()
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries, _ := store.List(dir)
+			var remaining []string
+			for _, e := range entries {
+				if strings.HasSuffix(e.Name, ".jar") {
+					remaining = append(remaining, strings.TrimSuffix(strings.TrimPrefix(e.Name, "app-1.0-"), ".jar"))
+				}
+			}
+			sort.Sort(sort.Reverse(sort.StringSlice(remaining)))
+			if strings.Join(remaining, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("remaining builds %v, want %v", remaining, tc.want)
+			}
+			if run.Versions != 5 || run.DeletedVersions != 5-len(tc.want) {
+				t.Errorf("unexpected report %+v", run)
+			}
+		})
+	}
+}
+
+func TestSnapshotCleanupService_UpdatesMetadata(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{SnapshotKeepCount: 1, MetadataLockTTL: "5s"})
+
+	dir := "com/example/app/1.0-SNAPSHOT"
+	now := time.Now()
+	builds := []string{"20240103.120000-3", "20240102.120000-2", "20240101.120000-1"}
+	var versions strings.Builder
+	for i, b := range builds {
+		name := "app-1.0-" + b + ".jar"
+		store.Save(filepath.Join(dir, name), strings.NewReader("x"))
+		age := now.Add(-time.Duration(i) * time.Hour)
+		os.Chtimes(filepath.Join(base, dir, name), age, age)
+		versions.WriteString("<snapshotVersion><extension>jar</extension><value>1.0-" + b + "</value></snapshotVersion>")
+	}
+	// Metadata as some deploy tools write it, listing every build
+	store.Save(dir+"/maven-metadata.xml", strings.NewReader(`<metadata><groupId>com.example</groupId><artifactId>app</artifactId><version>1.0-SNAPSHOT</version>`+
+		`<versioning><snapshot><timestamp>20240103.120000</timestamp><buildNumber>3</buildNumber></snapshot><snapshotVersions>`+versions.String()+`</snapshotVersions></versioning></metadata>`))
+
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+	reader, found, _ := store.Get(dir + "/maven-metadata.xml")
+	if !found {
+		t.Fatal("metadata should be kept while builds remain")
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if !strings.Contains(string(data), "1.0-20240103.120000-3") {
+		t.Errorf("metadata lost the remaining build:\n%s", data)
+	}
+	for _, purged := range builds[1:] {
+		if strings.Contains(string(data), purged) {
+			t.Errorf("metadata still lists purged build %s:\n%s", purged, data)
+		}
+	}
+
+	// Once every build expires the metadata goes with them
+	svc.Config.SnapshotKeepCount = 0
+	svc.Config.SnapshotKeepDays = 1
+	old := now.Add(-48 * time.Hour)
+	os.Chtimes(filepath.Join(base, dir, "app-1.0-20240103.120000-3.jar"), old, old)
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"maven-metadata.xml", "maven-metadata.xml.sha1", "maven-metadata.xml.md5"} {
+		if found, _ := store.Head(dir + "/" + name); found {
+			t.Errorf("%s should be deleted with the last build", name)
+		}
+	}
+}
+
+func TestSnapshotCleanupService_RemovesEmptyDirectories(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{SnapshotKeepDays: 1})
+
+	old := time.Now().Add(-48 * time.Hour)
+	for _, p := range []string{
+		"repository/snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-20240101.120000-1.jar",
+		"repository/snapshots/com/example/lib/1.0-SNAPSHOT/lib-1.0-20240101.120000-1.jar",
+		"repository/develop/org/solo/tool/2.0-SNAPSHOT/tool-2.0-20240101.120000-1.jar",
+	} {
+		store.Save(p, strings.NewReader("x"))
+		os.Chtimes(filepath.Join(base, p), old, old)
+	}
+	// A sibling that stays keeps com/example alive
+	store.Save("repository/snapshots/com/example/other/1.0/other-1.0.jar", strings.NewReader("x"))
+	// An empty directory cleanup did not empty is left alone
+	store.CreateDir("repository/snapshots/com/example/empty/1.0-SNAPSHOT")
+
+	run, err := svc.RunCleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]bool{
+		"repository/snapshots/com/example/app":                     false,
+		"repository/snapshots/com/example/lib":                     false,
+		"repository/snapshots/com/example":                         true,
+		"repository/snapshots/com/example/empty/1.0-SNAPSHOT":      true,
+		"repository/develop/org":                                   false,
+		"repository/develop":                                       true,
+		"repository/snapshots/com/example/other/1.0/other-1.0.jar": true,
+	} {
+		_, err := os.Stat(filepath.Join(base, p))
+		if exists := err == nil; exists != want {
+			t.Errorf("%s exists = %v, want %v", p, exists, want)
+		}
+	}
+	// app and lib with their version directories, and org/solo/tool/2.0-SNAPSHOT
+	if run.RemovedDirectories != 8 {
+		t.Errorf("removed %d directories, want 8", run.RemovedDirectories)
+	}
+}
+
+func TestIsRepoRoot(t *testing.T) {
+	for dir, want := range map[string]bool{
+		".":                            true,
+		"repository":                   true,
+		"repository/snapshots":         true,
+		"repository/snapshots/com":     false,
+		"com":                          false,
+		"com/example/app/1.0-SNAPSHOT": false,
+	} {
+		if got := isRepoRoot(dir); got != want {
+			t.Errorf("isRepoRoot(%q) = %v, want %v", dir, got, want)
+		}
+	}
+}
+
+func TestCleanupSchedule(t *testing.T) {
+	now := time.Date(2024, 5, 10, 14, 30, 0, 0, time.Local)
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"1h", now.Add(time.Hour)},
+		{"0 3 * * *", time.Date(2024, 5, 11, 3, 0, 0, 0, time.Local)},
+		{"@daily", time.Date(2024, 5, 11, 0, 0, 0, 0, time.Local)},
+		{"*/15 * * * *", time.Date(2024, 5, 10, 14, 45, 0, 0, time.Local)},
+	} {
+		schedule, err := cleanupSchedule(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+		if got := schedule.Next(now); !got.Equal(tc.want) {
+			t.Errorf("%q: next run %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+	for _, spec := range []string{"", "-1h", "soon", "0 3 * *"} {
+		if _, err := cleanupSchedule(spec); err == nil {
+			t.Errorf("%q should be rejected", spec)
+		}
+	}
+}
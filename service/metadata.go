@@ -0,0 +1,501 @@
+package service
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+// Metadata mirrors the maven-metadata.xml format used at the groupId,
+// artifactId and snapshot version levels.
+type Metadata struct {
+	XMLName      xml.Name    `xml:"metadata"`
+	ModelVersion string      `xml:"modelVersion,attr,omitempty"`
+	GroupID      string      `xml:"groupId,omitempty"`
+	ArtifactID   string      `xml:"artifactId,omitempty"`
+	Version      string      `xml:"version,omitempty"`
+	Versioning   *Versioning `xml:"versioning,omitempty"`
+	Plugins      []Plugin    `xml:"plugins>plugin,omitempty"`
+}
+
+type Versioning struct {
+	Latest           string            `xml:"latest,omitempty"`
+	Release          string            `xml:"release,omitempty"`
+	Snapshot         *Snapshot         `xml:"snapshot,omitempty"`
+	Versions         []string          `xml:"versions>version,omitempty"`
+	LastUpdated      string            `xml:"lastUpdated,omitempty"`
+	SnapshotVersions []SnapshotVersion `xml:"snapshotVersions>snapshotVersion,omitempty"`
+}
+
+type Snapshot struct {
+	Timestamp   string `xml:"timestamp,omitempty"`
+	BuildNumber int    `xml:"buildNumber,omitempty"`
+	LocalCopy   bool   `xml:"localCopy,omitempty"`
+}
+
+type SnapshotVersion struct {
+	Classifier string `xml:"classifier,omitempty"`
+	Extension  string `xml:"extension"`
+	Value      string `xml:"value"`
+	Updated    string `xml:"updated"`
+}
+
+type Plugin struct {
+	Name       string `xml:"name,omitempty"`
+	Prefix     string `xml:"prefix"`
+	ArtifactID string `xml:"artifactId"`
+}
+
+func ParseMetadata(data []byte) (*Metadata, error) {
+	var md Metadata
+	if err := xml.Unmarshal(data, &md); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+func (m *Metadata) Marshal() ([]byte, error) {
+	out, err := xml.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
+
+// MergeMetadata combines two metadata documents for the same coordinates so
+// that no version known to either side is lost.
+func MergeMetadata(existing, incoming *Metadata) *Metadata {
+	if existing == nil {
+		return incoming
+	}
+	if incoming == nil {
+		return existing
+	}
+
+	merged := *incoming
+	if merged.ModelVersion == "" {
+		merged.ModelVersion = existing.ModelVersion
+	}
+	if merged.GroupID == "" {
+		merged.GroupID = existing.GroupID
+	}
+	if merged.ArtifactID == "" {
+		merged.ArtifactID = existing.ArtifactID
+	}
+	if merged.Version == "" {
+		merged.Version = existing.Version
+	}
+	merged.Versioning = mergeVersioning(existing.Versioning, incoming.Versioning)
+	merged.Plugins = mergePlugins(existing.Plugins, incoming.Plugins)
+	return &merged
+}
+
+func mergeVersioning(existing, incoming *Versioning) *Versioning {
+	if existing == nil {
+		return incoming
+	}
+	if incoming == nil {
+		return existing
+	}
+
+	merged := *incoming
+	if merged.Latest == "" {
+		merged.Latest = existing.Latest
+	}
+	if merged.Release == "" {
+		merged.Release = existing.Release
+	}
+
+	seen := make(map[string]bool)
+	merged.Versions = nil
+	for _, v := range append(append([]string{}, existing.Versions...), incoming.Versions...) {
+		if !seen[v] {
+			seen[v] = true
+			merged.Versions = append(merged.Versions, v)
+		}
+	}
+
+	// Timestamps share a fixed-width layout, so string comparison orders them
+	if existing.LastUpdated > merged.LastUpdated {
+		merged.LastUpdated = existing.LastUpdated
+	}
+	if existing.Snapshot != nil && (merged.Snapshot == nil || existing.Snapshot.Timestamp > merged.Snapshot.Timestamp) {
+		merged.Snapshot = existing.Snapshot
+	}
+
+	byKey := make(map[string]int)
+	merged.SnapshotVersions = nil
+	for _, sv := range append(append([]SnapshotVersion{}, existing.SnapshotVersions...), incoming.SnapshotVersions...) {
+		key := sv.Classifier + ":" + sv.Extension
+		if idx, ok := byKey[key]; ok {
+			if sv.Updated >= merged.SnapshotVersions[idx].Updated {
+				merged.SnapshotVersions[idx] = sv
+			}
+			continue
+		}
+		byKey[key] = len(merged.SnapshotVersions)
+		merged.SnapshotVersions = append(merged.SnapshotVersions, sv)
+	}
+	return &merged
+}
+
+func mergePlugins(existing, incoming []Plugin) []Plugin {
+	seen := make(map[string]bool)
+	var merged []Plugin
+	for _, p := range append(append([]Plugin{}, incoming...), existing...) {
+		if !seen[p.Prefix] {
+			seen[p.Prefix] = true
+			merged = append(merged, p)
+		}
+	}
+	return merged
+}
+
+type MetadataService struct {
+	Store  storage.StorageProvider
+	Config *config.Config
+	// Clock stamps lastUpdated; its result is always converted to UTC
+	Clock func() time.Time
+}
+
+func NewMetadataService(store storage.StorageProvider, cfg *config.Config) *MetadataService {
+	return &MetadataService{
+		Store:  store,
+		Config: cfg,
+		Clock:  time.Now,
+	}
+}
+
+// lastUpdatedLayout is the yyyyMMddHHmmss format of <lastUpdated>.
+const lastUpdatedLayout = "20060102150405"
+
+// stampLastUpdated moves v.LastUpdated to the current time, unless a stored
+// value is already later. A writer with a lagging clock must never make the
+// metadata look older, or clients keep their cached copy and miss versions.
+func (s *MetadataService) stampLastUpdated(previous string, v *Versioning) {
+	clock := s.Clock
+	if clock == nil {
+		clock = time.Now
+	}
+	now := clock().UTC().Format(lastUpdatedLayout)
+	for _, candidate := range []string{now, previous} {
+		// Timestamps share a fixed-width layout, so string comparison orders them
+		if candidate > v.LastUpdated {
+			v.LastUpdated = candidate
+		}
+	}
+}
+
+// lock serialises metadata writes for path. Backends that cannot lock across
+// instances fall back to no locking.
+func (s *MetadataService) lock(path string) (func(), error) {
+	locker, ok := s.Store.(storage.Locker)
+	if !ok {
+		return func() {}, nil
+	}
+	ttl, err := time.ParseDuration(s.Config.MetadataLockTTL)
+	if err != nil || ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return locker.Lock(path, ttl)
+}
+
+// Update merges an uploaded maven-metadata.xml into the stored copy under a
+// lock, so concurrent deploys from several instances don't drop versions.
+// Documents that cannot be parsed are stored unchanged.
+func (s *MetadataService) Update(path string, data io.Reader) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	incoming, err := ParseMetadata(body)
+	if err != nil {
+		log.Printf("Storing unparseable metadata %s as-is: %v\n", path, err)
+		return s.Store.Save(path, bytes.NewReader(body))
+	}
+
+	unlock, err := s.lock(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := s.read(path)
+	if err != nil {
+		return err
+	}
+	merged := MergeMetadata(existing, incoming)
+	if merged.Versioning != nil {
+		previous := ""
+		if existing != nil && existing.Versioning != nil {
+			previous = existing.Versioning.LastUpdated
+		}
+		s.stampLastUpdated(previous, merged.Versioning)
+	}
+	return s.write(path, merged)
+}
+
+func (s *MetadataService) read(path string) (*Metadata, error) {
+	reader, found, err := s.Store.Get(path)
+	if err != nil || !found {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	md, err := ParseMetadata(data)
+	if err != nil {
+		log.Printf("Replacing unparseable metadata %s: %v\n", path, err)
+		return nil, nil
+	}
+	return md, nil
+}
+
+// write stores the metadata along with its .sha1 and .md5 sidecars, since a
+// merged document no longer matches the checksums the client computed.
+func (s *MetadataService) write(path string, md *Metadata) error {
+	data, err := md.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := s.Store.Save(path, bytes.NewReader(data)); err != nil {
+		return err
+	}
+
+	sha := sha1.Sum(data)
+	if err := s.Store.Save(path+".sha1", bytes.NewReader([]byte(hex.EncodeToString(sha[:])))); err != nil {
+		return fmt.Errorf("failed to write sha1: %w", err)
+	}
+	sum := md5.Sum(data)
+	if err := s.Store.Save(path+".md5", bytes.NewReader([]byte(hex.EncodeToString(sum[:])))); err != nil {
+		return fmt.Errorf("failed to write md5: %w", err)
+	}
+	return nil
+}
+
+// RecordUpload updates the metadata an uploaded artifact file belongs to, so
+// clients that do not deploy maven-metadata.xml themselves can still resolve
+// it: the artifact-level document gains the version and, for snapshots, the
+// version-level document is rebuilt from the timestamped builds on disk. Paths
+// that do not follow the Maven layout are ignored.
+func (s *MetadataService) RecordUpload(p string) error {
+	p = strings.Trim(p, "/")
+	versionDir := path.Dir(p)
+	version := path.Base(versionDir)
+	artifactDir := path.Dir(versionDir)
+	artifactID := path.Base(artifactDir)
+	baseVersion := strings.TrimSuffix(version, "-SNAPSHOT")
+	groupID := groupIDFromDir(versionDir)
+	if groupID == "" || !strings.HasPrefix(path.Base(p), artifactID+"-"+baseVersion) {
+		return nil
+	}
+
+	if err := s.addVersion(artifactDir+"/maven-metadata.xml", groupID, artifactID, version); err != nil {
+		return err
+	}
+	if strings.HasSuffix(version, "-SNAPSHOT") {
+		// Non-unique snapshots have no timestamped builds to describe
+		if _, err := s.ReconcileSnapshot(versionDir); err != nil && !errors.Is(err, ErrNoSnapshotBuilds) {
+			return err
+		}
+	}
+	return nil
+}
+
+// addVersion lists version in the artifact-level metadata at mdPath, making it
+// the latest (and, for releases, the release) version. Versions that are
+// already listed leave the document untouched.
+func (s *MetadataService) addVersion(mdPath, groupID, artifactID, version string) error {
+	unlock, err := s.lock(mdPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	existing, err := s.read(mdPath)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.Versioning != nil {
+		for _, v := range existing.Versioning.Versions {
+			if v == version {
+				return nil
+			}
+		}
+	}
+
+	incoming := &Metadata{
+		GroupID:    groupID,
+		ArtifactID: artifactID,
+		Versioning: &Versioning{Latest: version, Versions: []string{version}},
+	}
+	if !strings.HasSuffix(version, "-SNAPSHOT") {
+		incoming.Versioning.Release = version
+	}
+	merged := MergeMetadata(existing, incoming)
+	previous := ""
+	if existing != nil && existing.Versioning != nil {
+		previous = existing.Versioning.LastUpdated
+	}
+	s.stampLastUpdated(previous, merged.Versioning)
+	return s.write(mdPath, merged)
+}
+
+// timestampedFileRegex splits the part of a unique snapshot filename after
+// "<artifactId>-<baseVersion>-" into timestamp, build number, classifier and
+// extension.
+var timestampedFileRegex = regexp.MustCompile(`^(\d{8}\.\d{6})-(\d+)(?:-([^.]+))?\.(.+)$`)
+
+var checksumExtensions = []string{".sha1", ".md5", ".sha256", ".sha512"}
+
+var (
+	ErrNotSnapshotDir   = errors.New("not a snapshot version directory")
+	ErrNoSnapshotBuilds = errors.New("no timestamped snapshot builds found")
+)
+
+type snapshotBuild struct {
+	Timestamp   string
+	BuildNumber int
+}
+
+func (b snapshotBuild) newerThan(o snapshotBuild) bool {
+	if b.Timestamp != o.Timestamp {
+		return b.Timestamp > o.Timestamp
+	}
+	return b.BuildNumber > o.BuildNumber
+}
+
+// ReconcileSnapshot rewrites the maven-metadata.xml of a snapshot version
+// directory so its <snapshot> and <snapshotVersions> describe the timestamped
+// files actually present on disk.
+func (s *MetadataService) ReconcileSnapshot(dir string) (*Metadata, error) {
+	dir = strings.Trim(dir, "/")
+	version := path.Base(dir)
+	if !strings.HasSuffix(version, "-SNAPSHOT") {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotSnapshotDir)
+	}
+	artifactID := path.Base(path.Dir(dir))
+	baseVersion := strings.TrimSuffix(version, "-SNAPSHOT")
+	prefix := artifactID + "-" + baseVersion + "-"
+
+	entries, err := s.Store.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest snapshotBuild
+	latestByKey := make(map[string]SnapshotVersion)
+	buildByKey := make(map[string]snapshotBuild)
+	var keys []string
+	for _, e := range entries {
+		if e.IsDir || !strings.HasPrefix(e.Name, prefix) || isChecksumFile(e.Name) || storage.IsInternal(e.Name) || storage.IsResolutionMarker(e.Name) {
+			continue
+		}
+		m := timestampedFileRegex.FindStringSubmatch(strings.TrimPrefix(e.Name, prefix))
+		if m == nil {
+			continue
+		}
+		buildNumber, _ := strconv.Atoi(m[2])
+		build := snapshotBuild{Timestamp: m[1], BuildNumber: buildNumber}
+		if build.newerThan(latest) {
+			latest = build
+		}
+
+		key := m[3] + ":" + m[4]
+		if current, ok := buildByKey[key]; ok && !build.newerThan(current) {
+			continue
+		}
+		if _, ok := buildByKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		buildByKey[key] = build
+		latestByKey[key] = SnapshotVersion{
+			Classifier: m[3],
+			Extension:  m[4],
+			Value:      fmt.Sprintf("%s-%s-%d", baseVersion, build.Timestamp, build.BuildNumber),
+			Updated:    strings.Replace(build.Timestamp, ".", "", 1),
+		}
+	}
+	if latest.Timestamp == "" {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNoSnapshotBuilds)
+	}
+
+	mdPath := dir + "/maven-metadata.xml"
+	unlock, err := s.lock(mdPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	md, err := s.read(mdPath)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		md = &Metadata{}
+	}
+	if md.GroupID == "" {
+		md.GroupID = groupIDFromDir(dir)
+	}
+	md.ArtifactID = artifactID
+	md.Version = version
+
+	versioning := &Versioning{
+		Snapshot: &Snapshot{Timestamp: latest.Timestamp, BuildNumber: latest.BuildNumber},
+	}
+	previous := ""
+	if md.Versioning != nil {
+		previous = md.Versioning.LastUpdated
+	}
+	s.stampLastUpdated(previous, versioning)
+	sort.Strings(keys)
+	for _, key := range keys {
+		versioning.SnapshotVersions = append(versioning.SnapshotVersions, latestByKey[key])
+	}
+	md.Versioning = versioning
+
+	if err := s.write(mdPath, md); err != nil {
+		return nil, err
+	}
+	return md, nil
+}
+
+func isChecksumFile(name string) bool {
+	for _, ext := range checksumExtensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// groupIDFromDir derives the groupId from a version directory such as
+// repository/<repo>/com/example/app/1.0-SNAPSHOT.
+func groupIDFromDir(dir string) string {
+	parts := strings.Split(dir, "/")
+	if len(parts) > 2 && parts[0] == "repository" {
+		parts = parts[2:]
+	}
+	if len(parts) < 3 {
+		return ""
+	}
+	return strings.Join(parts[:len(parts)-2], ".")
+}
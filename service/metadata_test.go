@@ -0,0 +1,267 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func artifactMetadata(version string) string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<metadata>
+  <groupId>com.example</groupId>
+  <artifactId>app</artifactId>
+  <versioning>
+    <latest>%[1]s</latest>
+    <release>%[1]s</release>
+    <versions>
+      <version>%[1]s</version>
+    </versions>
+    <lastUpdated>20240101000000</lastUpdated>
+  </versioning>
+</metadata>`, version)
+}
+
+func readMetadata(t *testing.T, store storage.StorageProvider, path string) *Metadata {
+	t.Helper()
+	reader, found, err := store.Get(path)
+	if err != nil || !found {
+		t.Fatalf("metadata not found: %v", err)
+	}
+	defer reader.Close()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := ParseMetadata(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return md
+}
+
+func TestMetadataService_ConcurrentUpdates(t *testing.T) {
+	base := t.TempDir()
+	cfg := &config.Config{MetadataLockTTL: "5s"}
+	path := "repository/releases/com/example/app/maven-metadata.xml"
+
+	// Two services over the same directory stand in for two replicas
+	replicas := []*MetadataService{
+		NewMetadataService(storage.NewLocalStorage(base), cfg),
+		NewMetadataService(storage.NewLocalStorage(base), cfg),
+	}
+
+	var wg sync.WaitGroup
+	versions := []string{"1.0", "1.1", "1.2", "1.3", "1.4", "1.5"}
+	for i, v := range versions {
+		wg.Add(1)
+		go func(svc *MetadataService, version string) {
+			defer wg.Done()
+			if err := svc.Update(path, strings.NewReader(artifactMetadata(version))); err != nil {
+				t.Error(err)
+			}
+		}(replicas[i%2], v)
+	}
+	wg.Wait()
+
+	md := readMetadata(t, storage.NewLocalStorage(base), path)
+	got := make(map[string]bool)
+	for _, v := range md.Versioning.Versions {
+		got[v] = true
+	}
+	for _, v := range versions {
+		if !got[v] {
+			t.Errorf("version %s was lost, have %v", v, md.Versioning.Versions)
+		}
+	}
+}
+
+func TestMergeMetadata(t *testing.T) {
+	existing, _ := ParseMetadata([]byte(artifactMetadata("1.0")))
+	incoming, _ := ParseMetadata([]byte(artifactMetadata("2.0")))
+	incoming.Versioning.LastUpdated = "20230101000000"
+
+	merged := MergeMetadata(existing, incoming)
+	if strings.Join(merged.Versioning.Versions, ",") != "1.0,2.0" {
+		t.Errorf("unexpected versions %v", merged.Versioning.Versions)
+	}
+	if merged.Versioning.Latest != "2.0" {
+		t.Errorf("expected latest 2.0, got %s", merged.Versioning.Latest)
+	}
+	if merged.Versioning.LastUpdated != "20240101000000" {
+		t.Errorf("expected newest lastUpdated to win, got %s", merged.Versioning.LastUpdated)
+	}
+}
+
+func TestMetadataService_ReconcileSnapshot(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	svc := NewMetadataService(store, &config.Config{MetadataLockTTL: "5s"})
+	dir := "repository/develop/com/example/app/1.0-SNAPSHOT"
+
+	// Metadata still points at build 1 although builds 2 and 3 landed
+	stale := `<?xml version="1.0" encoding="UTF-8"?>
+<metadata>
+  <groupId>com.example</groupId>
+  <artifactId>app</artifactId>
+  <version>1.0-SNAPSHOT</version>
+  <versioning>
+    <snapshot><timestamp>20240101.100000</timestamp><buildNumber>1</buildNumber></snapshot>
+    <lastUpdated>20240101100000</lastUpdated>
+    <snapshotVersions>
+      <snapshotVersion><extension>jar</extension><value>1.0-20240101.100000-1</value><updated>20240101100000</updated></snapshotVersion>
+    </snapshotVersions>
+  </versioning>
+</metadata>`
+	files := map[string]string{
+		"maven-metadata.xml":                            stale,
+		"app-1.0-20240101.100000-1.jar":                 "1",
+		"app-1.0-20240101.100000-1.pom":                 "1",
+		"app-1.0-20240102.100000-2.jar":                 "2",
+		"app-1.0-20240102.100000-2.pom":                 "2",
+		"app-1.0-20240103.100000-3.jar":                 "3",
+		"app-1.0-20240103.100000-3.jar.sha1":            "3",
+		"app-1.0-20240103.100000-3-sources.jar":         "3",
+		"app-1.0-20240103.100000-3.jar.provenance.json": "{}",
+	}
+	for name, content := range files {
+		if err := store.Save(dir+"/"+name, strings.NewReader(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := svc.ReconcileSnapshot(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	md := readMetadata(t, store, dir+"/maven-metadata.xml")
+	if md.Versioning.Snapshot.Timestamp != "20240103.100000" || md.Versioning.Snapshot.BuildNumber != 3 {
+		t.Errorf("unexpected snapshot %+v", md.Versioning.Snapshot)
+	}
+	want := map[string]string{
+		":jar":        "1.0-20240103.100000-3",
+		":pom":        "1.0-20240102.100000-2",
+		"sources:jar": "1.0-20240103.100000-3",
+	}
+	if len(md.Versioning.SnapshotVersions) != len(want) {
+		t.Fatalf("expected %d snapshot versions, got %+v", len(want), md.Versioning.SnapshotVersions)
+	}
+	for _, sv := range md.Versioning.SnapshotVersions {
+		if want[sv.Classifier+":"+sv.Extension] != sv.Value {
+			t.Errorf("unexpected snapshot version %+v", sv)
+		}
+	}
+	if md.GroupID != "com.example" || md.Version != "1.0-SNAPSHOT" {
+		t.Errorf("coordinates not preserved: %+v", md)
+	}
+
+	if _, err := svc.ReconcileSnapshot("repository/develop/com/example/app/1.0"); err == nil {
+		t.Error("expected release directory to be rejected")
+	}
+}
+
+func TestMetadataService_LastUpdatedNeverRegresses(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	svc := NewMetadataService(store, &config.Config{MetadataLockTTL: "5s"})
+	svc.Clock = func() time.Time {
+		// A non-UTC clock must still produce UTC timestamps
+		return time.Date(2024, 6, 1, 14, 0, 0, 0, time.FixedZone("UTC+2", 2*3600))
+	}
+	path := "repository/releases/com/example/app/maven-metadata.xml"
+
+	if err := svc.Update(path, strings.NewReader(artifactMetadata("1.0"))); err != nil {
+		t.Fatal(err)
+	}
+	if got := readMetadata(t, store, path).Versioning.LastUpdated; got != "20240601120000" {
+		t.Fatalf("expected lastUpdated stamped from the clock in UTC, got %s", got)
+	}
+
+	// Another writer left a lastUpdated in the future
+	future := strings.Replace(artifactMetadata("1.1"), "20240101000000", "20990101000000", 1)
+	if err := svc.Update(path, strings.NewReader(future)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Update(path, strings.NewReader(artifactMetadata("1.2"))); err != nil {
+		t.Fatal(err)
+	}
+	md := readMetadata(t, store, path)
+	if md.Versioning.LastUpdated != "20990101000000" {
+		t.Errorf("expected lastUpdated not to move backward, got %s", md.Versioning.LastUpdated)
+	}
+	if len(md.Versioning.Versions) != 3 {
+		t.Errorf("expected all versions to be kept, got %v", md.Versioning.Versions)
+	}
+}
+
+func TestMetadataService_ReconcileKeepsFutureLastUpdated(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	svc := NewMetadataService(store, &config.Config{MetadataLockTTL: "5s"})
+	dir := "repository/develop/com/example/app/1.0-SNAPSHOT"
+
+	existing := `<metadata><versioning><lastUpdated>20990101000000</lastUpdated></versioning></metadata>`
+	store.Save(dir+"/maven-metadata.xml", strings.NewReader(existing))
+	store.Save(dir+"/app-1.0-20240103.100000-3.jar", strings.NewReader("3"))
+
+	md, err := svc.ReconcileSnapshot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if md.Versioning.LastUpdated != "20990101000000" {
+		t.Errorf("expected lastUpdated not to move backward, got %s", md.Versioning.LastUpdated)
+	}
+}
+
+func TestMetadataService_RecordUpload(t *testing.T) {
+	store := storage.NewLocalStorage(t.TempDir())
+	svc := NewMetadataService(store, &config.Config{MetadataLockTTL: "5s"})
+	svc.Clock = func() time.Time { return time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC) }
+	artifactDir := "repository/develop/com/example/app"
+
+	for _, p := range []string{
+		"1.0/app-1.0.pom",
+		"1.0/app-1.0.jar",
+		"1.1/app-1.1.jar",
+		"2.0-SNAPSHOT/app-2.0-20240301.110000-1.jar",
+		"2.0-SNAPSHOT/app-2.0-20240301.110000-1-sources.jar",
+	} {
+		if err := store.Save(artifactDir+"/"+p, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+		if err := svc.RecordUpload(artifactDir + "/" + p); err != nil {
+			t.Fatalf("RecordUpload(%s): %v", p, err)
+		}
+	}
+
+	md := readMetadata(t, store, artifactDir+"/maven-metadata.xml")
+	if md.GroupID != "com.example" || md.ArtifactID != "app" {
+		t.Errorf("unexpected coordinates %+v", md)
+	}
+	v := md.Versioning
+	if strings.Join(v.Versions, ",") != "1.0,1.1,2.0-SNAPSHOT" {
+		t.Errorf("unexpected versions %v", v.Versions)
+	}
+	if v.Latest != "2.0-SNAPSHOT" || v.Release != "1.1" || v.LastUpdated != "20240301120000" {
+		t.Errorf("unexpected versioning %+v", v)
+	}
+
+	snap := readMetadata(t, store, artifactDir+"/2.0-SNAPSHOT/maven-metadata.xml")
+	if snap.Version != "2.0-SNAPSHOT" || snap.Versioning.Snapshot == nil || snap.Versioning.Snapshot.Timestamp != "20240301.110000" || snap.Versioning.Snapshot.BuildNumber != 1 {
+		t.Errorf("unexpected snapshot metadata %+v", snap.Versioning)
+	}
+	if len(snap.Versioning.SnapshotVersions) != 2 {
+		t.Errorf("expected jar and sources snapshot versions, got %+v", snap.Versioning.SnapshotVersions)
+	}
+
+	// Files outside the Maven layout are ignored
+	if err := svc.RecordUpload("repository/develop/readme.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := store.Head("repository/maven-metadata.xml"); found {
+		t.Error("metadata written for a non-artifact path")
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// snapshotPolicy is the retention applied to one snapshot directory.
+type snapshotPolicy struct {
+	KeepDays       int
+	KeepCount      int
+	KeepLatestOnly bool
+}
+
+// parseSnapshotPolicy parses a MAVEN_SNAPSHOT_POLICY_<repo> value: a
+// comma-separated list of "keepLatest", "days:N" and "count:N".
+func parseSnapshotPolicy(spec string) (snapshotPolicy, error) {
+	var p snapshotPolicy
+	for _, term := range strings.Split(spec, ",") {
+		term = strings.TrimSpace(term)
+		if strings.EqualFold(term, "keepLatest") {
+			p.KeepLatestOnly = true
+			continue
+		}
+		name, value, ok := strings.Cut(term, ":")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 0 {
+			return snapshotPolicy{}, fmt.Errorf("invalid snapshot policy term %q", term)
+		}
+		switch strings.ToLower(name) {
+		case "days":
+			p.KeepDays = n
+		case "count":
+			p.KeepCount = n
+		default:
+			return snapshotPolicy{}, fmt.Errorf("invalid snapshot policy term %q", term)
+		}
+	}
+	return p, nil
+}
+
+// policyFor returns the retention for dir: the override of the repository
+// it lives in (repository/<repo>/...), or the global settings. An override
+// replaces the global policy as a whole.
+func (s *SnapshotCleanupService) policyFor(dir string) snapshotPolicy {
+	global := snapshotPolicy{
+		KeepDays:       s.Config.SnapshotKeepDays,
+		KeepCount:      s.Config.SnapshotKeepCount,
+		KeepLatestOnly: s.Config.SnapshotKeepLatestOnly,
+	}
+	rest, ok := strings.CutPrefix(path.Clean(dir), "repository/")
+	if !ok {
+		return global
+	}
+	repo, _, _ := strings.Cut(rest, "/")
+	spec, ok := s.Config.SnapshotPolicies[repo]
+	if !ok {
+		return global
+	}
+	p, err := parseSnapshotPolicy(spec)
+	if err != nil {
+		log.Printf("Ignoring snapshot policy for repository %s: %v\n", repo, err)
+		return global
+	}
+	return p
+}
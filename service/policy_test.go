@@ -0,0 +1,71 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func TestParseSnapshotPolicy(t *testing.T) {
+	for spec, want := range map[string]snapshotPolicy{
+		"keepLatest":         {KeepLatestOnly: true},
+		"days:7":             {KeepDays: 7},
+		"days:14, count:3":   {KeepDays: 14, KeepCount: 3},
+		"count:2,keeplatest": {KeepCount: 2, KeepLatestOnly: true},
+	} {
+		got, err := parseSnapshotPolicy(spec)
+		if err != nil || got != want {
+			t.Errorf("parseSnapshotPolicy(%q) = %+v, %v; want %+v", spec, got, err, want)
+		}
+	}
+	for _, spec := range []string{"", "days", "days:x", "count:-1", "weeks:2", "latest"} {
+		if _, err := parseSnapshotPolicy(spec); err == nil {
+			t.Errorf("parseSnapshotPolicy(%q) should fail", spec)
+		}
+	}
+}
+
+func TestSnapshotCleanupService_PerRepositoryPolicies(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewSnapshotCleanupService(store, &config.Config{
+		SnapshotKeepDays: 30,
+		SnapshotPolicies: map[string]string{
+			"develop": "days:7",
+			"ci":      "keepLatest",
+			"broken":  "weekly",
+		},
+	})
+
+	now := time.Now()
+	// Builds 1, 10 and 20 days old in every repository
+	ages := map[string]time.Duration{"3": 24 * time.Hour, "2": 10 * 24 * time.Hour, "1": 20 * 24 * time.Hour}
+	for _, repo := range []string{"develop", "ci", "other", "broken"} {
+		for build, age := range ages {
+			p := "repository/" + repo + "/com/example/app/1.0-SNAPSHOT/app-1.0-20240101.12000" + build + "-" + build + ".jar"
+			store.Save(p, strings.NewReader("x"))
+			os.Chtimes(filepath.Join(base, p), now.Add(-age), now.Add(-age))
+		}
+	}
+
+	if _, err := svc.RunCleanup(); err != nil {
+		t.Fatal(err)
+	}
+
+	for repo, want := range map[string]int{
+		"develop": 1, // only the build inside 7 days
+		"ci":      1, // only the latest, whatever its age
+		"other":   3, // global 30 days keeps all
+		"broken":  3, // an invalid override falls back to the global policy
+	} {
+		entries, _ := store.List("repository/" + repo + "/com/example/app/1.0-SNAPSHOT")
+		if len(entries) != want {
+			t.Errorf("%s: %d builds left, want %d", repo, len(entries), want)
+		}
+	}
+}
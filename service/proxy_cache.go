@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+// ProxyCacheCleanupService prunes cached upstream artifacts below the proxy
+// cache prefix that have not been read for longer than the configured idle
+// window. It is independent of snapshot cleanup and skips -SNAPSHOT
+// directories entirely.
+type ProxyCacheCleanupService struct {
+	Store  storage.StorageProvider
+	Config *config.Config
+	Clock  func() time.Time
+	Ctx    context.Context
+	Cancel context.CancelFunc
+	// Changed, when set, is called with each directory artifacts were pruned
+	// from, so caches describing it can be dropped
+	Changed func(dir string)
+}
+
+func NewProxyCacheCleanupService(store storage.StorageProvider, cfg *config.Config) *ProxyCacheCleanupService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ProxyCacheCleanupService{
+		Store:  store,
+		Config: cfg,
+		Clock:  time.Now,
+		Ctx:    ctx,
+		Cancel: cancel,
+	}
+}
+
+// maxIdle returns the configured idle window, or false when the cleanup is
+// disabled.
+func (s *ProxyCacheCleanupService) maxIdle() (time.Duration, bool) {
+	if s.Config.ProxyCacheMaxIdle == "" || strings.Trim(s.Config.ProxyCachePrefix, "/") == "" {
+		return 0, false
+	}
+	idle, err := time.ParseDuration(s.Config.ProxyCacheMaxIdle)
+	if err != nil || idle <= 0 {
+		log.Printf("Invalid MAVEN_PROXY_CACHE_MAX_IDLE %q, proxy cache cleanup disabled\n", s.Config.ProxyCacheMaxIdle)
+		return 0, false
+	}
+	return idle, true
+}
+
+func (s *ProxyCacheCleanupService) Start() {
+	if _, ok := s.maxIdle(); !ok {
+		log.Println("Proxy cache cleanup task is disabled")
+		return
+	}
+
+	interval, err := time.ParseDuration(s.Config.ProxyCacheCleanupInterval)
+	if err != nil || interval <= 0 {
+		log.Printf("Invalid proxy cache cleanup interval %q, using default 1h\n", s.Config.ProxyCacheCleanupInterval)
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if removed, err := s.RunCleanup(); err != nil {
+					log.Printf("Proxy cache cleanup failed: %v\n", err)
+				} else {
+					log.Printf("Proxy cache cleanup removed %d idle artifacts\n", removed)
+				}
+			case <-s.Ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+func (s *ProxyCacheCleanupService) Stop() {
+	s.Cancel()
+}
+
+// RunCleanup removes every cached artifact whose last access is older than
+// the idle window and returns how many were removed.
+func (s *ProxyCacheCleanupService) RunCleanup() (int, error) {
+	idle, ok := s.maxIdle()
+	if !ok {
+		return 0, nil
+	}
+	prefix := strings.Trim(s.Config.ProxyCachePrefix, "/")
+
+	var dirs []string
+	err := s.Store.Walk(prefix, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // Continue walk
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if strings.HasSuffix(path, "-SNAPSHOT") {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := s.Clock().Add(-idle)
+	removed := 0
+	for _, dir := range dirs {
+		n, err := s.pruneDir(dir, cutoff)
+		if err != nil {
+			log.Printf("Failed to prune proxy cache directory %s: %v\n", dir, err)
+		}
+		if n > 0 && s.Changed != nil {
+			s.Changed(dir)
+		}
+		removed += n
+	}
+	return removed, nil
+}
+
+// pruneDir removes the idle artifacts of one directory. An artifact's
+// companions (checksums, signatures and bookkeeping files named after it) go
+// with it and count as reads of it; pins are kept so a re-fetched copy is
+// still verified.
+func (s *ProxyCacheCleanupService) pruneDir(dir string, cutoff time.Time) (int, error) {
+	entries, err := s.Store.List(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var artifacts []storage.Entry
+	for _, e := range entries {
+		if e.IsDir || storage.IsInternal(e.Name) || strings.HasPrefix(e.Name, "maven-metadata") {
+			continue
+		}
+		artifacts = append(artifacts, e)
+	}
+
+	removed := 0
+	for _, a := range artifacts {
+		if isCompanion(a.Name, artifacts) {
+			continue
+		}
+
+		var companions []storage.Entry
+		lastAccess := a.ModTime
+		for _, e := range entries {
+			if e.IsDir || !strings.HasPrefix(e.Name, a.Name+".") {
+				continue
+			}
+			if e.ModTime.After(lastAccess) && (!storage.IsInternal(e.Name) || strings.HasSuffix(e.Name, storage.AccessSuffix)) {
+				lastAccess = e.ModTime
+			}
+			companions = append(companions, e)
+		}
+		if !lastAccess.Before(cutoff) {
+			continue
+		}
+
+		log.Printf("Pruning idle cached artifact %s/%s (last access %s)\n", dir, a.Name, lastAccess.Format(time.RFC3339))
+		if err := s.Store.Delete(dir + "/" + a.Name); err != nil {
+			log.Printf("Failed to delete %s/%s: %v\n", dir, a.Name, err)
+			continue
+		}
+		for _, e := range companions {
+			if strings.HasSuffix(e.Name, ".pin") {
+				continue
+			}
+			if err := s.Store.Delete(dir + "/" + e.Name); err != nil {
+				log.Printf("Failed to delete %s/%s: %v\n", dir, e.Name, err)
+			}
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// isCompanion reports whether name belongs to another artifact in the same
+// directory, such as app.jar.sha1 next to app.jar.
+func isCompanion(name string, artifacts []storage.Entry) bool {
+	for _, a := range artifacts {
+		if a.Name != name && strings.HasPrefix(name, a.Name+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"maven_repo/config"
+	"maven_repo/storage"
+)
+
+func TestProxyCacheCleanupService_PrunesIdleReleases(t *testing.T) {
+	base := t.TempDir()
+	store := storage.NewLocalStorage(base)
+	svc := NewProxyCacheCleanupService(store, &config.Config{
+		ProxyCacheMaxIdle: "720h",
+		ProxyCachePrefix:  "repository/maven-public",
+	})
+
+	now := time.Now()
+	old := now.Add(-60 * 24 * time.Hour)
+	seed := func(path string, modTime time.Time) {
+		t.Helper()
+		if err := store.Save(path, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(base, path), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := "repository/maven-public/com/example/lib/1.0"
+	// Cached long ago and never read since
+	seed(dir+"/lib-1.0.jar", old)
+	seed(dir+"/lib-1.0.jar.sha1", old)
+	seed(dir+"/lib-1.0.jar.pin", old)
+	// Cached long ago but read recently
+	seed(dir+"/lib-1.0.pom", old)
+	seed(dir+"/lib-1.0.pom"+storage.AccessSuffix, now.Add(-time.Hour))
+	// Snapshots and anything outside the prefix are left alone
+	seed("repository/maven-public/com/example/lib/2.0-SNAPSHOT/lib-2.0-SNAPSHOT.jar", old)
+	seed("repository/releases/com/example/lib/1.0/lib-1.0.jar", old)
+
+	var changed []string
+	svc.Changed = func(dir string) { changed = append(changed, dir) }
+	removed, err := svc.RunCleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("expected 1 artifact removed, got %d", removed)
+	}
+	if len(changed) != 1 || changed[0] != dir {
+		t.Errorf("expected %s to be reported changed, got %v", dir, changed)
+	}
+
+	for path, want := range map[string]bool{
+		dir + "/lib-1.0.jar":      false,
+		dir + "/lib-1.0.jar.sha1": false,
+		dir + "/lib-1.0.jar.pin":  true,
+		dir + "/lib-1.0.pom":      true,
+		"repository/maven-public/com/example/lib/2.0-SNAPSHOT/lib-2.0-SNAPSHOT.jar": true,
+		"repository/releases/com/example/lib/1.0/lib-1.0.jar":                       true,
+	} {
+		if found, _ := store.Head(path); found != want {
+			t.Errorf("%s: expected present=%v, got %v", path, want, found)
+		}
+	}
+}
+
+func TestProxyCacheCleanupService_DisabledByDefault(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	store.Now = func() time.Time { return time.Now().Add(-365 * 24 * time.Hour) }
+	path := "repository/maven-public/com/example/lib/1.0/lib-1.0.jar"
+	store.Save(path, strings.NewReader("x"))
+
+	svc := NewProxyCacheCleanupService(store, &config.Config{ProxyCachePrefix: "repository/maven-public"})
+	if removed, err := svc.RunCleanup(); err != nil || removed != 0 {
+		t.Fatalf("expected no-op, got %d, %v", removed, err)
+	}
+	if found, _ := store.Head(path); !found {
+		t.Error("artifact removed while cleanup is disabled")
+	}
+}
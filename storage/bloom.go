@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"hash/fnv"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BloomFilter is a fixed-size, concurrency-safe bloom filter over strings.
+type BloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64 // number of bits
+	k    uint64 // number of hash functions
+}
+
+// NewBloomFilter sizes a filter for the expected number of items at the given
+// false-positive rate.
+func NewBloomFilter(expectedItems int, fpRate float64) *BloomFilter {
+	if expectedItems < 1 {
+		expectedItems = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	n := float64(expectedItems)
+	m := uint64(math.Ceil(-n * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Max(1, math.Round(float64(m)/n*math.Ln2)))
+	return &BloomFilter{
+		bits: make([]uint64, (m+63)/64),
+		m:    m,
+		k:    k,
+	}
+}
+
+// hashes derives two base hashes used for double hashing (Kirsch-Mitzenmacher).
+func (b *BloomFilter) hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	h1 := h.Sum64()
+	h.Write([]byte{0})
+	h2 := h.Sum64() | 1
+	return h1, h2
+}
+
+func (b *BloomFilter) Add(key string) {
+	h1, h2 := b.hashes(key)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain returns false only if key was definitely never added.
+func (b *BloomFilter) MayContain(key string) bool {
+	h1, h2 := b.hashes(key)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// BloomStorage wraps a StorageProvider with a bloom filter of known paths so
+// lookups of paths that were never stored skip the backend entirely. Deletes
+// are not removed from the filter; they simply become false positives.
+type BloomStorage struct {
+	StorageProvider
+	Filter *BloomFilter
+}
+
+func NewBloomStorage(inner StorageProvider, expectedItems int) *BloomStorage {
+	return &BloomStorage{
+		StorageProvider: inner,
+		Filter:          NewBloomFilter(expectedItems, 0.01),
+	}
+}
+
+// LinkWalker is implemented by backends whose Walk can skip files that Get
+// still reaches, such as LocalStorage behind symlinked directories.
+type LinkWalker interface {
+	// WalkLinks walks like Walk but visits everything Get can reach.
+	WalkLinks(path string, walkFn func(path string, info os.FileInfo, err error) error) error
+}
+
+// walkLinks walks path through s with WalkLinks where available.
+func walkLinks(s StorageProvider, path string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	if walker, ok := s.(LinkWalker); ok {
+		return walker.WalkLinks(path, walkFn)
+	}
+	return s.Walk(path, walkFn)
+}
+
+// Rebuild adds every path currently in the backend to the filter and returns
+// the number of paths seen. Symlinked directories are followed whatever
+// MAVEN_WALK_FOLLOW_SYMLINKS says, since reads resolve them too.
+func (s *BloomStorage) Rebuild() (int, error) {
+	count := 0
+	s.Filter.Add(".")
+	err := walkLinks(s.StorageProvider, ".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		s.Filter.Add(bloomKey(path))
+		count++
+		return nil
+	})
+	return count, err
+}
+
+func bloomKey(path string) string {
+	return strings.Trim(filepath.ToSlash(filepath.Clean(path)), "/")
+}
+
+func (s *BloomStorage) add(path string) {
+	key := bloomKey(path)
+	for key != "" && key != "." {
+		s.Filter.Add(key)
+		idx := strings.LastIndex(key, "/")
+		if idx == -1 {
+			break
+		}
+		key = key[:idx]
+	}
+}
+
+func (s *BloomStorage) known(path string) bool {
+	key := bloomKey(path)
+	return key == "" || key == "." || s.Filter.MayContain(key)
+}
+
+func (s *BloomStorage) Save(path string, data io.Reader) error {
+	// Register before writing so concurrent readers never miss an in-flight file
+	s.add(path)
+	return s.StorageProvider.Save(path, data)
+}
+
+func (s *BloomStorage) CreateDir(path string) error {
+	s.add(path)
+	return s.StorageProvider.CreateDir(path)
+}
+
+func (s *BloomStorage) Get(path string) (io.ReadCloser, bool, error) {
+	if !s.known(path) {
+		return nil, false, nil
+	}
+	return s.StorageProvider.Get(path)
+}
+
+func (s *BloomStorage) Head(path string) (bool, error) {
+	if !s.known(path) {
+		return false, nil
+	}
+	return s.StorageProvider.Head(path)
+}
+
+func (s *BloomStorage) Stat(path string) (Entry, bool, error) {
+	if !s.known(path) {
+		return Entry{}, false, nil
+	}
+	return s.StorageProvider.Stat(path)
+}
+
+func (s *BloomStorage) List(path string) ([]Entry, error) {
+	if !s.known(path) {
+		return nil, nil
+	}
+	return s.StorageProvider.List(path)
+}
+
+func (s *BloomStorage) Lock(path string, ttl time.Duration) (func(), error) {
+	return lockInner(s.StorageProvider, path, ttl)
+}
+
+func (s *BloomStorage) RemoveEmptyDir(path string) (bool, error) {
+	return removeEmptyDirInner(s.StorageProvider, path)
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// countingStorage records how often the lookup methods reach the backend.
+type countingStorage struct {
+	StorageProvider
+	lookups int
+}
+
+func (s *countingStorage) Get(path string) (io.ReadCloser, bool, error) {
+	s.lookups++
+	return s.StorageProvider.Get(path)
+}
+
+func (s *countingStorage) Head(path string) (bool, error) {
+	s.lookups++
+	return s.StorageProvider.Head(path)
+}
+
+func (s *countingStorage) List(path string) ([]Entry, error) {
+	s.lookups++
+	return s.StorageProvider.List(path)
+}
+
+func TestBloomFilter(t *testing.T) {
+	f := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !f.MayContain(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("false negative for key-%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.MayContain(fmt.Sprintf("other-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("false positive rate too high: %d/10000", falsePositives)
+	}
+}
+
+func TestBloomStorage(t *testing.T) {
+	local := NewLocalStorage(t.TempDir())
+	existing := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	if err := local.Save(existing, strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+
+	inner := &countingStorage{StorageProvider: local}
+	s := NewBloomStorage(inner, 1000)
+	if _, err := s.Rebuild(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Known-absent path never reaches the backend
+	absent := "repository/releases/com/example/missing/1.0/missing-1.0.jar"
+	if found, _ := s.Head(absent); found {
+		t.Fatal("expected absent path to be missing")
+	}
+	if r, found, _ := s.Get(absent); found || r != nil {
+		t.Fatal("expected absent path to be missing")
+	}
+	if entries, _ := s.List(absent); entries != nil {
+		t.Fatal("expected no entries for absent path")
+	}
+	if inner.lookups != 0 {
+		t.Fatalf("expected absent lookups to be short-circuited, got %d backend calls", inner.lookups)
+	}
+
+	// Present path still resolves, as do its parent directories
+	r, found, err := s.Get(existing)
+	if err != nil || !found {
+		t.Fatalf("expected existing artifact, found=%v err=%v", found, err)
+	}
+	r.Close()
+	if entries, _ := s.List("repository/releases/com/example/app"); len(entries) != 1 {
+		t.Errorf("expected parent directory to be listable, got %v", entries)
+	}
+
+	// New saves are visible immediately
+	saved := "repository/releases/com/example/app/2.0/app-2.0.jar"
+	if err := s.Save(saved, strings.NewReader("jar2")); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := s.Head(saved); !found {
+		t.Error("expected newly saved artifact to be found")
+	}
+}
+
+func TestBloomStorage_RebuildFollowsSymlinks(t *testing.T) {
+	base, shared := t.TempDir(), t.TempDir()
+	if err := NewLocalStorage(shared).Save("com/example/app/1.0/app-1.0.jar", strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(base, "repository"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(shared, filepath.Join(base, "repository", "releases")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	// Maintenance walks skip the link, but reads resolve it, so the filter
+	// must know what is behind it
+	for _, inner := range []StorageProvider{
+		NewLocalStorage(base),
+		NewMountStorage(NewLocalStorage(base), map[string]StorageProvider{"repository/snapshots": NewLocalStorage(t.TempDir())}),
+	} {
+		s := NewBloomStorage(inner, 1000)
+		if _, err := s.Rebuild(); err != nil {
+			t.Fatal(err)
+		}
+		if found, err := s.Head("repository/releases/com/example/app/1.0/app-1.0.jar"); err != nil || !found {
+			t.Errorf("%T: artifact behind a symlink: found=%v err=%v", inner, found, err)
+		}
+	}
+}
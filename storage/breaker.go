@@ -0,0 +1,221 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without touching the backend while the breaker
+// is open.
+var ErrCircuitOpen = errors.New("storage unavailable: circuit breaker open")
+
+// BreakerStorage wraps a StorageProvider with a circuit breaker. After
+// Threshold consecutive failures it opens and fails fast for Cooldown, then
+// lets a single trial operation through; success closes it again.
+type BreakerStorage struct {
+	StorageProvider
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	failures int
+	open     bool
+	openedAt time.Time
+	trial    bool
+}
+
+func NewBreakerStorage(inner StorageProvider, threshold int, cooldown time.Duration) *BreakerStorage {
+	return &BreakerStorage{
+		StorageProvider: inner,
+		Threshold:       threshold,
+		Cooldown:        cooldown,
+	}
+}
+
+// RetryAfter reports whether calls are currently being refused and how long
+// until the breaker will try the backend again.
+func (s *BreakerStorage) RetryAfter() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open {
+		return 0, false
+	}
+	if wait := time.Until(s.openedAt.Add(s.Cooldown)); wait > 0 {
+		return wait, true
+	}
+	if s.trial {
+		return time.Second, true
+	}
+	return 0, false
+}
+
+func (s *BreakerStorage) allow() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.open {
+		return nil
+	}
+	if s.trial || time.Since(s.openedAt) < s.Cooldown {
+		return ErrCircuitOpen
+	}
+	s.trial = true
+	return nil
+}
+
+// neutral reports whether err says nothing about the backend's health: the
+// path was invalid or simply not there. Counting those would let a client
+// open the breaker for everyone by requesting bad paths.
+func neutral(err error) bool {
+	return errors.Is(err, ErrInvalidPath) || errors.Is(err, fs.ErrNotExist)
+}
+
+func (s *BreakerStorage) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if neutral(err) {
+		// Neither a failure nor proof of recovery; a pending trial is
+		// handed to the next call
+		s.trial = false
+		return
+	}
+	if err == nil {
+		if s.open {
+			log.Printf("Storage circuit breaker closed\n")
+		}
+		s.failures = 0
+		s.open = false
+		s.trial = false
+		return
+	}
+
+	s.failures++
+	if s.trial {
+		// Trial failed, stay open for another cooldown
+		s.trial = false
+		s.openedAt = time.Now()
+		return
+	}
+	if !s.open && s.failures >= s.Threshold {
+		s.open = true
+		s.openedAt = time.Now()
+		log.Printf("Storage circuit breaker opened after %d consecutive failures: %v\n", s.failures, err)
+	}
+}
+
+// trackedReader remembers whether the caller-supplied reader failed, so a
+// client dropping an upload is not blamed on the backend.
+type trackedReader struct {
+	io.Reader
+	err error
+}
+
+func (r *trackedReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (s *BreakerStorage) Save(path string, data io.Reader) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	tracked := &trackedReader{Reader: data}
+	err := s.StorageProvider.Save(path, tracked)
+	if err != nil && tracked.err != nil {
+		// The source failed, not the backend; leave the breaker state alone
+		s.record(nil)
+		return err
+	}
+	s.record(err)
+	return err
+}
+
+func (s *BreakerStorage) Get(path string) (io.ReadCloser, bool, error) {
+	if err := s.allow(); err != nil {
+		return nil, false, err
+	}
+	reader, found, err := s.StorageProvider.Get(path)
+	s.record(err)
+	return reader, found, err
+}
+
+func (s *BreakerStorage) Head(path string) (bool, error) {
+	if err := s.allow(); err != nil {
+		return false, err
+	}
+	found, err := s.StorageProvider.Head(path)
+	s.record(err)
+	return found, err
+}
+
+func (s *BreakerStorage) Stat(path string) (Entry, bool, error) {
+	if err := s.allow(); err != nil {
+		return Entry{}, false, err
+	}
+	entry, found, err := s.StorageProvider.Stat(path)
+	s.record(err)
+	return entry, found, err
+}
+
+func (s *BreakerStorage) List(path string) ([]Entry, error) {
+	if err := s.allow(); err != nil {
+		return nil, err
+	}
+	entries, err := s.StorageProvider.List(path)
+	s.record(err)
+	return entries, err
+}
+
+func (s *BreakerStorage) Delete(path string) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := s.StorageProvider.Delete(path)
+	s.record(err)
+	return err
+}
+
+func (s *BreakerStorage) CreateDir(path string) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	err := s.StorageProvider.CreateDir(path)
+	s.record(err)
+	return err
+}
+
+func (s *BreakerStorage) Walk(path string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	if err := s.allow(); err != nil {
+		return err
+	}
+	var callbackErr error
+	err := s.StorageProvider.Walk(path, func(p string, info os.FileInfo, err error) error {
+		callbackErr = walkFn(p, info, err)
+		return callbackErr
+	})
+	if err != nil && err == callbackErr {
+		// The callback stopped the walk, not the backend
+		s.record(nil)
+		return err
+	}
+	s.record(err)
+	return err
+}
+
+// Lock passes through to the wrapped backend so metadata locking keeps
+// working behind the breaker.
+func (s *BreakerStorage) Lock(path string, ttl time.Duration) (func(), error) {
+	return lockInner(s.StorageProvider, path, ttl)
+}
+
+// RemoveEmptyDir passes through like Lock.
+func (s *BreakerStorage) RemoveEmptyDir(path string) (bool, error) {
+	return removeEmptyDirInner(s.StorageProvider, path)
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingStorage fails every call while Down is set.
+type failingStorage struct {
+	StorageProvider
+	Down  bool
+	Calls int
+}
+
+var errBackendDown = errors.New("backend down")
+
+func (s *failingStorage) Head(path string) (bool, error) {
+	s.Calls++
+	if strings.Contains(path, "..") {
+		return false, fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	if path == "missing" {
+		return false, fs.ErrNotExist
+	}
+	if s.Down {
+		return false, errBackendDown
+	}
+	return true, nil
+}
+
+func (s *failingStorage) Save(path string, data io.Reader) error {
+	s.Calls++
+	_, err := io.Copy(io.Discard, data)
+	return err
+}
+
+func TestBreakerStorage_OpensAndRecovers(t *testing.T) {
+	inner := &failingStorage{Down: true}
+	b := NewBreakerStorage(inner, 3, 50*time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		if _, err := b.Head("a"); !errors.Is(err, errBackendDown) {
+			t.Fatalf("call %d: expected backend error, got %v", i, err)
+		}
+	}
+	if _, open := b.RetryAfter(); !open {
+		t.Fatal("expected breaker to open after 3 consecutive failures")
+	}
+
+	// Open breaker short-circuits without touching the backend
+	if _, err := b.Head("a"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen, got %v", err)
+	}
+	if inner.Calls != 3 {
+		t.Fatalf("expected backend to see 3 calls, got %d", inner.Calls)
+	}
+
+	// After the cooldown a failed trial keeps it open
+	time.Sleep(60 * time.Millisecond)
+	if _, err := b.Head("a"); !errors.Is(err, errBackendDown) {
+		t.Fatalf("expected trial call to reach the backend, got %v", err)
+	}
+	if _, open := b.RetryAfter(); !open {
+		t.Fatal("expected breaker to stay open after a failed trial")
+	}
+
+	// A successful trial closes it
+	inner.Down = false
+	time.Sleep(60 * time.Millisecond)
+	if found, err := b.Head("a"); err != nil || !found {
+		t.Fatalf("expected trial to succeed, got %v, %v", found, err)
+	}
+	if _, open := b.RetryAfter(); open {
+		t.Fatal("expected breaker to close after a successful trial")
+	}
+}
+
+type brokenReader struct{}
+
+func (brokenReader) Read(p []byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestBreakerStorage_IgnoresSourceErrors(t *testing.T) {
+	b := NewBreakerStorage(&failingStorage{}, 1, time.Minute)
+
+	// A client dropping its upload says nothing about the backend
+	if err := b.Save("a", brokenReader{}); err == nil {
+		t.Fatal("expected the read error to be returned")
+	}
+	if _, open := b.RetryAfter(); open {
+		t.Fatal("source errors must not open the breaker")
+	}
+	if err := b.Save("a", strings.NewReader("ok")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBreakerStorage_IgnoresInvalidAndMissingPaths(t *testing.T) {
+	inner := &failingStorage{}
+	b := NewBreakerStorage(inner, 2, 50*time.Millisecond)
+
+	// Traversal attempts and missing files must not open the breaker
+	for i := 0; i < 5; i++ {
+		if _, err := b.Head("../../etc/passwd"); !errors.Is(err, ErrInvalidPath) {
+			t.Fatalf("expected ErrInvalidPath, got %v", err)
+		}
+		if _, err := b.Head("missing"); !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected ErrNotExist, got %v", err)
+		}
+	}
+	if _, open := b.RetryAfter(); open {
+		t.Fatal("invalid and missing paths must not open the breaker")
+	}
+
+	// Nor do they close an open one, or use up its trial
+	inner.Down = true
+	b.Head("a")
+	b.Head("a")
+	inner.Down = false
+	time.Sleep(60 * time.Millisecond)
+	b.Head("../x")
+	if !b.open || b.trial {
+		t.Fatalf("an invalid path must neither close the breaker nor hold its trial: open=%v trial=%v", b.open, b.trial)
+	}
+	if found, err := b.Head("a"); err != nil || !found {
+		t.Fatalf("expected the next call to be the trial, got %v, %v", found, err)
+	}
+	if _, open := b.RetryAfter(); open {
+		t.Fatal("expected the trial to close the breaker")
+	}
+}
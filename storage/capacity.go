@@ -0,0 +1,48 @@
+package storage
+
+import "errors"
+
+// CapacityReporter is implemented by backends that know how much space is
+// left for new files.
+type CapacityReporter interface {
+	// Capacity returns the free and total bytes available for files at path.
+	Capacity(path string) (free, total uint64, err error)
+}
+
+// Capacity asks s for the space available at path. Backends that cannot tell,
+// such as object stores, return errors.ErrUnsupported.
+func Capacity(s StorageProvider, path string) (free, total uint64, err error) {
+	if reporter, ok := s.(CapacityReporter); ok {
+		return reporter.Capacity(path)
+	}
+	return 0, 0, errors.ErrUnsupported
+}
+
+// Capacity reports the filesystem holding BasePath.
+func (s *LocalStorage) Capacity(path string) (uint64, uint64, error) {
+	if _, err := s.fullPath(path); err != nil {
+		return 0, 0, err
+	}
+	return DiskUsage(s.BasePath)
+}
+
+func (s *MountStorage) Capacity(p string) (uint64, uint64, error) {
+	store, sub, _ := s.route(p)
+	return Capacity(store, sub)
+}
+
+func (s *BloomStorage) Capacity(p string) (uint64, uint64, error) {
+	return Capacity(s.StorageProvider, p)
+}
+
+func (s *BreakerStorage) Capacity(p string) (uint64, uint64, error) {
+	return Capacity(s.StorageProvider, p)
+}
+
+func (s *GraceStorage) Capacity(p string) (uint64, uint64, error) {
+	return Capacity(s.StorageProvider, p)
+}
+
+func (s *ListCacheStorage) Capacity(p string) (uint64, uint64, error) {
+	return Capacity(s.StorageProvider, p)
+}
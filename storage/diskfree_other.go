@@ -0,0 +1,10 @@
+//go:build !unix
+
+package storage
+
+import "errors"
+
+// DiskUsage is not supported on this platform.
+func DiskUsage(path string) (free, total uint64, err error) {
+	return 0, 0, errors.ErrUnsupported
+}
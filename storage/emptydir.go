@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// EmptyDirRemover is implemented by backends whose directories outlive their
+// contents. Object stores need no such cleanup: a prefix disappears with its
+// last object.
+type EmptyDirRemover interface {
+	// RemoveEmptyDir removes path only if it is an empty directory, and
+	// reports whether it did. Unlike Delete it never removes contents.
+	RemoveEmptyDir(path string) (bool, error)
+}
+
+func (s *LocalStorage) RemoveEmptyDir(path string) (bool, error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return false, err
+	}
+	if fullPath == filepath.Clean(s.BasePath) {
+		return false, fmt.Errorf("%w: refusing to delete the storage root", ErrInvalidPath)
+	}
+	entries, err := os.ReadDir(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if len(entries) > 0 {
+		return false, nil
+	}
+	if err := os.Remove(fullPath); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// removeEmptyDirInner is used by decorators to pass RemoveEmptyDir through to
+// the backend they wrap. Backends without it have nothing to remove.
+func removeEmptyDirInner(inner StorageProvider, path string) (bool, error) {
+	if remover, ok := inner.(EmptyDirRemover); ok {
+		return remover.RemoveEmptyDir(path)
+	}
+	return false, nil
+}
@@ -0,0 +1,451 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// gcsUploadChunkSize is how much of an upload is buffered and sent per request
+// of a resumable upload. GCS requires a multiple of 256 KiB.
+const gcsUploadChunkSize = 8 << 20
+
+const gcsDefaultEndpoint = "https://storage.googleapis.com"
+
+// GCSStorage keeps artifacts as objects in a Google Cloud Storage bucket,
+// keyed by their storage path, through the GCS JSON API. Directories are key
+// prefixes; empty ones exist through their .keep marker.
+type GCSStorage struct {
+	Client *http.Client
+	Bucket string
+	// Endpoint is the API root, https://storage.googleapis.com unless pointed
+	// at an emulator.
+	Endpoint string
+}
+
+func NewGCSStorage(client *http.Client, bucket, endpoint string) *GCSStorage {
+	if endpoint == "" {
+		endpoint = gcsDefaultEndpoint
+	}
+	return &GCSStorage{Client: client, Bucket: bucket, Endpoint: strings.TrimRight(endpoint, "/")}
+}
+
+// NewGCSClient returns an HTTP client authorised for read-write bucket access
+// with the service account key in credentialsFile, or with Application
+// Default Credentials when it is empty.
+func NewGCSClient(credentialsFile string) (*http.Client, error) {
+	ctx := context.Background()
+	const scope = "https://www.googleapis.com/auth/devstorage.read_write"
+	if credentialsFile == "" {
+		client, err := google.DefaultClient(ctx, scope)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find default GCS credentials: %w", err)
+		}
+		return client, nil
+	}
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS credentials: %w", err)
+	}
+	creds, err := google.CredentialsFromJSON(ctx, data, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load GCS credentials: %w", err)
+	}
+	return oauth2.NewClient(ctx, creds.TokenSource), nil
+}
+
+// gcsObject is the object resource returned by the JSON API.
+type gcsObject struct {
+	Name    string    `json:"name"`
+	Size    string    `json:"size"`
+	Updated time.Time `json:"updated"`
+}
+
+func (o gcsObject) size() int64 {
+	n, _ := strconv.ParseInt(o.Size, 10, 64)
+	return n
+}
+
+type gcsList struct {
+	Items         []gcsObject `json:"items"`
+	Prefixes      []string    `json:"prefixes"`
+	NextPageToken string      `json:"nextPageToken"`
+}
+
+// gcsError is a non-success API response.
+type gcsError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *gcsError) Error() string {
+	return fmt.Sprintf("gcs: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func gcsStatus(err error) int {
+	var ge *gcsError
+	if errors.As(err, &ge) {
+		return ge.StatusCode
+	}
+	return 0
+}
+
+func (s *GCSStorage) objectURL(key string) string {
+	return fmt.Sprintf("%s/storage/v1/b/%s/o/%s", s.Endpoint, url.PathEscape(s.Bucket), url.PathEscape(key))
+}
+
+// do sends req and turns any status but the expected ones into a gcsError.
+func (s *GCSStorage) do(req *http.Request, expected ...int) (*http.Response, error) {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, &gcsError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+}
+
+func (s *GCSStorage) getJSON(rawURL string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// attrs fetches the metadata of the object key.
+func (s *GCSStorage) attrs(key string) (gcsObject, bool, error) {
+	var obj gcsObject
+	err := s.getJSON(s.objectURL(key), &obj)
+	if gcsStatus(err) == http.StatusNotFound {
+		return gcsObject{}, false, nil
+	}
+	return obj, err == nil, err
+}
+
+// list calls fn with each page of objects (and, with a delimiter, prefixes)
+// below prefix.
+func (s *GCSStorage) list(prefix, delimiter string, maxResults int, fn func(*gcsList) error) error {
+	query := url.Values{"prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if maxResults > 0 {
+		query.Set("maxResults", strconv.Itoa(maxResults))
+	}
+	for {
+		var page gcsList
+		if err := s.getJSON(fmt.Sprintf("%s/storage/v1/b/%s/o?%s", s.Endpoint, url.PathEscape(s.Bucket), query.Encode()), &page); err != nil {
+			return err
+		}
+		if err := fn(&page); err != nil {
+			return err
+		}
+		if page.NextPageToken == "" || maxResults > 0 {
+			return nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// Save streams data to the object with a resumable upload, sending it in
+// chunks so only one chunk is held in memory.
+func (s *GCSStorage) Save(p string, data io.Reader) error {
+	query := url.Values{"uploadType": {"resumable"}, "name": {s3Key(p)}}
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.Endpoint, url.PathEscape(s.Bucket), query.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, http.StatusOK)
+	if err != nil {
+		return fmt.Errorf("failed to start upload: %w", err)
+	}
+	resp.Body.Close()
+	session := resp.Header.Get("Location")
+	if session == "" {
+		return fmt.Errorf("failed to start upload: no session URI")
+	}
+
+	buf := make([]byte, gcsUploadChunkSize)
+	var offset int64
+	for {
+		n, readErr := io.ReadFull(data, buf)
+		last := readErr == io.EOF || readErr == io.ErrUnexpectedEOF
+		if readErr != nil && !last {
+			s.cancelUpload(session)
+			return readErr
+		}
+
+		var contentRange string
+		switch {
+		case n == 0:
+			// The previous chunk turned out to be the last one
+			contentRange = fmt.Sprintf("bytes */%d", offset)
+		case last:
+			contentRange = fmt.Sprintf("bytes %d-%d/%d", offset, offset+int64(n)-1, offset+int64(n))
+		default:
+			contentRange = fmt.Sprintf("bytes %d-%d/*", offset, offset+int64(n)-1)
+		}
+		req, err := http.NewRequest(http.MethodPut, session, bytes.NewReader(buf[:n]))
+		if err != nil {
+			s.cancelUpload(session)
+			return err
+		}
+		req.Header.Set("Content-Range", contentRange)
+		expected := http.StatusPermanentRedirect // 308: chunk stored, send the next one
+		if last {
+			expected = http.StatusOK
+		}
+		resp, err := s.do(req, expected, http.StatusCreated)
+		if err != nil {
+			s.cancelUpload(session)
+			return fmt.Errorf("failed to upload object: %w", err)
+		}
+		resp.Body.Close()
+		if last {
+			return nil
+		}
+		offset += int64(n)
+	}
+}
+
+// cancelUpload abandons a resumable upload session, so no partial object is
+// ever created.
+func (s *GCSStorage) cancelUpload(session string) {
+	req, err := http.NewRequest(http.MethodDelete, session, nil)
+	if err != nil {
+		return
+	}
+	if resp, err := s.Client.Do(req); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (s *GCSStorage) Get(p string) (io.ReadCloser, bool, error) {
+	key := s3Key(p)
+	if key == "" {
+		return nil, false, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, s.objectURL(key)+"?alt=media", nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := s.do(req, http.StatusOK)
+	if gcsStatus(err) == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	info := &objectFileInfo{name: path.Base(key), size: resp.ContentLength}
+	if modTime, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.modTime = modTime
+	}
+	return &gcsReader{ReadCloser: resp.Body, info: info}, true, nil
+}
+
+// gcsReader is the body of a fetched object. Like *os.File it reports the
+// object's size and modification time through Stat.
+type gcsReader struct {
+	io.ReadCloser
+	info *objectFileInfo
+}
+
+func (r *gcsReader) Stat() (os.FileInfo, error) {
+	return r.info, nil
+}
+
+// Head reports whether p is an object or a non-empty directory.
+func (s *GCSStorage) Head(p string) (bool, error) {
+	_, found, err := s.Stat(p)
+	return found, err
+}
+
+// Stat returns the object at p, or a directory entry when p is a non-empty
+// prefix.
+func (s *GCSStorage) Stat(p string) (Entry, bool, error) {
+	key := s3Key(p)
+	if key != "" {
+		obj, found, err := s.attrs(key)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		if found {
+			return Entry{Name: path.Base(key), Size: obj.size(), ModTime: obj.Updated}, true, nil
+		}
+	}
+
+	found := false
+	err := s.list(s3Prefix(p), "", 1, func(page *gcsList) error {
+		found = len(page.Items) > 0
+		return nil
+	})
+	if err != nil || !found {
+		return Entry{}, false, err
+	}
+	return Entry{Name: path.Base(key), IsDir: true}, true, nil
+}
+
+// List returns the objects and sub-prefixes directly below p. Like
+// LocalStorage it returns nil for paths that are not directories.
+func (s *GCSStorage) List(p string) ([]Entry, error) {
+	prefix := s3Prefix(p)
+	var result []Entry
+	err := s.list(prefix, "/", 0, func(page *gcsList) error {
+		if result == nil && (len(page.Prefixes) > 0 || len(page.Items) > 0) {
+			result = make([]Entry, 0, len(page.Prefixes)+len(page.Items))
+		}
+		for _, cp := range page.Prefixes {
+			result = append(result, Entry{Name: strings.TrimSuffix(strings.TrimPrefix(cp, prefix), "/"), IsDir: true})
+		}
+		for _, obj := range page.Items {
+			name := strings.TrimPrefix(obj.Name, prefix)
+			if name == "" {
+				continue // Folder placeholder written by some tools
+			}
+			result = append(result, Entry{Name: name, Size: obj.size(), ModTime: obj.Updated})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	}
+	return result, nil
+}
+
+func (s *GCSStorage) deleteObject(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, s.objectURL(key), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, http.StatusNoContent, http.StatusOK)
+	if gcsStatus(err) == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Delete removes the object at p and everything below it, like os.RemoveAll.
+// The JSON API deletes one object per request.
+func (s *GCSStorage) Delete(p string) error {
+	key, err := deleteKey(p)
+	if err != nil {
+		return err
+	}
+	if err := s.deleteObject(key); err != nil {
+		return err
+	}
+	var keys []string
+	err = s.list(key+"/", "", 0, func(page *gcsList) error {
+		for _, obj := range page.Items {
+			keys = append(keys, obj.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := s.deleteObject(key); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func (s *GCSStorage) CreateDir(p string) error {
+	return s.Save(path.Join(s3Key(p), DirMarker), bytes.NewReader(nil))
+}
+
+// Walk visits p and everything below it in key order, mirroring
+// filepath.Walk; see objectWalk.
+func (s *GCSStorage) Walk(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	root := s3Key(p)
+	if root != "" {
+		obj, found, err := s.attrs(root)
+		if err != nil {
+			return skipToNil(walkFn(root, nil, err))
+		}
+		if found {
+			return skipToNil(walkFn(root, &objectFileInfo{name: path.Base(root), size: obj.size(), modTime: obj.Updated}, nil))
+		}
+	}
+
+	walk := newObjectWalk(root, walkFn)
+	var stop error
+	err := s.list(s3Prefix(p), "", 0, func(page *gcsList) error {
+		for _, obj := range page.Items {
+			if stop = walk.visit(obj.Name, obj.size(), obj.Updated); stop != nil {
+				return stop
+			}
+		}
+		return nil
+	})
+	if stop != nil {
+		return skipToNil(stop)
+	}
+	if err != nil {
+		return skipToNil(walkFn(walkName(root), nil, err))
+	}
+	return walk.finish()
+}
+
+// Lock creates "<path>.lock" only if it does not exist yet
+// (ifGenerationMatch=0), so only one instance can hold it. Locks older than
+// ttl are considered abandoned and broken.
+func (s *GCSStorage) Lock(p string, ttl time.Duration) (func(), error) {
+	key := s3Key(p) + ".lock"
+	query := url.Values{"uploadType": {"media"}, "name": {key}, "ifGenerationMatch": {"0"}}
+	createURL := fmt.Sprintf("%s/upload/storage/v1/b/%s/o?%s", s.Endpoint, url.PathEscape(s.Bucket), query.Encode())
+	for {
+		req, err := http.NewRequest(http.MethodPost, createURL, strings.NewReader(fmt.Sprintf("%d\n", os.Getpid())))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := s.do(req, http.StatusOK)
+		if err == nil {
+			resp.Body.Close()
+			return func() { s.deleteObject(key) }, nil
+		}
+		if gcsStatus(err) != http.StatusPreconditionFailed {
+			return nil, fmt.Errorf("failed to create lock object: %w", err)
+		}
+
+		if obj, found, err := s.attrs(key); err == nil && found && time.Since(obj.Updated) > ttl {
+			s.deleteObject(key)
+			continue
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
@@ -0,0 +1,371 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeGCS is an in-memory stand-in for the GCS JSON API calls GCSStorage
+// makes: object metadata, media download, delete, paginated listing (with
+// delimiter), resumable uploads and conditional media uploads.
+type fakeGCS struct {
+	mu       sync.Mutex
+	url      string
+	objects  map[string][]byte
+	modTime  map[string]time.Time
+	sessions map[string]*bytes.Buffer
+	chunks   int
+}
+
+func newFakeGCS(t *testing.T) (*GCSStorage, *fakeGCS) {
+	t.Helper()
+	fake := &fakeGCS{objects: map[string][]byte{}, modTime: map[string]time.Time{}, sessions: map[string]*bytes.Buffer{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	fake.url = srv.URL
+	return NewGCSStorage(srv.Client(), "bucket", srv.URL), fake
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		name := query.Get("name")
+		if query.Get("uploadType") == "resumable" {
+			id := strconv.Itoa(len(f.sessions))
+			f.sessions[id] = &bytes.Buffer{}
+			w.Header().Set("Location", f.url+"/session/"+id+"?name="+name)
+			return
+		}
+		if _, exists := f.objects[name]; exists && query.Get("ifGenerationMatch") == "0" {
+			http.Error(w, "conditionNotMet", http.StatusPreconditionFailed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.store(name, body)
+	case strings.HasPrefix(r.URL.Path, "/session/"):
+		id := strings.TrimPrefix(r.URL.Path, "/session/")
+		buf, ok := f.sessions[id]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.sessions, id)
+			w.WriteHeader(499)
+			return
+		}
+		f.chunks++
+		io.Copy(buf, r.Body)
+		if strings.HasSuffix(r.Header.Get("Content-Range"), "/*") {
+			w.WriteHeader(http.StatusPermanentRedirect)
+			return
+		}
+		f.store(query.Get("name"), buf.Bytes())
+		delete(f.sessions, id)
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+		f.list(w, query.Get("prefix"), query.Get("delimiter"), query.Get("maxResults"), query.Get("pageToken"))
+	case strings.HasPrefix(r.URL.Path, "/storage/v1/b/bucket/o/"):
+		key := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+		body, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "No such object", http.StatusNotFound)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(f.objects, key)
+			w.WriteHeader(http.StatusNoContent)
+		case query.Get("alt") == "media":
+			w.Header().Set("Last-Modified", f.modTime[key].UTC().Format(http.TimeFormat))
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write(body)
+		default:
+			json.NewEncoder(w).Encode(f.object(key))
+		}
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeGCS) store(key string, body []byte) {
+	f.objects[key] = append([]byte{}, body...)
+	f.modTime[key] = time.Now()
+}
+
+func (f *fakeGCS) object(key string) gcsObject {
+	return gcsObject{Name: key, Size: strconv.Itoa(len(f.objects[key])), Updated: f.modTime[key]}
+}
+
+// list pages through results two at a time, so pagination is exercised.
+func (f *fakeGCS) list(w http.ResponseWriter, prefix, delimiter, maxResults, pageToken string) {
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var all []any
+	seen := map[string]bool{}
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			cp := prefix + rest[:i+1]
+			if !seen[cp] {
+				seen[cp] = true
+				all = append(all, cp)
+			}
+			continue
+		}
+		all = append(all, f.object(k))
+	}
+
+	pageSize := 2
+	if n, err := strconv.Atoi(maxResults); err == nil {
+		pageSize = n
+	}
+	start, _ := strconv.Atoi(pageToken)
+	end := min(start+pageSize, len(all))
+	var page gcsList
+	for _, v := range all[start:end] {
+		if cp, ok := v.(string); ok {
+			page.Prefixes = append(page.Prefixes, cp)
+		} else {
+			page.Items = append(page.Items, v.(gcsObject))
+		}
+	}
+	if end < len(all) {
+		page.NextPageToken = strconv.Itoa(end)
+	}
+	json.NewEncoder(w).Encode(page)
+}
+
+func TestGCSStorage_SaveGetHeadList(t *testing.T) {
+	s, _ := newFakeGCS(t)
+
+	for _, name := range []string{"app-1.0.jar", "app-1.0.pom", "app-1.0.jar.sha1"} {
+		if err := s.Save("repository/develop/com/example/app/1.0/"+name, strings.NewReader("jar")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reader, found, err := s.Get("repository/develop/com/example/app/1.0/app-1.0.jar")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if st, ok := reader.(interface{ Stat() (os.FileInfo, error) }); !ok {
+		t.Error("GCS objects should report their size through Stat")
+	} else if info, _ := st.Stat(); info.Size() != 3 || info.ModTime().IsZero() {
+		t.Errorf("unexpected object info: size %d, modified %v", info.Size(), info.ModTime())
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "jar" {
+		t.Errorf("Get returned %q", data)
+	}
+	if _, found, err := s.Get("repository/develop/missing.jar"); found || err != nil {
+		t.Errorf("Get missing: found=%v err=%v", found, err)
+	}
+
+	for path, want := range map[string]bool{
+		"repository/develop/com/example/app/1.0/app-1.0.jar": true,
+		"repository/develop/com/example":                     true,
+		".":                                                  true,
+		"repository/develop/missing.jar":                     false,
+	} {
+		if found, err := s.Head(path); err != nil || found != want {
+			t.Errorf("Head(%s) = %v, %v; want %v", path, found, err, want)
+		}
+	}
+	if e, found, err := s.Stat("repository/develop/com/example/app/1.0/app-1.0.pom"); err != nil || !found || e.IsDir || e.Size != 3 || e.ModTime.IsZero() {
+		t.Errorf("Stat object = %+v, %v, %v", e, found, err)
+	}
+	if e, found, err := s.Stat("repository/develop/com/example"); err != nil || !found || !e.IsDir || e.Name != "example" {
+		t.Errorf("Stat prefix = %+v, %v, %v", e, found, err)
+	}
+
+	entries, err := s.List("repository/develop/com/example")
+	if err != nil || len(entries) != 1 || entries[0].Name != "app" || !entries[0].IsDir {
+		t.Errorf("unexpected directory listing %+v, %v", entries, err)
+	}
+	// Three files span two pages
+	entries, _ = s.List("repository/develop/com/example/app/1.0/")
+	if len(entries) != 3 || entries[0].Name != "app-1.0.jar" || entries[0].Size != 3 || entries[0].IsDir {
+		t.Errorf("unexpected file listing %+v", entries)
+	}
+	if entries, err := s.List("repository/develop/missing"); entries != nil || err != nil {
+		t.Errorf("List missing = %+v, %v; want nil", entries, err)
+	}
+}
+
+func TestGCSStorage_ResumableUpload(t *testing.T) {
+	s, fake := newFakeGCS(t)
+
+	// Larger than one chunk, and streamed from a reader that cannot seek
+	data := bytes.Repeat([]byte("x"), gcsUploadChunkSize+10)
+	if err := s.Save("big.jar", io.MultiReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	if !bytes.Equal(fake.objects["big.jar"], data) || fake.chunks != 2 {
+		t.Errorf("stored %d bytes in %d chunks", len(fake.objects["big.jar"]), fake.chunks)
+	}
+	fake.mu.Unlock()
+
+	if err := s.Save("empty.jar", bytes.NewReader(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := s.Head("empty.jar"); !found {
+		t.Error("empty object was not created")
+	}
+
+	if err := s.Save("broken.jar", io.MultiReader(strings.NewReader("partial"), failingReader{})); err == nil {
+		t.Fatal("expected the reader error")
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if _, ok := fake.objects["broken.jar"]; ok || len(fake.sessions) != 0 {
+		t.Errorf("failed upload left an object or an open session: %d sessions", len(fake.sessions))
+	}
+}
+
+func TestGCSStorage_CreateDirAndDelete(t *testing.T) {
+	s, fake := newFakeGCS(t)
+
+	if err := s.CreateDir("repository/staging"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := s.List("repository/staging"); err != nil || entries == nil {
+		t.Fatalf("empty directory should list as non-nil, got %+v, %v", entries, err)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.Save(fmt.Sprintf("repository/develop/com/example/app/1.%d/app.jar", i), strings.NewReader("jar"))
+	}
+	s.Save("repository/develop/com/example/other/1.0/other-1.0.jar", strings.NewReader("jar"))
+
+	if err := s.Delete("repository/develop/com/example/app"); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for key := range fake.objects {
+		if strings.HasPrefix(key, "repository/develop/com/example/app/") {
+			t.Errorf("%s survived the delete", key)
+		}
+	}
+	if _, ok := fake.objects["repository/develop/com/example/other/1.0/other-1.0.jar"]; !ok {
+		t.Error("delete removed a sibling directory")
+	}
+}
+
+func TestGCSStorage_DeleteRejectsRootAndTraversal(t *testing.T) {
+	s, fake := newFakeGCS(t)
+	s.Save("repository/releases/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar"))
+
+	for _, p := range []string{"", ".", "/", "repository/releases/../..", "../evil", "repository/../../evil"} {
+		if err := s.Delete(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Delete(%q) = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.objects) != 1 {
+		t.Errorf("expected the bucket to keep its object, got %d objects", len(fake.objects))
+	}
+}
+
+func TestGCSStorage_Walk(t *testing.T) {
+	s, _ := newFakeGCS(t)
+	for _, p := range []string{
+		"repository/develop/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar",
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+		"repository/releases/com/example/lib/2.0/lib-2.0.jar",
+	} {
+		if err := s.Save(p, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dirs, files []string
+	err := s.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			if strings.HasSuffix(path, "-SNAPSHOT") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantFiles := []string{"repository/develop/com/example/app/1.0/app-1.0.jar", "repository/releases/com/example/lib/2.0/lib-2.0.jar"}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+	if dirs[0] != "." || !containsString(dirs, "repository/develop/com/example/app/1.0-SNAPSHOT") || !containsString(dirs, "repository/releases") {
+		t.Errorf("unexpected directories %v", dirs)
+	}
+
+	var missing error
+	s.Walk("repository/none", func(path string, info os.FileInfo, err error) error {
+		missing = err
+		return nil
+	})
+	if !os.IsNotExist(missing) {
+		t.Errorf("walking a missing path should report not-exist, got %v", missing)
+	}
+}
+
+func TestGCSStorage_Lock(t *testing.T) {
+	s, _ := newFakeGCS(t)
+
+	release, err := s.Lock("com/example/maven-metadata.xml", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan struct{})
+	go func() {
+		r, err := s.Lock("com/example/maven-metadata.xml", time.Minute)
+		if err == nil {
+			r()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+}
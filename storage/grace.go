@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// CompleteSuffix marks the empty file written next to an artifact once it has
+// been stored in full.
+const CompleteSuffix = ".complete"
+
+// GraceStorage hides files younger than Grace that have no completion marker,
+// so on shared storage a replica never serves an upload that another instance
+// is still writing. Files older than Grace are always visible, which keeps
+// data written before markers existed reachable.
+type GraceStorage struct {
+	StorageProvider
+	Grace time.Duration
+}
+
+func NewGraceStorage(inner StorageProvider, grace time.Duration) *GraceStorage {
+	return &GraceStorage{StorageProvider: inner, Grace: grace}
+}
+
+// Save writes the completion marker only after the file itself was stored
+// successfully.
+func (s *GraceStorage) Save(p string, data io.Reader) error {
+	if err := s.StorageProvider.Save(p, data); err != nil {
+		return err
+	}
+	if IsInternal(p) {
+		return nil
+	}
+	return s.StorageProvider.Save(p+CompleteSuffix, bytes.NewReader(nil))
+}
+
+func (s *GraceStorage) Delete(p string) error {
+	if err := s.StorageProvider.Delete(p); err != nil {
+		return err
+	}
+	return s.StorageProvider.Delete(p + CompleteSuffix)
+}
+
+func (s *GraceStorage) fresh(e Entry) bool {
+	return !e.IsDir && !IsInternal(e.Name) && time.Since(e.ModTime) < s.Grace
+}
+
+// stat returns the entry for p, treating a fresh file without a completion
+// marker as missing. Only fresh files pay for the marker lookup, so reading
+// older data, including everything stored before markers existed, costs a
+// single Stat.
+func (s *GraceStorage) stat(p string) (Entry, bool, error) {
+	e, found, err := s.StorageProvider.Stat(p)
+	if err != nil || !found || IsInternal(p) || !s.fresh(e) {
+		return e, found, err
+	}
+	marked, err := s.StorageProvider.Head(p + CompleteSuffix)
+	if err != nil || !marked {
+		return Entry{}, false, err
+	}
+	return e, true, nil
+}
+
+func (s *GraceStorage) Get(p string) (io.ReadCloser, bool, error) {
+	_, found, err := s.stat(p)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return s.StorageProvider.Get(p)
+}
+
+func (s *GraceStorage) Head(p string) (bool, error) {
+	_, found, err := s.stat(p)
+	return found, err
+}
+
+func (s *GraceStorage) Stat(p string) (Entry, bool, error) {
+	return s.stat(p)
+}
+
+func (s *GraceStorage) List(p string) ([]Entry, error) {
+	entries, err := s.StorageProvider.List(p)
+	if err != nil || entries == nil {
+		return entries, err
+	}
+	names := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		names[e.Name] = true
+	}
+	result := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		if s.fresh(e) && !names[e.Name+CompleteSuffix] {
+			continue
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (s *GraceStorage) WalkLinks(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	return walkLinks(s.StorageProvider, p, walkFn)
+}
+
+func (s *GraceStorage) Lock(p string, ttl time.Duration) (func(), error) {
+	return lockInner(s.StorageProvider, p, ttl)
+}
+
+func (s *GraceStorage) RemoveEmptyDir(p string) (bool, error) {
+	return removeEmptyDirInner(s.StorageProvider, p)
+}
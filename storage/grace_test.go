@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraceStorage_HidesIncompleteFiles(t *testing.T) {
+	base := t.TempDir()
+	local := NewLocalStorage(base)
+	s := NewGraceStorage(local, time.Minute)
+	dir := "repository/releases/com/example/app/1.0"
+	inFlight := dir + "/app-1.0.jar"
+
+	// Another instance is still writing: the file exists but has no marker
+	if err := local.Save(inFlight, strings.NewReader("partial")); err != nil {
+		t.Fatal(err)
+	}
+	if _, found, _ := s.Get(inFlight); found {
+		t.Fatal("expected an unmarked fresh file to be hidden from Get")
+	}
+	if found, _ := s.Head(inFlight); found {
+		t.Fatal("expected an unmarked fresh file to be hidden from Head")
+	}
+	if entries, _ := s.List(dir); len(entries) != 0 {
+		t.Fatalf("expected an unmarked fresh file to be hidden from List, got %+v", entries)
+	}
+
+	// The writer finishes and marks it complete
+	if err := local.Save(inFlight+CompleteSuffix, strings.NewReader("")); err != nil {
+		t.Fatal(err)
+	}
+	reader, found, err := s.Get(inFlight)
+	if err != nil || !found {
+		t.Fatalf("expected the marked file to be visible, got %v, %v", found, err)
+	}
+	reader.Close()
+	entries, _ := s.List(dir)
+	if !containsEntry(entries, "app-1.0.jar") {
+		t.Fatalf("expected the marked file in the listing, got %+v", entries)
+	}
+}
+
+func TestGraceStorage_SaveMarksAndOldFilesStayVisible(t *testing.T) {
+	base := t.TempDir()
+	local := NewLocalStorage(base)
+	s := NewGraceStorage(local, time.Minute)
+
+	// Saves through the decorator are marked once written
+	if err := s.Save("a/app.pom", strings.NewReader("<project/>")); err != nil {
+		t.Fatal(err)
+	}
+	reader, found, err := s.Get("a/app.pom")
+	if err != nil || !found {
+		t.Fatalf("expected a saved file to be visible, got %v, %v", found, err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "<project/>" {
+		t.Fatalf("unexpected content %q", data)
+	}
+
+	// Files older than the grace period predate markers and stay visible
+	local.Save("a/legacy.jar", strings.NewReader("old"))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(base, "a/legacy.jar"), old, old)
+	if found, _ := s.Head("a/legacy.jar"); !found {
+		t.Fatal("expected an old unmarked file to be visible")
+	}
+
+	if err := s.Delete("a/app.pom"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := local.Head("a/app.pom" + CompleteSuffix); found {
+		t.Error("expected the marker to be deleted with the file")
+	}
+}
+
+func containsEntry(entries []Entry, name string) bool {
+	for _, e := range entries {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGraceStorage_ListStream(t *testing.T) {
+	local := NewLocalStorage(t.TempDir())
+	s := NewGraceStorage(local, time.Minute)
+	dir := "repository/releases/com/example/app/1.0"
+	local.Save(dir+"/in-flight.jar", strings.NewReader("partial"))
+	s.Save(dir+"/a.jar", strings.NewReader("a"))
+	s.Save(dir+"/b.jar", strings.NewReader("b"))
+
+	// Batches of one keep every marker apart from its file
+	var names []string
+	found, err := s.ListStream(dir, 1, func(batch []Entry) error {
+		for _, e := range batch {
+			if !IsInternal(e.Name) {
+				names = append(names, e.Name)
+			}
+		}
+		return nil
+	})
+	sort.Strings(names)
+	if err != nil || !found || strings.Join(names, ",") != "a.jar,b.jar" {
+		t.Errorf("ListStream = %v, %v, %v", names, found, err)
+	}
+}
+
+// markerCountingStorage counts the listings and lookups that reach the backend.
+type markerCountingStorage struct {
+	StorageProvider
+	lists, heads int
+}
+
+func (s *markerCountingStorage) List(p string) ([]Entry, error) {
+	s.lists++
+	return s.StorageProvider.List(p)
+}
+
+func (s *markerCountingStorage) Head(p string) (bool, error) {
+	s.heads++
+	return s.StorageProvider.Head(p)
+}
+
+func TestGraceStorage_OldFilesCostASingleStat(t *testing.T) {
+	base := t.TempDir()
+	local := NewLocalStorage(base)
+	old := "repository/releases/com/example/app/1.0/app-1.0.jar"
+	if err := local.Save(old, strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filepath.Join(base, old), past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	inner := &markerCountingStorage{StorageProvider: local}
+	s := NewGraceStorage(inner, time.Minute)
+	reader, found, err := s.Get(old)
+	if err != nil || !found {
+		t.Fatalf("expected the unmarked old file to be visible, got %v, %v", found, err)
+	}
+	reader.Close()
+	if found, _ := s.Head(old); !found {
+		t.Fatal("expected Head to find the old file")
+	}
+	if inner.lists != 0 || inner.heads != 0 {
+		t.Errorf("reading an old file listed %d directories and looked up %d markers", inner.lists, inner.heads)
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"path"
+	"strings"
+)
+
+// internalSuffixes mark bookkeeping files the server keeps next to artifacts,
+// such as provenance records, tags, pins, lock files, completion markers,
+// access markers and in-progress uploads. They are not artifacts and are
+// hidden from clients.
+var internalSuffixes = []string{".provenance.json", ".tags.json", ".pin", ".lock", CompleteSuffix, AccessSuffix, UploadSuffix}
+
+// UploadSuffix ends the temporary file LocalStorage.Save writes before
+// renaming it into place.
+const UploadSuffix = ".uploading"
+
+// AccessSuffix marks the empty file whose modification time records when a
+// cached artifact was last read.
+const AccessSuffix = ".access"
+
+// DirMarker is the placeholder object that backends without real directories
+// store to keep an empty directory visible.
+const DirMarker = ".keep"
+
+func IsInternal(name string) bool {
+	if name == DirMarker || strings.HasSuffix(name, "/"+DirMarker) {
+		return true
+	}
+	for _, suffix := range internalSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsResolutionMarker reports whether name is one of the files Maven keeps in
+// a local repository to remember resolution attempts (*.lastUpdated and
+// _remote.repositories). They only confuse clients when they leak into a
+// server, so they are neither stored nor served.
+func IsResolutionMarker(name string) bool {
+	base := path.Base(name)
+	return strings.HasSuffix(base, ".lastUpdated") || base == "_remote.repositories"
+}
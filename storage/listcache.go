@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+type cachedList struct {
+	entries []Entry
+	at      time.Time
+}
+
+// ListCacheStorage wraps a StorageProvider and reuses List results for a short
+// TTL, so browsing and aggregated listings do not hit a slow backend for every
+// request. Writes through it drop the listings they can change; writes by
+// other instances sharing the storage show up once the TTL has passed.
+type ListCacheStorage struct {
+	StorageProvider
+	TTL time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cachedList
+	lastSweep time.Time
+}
+
+func NewListCacheStorage(inner StorageProvider, ttl time.Duration) *ListCacheStorage {
+	return &ListCacheStorage{
+		StorageProvider: inner,
+		TTL:             ttl,
+		entries:         make(map[string]cachedList),
+	}
+}
+
+func listCacheKey(p string) string {
+	key := path.Clean(strings.Trim(strings.ReplaceAll(p, "\\", "/"), "/"))
+	if key == "." {
+		return ""
+	}
+	return key
+}
+
+func (s *ListCacheStorage) List(p string) ([]Entry, error) {
+	key := listCacheKey(p)
+	s.mu.Lock()
+	cached, ok := s.entries[key]
+	s.mu.Unlock()
+	if ok && time.Since(cached.at) < s.TTL {
+		return copyEntries(cached.entries), nil
+	}
+
+	entries, err := s.StorageProvider.List(p)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Drop expired listings now and then, so directories browsed once do not
+	// stay in memory
+	if now.Sub(s.lastSweep) > s.TTL {
+		for k, cached := range s.entries {
+			if now.Sub(cached.at) >= s.TTL {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.entries[key] = cachedList{entries: copyEntries(entries), at: now}
+	return entries, nil
+}
+
+// copyEntries keeps callers that sort or filter a listing from changing the
+// cached copy. A nil listing (no such directory) stays nil.
+func copyEntries(entries []Entry) []Entry {
+	if entries == nil {
+		return nil
+	}
+	return append([]Entry{}, entries...)
+}
+
+// invalidate drops the listings a write to p can change: those of its
+// ancestors, which may gain or lose an entry, and of p and everything below it,
+// for directories that were created or removed.
+func (s *ListCacheStorage) invalidate(p string) {
+	key := listCacheKey(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for cached := range s.entries {
+		if cached == "" || cached == key || strings.HasPrefix(key, cached+"/") || strings.HasPrefix(cached, key+"/") {
+			delete(s.entries, cached)
+		}
+	}
+}
+
+func (s *ListCacheStorage) Save(p string, data io.Reader) error {
+	defer s.invalidate(p)
+	return s.StorageProvider.Save(p, data)
+}
+
+func (s *ListCacheStorage) Delete(p string) error {
+	defer s.invalidate(p)
+	return s.StorageProvider.Delete(p)
+}
+
+func (s *ListCacheStorage) CreateDir(p string) error {
+	defer s.invalidate(p)
+	return s.StorageProvider.CreateDir(p)
+}
+
+func (s *ListCacheStorage) Lock(p string, ttl time.Duration) (func(), error) {
+	return lockInner(s.StorageProvider, p, ttl)
+}
+
+func (s *ListCacheStorage) RemoveEmptyDir(p string) (bool, error) {
+	defer s.invalidate(p)
+	return removeEmptyDirInner(s.StorageProvider, p)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+type countingLister struct {
+	StorageProvider
+	lists int
+}
+
+func (c *countingLister) List(path string) ([]Entry, error) {
+	c.lists++
+	return c.StorageProvider.List(path)
+}
+
+func TestListCacheStorage(t *testing.T) {
+	backend := &countingLister{StorageProvider: NewMemoryStorage()}
+	s := NewListCacheStorage(backend, time.Minute)
+	s.Save("repository/releases/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar"))
+
+	names := func(path string) string {
+		t.Helper()
+		entries, err := s.List(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	if got := names("repository/releases/com/example/app"); got != "1.0" {
+		t.Fatalf("unexpected listing %q", got)
+	}
+	names("repository/releases/com/example/app/")
+	names("repository/releases/com/example/app/1.0")
+	if backend.lists != 2 {
+		t.Errorf("expected repeated listings to be cached, backend listed %d times", backend.lists)
+	}
+
+	// A save shows up in its ancestors' listings straight away
+	s.Save("repository/releases/com/example/app/2.0/app-2.0.jar", strings.NewReader("jar"))
+	if got := names("repository/releases/com/example/app"); got != "1.0,2.0" {
+		t.Errorf("listing after save = %q", got)
+	}
+	// and so does a delete, including for the deleted directory itself
+	s.Delete("repository/releases/com/example/app/1.0")
+	if got := names("repository/releases/com/example/app"); got != "2.0" {
+		t.Errorf("listing after delete = %q", got)
+	}
+	if entries, _ := s.List("repository/releases/com/example/app/1.0"); entries != nil {
+		t.Errorf("deleted directory still listed: %+v", entries)
+	}
+
+	// Callers may modify what they get back
+	entries, _ := s.List("repository/releases/com/example/app")
+	entries[0].Name = "changed"
+	if got := names("repository/releases/com/example/app"); got != "2.0" {
+		t.Errorf("caller modified the cached listing: %q", got)
+	}
+
+	s.TTL = 0
+	before := backend.lists
+	names("repository/releases/com/example/app")
+	if backend.lists != before+1 {
+		t.Error("expired listing was served from cache")
+	}
+}
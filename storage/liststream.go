@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+)
+
+// ListStreamer is implemented by backends that can read a directory in
+// batches, so a very large directory is never held in memory at once.
+type ListStreamer interface {
+	// ListStream calls fn with successive batches of at most n entries of
+	// the directory at path, in storage order, and reports whether path is a
+	// directory. An error from fn stops the listing and is returned.
+	ListStream(path string, n int, fn func([]Entry) error) (bool, error)
+}
+
+// ListStream lists path in batches through s, falling back to a single List
+// for backends that cannot stream.
+func ListStream(s StorageProvider, path string, n int, fn func([]Entry) error) (bool, error) {
+	if streamer, ok := s.(ListStreamer); ok {
+		return streamer.ListStream(path, n, fn)
+	}
+	entries, err := s.List(path)
+	if err != nil || entries == nil {
+		return false, err
+	}
+	for len(entries) > 0 {
+		batch := entries[:min(n, len(entries))]
+		if err := fn(batch); err != nil {
+			return true, err
+		}
+		entries = entries[len(batch):]
+	}
+	return true, nil
+}
+
+// ListStream reads the directory n entries at a time with os.File.ReadDir.
+func (s *LocalStorage) ListStream(p string, n int, fn func([]Entry) error) (bool, error) {
+	fullPath, err := s.fullPath(p)
+	if err != nil {
+		return false, err
+	}
+	dir, err := os.Open(fullPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer dir.Close()
+	if stat, err := dir.Stat(); err != nil || !stat.IsDir() {
+		return false, err
+	}
+
+	for {
+		dirEntries, readErr := dir.ReadDir(n)
+		batch := make([]Entry, 0, len(dirEntries))
+		for _, e := range dirEntries {
+			if entry, ok := localEntry(fullPath, e); ok {
+				batch = append(batch, entry)
+			}
+		}
+		if len(batch) > 0 {
+			if err := fn(batch); err != nil {
+				return true, err
+			}
+		}
+		if readErr == io.EOF {
+			return true, nil
+		}
+		if readErr != nil {
+			return true, readErr
+		}
+	}
+}
+
+// ListStream applies List's filter batch by batch. A completion marker may
+// arrive in a different batch than its file, so fresh files without one in
+// their own batch are checked individually.
+func (s *GraceStorage) ListStream(p string, n int, fn func([]Entry) error) (bool, error) {
+	return ListStream(s.StorageProvider, p, n, func(batch []Entry) error {
+		names := make(map[string]bool, len(batch))
+		for _, e := range batch {
+			names[e.Name] = true
+		}
+		visible := make([]Entry, 0, len(batch))
+		for _, e := range batch {
+			if s.fresh(e) && !names[e.Name+CompleteSuffix] {
+				if marked, err := s.StorageProvider.Head(path.Join(p, e.Name+CompleteSuffix)); err != nil || !marked {
+					continue
+				}
+			}
+			visible = append(visible, e)
+		}
+		if len(visible) == 0 {
+			return nil
+		}
+		return fn(visible)
+	})
+}
+
+func (s *BloomStorage) ListStream(p string, n int, fn func([]Entry) error) (bool, error) {
+	if !s.known(p) {
+		return false, nil
+	}
+	return ListStream(s.StorageProvider, p, n, fn)
+}
+
+func (s *BreakerStorage) ListStream(p string, n int, fn func([]Entry) error) (bool, error) {
+	if err := s.allow(); err != nil {
+		return false, err
+	}
+	var callbackErr error
+	found, err := ListStream(s.StorageProvider, p, n, func(batch []Entry) error {
+		callbackErr = fn(batch)
+		return callbackErr
+	})
+	if err != nil && err == callbackErr {
+		// The callback stopped the listing, not the backend
+		s.record(nil)
+		return found, err
+	}
+	s.record(err)
+	return found, err
+}
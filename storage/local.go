@@ -1,13 +1,19 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// ErrInvalidPath is returned for storage paths that resolve outside the
+// storage root, e.g. through "../" segments.
+var ErrInvalidPath = errors.New("invalid storage path")
+
 type Entry struct {
 	Name    string
 	IsDir   bool
@@ -19,39 +25,86 @@ type StorageProvider interface {
 	Save(path string, data io.Reader) error
 	Get(path string) (io.ReadCloser, bool, error)
 	Head(path string) (bool, error)
+	// Stat returns the entry for a file or directory without opening or
+	// listing it.
+	Stat(path string) (Entry, bool, error)
 	List(path string) ([]Entry, error)
 	Delete(path string) error
+	// CreateDir makes an empty directory appear in listings. Backends without
+	// real directories store a .keep marker inside it.
+	CreateDir(path string) error
 	Walk(path string, walkFn func(path string, info os.FileInfo, err error) error) error
 }
 
 type LocalStorage struct {
 	BasePath string
+	// FollowSymlinks makes Walk descend into symlinked directories.
+	FollowSymlinks bool
 }
 
 func NewLocalStorage(basePath string) *LocalStorage {
 	return &LocalStorage{BasePath: basePath}
 }
 
-func (s *LocalStorage) Save(path string, data io.Reader) error {
+// fullPath resolves path below BasePath. Paths that would escape it are
+// rejected with ErrInvalidPath; absolute paths are taken relative to BasePath.
+func (s *LocalStorage) fullPath(path string) (string, error) {
 	fullPath := filepath.Join(s.BasePath, path)
+	rel, err := filepath.Rel(s.BasePath, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPath, path)
+	}
+	return fullPath, nil
+}
+
+func (s *LocalStorage) Save(path string, data io.Reader) error {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
 	dir := filepath.Dir(fullPath)
 
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	file, err := os.Create(fullPath)
+	// Write next to the target and rename into place, so readers see either
+	// the previous file or the complete new one, never a partial write.
+	file, err := os.CreateTemp(dir, "."+filepath.Base(fullPath)+".*"+UploadSuffix)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %w", err)
 	}
-	defer file.Close()
+	tmpPath := file.Name()
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(tmpPath)
+		}
+	}()
 
-	_, err = io.Copy(file, data)
-	return err
+	if _, err = io.Copy(file, data); err != nil {
+		return err
+	}
+	if err = file.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync file: %w", err)
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to close file: %w", err)
+	}
+	if err = os.Rename(tmpPath, fullPath); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
 }
 
 func (s *LocalStorage) Get(path string) (io.ReadCloser, bool, error) {
-	fullPath := filepath.Join(s.BasePath, path)
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return nil, false, err
+	}
 	file, err := os.Open(fullPath)
 	if os.IsNotExist(err) {
 		return nil, false, nil
@@ -63,19 +116,30 @@ func (s *LocalStorage) Get(path string) (io.ReadCloser, bool, error) {
 }
 
 func (s *LocalStorage) Head(path string) (bool, error) {
-	fullPath := filepath.Join(s.BasePath, path)
-	_, err := os.Stat(fullPath)
+	_, found, err := s.Stat(path)
+	return found, err
+}
+
+func (s *LocalStorage) Stat(path string) (Entry, bool, error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	info, err := os.Stat(fullPath)
 	if os.IsNotExist(err) {
-		return false, nil
+		return Entry{}, false, nil
 	}
 	if err != nil {
-		return false, err
+		return Entry{}, false, err
 	}
-	return true, nil
+	return Entry{Name: info.Name(), IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}, true, nil
 }
 
 func (s *LocalStorage) List(path string) ([]Entry, error) {
-	fullPath := filepath.Join(s.BasePath, path)
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
 	stat, err := os.Stat(fullPath)
 	if os.IsNotExist(err) {
 		return nil, nil // Not found is not an error, just empty list? Or specific error?
@@ -92,34 +156,145 @@ func (s *LocalStorage) List(path string) ([]Entry, error) {
 		return nil, err
 	}
 
-	var result []Entry
+	// Non-nil even when empty so an empty directory is still a directory
+	result := make([]Entry, 0, len(entries))
 	for _, e := range entries {
-		info, err := e.Info()
-		if err != nil {
-			continue
+		if entry, ok := localEntry(fullPath, e); ok {
+			result = append(result, entry)
 		}
-		result = append(result, Entry{
-			Name:    e.Name(),
-			IsDir:   e.IsDir(),
-			Size:    info.Size(),
-			ModTime: info.ModTime(),
-		})
 	}
 	return result, nil
 }
 
+// localEntry describes e, read from the directory dir. Reads resolve
+// symlinks, so they are listed as what they point to.
+func localEntry(dir string, e os.DirEntry) (Entry, bool) {
+	info, err := e.Info()
+	if err != nil {
+		return Entry{}, false
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Stat(filepath.Join(dir, e.Name())); err == nil {
+			info = target
+		}
+	}
+	return Entry{Name: e.Name(), IsDir: info.IsDir(), Size: info.Size(), ModTime: info.ModTime()}, true
+}
+
 func (s *LocalStorage) Delete(path string) error {
-	fullPath := filepath.Join(s.BasePath, path)
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	// Never wipe the whole storage root, e.g. for "repository/x/../.."
+	if fullPath == filepath.Clean(s.BasePath) {
+		return fmt.Errorf("%w: refusing to delete the storage root", ErrInvalidPath)
+	}
 	return os.RemoveAll(fullPath)
 }
 
+func (s *LocalStorage) CreateDir(path string) error {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	return nil
+}
+
 func (s *LocalStorage) Walk(path string, walkFn func(path string, info os.FileInfo, err error) error) error {
-	fullPath := filepath.Join(s.BasePath, path)
-	return filepath.Walk(fullPath, func(wPath string, info os.FileInfo, err error) error {
+	return s.walk(path, s.FollowSymlinks, walkFn)
+}
+
+// WalkLinks walks like Walk with FollowSymlinks set, so it visits every file
+// Get can reach.
+func (s *LocalStorage) WalkLinks(path string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	return s.walk(path, true, walkFn)
+}
+
+func (s *LocalStorage) walk(path string, follow bool, walkFn func(path string, info os.FileInfo, err error) error) error {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return err
+	}
+	relFn := func(wPath string, info os.FileInfo, err error) error {
 		relPath, relErr := filepath.Rel(s.BasePath, wPath)
 		if relErr != nil {
 			return walkFn(wPath, info, relErr)
 		}
 		return walkFn(relPath, info, err)
-	})
+	}
+
+	if !follow {
+		return filepath.Walk(fullPath, relFn)
+	}
+
+	info, statErr := os.Stat(fullPath)
+	if statErr != nil {
+		err = relFn(fullPath, nil, statErr)
+	} else {
+		err = s.walkFollow(fullPath, info, relFn, make(map[string]bool))
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkFollow mirrors filepath.Walk but resolves symlinks. Directories are
+// tracked by their real path so a link pointing back up the tree is only
+// visited once.
+func (s *LocalStorage) walkFollow(path string, info os.FileInfo, walkFn filepath.WalkFunc, visited map[string]bool) error {
+	if !info.IsDir() {
+		return walkFn(path, info, nil)
+	}
+
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	if visited[realPath] {
+		return nil
+	}
+	visited[realPath] = true
+
+	if err := walkFn(path, info, nil); err != nil {
+		return err
+	}
+
+	names, err := readDirNames(path)
+	if err != nil {
+		return walkFn(path, info, err)
+	}
+	for _, name := range names {
+		child := filepath.Join(path, name)
+		childInfo, err := os.Stat(child)
+		if err != nil {
+			if err := walkFn(child, childInfo, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := s.walkFollow(child, childInfo, walkFn, visited); err != nil {
+			if err == filepath.SkipDir && childInfo.IsDir() {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func readDirNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names, nil
 }
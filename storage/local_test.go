@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func walkFiles(t *testing.T, s *LocalStorage) []string {
+	t.Helper()
+	var files []string
+	err := s.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if !info.IsDir() {
+			files = append(files, filepath.ToSlash(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func TestLocalStorage_WalkSymlinks(t *testing.T) {
+	base := t.TempDir()
+	archive := t.TempDir()
+
+	s := NewLocalStorage(base)
+	if err := s.Save("repository/develop/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+
+	archived := filepath.Join(archive, "com/example/old/1.0-SNAPSHOT")
+	if err := os.MkdirAll(archived, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(archived, "old-1.0-SNAPSHOT.jar"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(archive, filepath.Join(base, "repository/archive")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	// A cycle back to the storage root must not cause infinite recursion
+	if err := os.Symlink(base, filepath.Join(archived, "loop")); err != nil {
+		t.Fatal(err)
+	}
+
+	linked := "repository/archive/com/example/old/1.0-SNAPSHOT/old-1.0-SNAPSHOT.jar"
+
+	files := walkFiles(t, s)
+	for _, f := range files {
+		if f == linked {
+			t.Fatalf("symlinked content should not be visited by default: %v", files)
+		}
+	}
+
+	s.FollowSymlinks = true
+	files = walkFiles(t, s)
+	want := []string{
+		linked,
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+	}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, files)
+	}
+}
+
+func TestLocalStorage_CreateDir(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	if err := s.CreateDir("repository/staging/com/example"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := s.List("repository/staging/com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "example" || !entries[0].IsDir {
+		t.Fatalf("expected the created directory in the listing, got %+v", entries)
+	}
+
+	// The empty directory itself lists as a directory, not as missing
+	entries, err = s.List("repository/staging/com/example")
+	if err != nil || entries == nil || len(entries) != 0 {
+		t.Fatalf("expected an empty, non-nil listing, got %+v, %v", entries, err)
+	}
+}
+
+func TestLocalStorage_Stat(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	s.Save("repository/releases/app.jar", strings.NewReader("jar"))
+
+	e, found, err := s.Stat("repository/releases/app.jar")
+	if err != nil || !found || e.Name != "app.jar" || e.IsDir || e.Size != 3 || e.ModTime.IsZero() {
+		t.Errorf("file: %+v, found=%v, err=%v", e, found, err)
+	}
+	e, found, err = s.Stat("repository/releases")
+	if err != nil || !found || !e.IsDir {
+		t.Errorf("directory: %+v, found=%v, err=%v", e, found, err)
+	}
+	if _, found, err := s.Stat("repository/releases/missing.jar"); found || err != nil {
+		t.Errorf("missing: found=%v, err=%v", found, err)
+	}
+	if _, _, err := s.Stat("../outside"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("traversal: expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestLocalStorage_RemoveEmptyDir(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	s.CreateDir("repository/staging/empty")
+	s.Save("repository/staging/full/app.jar", strings.NewReader("jar"))
+
+	if removed, err := s.RemoveEmptyDir("repository/staging/full"); removed || err != nil {
+		t.Errorf("non-empty directory: removed=%v err=%v", removed, err)
+	}
+	if found, _ := s.Head("repository/staging/full/app.jar"); !found {
+		t.Error("RemoveEmptyDir deleted contents")
+	}
+	if removed, err := s.RemoveEmptyDir("repository/staging/empty"); !removed || err != nil {
+		t.Errorf("empty directory: removed=%v err=%v", removed, err)
+	}
+	if removed, err := s.RemoveEmptyDir("repository/staging/missing"); removed || err != nil {
+		t.Errorf("missing directory: removed=%v err=%v", removed, err)
+	}
+	if _, err := s.RemoveEmptyDir("."); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("storage root: expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestLocalStorage_RejectsPathTraversal(t *testing.T) {
+	parent := t.TempDir()
+	base := filepath.Join(parent, "storage")
+	s := NewLocalStorage(base)
+
+	for _, p := range []string{"../evil.jar", "repository/develop/../../../evil.jar", ".."} {
+		if err := s.Save(p, strings.NewReader("evil")); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Save(%s) = %v, want ErrInvalidPath", p, err)
+		}
+		if _, _, err := s.Get(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Get(%s) = %v, want ErrInvalidPath", p, err)
+		}
+		if _, err := s.Head(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Head(%s) = %v, want ErrInvalidPath", p, err)
+		}
+		if err := s.Delete(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Delete(%s) = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "evil.jar")); !os.IsNotExist(err) {
+		t.Fatal("a file was written outside the storage root")
+	}
+
+	// Absolute paths and ".." that stays inside the root resolve below BasePath
+	if err := s.Save("/com/example/app.jar", strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save("repository/develop/../releases/app.jar", strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"com/example/app.jar", "repository/releases/app.jar"} {
+		if _, err := os.Stat(filepath.Join(base, p)); err != nil {
+			t.Errorf("expected %s below the storage root: %v", p, err)
+		}
+	}
+
+	// Paths that clean to the root itself must not wipe the storage
+	if err := s.Delete("repository/develop/../.."); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("deleting the storage root = %v, want ErrInvalidPath", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "com/example/app.jar")); err != nil {
+		t.Error("storage contents were removed")
+	}
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestLocalStorage_SaveIsAtomic(t *testing.T) {
+	base := t.TempDir()
+	s := NewLocalStorage(base)
+	dir := "repository/releases/com/example/app/1.0"
+
+	err := s.Save(dir+"/app-1.0.jar", io.MultiReader(strings.NewReader("partial"), failingReader{}))
+	if err == nil {
+		t.Fatal("expected the reader error")
+	}
+	if _, err := os.Stat(filepath.Join(base, dir, "app-1.0.jar")); !os.IsNotExist(err) {
+		t.Errorf("failed save left a file at the target path: %v", err)
+	}
+	if files := walkFiles(t, s); len(files) != 0 {
+		t.Errorf("failed save left files behind: %v", files)
+	}
+
+	// A failed overwrite keeps the previous content
+	if err := s.Save(dir+"/app-1.0.pom", strings.NewReader("pom")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(dir+"/app-1.0.pom", io.MultiReader(strings.NewReader("par"), failingReader{})); err == nil {
+		t.Fatal("expected the reader error")
+	}
+	data, _ := os.ReadFile(filepath.Join(base, dir, "app-1.0.pom"))
+	if string(data) != "pom" {
+		t.Errorf("failed overwrite left %q", data)
+	}
+	if files := walkFiles(t, s); len(files) != 1 {
+		t.Errorf("unexpected files %v", files)
+	}
+}
+
+func TestLocalStorage_ListStream(t *testing.T) {
+	s := NewLocalStorage(t.TempDir())
+	for i := 0; i < 5; i++ {
+		s.Save("repository/releases/app/1."+string(rune('0'+i))+"/app.jar", strings.NewReader("x"))
+	}
+
+	var sizes []int
+	var names []string
+	found, err := s.ListStream("repository/releases/app", 2, func(batch []Entry) error {
+		sizes = append(sizes, len(batch))
+		for _, e := range batch {
+			names = append(names, e.Name)
+		}
+		return nil
+	})
+	if err != nil || !found {
+		t.Fatalf("ListStream: found=%v err=%v", found, err)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "1.0,1.1,1.2,1.3,1.4" || len(sizes) != 3 || sizes[0] != 2 {
+		t.Errorf("unexpected batches %v of %v", sizes, names)
+	}
+
+	for _, p := range []string{"repository/missing", "repository/releases/app/1.0/app.jar"} {
+		if found, err := s.ListStream(p, 2, func([]Entry) error { t.Errorf("%s: unexpected batch", p); return nil }); found || err != nil {
+			t.Errorf("%s: found=%v err=%v", p, found, err)
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	if _, err := s.ListStream("repository/releases/app", 2, func([]Entry) error { calls++; return stop }); err != stop || calls != 1 {
+		t.Errorf("callback error should stop the listing: %v after %d calls", err, calls)
+	}
+}
+
+func TestLocalStorage_ListStreamResolvesSymlinks(t *testing.T) {
+	base, shared := t.TempDir(), t.TempDir()
+	s := NewLocalStorage(base)
+	s.Save("repository/releases/app.jar", strings.NewReader("x"))
+	if err := os.Symlink(shared, filepath.Join(base, "repository", "releases", "linked")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	listed, err := s.List("repository/releases")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []Entry
+	if _, err := s.ListStream("repository/releases", 10, func(batch []Entry) error {
+		streamed = append(streamed, batch...)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, entries := range [][]Entry{listed, streamed} {
+		for _, e := range entries {
+			if e.Name == "linked" && !e.IsDir {
+				t.Errorf("expected the symlinked directory to be listed as a directory: %+v", e)
+			}
+		}
+	}
+	if len(streamed) != len(listed) {
+		t.Errorf("List and ListStream disagree: %+v vs %+v", listed, streamed)
+	}
+}
@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Locker is implemented by backends that can hold a lock visible to every
+// instance sharing the same storage.
+type Locker interface {
+	// Lock blocks until the lock for path is held and returns a function that
+	// releases it. Locks older than ttl are considered abandoned and broken.
+	Lock(path string, ttl time.Duration) (func(), error)
+}
+
+const lockPollInterval = 20 * time.Millisecond
+
+// Lock creates "<path>.lock" exclusively next to the target file.
+func (s *LocalStorage) Lock(path string, ttl time.Duration) (func(), error) {
+	fullPath, err := s.fullPath(path)
+	if err != nil {
+		return nil, err
+	}
+	lockPath := fullPath + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lockPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	for {
+		file, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d\n", os.Getpid())
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > ttl {
+			os.Remove(lockPath)
+			continue
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// lockInner is used by decorators to pass Lock through to the backend they
+// wrap. Backends without locking get a no-op lock, like callers that check for
+// Locker themselves.
+func lockInner(inner StorageProvider, path string, ttl time.Duration) (func(), error) {
+	if locker, ok := inner.(Locker); ok {
+		return locker.Lock(path, ttl)
+	}
+	return func() {}, nil
+}
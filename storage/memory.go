@@ -0,0 +1,300 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStorage keeps files in a map, for tests that should not touch disk.
+// Directories exist while something is stored below them, or once created
+// with CreateDir, and behave like LocalStorage's: paths escaping the root are
+// rejected and empty directories list as an empty, non-nil slice.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	files map[string]memFile
+	dirs  map[string]bool
+	// Now stamps modification times; tests may replace it.
+	Now func() time.Time
+}
+
+type memFile struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		files: make(map[string]memFile),
+		dirs:  make(map[string]bool),
+		Now:   time.Now,
+	}
+}
+
+// memKey normalises p to a slash-separated key without leading or trailing
+// slashes; the root is "".
+func memKey(p string) (string, error) {
+	key := path.Clean(strings.TrimLeft(filepath.ToSlash(p), "/"))
+	if key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%w: %s", ErrInvalidPath, p)
+	}
+	if key == "." {
+		return "", nil
+	}
+	return key, nil
+}
+
+// isDir reports whether key is the root, a created directory or a prefix of
+// a stored file. Callers hold mu.
+func (s *MemoryStorage) isDir(key string) bool {
+	if key == "" || s.dirs[key] {
+		return true
+	}
+	prefix := key + "/"
+	for k := range s.files {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	for d := range s.dirs {
+		if strings.HasPrefix(d, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MemoryStorage) Save(p string, data io.Reader) error {
+	key, err := memKey(p)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("%w: cannot write the storage root", ErrInvalidPath)
+	}
+	// Read everything first so a failing source leaves the old content
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isDir(key) {
+		return fmt.Errorf("failed to create file: %s is a directory", p)
+	}
+	s.files[key] = memFile{data: content, modTime: s.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Get(p string) (io.ReadCloser, bool, error) {
+	key, err := memKey(p)
+	if err != nil {
+		return nil, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.files[key]
+	if !ok {
+		return nil, false, nil
+	}
+	info := &memFileInfo{name: path.Base(key), size: int64(len(f.data)), modTime: f.modTime}
+	return &memObject{Reader: bytes.NewReader(f.data), info: info}, true, nil
+}
+
+// memObject reports its size and modification time through Stat, like
+// *os.File.
+type memObject struct {
+	*bytes.Reader
+	info *memFileInfo
+}
+
+func (o *memObject) Close() error               { return nil }
+func (o *memObject) Stat() (os.FileInfo, error) { return o.info, nil }
+
+func (s *MemoryStorage) Head(p string) (bool, error) {
+	_, found, err := s.Stat(p)
+	return found, err
+}
+
+func (s *MemoryStorage) Stat(p string) (Entry, bool, error) {
+	key, err := memKey(p)
+	if err != nil {
+		return Entry{}, false, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stat(key)
+}
+
+func (s *MemoryStorage) stat(key string) (Entry, bool, error) {
+	if f, ok := s.files[key]; ok {
+		return Entry{Name: path.Base(key), Size: int64(len(f.data)), ModTime: f.modTime}, true, nil
+	}
+	if s.isDir(key) {
+		return Entry{Name: path.Base(key), IsDir: true}, true, nil
+	}
+	return Entry{}, false, nil
+}
+
+func (s *MemoryStorage) List(p string) ([]Entry, error) {
+	key, err := memKey(p)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.list(key), nil
+}
+
+// list returns the entries directly below key sorted by name, or nil when key
+// is not a directory. Callers hold mu.
+func (s *MemoryStorage) list(key string) []Entry {
+	if !s.isDir(key) {
+		return nil
+	}
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+	children := map[string]Entry{}
+	addDir := func(rest string) {
+		name, _, _ := strings.Cut(rest, "/")
+		if _, ok := children[name]; !ok {
+			children[name] = Entry{Name: name, IsDir: true}
+		}
+	}
+	for k, f := range s.files {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if !ok {
+			continue
+		}
+		if strings.Contains(rest, "/") {
+			addDir(rest)
+			continue
+		}
+		children[rest] = Entry{Name: rest, Size: int64(len(f.data)), ModTime: f.modTime}
+	}
+	for d := range s.dirs {
+		if rest, ok := strings.CutPrefix(d, prefix); ok {
+			addDir(rest)
+		}
+	}
+
+	// Non-nil even when empty so an empty directory is still a directory
+	result := make([]Entry, 0, len(children))
+	for _, e := range children {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Delete removes p and everything below it.
+func (s *MemoryStorage) Delete(p string) error {
+	key, err := memKey(p)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("%w: refusing to delete the storage root", ErrInvalidPath)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefix := key + "/"
+	for k := range s.files {
+		if k == key || strings.HasPrefix(k, prefix) {
+			delete(s.files, k)
+		}
+	}
+	for d := range s.dirs {
+		if d == key || strings.HasPrefix(d, prefix) {
+			delete(s.dirs, d)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStorage) CreateDir(p string) error {
+	key, err := memKey(p)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.files[key]; ok {
+		return fmt.Errorf("failed to create directory: %s is a file", p)
+	}
+	if key != "" {
+		s.dirs[key] = true
+	}
+	return nil
+}
+
+// Walk visits p and everything below it in lexical order, like
+// filepath.Walk. Paths are passed relative to the root, which is ".".
+func (s *MemoryStorage) Walk(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	key, err := memKey(p)
+	if err != nil {
+		return err
+	}
+	s.mu.RLock()
+	e, found, _ := s.stat(key)
+	s.mu.RUnlock()
+	if !found {
+		return skipToNil(walkFn(walkName(key), nil, &fs.PathError{Op: "lstat", Path: p, Err: fs.ErrNotExist}))
+	}
+	return skipToNil(s.walk(key, e, walkFn))
+}
+
+func (s *MemoryStorage) walk(key string, e Entry, walkFn func(path string, info os.FileInfo, err error) error) error {
+	err := walkFn(walkName(key), &memFileInfo{name: e.Name, size: e.Size, modTime: e.ModTime, dir: e.IsDir}, nil)
+	if err != nil || !e.IsDir {
+		return err
+	}
+
+	// Snapshot the children so walkFn may modify the storage
+	s.mu.RLock()
+	children := s.list(key)
+	s.mu.RUnlock()
+	for _, child := range children {
+		childKey := child.Name
+		if key != "" {
+			childKey = key + "/" + child.Name
+		}
+		if err := s.walk(childKey, child, walkFn); err != nil {
+			if err == filepath.SkipDir && child.IsDir {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.dir }
+func (fi *memFileInfo) Sys() any           { return nil }
+
+func (fi *memFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testStorageContract checks the behaviour handlers and services rely on,
+// against any StorageProvider.
+func testStorageContract(t *testing.T, s StorageProvider) {
+	t.Helper()
+	for _, p := range []string{
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+		"repository/develop/com/example/app/1.0/app-1.0.pom",
+		"repository/releases/com/example/lib/2.0/lib-2.0.jar",
+	} {
+		if err := s.Save(p, strings.NewReader("data")); err != nil {
+			t.Fatalf("Save(%s): %v", p, err)
+		}
+	}
+
+	reader, found, err := s.Get("repository/develop/com/example/app/1.0/app-1.0.jar")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if st, ok := reader.(interface{ Stat() (os.FileInfo, error) }); !ok {
+		t.Error("readers should report their size through Stat")
+	} else if info, _ := st.Stat(); info.Size() != 4 {
+		t.Errorf("Stat size = %d", info.Size())
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "data" {
+		t.Errorf("Get returned %q", data)
+	}
+	if _, found, err := s.Get("repository/develop/missing.jar"); found || err != nil {
+		t.Errorf("Get missing: found=%v err=%v", found, err)
+	}
+
+	for p, want := range map[string]bool{
+		"repository/develop/com/example/app/1.0/app-1.0.jar": true,
+		"repository/develop/com/example":                     true,
+		".":                                                  true,
+		"repository/develop/missing.jar":                     false,
+	} {
+		if found, err := s.Head(p); err != nil || found != want {
+			t.Errorf("Head(%s) = %v, %v; want %v", p, found, err, want)
+		}
+	}
+	if e, found, err := s.Stat("repository/develop/com/example/app/1.0/app-1.0.pom"); err != nil || !found || e.IsDir || e.Size != 4 || e.Name != "app-1.0.pom" {
+		t.Errorf("Stat file = %+v, %v, %v", e, found, err)
+	}
+	if e, found, err := s.Stat("repository/develop/com"); err != nil || !found || !e.IsDir {
+		t.Errorf("Stat directory = %+v, %v, %v", e, found, err)
+	}
+
+	entries, err := s.List("repository/develop/com/example/app/1.0")
+	if err != nil || len(entries) != 2 || entries[0].Name != "app-1.0.jar" || entries[0].IsDir || entries[0].Size != 4 {
+		t.Errorf("List files = %+v, %v", entries, err)
+	}
+	entries, _ = s.List("repository")
+	if len(entries) != 2 || entries[0].Name != "develop" || !entries[0].IsDir || entries[1].Name != "releases" {
+		t.Errorf("List directories = %+v", entries)
+	}
+	if entries, err := s.List("repository/missing"); entries != nil || err != nil {
+		t.Errorf("List missing = %+v, %v; want nil", entries, err)
+	}
+	if entries, err := s.List("repository/develop/com/example/app/1.0/app-1.0.jar"); entries != nil || err != nil {
+		t.Errorf("List file = %+v, %v; want nil", entries, err)
+	}
+
+	if err := s.CreateDir("repository/staging"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := s.List("repository/staging"); err != nil || entries == nil || len(entries) != 0 {
+		t.Errorf("empty directory should list as empty and non-nil, got %+v, %v", entries, err)
+	}
+
+	var walked []string
+	err = s.Walk(".", func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && p == "repository/releases" {
+			return filepath.SkipDir
+		}
+		walked = append(walked, filepath.ToSlash(p))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ". repository repository/develop repository/develop/com repository/develop/com/example repository/develop/com/example/app " +
+		"repository/develop/com/example/app/1.0 repository/develop/com/example/app/1.0/app-1.0.jar repository/develop/com/example/app/1.0/app-1.0.pom repository/staging"
+	if strings.Join(walked, " ") != want {
+		t.Errorf("Walk visited\n%s\nwant\n%s", strings.Join(walked, " "), want)
+	}
+	var missing error
+	s.Walk("repository/none", func(p string, info os.FileInfo, err error) error {
+		missing = err
+		return nil
+	})
+	if !os.IsNotExist(missing) {
+		t.Errorf("walking a missing path should report not-exist, got %v", missing)
+	}
+
+	if err := s.Delete("repository/develop/com/example/app"); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := s.Head("repository/develop/com/example/app/1.0/app-1.0.jar"); found {
+		t.Error("Delete should remove everything below the path")
+	}
+	if found, _ := s.Head("repository/releases/com/example/lib/2.0/lib-2.0.jar"); !found {
+		t.Error("Delete removed an unrelated file")
+	}
+	if err := s.Delete("."); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("deleting the root: expected ErrInvalidPath, got %v", err)
+	}
+	if _, _, err := s.Get("repository/../../etc/passwd"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("traversal: expected ErrInvalidPath, got %v", err)
+	}
+}
+
+func TestMemoryStorage_Contract(t *testing.T) {
+	testStorageContract(t, NewMemoryStorage())
+}
+
+func TestLocalStorage_Contract(t *testing.T) {
+	testStorageContract(t, NewLocalStorage(t.TempDir()))
+}
+
+func TestMemoryStorage_FailedSaveKeepsContent(t *testing.T) {
+	s := NewMemoryStorage()
+	s.Save("app.jar", strings.NewReader("old"))
+	if err := s.Save("app.jar", io.MultiReader(strings.NewReader("partial"), failingReader{})); err == nil {
+		t.Fatal("expected the reader error")
+	}
+	reader, _, _ := s.Get("app.jar")
+	data, _ := io.ReadAll(reader)
+	if string(data) != "old" {
+		t.Errorf("failed save replaced content with %q", data)
+	}
+}
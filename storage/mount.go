@@ -0,0 +1,267 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MountStorage dispatches each path to the provider mounted at the longest
+// matching prefix, e.g. "repository/releases" on durable disks and
+// "repository/snapshots" on fast scratch space. Mounted providers see paths
+// relative to their mount point. Everything else goes to the default
+// provider, which still shows the mount points in its parent listings.
+type MountStorage struct {
+	StorageProvider
+	// Mounts maps slash-separated prefixes without a trailing slash to
+	// their provider.
+	Mounts map[string]StorageProvider
+}
+
+func NewMountStorage(defaultStore StorageProvider, mounts map[string]StorageProvider) *MountStorage {
+	return &MountStorage{StorageProvider: defaultStore, Mounts: mounts}
+}
+
+// mountKey normalizes a storage path for matching against mount prefixes.
+func mountKey(p string) string {
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// route returns the provider for p, the path to use with it and the mount
+// prefix it was found under. Paths outside every mount are passed to the
+// default provider as they are, so it can reject ones that escape its root.
+func (s *MountStorage) route(p string) (StorageProvider, string, string) {
+	key := mountKey(p)
+	best := ""
+	for prefix := range s.Mounts {
+		if (key == prefix || strings.HasPrefix(key, prefix+"/")) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return s.StorageProvider, p, ""
+	}
+	rest := strings.TrimPrefix(strings.TrimPrefix(key, best), "/")
+	if rest == "" {
+		rest = "."
+	}
+	return s.Mounts[best], rest, best
+}
+
+// mountsBelow returns the names of the entries directly below dir that lead
+// to a mount point, such as "releases" for "repository".
+func (s *MountStorage) mountsBelow(dir string) []string {
+	key := mountKey(dir)
+	var names []string
+	seen := make(map[string]bool)
+	for prefix := range s.Mounts {
+		rest := prefix
+		if key != "" {
+			var ok bool
+			if rest, ok = strings.CutPrefix(prefix, key+"/"); !ok {
+				continue
+			}
+		}
+		name, _, _ := strings.Cut(rest, "/")
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *MountStorage) Save(p string, data io.Reader) error {
+	store, sub, _ := s.route(p)
+	return store.Save(sub, data)
+}
+
+func (s *MountStorage) Get(p string) (io.ReadCloser, bool, error) {
+	store, sub, _ := s.route(p)
+	return store.Get(sub)
+}
+
+func (s *MountStorage) Head(p string) (bool, error) {
+	_, found, err := s.Stat(p)
+	return found, err
+}
+
+func (s *MountStorage) Stat(p string) (Entry, bool, error) {
+	store, sub, _ := s.route(p)
+	e, found, err := store.Stat(sub)
+	if found || err != nil || store != s.StorageProvider {
+		return e, found, err
+	}
+	// Directories leading to a mount point exist even when the default
+	// provider has nothing there
+	if len(s.mountsBelow(p)) > 0 {
+		return Entry{Name: path.Base(mountKey(p)), IsDir: true}, true, nil
+	}
+	return e, false, nil
+}
+
+// List lists p in its provider. Directories leading to mount points list the
+// next path segment towards each of them as a subdirectory.
+func (s *MountStorage) List(p string) ([]Entry, error) {
+	store, sub, _ := s.route(p)
+	entries, err := store.List(sub)
+	if err != nil || store != s.StorageProvider {
+		return entries, err
+	}
+	return s.withMounts(p, entries), nil
+}
+
+func (s *MountStorage) withMounts(p string, entries []Entry) []Entry {
+	names := s.mountsBelow(p)
+	if len(names) == 0 {
+		return entries
+	}
+	if entries == nil {
+		entries = make([]Entry, 0, len(names))
+	}
+	present := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		present[e.Name] = true
+	}
+	for _, name := range names {
+		if !present[name] {
+			entries = append(entries, Entry{Name: name, IsDir: true, ModTime: time.Now()})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries
+}
+
+// ListStream streams p from its provider. The default provider's directories
+// that lead to mount points are listed in one batch so the mount points can
+// be merged in.
+func (s *MountStorage) ListStream(p string, n int, fn func([]Entry) error) (bool, error) {
+	store, sub, _ := s.route(p)
+	if store != s.StorageProvider || len(s.mountsBelow(p)) == 0 {
+		return ListStream(store, sub, n, fn)
+	}
+	entries, err := s.List(p)
+	if err != nil || entries == nil {
+		return false, err
+	}
+	return true, fn(entries)
+}
+
+func (s *MountStorage) Delete(p string) error {
+	store, sub, _ := s.route(p)
+	return store.Delete(sub)
+}
+
+func (s *MountStorage) CreateDir(p string) error {
+	store, sub, _ := s.route(p)
+	return store.CreateDir(sub)
+}
+
+func (s *MountStorage) Lock(p string, ttl time.Duration) (func(), error) {
+	store, sub, _ := s.route(p)
+	return lockInner(store, sub, ttl)
+}
+
+// RemoveEmptyDir never removes a mount point itself.
+func (s *MountStorage) RemoveEmptyDir(p string) (bool, error) {
+	store, sub, _ := s.route(p)
+	if store != s.StorageProvider && sub == "." {
+		return false, nil
+	}
+	return removeEmptyDirInner(store, sub)
+}
+
+// Walk walks p across the providers in lexical order, like filepath.Walk.
+// Subtrees without mount points below them are walked by their provider;
+// directories that lead to mount points are walked through List, so their
+// mounted entries appear in place of whatever the default provider has there.
+func (s *MountStorage) Walk(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	return s.walkWith(StorageProvider.Walk, p, walkFn)
+}
+
+// WalkLinks is Walk with every provider walked through its WalkLinks.
+func (s *MountStorage) WalkLinks(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	return s.walkWith(walkLinks, p, walkFn)
+}
+
+// storeWalker walks one provider, as StorageProvider.Walk or walkLinks do.
+type storeWalker func(store StorageProvider, path string, walkFn func(path string, info os.FileInfo, err error) error) error
+
+func (s *MountStorage) walkWith(walkStore storeWalker, p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	stopped := false
+	fn := func(wPath string, info os.FileInfo, err error) error {
+		err = walkFn(wPath, info, err)
+		if err == filepath.SkipAll {
+			stopped = true
+		}
+		return err
+	}
+	err := s.walk(walkStore, p, fn, &stopped)
+	return skipToNil(err)
+}
+
+// walk visits p and everything below it. Once fn has returned SkipAll,
+// stopped is set and nothing more is visited.
+func (s *MountStorage) walk(walkStore storeWalker, p string, fn func(path string, info os.FileInfo, err error) error, stopped *bool) error {
+	store, sub, prefix := s.route(p)
+	if store != s.StorageProvider {
+		return s.walkMount(walkStore, store, sub, prefix, fn)
+	}
+	if len(s.mountsBelow(p)) == 0 {
+		return walkStore(store, p, fn)
+	}
+
+	key := mountKey(p)
+	if key == "" {
+		key = "."
+	}
+	e, _, err := s.Stat(p)
+	if err != nil {
+		return fn(key, nil, err)
+	}
+	if err := fn(key, &objectFileInfo{name: e.Name, modTime: e.ModTime, dir: true}, nil); err != nil {
+		return err
+	}
+	entries, err := s.List(p)
+	if err != nil {
+		return fn(key, nil, err)
+	}
+	for _, e := range entries {
+		child := e.Name
+		if key != "." {
+			child = key + "/" + e.Name
+		}
+		if e.IsDir {
+			err = s.walk(walkStore, child, fn, stopped)
+			if err == filepath.SkipDir {
+				err = nil
+			}
+		} else {
+			err = fn(child, &objectFileInfo{name: e.Name, size: e.Size, modTime: e.ModTime}, nil)
+		}
+		if err != nil {
+			return err
+		}
+		if *stopped {
+			return filepath.SkipAll
+		}
+	}
+	return nil
+}
+
+// walkMount walks sub in a mounted provider, reporting paths below prefix.
+func (s *MountStorage) walkMount(walkStore storeWalker, store StorageProvider, sub, prefix string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	return walkStore(store, sub, func(wPath string, info os.FileInfo, err error) error {
+		if wPath = filepath.ToSlash(wPath); wPath == "." {
+			wPath = prefix
+		} else {
+			wPath = prefix + "/" + wPath
+		}
+		return walkFn(wPath, info, err)
+	})
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestMountStorage(t *testing.T) (*MountStorage, map[string]string) {
+	t.Helper()
+	dirs := map[string]string{"default": t.TempDir(), "releases": t.TempDir(), "develop-com": t.TempDir()}
+	return NewMountStorage(NewLocalStorage(dirs["default"]), map[string]StorageProvider{
+		"repository/releases":    NewLocalStorage(dirs["releases"]),
+		"repository/develop/com": NewLocalStorage(dirs["develop-com"]),
+	}), dirs
+}
+
+func TestMountStorage_Contract(t *testing.T) {
+	s, _ := newTestMountStorage(t)
+	testStorageContract(t, s)
+}
+
+func TestMountStorage_RoutesByPrefix(t *testing.T) {
+	s, dirs := newTestMountStorage(t)
+	for _, p := range []string{
+		"repository/releases/com/example/lib/2.0/lib-2.0.jar",
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+		"repository/develop/org/example/tool/1.0/tool-1.0.jar",
+		"repository/snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar",
+	} {
+		if err := s.Save(p, strings.NewReader("data")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for file, want := range map[string]bool{
+		filepath.Join(dirs["releases"], "com/example/lib/2.0/lib-2.0.jar"):                                       true,
+		filepath.Join(dirs["develop-com"], "example/app/1.0/app-1.0.jar"):                                        true,
+		filepath.Join(dirs["default"], "repository/develop/org/example/tool/1.0/tool-1.0.jar"):                   true,
+		filepath.Join(dirs["default"], "repository/snapshots/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar"): true,
+		filepath.Join(dirs["default"], "repository/releases"):                                                    false,
+	} {
+		if _, err := os.Stat(file); (err == nil) != want {
+			t.Errorf("%s exists: %v, want %v", file, err == nil, want)
+		}
+	}
+
+	// Mount points show up in their parents even where the default root has
+	// no directory
+	if entries, err := s.List("repository"); err != nil || len(entries) != 3 || entries[1].Name != "releases" || !entries[1].IsDir {
+		t.Errorf("List(repository) = %+v, %v", entries, err)
+	}
+	if found, err := s.Head("repository/releases"); err != nil || !found {
+		t.Errorf("Head(mount point) = %v, %v", found, err)
+	}
+
+	if _, _, err := s.Get("repository/releases/../../../outside.jar"); !errors.Is(err, ErrInvalidPath) {
+		t.Errorf("escaping path: got %v, want ErrInvalidPath", err)
+	}
+
+	if removed, err := s.RemoveEmptyDir("repository/releases"); removed || err != nil {
+		t.Errorf("RemoveEmptyDir(mount point) = %v, %v", removed, err)
+	}
+}
+
+func TestMountStorage_Walk(t *testing.T) {
+	s, dirs := newTestMountStorage(t)
+	s.Save("repository/releases/com/example/lib/2.0/lib-2.0.jar", strings.NewReader("data"))
+	s.Save("repository/develop/com/example/app/1.0/app-1.0.jar", strings.NewReader("data"))
+	s.Save("repository/develop/org/example/tool/1.0/tool-1.0.jar", strings.NewReader("data"))
+	// Shadowed by the mount, never visited
+	os.MkdirAll(filepath.Join(dirs["default"], "repository/develop/com/hidden"), 0755)
+	os.WriteFile(filepath.Join(dirs["default"], "repository/develop/com/hidden/hidden.jar"), []byte("x"), 0644)
+
+	files := func(root string, skip string) []string {
+		var files []string
+		err := s.Walk(root, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() && p == skip {
+				return filepath.SkipDir
+			}
+			if !info.IsDir() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+
+	got := files(".", "")
+	want := []string{
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+		"repository/develop/org/example/tool/1.0/tool-1.0.jar",
+		"repository/releases/com/example/lib/2.0/lib-2.0.jar",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Walk(.) = %v, want %v", got, want)
+	}
+	if got := files("repository/releases/com", ""); len(got) != 1 || got[0] != want[2] {
+		t.Errorf("Walk inside a mount = %v", got)
+	}
+	if got := files(".", "repository/develop"); len(got) != 1 || got[0] != want[2] {
+		t.Errorf("SkipDir above a mount point = %v", got)
+	}
+}
+
+func TestMountStorage_Capacity(t *testing.T) {
+	s := NewMountStorage(NewMemoryStorage(), map[string]StorageProvider{
+		"repository/releases": NewLocalStorage(t.TempDir()),
+	})
+
+	if _, _, err := Capacity(s, "repository/snapshots"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("memory backend: got %v, want ErrUnsupported", err)
+	}
+	free, total, err := Capacity(s, "repository/releases/com/example")
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("disk usage is not supported on this platform")
+	}
+	if err != nil || total == 0 || free > total {
+		t.Errorf("local mount: free=%d total=%d err=%v", free, total, err)
+	}
+}
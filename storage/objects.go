@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// objectWalk turns a lexically ordered listing of object keys below root into
+// a filepath.Walk-style walk for backends without real directories:
+// directories are synthesised from key prefixes, SkipDir skips a directory (or
+// the rest of a file's directory) and SkipAll ends the walk.
+type objectWalk struct {
+	root    string
+	walkFn  func(path string, info os.FileInfo, err error) error
+	visited map[string]bool
+	skipped map[string]bool
+	found   bool
+}
+
+func newObjectWalk(root string, walkFn func(path string, info os.FileInfo, err error) error) *objectWalk {
+	return &objectWalk{root: root, walkFn: walkFn, visited: map[string]bool{}, skipped: map[string]bool{}}
+}
+
+func (w *objectWalk) isSkipped(dir string) bool {
+	for d := dir; ; d = keyParent(d) {
+		if w.skipped[d] {
+			return true
+		}
+		if d == w.root {
+			return false
+		}
+	}
+}
+
+// visit reports the object key, after the directories leading to it that
+// have not been seen yet. A non-nil error ends the walk.
+func (w *objectWalk) visit(key string, size int64, modTime time.Time) error {
+	if strings.HasSuffix(key, "/") {
+		return nil // Folder placeholder written by some tools
+	}
+	w.found = true
+
+	dir := keyParent(key)
+	var parents []string
+	for d := dir; !w.visited[d]; d = keyParent(d) {
+		parents = append(parents, d)
+		if d == w.root {
+			break
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		d := parents[i]
+		w.visited[d] = true
+		if w.isSkipped(d) {
+			continue
+		}
+		info := &objectFileInfo{name: path.Base(walkName(d)), dir: true}
+		if err := w.walkFn(walkName(d), info, nil); err != nil {
+			if err == fs.SkipDir {
+				w.skipped[d] = true
+				continue
+			}
+			return err
+		}
+	}
+
+	if w.isSkipped(dir) {
+		return nil
+	}
+	info := &objectFileInfo{name: path.Base(key), size: size, modTime: modTime}
+	if err := w.walkFn(key, info, nil); err != nil {
+		if err == fs.SkipDir {
+			w.skipped[dir] = true
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// finish reports a missing root once every object has been visited.
+func (w *objectWalk) finish() error {
+	if !w.found {
+		return skipToNil(w.walkFn(walkName(w.root), nil, fs.ErrNotExist))
+	}
+	return nil
+}
+
+// keyParent returns the key of the directory holding key; "" is the bucket
+// root.
+func keyParent(key string) string {
+	if dir := path.Dir(key); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+// walkName is the path Walk reports for a key, matching LocalStorage which
+// reports the storage root as ".".
+func walkName(key string) string {
+	if key == "" {
+		return "."
+	}
+	return key
+}
+
+func skipToNil(err error) error {
+	if err == fs.SkipDir || err == fs.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// objectFileInfo describes an object or synthesised directory to Walk
+// callbacks and to Stat on fetched objects.
+type objectFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *objectFileInfo) Name() string       { return fi.name }
+func (fi *objectFileInfo) Size() int64        { return fi.size }
+func (fi *objectFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *objectFileInfo) IsDir() bool        { return fi.dir }
+func (fi *objectFileInfo) Sys() any           { return nil }
+
+func (fi *objectFileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0755
+	}
+	return 0644
+}
@@ -0,0 +1,336 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Storage keeps artifacts as objects in an S3 bucket (or any S3-compatible
+// store such as MinIO), keyed by their storage path. Directories are key
+// prefixes; empty ones exist through their .keep marker.
+type S3Storage struct {
+	Client *s3.Client
+	Bucket string
+}
+
+func NewS3Storage(client *s3.Client, bucket string) *S3Storage {
+	return &S3Storage{Client: client, Bucket: bucket}
+}
+
+// NewS3Client builds a client for region. A non-empty endpoint points it at an
+// S3-compatible server and switches to path-style addressing, which MinIO
+// needs. Without static keys the default AWS credential chain is used.
+func NewS3Client(region, endpoint, accessKey, secretKey string) (*s3.Client, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(region)}
+	if accessKey != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+// s3Key turns a storage path into an object key.
+func s3Key(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// s3Prefix is the key prefix of everything below the directory p.
+func s3Prefix(p string) string {
+	if key := s3Key(p); key != "" {
+		return key + "/"
+	}
+	return ""
+}
+
+// deleteKey is s3Key for Delete, which removes a whole prefix: paths that
+// escape the root are rejected, and so is the root itself, which would empty
+// the bucket.
+func deleteKey(p string) (string, error) {
+	key, err := memKey(p)
+	if err != nil {
+		return "", err
+	}
+	if key == "" {
+		return "", fmt.Errorf("%w: refusing to delete the storage root", ErrInvalidPath)
+	}
+	return key, nil
+}
+
+func s3Status(err error) int {
+	var re *awshttp.ResponseError
+	if errors.As(err, &re) {
+		return re.HTTPStatusCode()
+	}
+	return 0
+}
+
+func (s *S3Storage) Save(p string, data io.Reader) error {
+	// The SDK needs a seekable body to sign and retry the upload
+	body, ok := data.(io.ReadSeeker)
+	if !ok {
+		spool, err := os.CreateTemp("", "maven-s3-*")
+		if err != nil {
+			return fmt.Errorf("failed to spool upload: %w", err)
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if _, err := io.Copy(spool, data); err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		body = spool
+	}
+
+	_, err := s.Client.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s3Key(p)),
+		Body:   body,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put object: %w", err)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(p string) (io.ReadCloser, bool, error) {
+	out, err := s.Client.GetObject(context.Background(), &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s3Key(p)),
+	})
+	if s3Status(err) == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	info := &objectFileInfo{name: path.Base(s3Key(p)), size: aws.ToInt64(out.ContentLength), modTime: aws.ToTime(out.LastModified)}
+	return &s3Object{ReadCloser: out.Body, info: info}, true, nil
+}
+
+// s3Object is the body of a fetched object. Like *os.File it reports the
+// object's size and modification time through Stat.
+type s3Object struct {
+	io.ReadCloser
+	info *objectFileInfo
+}
+
+func (o *s3Object) Stat() (os.FileInfo, error) {
+	return o.info, nil
+}
+
+// Head reports whether p is an object or a non-empty directory.
+func (s *S3Storage) Head(p string) (bool, error) {
+	_, found, err := s.Stat(p)
+	return found, err
+}
+
+// Stat returns the object at p, or a directory entry when p is a non-empty
+// prefix.
+func (s *S3Storage) Stat(p string) (Entry, bool, error) {
+	key := s3Key(p)
+	if key != "" {
+		out, err := s.Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(key),
+		})
+		if err == nil {
+			return Entry{Name: path.Base(key), Size: aws.ToInt64(out.ContentLength), ModTime: aws.ToTime(out.LastModified)}, true, nil
+		}
+		if s3Status(err) != http.StatusNotFound {
+			return Entry{}, false, err
+		}
+	}
+
+	out, err := s.Client.ListObjectsV2(context.Background(), &s3.ListObjectsV2Input{
+		Bucket:  aws.String(s.Bucket),
+		Prefix:  aws.String(s3Prefix(p)),
+		MaxKeys: aws.Int32(1),
+	})
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if len(out.Contents) == 0 {
+		return Entry{}, false, nil
+	}
+	return Entry{Name: path.Base(key), IsDir: true}, true, nil
+}
+
+// List returns the objects and sub-prefixes directly below p. Like
+// LocalStorage it returns nil for paths that are not directories.
+func (s *S3Storage) List(p string) ([]Entry, error) {
+	prefix := s3Prefix(p)
+	var result []Entry
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if result == nil && (len(page.CommonPrefixes) > 0 || len(page.Contents) > 0) {
+			result = make([]Entry, 0, len(page.CommonPrefixes)+len(page.Contents))
+		}
+		for _, cp := range page.CommonPrefixes {
+			name := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), prefix), "/")
+			result = append(result, Entry{Name: name, IsDir: true})
+		}
+		for _, obj := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(obj.Key), prefix)
+			if name == "" {
+				continue // Folder placeholder written by some S3 tools
+			}
+			result = append(result, Entry{
+				Name:    name,
+				Size:    aws.ToInt64(obj.Size),
+				ModTime: aws.ToTime(obj.LastModified),
+			})
+		}
+	}
+	if result != nil {
+		sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	}
+	return result, nil
+}
+
+// Delete removes the object at p and everything below it, like os.RemoveAll.
+func (s *S3Storage) Delete(p string) error {
+	key, err := deleteKey(p)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil && s3Status(err) != http.StatusNotFound {
+		return err
+	}
+
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(key + "/"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return err
+		}
+		if len(page.Contents) == 0 {
+			continue
+		}
+		objects := make([]types.ObjectIdentifier, 0, len(page.Contents))
+		for _, obj := range page.Contents {
+			objects = append(objects, types.ObjectIdentifier{Key: obj.Key})
+		}
+		out, err := s.Client.DeleteObjects(context.Background(), &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.Bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("failed to delete %s: %s", aws.ToString(out.Errors[0].Key), aws.ToString(out.Errors[0].Message))
+		}
+	}
+	return nil
+}
+
+func (s *S3Storage) CreateDir(p string) error {
+	return s.Save(path.Join(s3Key(p), DirMarker), bytes.NewReader(nil))
+}
+
+// Walk visits p and everything below it in key order, mirroring
+// filepath.Walk; see objectWalk.
+func (s *S3Storage) Walk(p string, walkFn func(path string, info os.FileInfo, err error) error) error {
+	root := s3Key(p)
+	if root != "" {
+		out, err := s.Client.HeadObject(context.Background(), &s3.HeadObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(root),
+		})
+		if err == nil {
+			err = walkFn(root, &objectFileInfo{name: path.Base(root), size: aws.ToInt64(out.ContentLength), modTime: aws.ToTime(out.LastModified)}, nil)
+			return skipToNil(err)
+		}
+		if s3Status(err) != http.StatusNotFound {
+			return skipToNil(walkFn(root, nil, err))
+		}
+	}
+
+	walk := newObjectWalk(root, walkFn)
+	paginator := s3.NewListObjectsV2Paginator(s.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s3Prefix(p)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.Background())
+		if err != nil {
+			return skipToNil(walkFn(walkName(root), nil, err))
+		}
+		for _, obj := range page.Contents {
+			if err := walk.visit(aws.ToString(obj.Key), aws.ToInt64(obj.Size), aws.ToTime(obj.LastModified)); err != nil {
+				return skipToNil(err)
+			}
+		}
+	}
+	return walk.finish()
+}
+
+// Lock creates "<path>.lock" with a conditional put, so only one instance can
+// hold it. Locks older than ttl are considered abandoned and broken.
+func (s *S3Storage) Lock(p string, ttl time.Duration) (func(), error) {
+	key := s3Key(p) + ".lock"
+	for {
+		_, err := s.Client.PutObject(context.Background(), &s3.PutObjectInput{
+			Bucket:      aws.String(s.Bucket),
+			Key:         aws.String(key),
+			Body:        strings.NewReader(fmt.Sprintf("%d\n", os.Getpid())),
+			IfNoneMatch: aws.String("*"),
+		})
+		if err == nil {
+			return func() {
+				s.Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+			}, nil
+		}
+		if status := s3Status(err); status != http.StatusPreconditionFailed && status != http.StatusConflict {
+			return nil, fmt.Errorf("failed to create lock object: %w", err)
+		}
+
+		out, headErr := s.Client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+		if headErr == nil && time.Since(aws.ToTime(out.LastModified)) > ttl {
+			s.Client.DeleteObject(context.Background(), &s3.DeleteObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)})
+			continue
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
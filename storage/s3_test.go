@@ -0,0 +1,335 @@
+package storage
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 is an in-memory stand-in for the S3 API calls S3Storage makes:
+// object PUT/GET/HEAD/DELETE, ListObjectsV2 (with delimiter) and
+// DeleteObjects, with path-style addressing.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	modTime map[string]time.Time
+}
+
+type fakeListResult struct {
+	XMLName        xml.Name `xml:"ListBucketResult"`
+	IsTruncated    bool
+	Contents       []fakeObject
+	CommonPrefixes []struct{ Prefix string }
+	KeyCount       int
+}
+
+type fakeObject struct {
+	Key          string
+	Size         int64
+	LastModified string
+}
+
+type fakeDelete struct {
+	Objects []struct{ Key string } `xml:"Object"`
+}
+
+func newFakeS3(t *testing.T) (*S3Storage, *fakeS3) {
+	t.Helper()
+	fake := &fakeS3{objects: map[string][]byte{}, modTime: map[string]time.Time{}}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	client, err := NewS3Client("us-east-1", srv.URL, "key", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewS3Storage(client, "bucket"), fake
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/bucket"), "/")
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodGet && key == "" && query.Get("list-type") == "2":
+		f.list(w, query.Get("prefix"), query.Get("delimiter"), query.Get("max-keys"))
+	case r.Method == http.MethodPost && query.Has("delete"):
+		var req fakeDelete
+		xml.NewDecoder(r.Body).Decode(&req)
+		for _, o := range req.Objects {
+			delete(f.objects, o.Key)
+		}
+		w.Write([]byte(`<DeleteResult></DeleteResult>`))
+	case r.Method == http.MethodPut:
+		if r.Header.Get("If-None-Match") == "*" {
+			if _, exists := f.objects[key]; exists {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				w.Write([]byte(`<Error><Code>PreconditionFailed</Code></Error>`))
+				return
+			}
+		}
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+		f.modTime[key] = time.Now()
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		body, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+			}
+			return
+		}
+		w.Header().Set("Last-Modified", f.modTime[key].UTC().Format(http.TimeFormat))
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		if r.Method == http.MethodGet {
+			w.Write(body)
+		}
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, prefix, delimiter, maxKeys string) {
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var res fakeListResult
+	seen := map[string]bool{}
+	for _, k := range keys {
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			cp := prefix + rest[:i+1]
+			if !seen[cp] {
+				seen[cp] = true
+				res.CommonPrefixes = append(res.CommonPrefixes, struct{ Prefix string }{cp})
+			}
+			continue
+		}
+		res.Contents = append(res.Contents, fakeObject{Key: k, Size: int64(len(f.objects[k])), LastModified: f.modTime[k].UTC().Format(time.RFC3339)})
+		if maxKeys == "1" {
+			break
+		}
+	}
+	res.KeyCount = len(res.Contents) + len(res.CommonPrefixes)
+	xml.NewEncoder(w).Encode(res)
+}
+
+func TestS3Storage_SaveGetHeadList(t *testing.T) {
+	s, _ := newFakeS3(t)
+
+	if err := s.Save("repository/develop/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar")); err != nil {
+		t.Fatal(err)
+	}
+	// Streams that cannot seek are spooled before the upload
+	if err := s.Save("repository/develop/com/example/app/1.0/app-1.0.pom", io.MultiReader(strings.NewReader("p"), strings.NewReader("om"))); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, found, err := s.Get("repository/develop/com/example/app/1.0/app-1.0.jar")
+	if err != nil || !found {
+		t.Fatalf("Get: found=%v err=%v", found, err)
+	}
+	if st, ok := reader.(interface{ Stat() (os.FileInfo, error) }); !ok {
+		t.Error("S3 objects should report their size through Stat")
+	} else if info, _ := st.Stat(); info.Size() != 3 || info.ModTime().IsZero() {
+		t.Errorf("unexpected object info: size %d, modified %v", info.Size(), info.ModTime())
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "jar" {
+		t.Errorf("Get returned %q", data)
+	}
+	if _, found, err := s.Get("repository/develop/missing.jar"); found || err != nil {
+		t.Errorf("Get missing: found=%v err=%v", found, err)
+	}
+
+	for path, want := range map[string]bool{
+		"repository/develop/com/example/app/1.0/app-1.0.jar": true,
+		"repository/develop/com/example":                     true,
+		".":                                                  true,
+		"repository/develop/missing.jar":                     false,
+	} {
+		if found, err := s.Head(path); err != nil || found != want {
+			t.Errorf("Head(%s) = %v, %v; want %v", path, found, err, want)
+		}
+	}
+
+	if e, found, err := s.Stat("repository/develop/com/example/app/1.0/app-1.0.jar"); err != nil || !found || e.Name != "app-1.0.jar" || e.IsDir || e.Size != 3 || e.ModTime.IsZero() {
+		t.Errorf("Stat object = %+v, %v, %v", e, found, err)
+	}
+	if e, found, err := s.Stat("repository/develop/com/example"); err != nil || !found || !e.IsDir || e.Name != "example" {
+		t.Errorf("Stat prefix = %+v, %v, %v", e, found, err)
+	}
+	if _, found, err := s.Stat("repository/develop/missing.jar"); found || err != nil {
+		t.Errorf("Stat missing = %v, %v", found, err)
+	}
+
+	entries, err := s.List("repository/develop/com/example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != "app" || !entries[0].IsDir {
+		t.Errorf("unexpected directory listing %+v", entries)
+	}
+	entries, _ = s.List("repository/develop/com/example/app/1.0/")
+	if len(entries) != 2 || entries[0].Name != "app-1.0.jar" || entries[0].Size != 3 || entries[0].IsDir || entries[1].Size != 3 {
+		t.Errorf("unexpected file listing %+v", entries)
+	}
+	if entries, err := s.List("repository/develop/missing"); entries != nil || err != nil {
+		t.Errorf("List missing = %+v, %v; want nil", entries, err)
+	}
+}
+
+func TestS3Storage_CreateDirAndDelete(t *testing.T) {
+	s, fake := newFakeS3(t)
+
+	if err := s.CreateDir("repository/staging"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := s.List("repository/staging")
+	if err != nil || entries == nil {
+		t.Fatalf("empty directory should list as non-nil, got %+v, %v", entries, err)
+	}
+
+	s.Save("repository/develop/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar"))
+	s.Save("repository/develop/com/example/app/1.0/app-1.0.jar.sha1", strings.NewReader("sha"))
+	s.Save("repository/develop/com/example/other/1.0/other-1.0.jar", strings.NewReader("jar"))
+
+	if err := s.Delete("repository/develop/com/example/app"); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for key := range fake.objects {
+		if strings.HasPrefix(key, "repository/develop/com/example/app/") {
+			t.Errorf("%s survived the delete", key)
+		}
+	}
+	if _, ok := fake.objects["repository/develop/com/example/other/1.0/other-1.0.jar"]; !ok {
+		t.Error("delete removed a sibling directory")
+	}
+}
+
+func TestS3Storage_DeleteRejectsRootAndTraversal(t *testing.T) {
+	s, fake := newFakeS3(t)
+	s.Save("repository/releases/com/example/app/1.0/app-1.0.jar", strings.NewReader("jar"))
+
+	for _, p := range []string{"", ".", "/", "repository/releases/../..", "../evil", "repository/../../evil"} {
+		if err := s.Delete(p); !errors.Is(err, ErrInvalidPath) {
+			t.Errorf("Delete(%q) = %v, want ErrInvalidPath", p, err)
+		}
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if len(fake.objects) != 1 {
+		t.Errorf("expected the bucket to keep its object, got %d objects", len(fake.objects))
+	}
+}
+
+func TestS3Storage_Walk(t *testing.T) {
+	s, _ := newFakeS3(t)
+	for _, p := range []string{
+		"repository/develop/com/example/app/1.0-SNAPSHOT/app-1.0-SNAPSHOT.jar",
+		"repository/develop/com/example/app/1.0/app-1.0.jar",
+		"repository/releases/com/example/lib/2.0/lib-2.0.jar",
+	} {
+		if err := s.Save(p, strings.NewReader("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var dirs, files []string
+	err := s.Walk(".", func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+			if strings.HasSuffix(path, "-SNAPSHOT") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantFiles := []string{"repository/develop/com/example/app/1.0/app-1.0.jar", "repository/releases/com/example/lib/2.0/lib-2.0.jar"}
+	if strings.Join(files, ",") != strings.Join(wantFiles, ",") {
+		t.Errorf("files = %v, want %v", files, wantFiles)
+	}
+	if dirs[0] != "." || !containsString(dirs, "repository/develop/com/example/app/1.0-SNAPSHOT") || !containsString(dirs, "repository/releases") {
+		t.Errorf("unexpected directories %v", dirs)
+	}
+
+	var missing error
+	s.Walk("repository/none", func(path string, info os.FileInfo, err error) error {
+		missing = err
+		return nil
+	})
+	if !os.IsNotExist(missing) {
+		t.Errorf("walking a missing path should report not-exist, got %v", missing)
+	}
+}
+
+func TestS3Storage_Lock(t *testing.T) {
+	s, _ := newFakeS3(t)
+
+	release, err := s.Lock("com/example/maven-metadata.xml", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquired := make(chan struct{})
+	go func() {
+		r, err := s.Lock("com/example/maven-metadata.xml", time.Minute)
+		if err == nil {
+			r()
+		}
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("second lock acquired while the first is held")
+	case <-time.After(100 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("second lock not acquired after release")
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}